@@ -1,8 +1,10 @@
 module github.com/tecfu/efm-langserver
 
-go 1.23
+go 1.25
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/itchyny/gojq v0.12.17
 	github.com/mattn/go-unicodeclass v0.0.2
 	github.com/reviewdog/errorformat v0.0.0-20240608101709-1d3280ed6bd4
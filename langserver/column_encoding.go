@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// The values of lint-column-encoding, the units the columns of the output
+// of a tool are counted in. LSP positions count UTF-16 code units.
+const (
+	columnEncodingBytes = "bytes"
+	columnEncodingUTF8  = "utf8"
+	columnEncodingUTF16 = "utf16"
+)
+
+// utf16Column converts the zero based column col of line, counted in the
+// units of encoding, into UTF-16 code units. A column past the end of the
+// line stays past it by as many units.
+func utf16Column(line string, col int, encoding string) int {
+	if col <= 0 || (encoding != columnEncodingBytes && encoding != columnEncodingUTF8) {
+		return col
+	}
+	n := 0
+	for i, r := range line {
+		if i >= col {
+			return n
+		}
+		n += max(utf16.RuneLen(r), 1)
+	}
+	return n + col - len(line)
+}
+
+// lineTexts returns the lines of the files diagnostics are reported for,
+// the text of the open documents or else the one on disk, read once.
+type lineTexts struct {
+	open  func(uri DocumentURI) (string, bool)
+	lines map[DocumentURI][]string
+}
+
+// line returns the line n of uri, or "" if there is none.
+func (t *lineTexts) line(uri DocumentURI, n int) string {
+	lines, ok := t.lines[uri]
+	if !ok {
+		if text, open := t.open(uri); open {
+			lines = strings.Split(text, "\n")
+		} else if path, err := fromURI(uri); err == nil {
+			if b, err := os.ReadFile(path); err == nil {
+				lines = strings.Split(string(b), "\n")
+			}
+		}
+		if t.lines == nil {
+			t.lines = make(map[DocumentURI][]string)
+		}
+		t.lines[uri] = lines
+	}
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n], "\r")
+}
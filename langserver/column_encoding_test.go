@@ -0,0 +1,25 @@
+package langserver
+
+import "testing"
+
+func TestUTF16Column(t *testing.T) {
+	line := "é 😀 x"
+	tests := []struct {
+		col      int
+		encoding string
+		want     int
+	}{
+		{8, columnEncodingBytes, 5},
+		{8, columnEncodingUTF8, 5},
+		{3, columnEncodingBytes, 2},
+		{0, columnEncodingBytes, 0},
+		{12, columnEncodingBytes, 9},
+		{5, columnEncodingUTF16, 5},
+		{5, "", 5},
+	}
+	for _, tt := range tests {
+		if got := utf16Column(line, tt.col, tt.encoding); got != tt.want {
+			t.Errorf("utf16Column(%q, %d, %q) = %d, want %d", line, tt.col, tt.encoding, got, tt.want)
+		}
+	}
+}
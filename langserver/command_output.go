@@ -0,0 +1,217 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported values for the `output-mode` of commands.
+const (
+	outputModeInsertAtCursor   = "insert-at-cursor"
+	outputModeReplaceSelection = "replace-selection"
+	outputModeNewDocument      = "new-document"
+	outputModeMessage          = "message"
+	outputModeWorkspaceEdit    = "workspace-edit"
+)
+
+// stdoutOnly reports whether the output of command is its standard output
+// only, with the output modes editing the documents, which the warnings of
+// its standard error would end up in. Otherwise it is the combined output.
+func stdoutOnly(command *Command) bool {
+	switch command.OutputMode {
+	case outputModeInsertAtCursor, outputModeReplaceSelection, outputModeNewDocument, outputModeWorkspaceEdit:
+		return true
+	}
+	return false
+}
+
+// commandRange decodes the range a code action passes as third argument of
+// workspace/executeCommand.
+func commandRange(arguments []any) *Range {
+	if len(arguments) < 3 {
+		return nil
+	}
+	b, err := json.Marshal(arguments[2])
+	if err != nil {
+		return nil
+	}
+	var rng Range
+	if err := json.Unmarshal(b, &rng); err != nil {
+		return nil
+	}
+	return &rng
+}
+
+// commandOutput delivers the output of command. Without an output-mode the
+// output is returned to the client as the result of the command.
+func (h *langHandler) commandOutput(command *Command, uri DocumentURI, rng *Range, output string) (any, error) {
+	switch command.OutputMode {
+	case "":
+		return output, nil
+	case outputModeInsertAtCursor, outputModeReplaceSelection:
+		if rng == nil {
+			return nil, fmt.Errorf("no selection for command: %v", command.Title)
+		}
+		edit := TextEdit{Range: *rng, NewText: output}
+		if command.OutputMode == outputModeInsertAtCursor {
+			edit.Range.End = edit.Range.Start
+			output = strings.TrimSuffix(output, "\n")
+			edit.NewText = output
+		}
+		go h.applyEdit(command.Title, WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{uri: {edit}},
+		})
+	case outputModeNewDocument:
+		f, err := os.CreateTemp("", "efm-langserver-*"+filepath.Ext(string(uri)))
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.WriteString(output)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		go h.showDocument(toURI(f.Name()))
+	case outputModeMessage:
+		if output = strings.TrimSpace(output); output != "" {
+			h.showMessage(LogInfo, output)
+		}
+	case outputModeWorkspaceEdit:
+		if strings.TrimSpace(output) == "" {
+			// Nothing to change.
+			break
+		}
+		edit, err := h.outputWorkspaceEdit(output)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", command.Title, err)
+		}
+		go h.applyEdit(command.Title, edit)
+	default:
+		return nil, fmt.Errorf("unsupported output-mode: %v", command.OutputMode)
+	}
+	return "OK", nil
+}
+
+// outputWorkspaceEdit returns the workspace edit a command with output-mode
+// workspace-edit printed, as the JSON of a WorkspaceEdit, or as a unified
+// diff of the files to change.
+func (h *langHandler) outputWorkspaceEdit(output string) (WorkspaceEdit, error) {
+	var edit WorkspaceEdit
+	if strings.HasPrefix(strings.TrimSpace(output), "{") {
+		if err := json.Unmarshal([]byte(output), &edit); err != nil {
+			return edit, err
+		}
+		if edit.Changes == nil && edit.DocumentChanges == nil {
+			return edit, fmt.Errorf("no changes in workspace edit")
+		}
+		return edit, nil
+	}
+	changes, err := unifiedDiffEdits(output, h.rootPath)
+	if err != nil {
+		return edit, err
+	}
+	edit.Changes = changes
+	return edit, nil
+}
+
+// applyEdit asks the client to apply edit. It calls the client, so it must
+// not be used from the goroutine handling requests.
+func (h *langHandler) applyEdit(label string, edit WorkspaceEdit) {
+	var result ApplyWorkspaceEditResult
+	err := h.conn.Call(context.Background(), "workspace/applyEdit", &ApplyWorkspaceEditParams{Label: label, Edit: edit}, &result)
+	if err != nil {
+		h.logger.Printf("workspace/applyEdit failed: %v", err)
+		return
+	}
+	if !result.Applied {
+		h.logMessage(LogWarning, fmt.Sprintf("%v: edit not applied: %v", label, result.FailureReason))
+	}
+}
+
+// showDocument asks the client to open uri.
+func (h *langHandler) showDocument(uri DocumentURI) {
+	err := h.conn.Call(context.Background(), "window/showDocument", &ShowDocumentParams{URI: uri, TakeFocus: true}, nil)
+	if err != nil {
+		h.logger.Printf("window/showDocument failed: %v", err)
+	}
+}
+
+// commandOutputParams is the payload of the efm/commandOutput notification,
+// sent for each output line of a command when the client didn't pass a
+// progress token.
+type commandOutputParams struct {
+	Command string `json:"command"`
+	Line    string `json:"line"`
+}
+
+// outputStream collects the output of a command and streams it line by
+// line to the client. The buffer isn't embedded, as io.Copy would use its
+// ReadFrom rather than Write, bypassing the streaming.
+type outputStream struct {
+	buf      bytes.Buffer
+	h        *langHandler
+	title    string
+	progress *workDoneProgress
+	partial  []byte
+}
+
+func (h *langHandler) newOutputStream(title string, token any) *outputStream {
+	s := &outputStream{h: h, title: title}
+	if token != nil {
+		s.progress = h.clientProgress(token, title)
+	}
+	return s
+}
+
+func (s *outputStream) Write(p []byte) (int, error) {
+	s.buf.Write(p)
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.emit(strings.TrimRight(string(s.partial[:i]), "\r"))
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (s *outputStream) emit(line string) {
+	if s.progress != nil {
+		s.progress.notify(&WorkDoneProgressReport{Kind: "report", Message: line})
+		return
+	}
+	if s.h.conn == nil {
+		return
+	}
+	s.h.conn.Notify(context.Background(), "efm/commandOutput", &commandOutputParams{Command: s.title, Line: line})
+}
+
+// String returns the output collected.
+func (s *outputStream) String() string {
+	return s.buf.String()
+}
+
+// close flushes the last unterminated line and ends the progress.
+func (s *outputStream) close(err error) {
+	if len(s.partial) > 0 {
+		s.emit(string(s.partial))
+		s.partial = nil
+	}
+	if s.progress == nil {
+		return
+	}
+	message := "done"
+	if err != nil {
+		message = err.Error()
+	}
+	s.progress.notify(&WorkDoneProgressEnd{Kind: "end", Message: message})
+}
@@ -0,0 +1,84 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestCommandOutputProgress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	command := Command{Title: "Build", Command: "echo one; echo two; printf three; exit ${status}"}
+
+	// The notifications are handled in order, before the reply.
+	var notifications []string
+	conn, uri := serveCommand(t, command, func(req *jsonrpc2.Request) any {
+		switch req.Method {
+		case "$/progress":
+			var params struct {
+				Token string `json:"token"`
+				Value struct {
+					Kind    string `json:"kind"`
+					Title   string `json:"title"`
+					Message string `json:"message"`
+				} `json:"value"`
+			}
+			if err := json.Unmarshal(*req.Params, &params); err == nil {
+				notifications = append(notifications, fmt.Sprintf("%s %s %s%s", params.Token, params.Value.Kind, params.Value.Title, params.Value.Message))
+			}
+		case "efm/commandOutput":
+			var params commandOutputParams
+			if err := json.Unmarshal(*req.Params, &params); err == nil {
+				notifications = append(notifications, params.Command+": "+params.Line)
+			}
+		}
+		return nil
+	})
+	id := "efm-langserver\t" + command.Command + "\t" + string(uri)
+
+	// execute runs the command exiting with status, with the progress token
+	// if not nil.
+	execute := func(status string, token any) error {
+		notifications = nil
+		params := ExecuteCommandParams{
+			Command:   id,
+			Arguments: []any{string(uri), map[string]any{"status": status}},
+		}
+		params.WorkDoneToken = token
+		var output string
+		return conn.Call(context.Background(), "workspace/executeCommand", params, &output)
+	}
+
+	if err := execute("0", "tok"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tok begin Build", "tok report one", "tok report two", "tok report three", "tok end done"}
+	if !reflect.DeepEqual(notifications, want) {
+		t.Fatalf("expected the progress %q, got %q", want, notifications)
+	}
+
+	// A failing command ends the progress with its error.
+	if err := execute("3", "tok"); err == nil {
+		t.Fatal("expected the command to fail")
+	}
+	want = []string{"tok begin Build", "tok report one", "tok report two", "tok report three", "tok end exit status 3"}
+	if !reflect.DeepEqual(notifications, want) {
+		t.Fatalf("expected the progress %q, got %q", want, notifications)
+	}
+
+	// Without a token, the lines are sent as efm/commandOutput.
+	if err := execute("0", nil); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"Build: one", "Build: two", "Build: three"}
+	if !reflect.DeepEqual(notifications, want) {
+		t.Fatalf("expected the output %q, got %q", want, notifications)
+	}
+}
@@ -0,0 +1,113 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// commandParameterValues collects the parameter values passed by the client
+// as an object in the extra arguments of workspace/executeCommand, and
+// returns the parameters still missing a value.
+func commandParameterValues(parameters []CommandParameter, extra []any) (map[string]string, []CommandParameter) {
+	values := make(map[string]string)
+	for _, arg := range extra {
+		if m, ok := arg.(map[string]any); ok {
+			for k, v := range m {
+				values[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	var missing []CommandParameter
+	for _, p := range parameters {
+		if _, ok := values[p.Name]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	return values, missing
+}
+
+// substituteParameters replaces ${name} with the value of each parameter,
+// quoted by quote if given. It runs after the placeholders are replaced, in a
+// single pass, so that a value containing ${INPUT} or ${name} is kept as is.
+func substituteParameters(s string, values map[string]string, quote func(string) string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			break
+		}
+		value, ok := values[s[i+2:i+j]]
+		if !ok {
+			b.WriteString(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+		if quote != nil {
+			value = quote(value)
+		}
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+j+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// promptParameter asks the user to pick a value for p. The protocol has no
+// free text input, so the choices, or else the default, are offered.
+func (h *langHandler) promptParameter(p CommandParameter) (string, error) {
+	choices := p.Choices
+	if len(choices) == 0 && p.Default != "" {
+		choices = []string{p.Default}
+	}
+	if len(choices) == 0 {
+		return "", fmt.Errorf("no value for command parameter: %v", p.Name)
+	}
+	actions := make([]MessageActionItem, 0, len(choices))
+	for _, choice := range choices {
+		actions = append(actions, MessageActionItem{Title: choice})
+	}
+	message := p.Prompt
+	if message == "" {
+		message = p.Name
+	}
+
+	var item *MessageActionItem
+	err := h.conn.Call(context.Background(), "window/showMessageRequest", &ShowMessageRequestParams{
+		Type:    LogInfo,
+		Message: message,
+		Actions: actions,
+	}, &item)
+	if err != nil {
+		return "", err
+	}
+	if item == nil {
+		return "", fmt.Errorf("canceled")
+	}
+	return item.Title, nil
+}
+
+// promptAndRunCommand asks for the missing parameters, then runs command and
+// shows its output.
+func (h *langHandler) promptAndRunCommand(command Command, config Language, fname, uri string, values map[string]string, missing []CommandParameter, rng *Range) {
+	for _, p := range missing {
+		value, err := h.promptParameter(p)
+		if err != nil {
+			h.showMessage(LogError, fmt.Sprintf("%v: %v", command.Title, err))
+			return
+		}
+		values[p.Name] = value
+	}
+	if command.OutputMode == "" {
+		// Nobody is waiting for the result anymore.
+		command.OutputMode = outputModeMessage
+	}
+	if _, err := h.runCommand(&command, &config, fname, uri, values, rng, nil); err != nil {
+		h.showMessage(LogError, fmt.Sprintf("%v: %v", command.Title, err))
+	}
+}
@@ -0,0 +1,114 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestSubstituteParameters(t *testing.T) {
+	values := map[string]string{"name": "${INPUT} ${other}", "other": "x"}
+	got := substituteParameters("echo ${name} ${other} ${missing}", values, nil)
+	if want := "echo ${INPUT} ${other} x ${missing}"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+// serveCommand serves a command of the language text and opens a document
+// of it, the calls of the server to the client being answered by handle.
+func serveCommand(t *testing.T, command Command, handle func(req *jsonrpc2.Request) any) (*jsonrpc2.Conn, DocumentURI) {
+	t.Helper()
+	base := t.TempDir()
+	uri := toURI(filepath.Join(base, "foo.txt"))
+
+	server, client := net.Pipe()
+	config := NewConfig()
+	config.Logger = log.New(io.Discard, "", 0)
+	(*config.Languages)["text"] = []Language{{Commands: []Command{command}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go Serve(ctx, server, config)
+
+	conn := jsonrpc2.NewConn(
+		context.Background(),
+		jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			return handle(req), nil
+		}))
+	t.Cleanup(func() { conn.Close() })
+
+	var result InitializeResult
+	if err := conn.Call(ctx, "initialize", InitializeParams{RootURI: toURI(base)}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Notify(ctx, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "text", Text: "foo\n"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return conn, uri
+}
+
+func TestCommandParameters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	command := Command{
+		Title:      "Greet",
+		Command:    `printf '%s\n' ${name} ${INPUT}`,
+		Parameters: []CommandParameter{{Name: "name", Choices: []string{"x; y"}}},
+	}
+	// The prompts are answered with the choice, and the messages shown
+	// collected.
+	messages := make(chan string, 1)
+	conn, uri := serveCommand(t, command, func(req *jsonrpc2.Request) any {
+		switch req.Method {
+		case "window/showMessageRequest":
+			return MessageActionItem{Title: "x; y"}
+		case "window/showMessage":
+			var params ShowMessageParams
+			if err := json.Unmarshal(*req.Params, &params); err == nil {
+				messages <- params.Message
+			}
+		}
+		return nil
+	})
+	fname, _ := fromURI(uri)
+	id := "efm-langserver\t" + command.Command + "\t" + string(uri)
+
+	// The values passed by the client are quoted, and not expanded again.
+	var output string
+	if err := conn.Call(context.Background(), "workspace/executeCommand", ExecuteCommandParams{
+		Command:   id,
+		Arguments: []any{string(uri), map[string]any{"name": "a b; ${INPUT}"}},
+	}, &output); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a b; ${INPUT}\n" + filepath.ToSlash(fname) + "\n"; output != want {
+		t.Fatalf("expected %q, got %q", want, output)
+	}
+
+	// The missing ones are prompted for, the output shown as a message.
+	if err := conn.Call(context.Background(), "workspace/executeCommand", ExecuteCommandParams{
+		Command:   id,
+		Arguments: []any{string(uri)},
+	}, &output); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-messages:
+		if want := "x; y\n" + filepath.ToSlash(fname); message != want {
+			t.Fatalf("expected %q, got %q", want, message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the output of the command prompted for")
+	}
+}
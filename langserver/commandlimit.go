@@ -0,0 +1,64 @@
+package langserver
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// commandLimit bounds the commands of the tools running at once, so that
+// opening many documents doesn't start dozens of linters together.
+type commandLimit struct {
+	mu  sync.Mutex
+	sem chan struct{}
+}
+
+// setMax changes the limit. Zero means no limit.
+func (l *commandLimit) setMax(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case n <= 0:
+		l.sem = nil
+	case cap(l.sem) != n:
+		// Commands running keep their slot in the previous semaphore.
+		l.sem = make(chan struct{}, n)
+	}
+}
+
+// acquire waits for a slot and returns the function giving it back.
+func (l *commandLimit) acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	sem := l.sem
+	l.mu.Unlock()
+	if sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// output is cmd.Output once max-concurrent-commands allows to run cmd.
+func (h *langHandler) output(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	release, err := h.commandLimit.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return cmd.Output()
+}
+
+// combinedOutput is cmd.CombinedOutput once max-concurrent-commands allows
+// to run cmd.
+func (h *langHandler) combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	release, err := h.commandLimit.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,110 @@
+package langserver
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/mattn/go-unicodeclass"
+)
+
+// completionCacheKey is what the items of a completion-command depend on:
+// the command, where the word being completed starts, and the text of the
+// document before it.
+type completionCacheKey struct {
+	command string
+	line    int
+	start   int
+	before  string
+}
+
+// completionCacheEntry is the items a completion-command returned for the
+// word typed so far, prefix.
+type completionCacheEntry struct {
+	key    completionCacheKey
+	prefix string
+	items  []CompletionItem
+}
+
+// completionCache keeps the last items of the completion-command of each
+// document, so that typing further into the same word filters them instead
+// of running the command on every keystroke.
+type completionCache struct {
+	mu      sync.Mutex
+	entries map[DocumentURI]completionCacheEntry
+}
+
+// set keeps the items returned for prefix, typed at key.
+func (c *completionCache) set(uri DocumentURI, key completionCacheKey, prefix string, items []CompletionItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[DocumentURI]completionCacheEntry)
+	}
+	c.entries[uri] = completionCacheEntry{key: key, prefix: prefix, items: items}
+}
+
+// get returns the kept items of uri, if they were returned at key for a
+// word prefix extends.
+func (c *completionCache) get(uri DocumentURI, key completionCacheKey, prefix string) ([]CompletionItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[uri]
+	if !ok || e.key != key || !strings.HasPrefix(prefix, e.prefix) {
+		return nil, false
+	}
+	return e.items, true
+}
+
+// forget drops the items of uri, e.g. when the document is closed.
+func (c *completionCache) forget(uri DocumentURI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, uri)
+}
+
+// filterCompletionItems returns the items whose filter text, or else label,
+// starts with prefix, ignoring case, as clients filter them.
+func filterCompletionItems(items []CompletionItem, prefix string) []CompletionItem {
+	prefix = strings.ToLower(prefix)
+	result := []CompletionItem{}
+	for _, item := range items {
+		text := item.FilterText
+		if text == "" {
+			text = item.Label
+		}
+		if strings.HasPrefix(strings.ToLower(text), prefix) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// isWordRune reports whether r is part of the words completed, like the
+// identifiers of most languages.
+func isWordRune(r rune) bool {
+	return r == '_' || unicodeclass.Is(r) == unicodeclass.Word
+}
+
+// completionPrefix returns where the word being completed at pos of text
+// starts, the text before it and the part of it before pos. Characters are
+// counted in UTF-16 code units like pos.
+func completionPrefix(text string, pos Position) (start int, before string, prefix string) {
+	lines := strings.SplitAfter(text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return pos.Character, text, ""
+	}
+	offset := 0
+	for _, line := range lines[:pos.Line] {
+		offset += len(line)
+	}
+	chars := utf16.Encode([]rune(lines[pos.Line]))
+	end := min(max(pos.Character, 0), len(chars))
+	runes := []rune(string(utf16.Decode(chars[:end])))
+	i := len(runes)
+	for i > 0 && isWordRune(runes[i-1]) {
+		i--
+	}
+	head := string(runes[:i])
+	return len(utf16.Encode(runes[:i])), text[:offset+len(head)], string(runes[i:])
+}
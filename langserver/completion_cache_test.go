@@ -0,0 +1,84 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCompletionPrefix(t *testing.T) {
+	text := "package main\n\tfmt.Pri x\n"
+	start, before, prefix := completionPrefix(text, Position{Line: 1, Character: 8})
+	if start != 5 || before != "package main\n\tfmt." || prefix != "Pri" {
+		t.Fatalf("expected the word Pri at 5, got %q at %d after %q", prefix, start, before)
+	}
+	start, _, prefix = completionPrefix(text, Position{Line: 1, Character: 5})
+	if start != 5 || prefix != "" {
+		t.Fatalf("expected no word at 5, got %q at %d", prefix, start)
+	}
+	start, _, prefix = completionPrefix("😀 na_me\n", Position{Line: 0, Character: 8})
+	if start != 3 || prefix != "na_me" {
+		t.Fatalf("expected the word na_me at 3, got %q at %d", prefix, start)
+	}
+}
+
+func TestCompletionCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base := t.TempDir()
+	runs := filepath.Join(base, "runs")
+	uri := toURI(filepath.Join(base, "foo"))
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{
+				CompletionCommand: "echo >> " + runs + "; printf 'foo\\nfoobar\\nbaz\\n'",
+				CompletionStdin:   true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "let x = f\n"},
+		},
+	}
+	complete := func(text string, character int) []string {
+		t.Helper()
+		h.files[uri].Text = text
+		list, err := h.completion(uri, &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{Position: Position{Line: 0, Character: character}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	count := func() int {
+		b, _ := os.ReadFile(runs)
+		return strings.Count(string(b), "\n")
+	}
+
+	if got := complete("let x = f\n", 9); len(got) != 3 {
+		t.Fatalf("expected the items of the command, got %v", got)
+	}
+	if got := complete("let x = foob\n", 12); strings.Join(got, ",") != "foobar" || count() != 1 {
+		t.Fatalf("expected foobar filtered without running the command again, got %v after %d runs", got, count())
+	}
+	complete("let y = foob\n", 12)
+	if count() != 2 {
+		t.Fatalf("expected the command to run again once the text before the word changed, got %d runs", count())
+	}
+	complete("let y = foob\n", 8)
+	if count() != 3 {
+		t.Fatalf("expected the command to run again for a shorter word, got %d runs", count())
+	}
+}
@@ -0,0 +1,95 @@
+package langserver
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// The values of completion-filter, how the completion items are filtered
+// by the word typed so far.
+const (
+	completionFilterPrefix = "prefix"
+	completionFilterFuzzy  = "fuzzy"
+)
+
+// filterCompletion filters the completion items by the word typed so far,
+// prefix, as completion-filter says.
+func (h *langHandler) filterCompletion(items []CompletionItem, prefix string) []CompletionItem {
+	switch h.completionFilter {
+	case completionFilterPrefix:
+		return filterCompletionItems(items, prefix)
+	case completionFilterFuzzy:
+		return fuzzyCompletionItems(items, prefix)
+	}
+	return items
+}
+
+// fuzzyCompletionItems returns the items whose filter text, or else label,
+// fuzzy matches prefix, best first. Their sort text keeps the order on the
+// clients which sort the items.
+func fuzzyCompletionItems(items []CompletionItem, prefix string) []CompletionItem {
+	if prefix == "" {
+		return items
+	}
+	type scored struct {
+		item  CompletionItem
+		score int
+	}
+	var matches []scored
+	for _, item := range items {
+		text := item.FilterText
+		if text == "" {
+			text = item.Label
+		}
+		if score, ok := fuzzyScore(prefix, text); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]CompletionItem, 0, len(matches))
+	for i, m := range matches {
+		m.item.SortText = fmt.Sprintf("%05d", i)
+		result = append(result, m.item)
+	}
+	return result
+}
+
+// fuzzyScore reports whether the characters of pattern appear in text in
+// order, ignoring case, and how well: matches at the start of text or of its
+// words, consecutive ones and ones of the same case score more, gaps and
+// the characters left over less.
+func fuzzyScore(pattern, text string) (int, bool) {
+	p, t := []rune(pattern), []rune(text)
+	score, j, last := 0, 0, -1
+	for i := 0; i < len(t) && j < len(p); i++ {
+		if unicode.ToLower(t[i]) != unicode.ToLower(p[j]) {
+			continue
+		}
+		switch {
+		case i == 0:
+			score += 10
+		case i == last+1:
+			score += 6
+		case !isWordRune(t[i-1]) || t[i-1] == '_' || (unicode.IsLower(t[i-1]) && unicode.IsUpper(t[i])):
+			score += 5
+		default:
+			score++
+		}
+		if last >= 0 && i > last+1 {
+			score -= min(i-last-1, 3)
+		}
+		if t[i] == p[j] {
+			score++
+		}
+		last = i
+		j++
+	}
+	if j < len(p) {
+		return 0, false
+	}
+	return score - (len(t)-len(p))/4, true
+}
@@ -0,0 +1,57 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("gub", "getUser"); ok {
+		t.Fatal("expected gub not to match getUser")
+	}
+	prefix, _ := fuzzyScore("get", "getUser")
+	words, _ := fuzzyScore("gu", "getUser")
+	inner, ok := fuzzyScore("gu", "debugUser")
+	if !ok {
+		t.Fatal("expected gu to match debugUser")
+	}
+	if words <= inner {
+		t.Fatalf("expected the start of words to score more, got %d and %d", words, inner)
+	}
+	if short, _ := fuzzyScore("get", "get"); short <= prefix {
+		t.Fatalf("expected the shorter text to score more, got %d and %d", short, prefix)
+	}
+}
+
+func TestFuzzyCompletionItems(t *testing.T) {
+	items := []CompletionItem{
+		{Label: "debugUser"},
+		{Label: "set_user_name"},
+		{Label: "getUser()", FilterText: "getUser"},
+		{Label: "other"},
+	}
+	var labels, sortTexts []string
+	for _, item := range fuzzyCompletionItems(items, "usn") {
+		labels = append(labels, item.Label)
+		sortTexts = append(sortTexts, item.SortText)
+	}
+	if want := []string{"set_user_name"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("expected %q, got %q", want, labels)
+	}
+
+	labels, sortTexts = nil, nil
+	for _, item := range fuzzyCompletionItems(items, "gu") {
+		labels = append(labels, item.Label)
+		sortTexts = append(sortTexts, item.SortText)
+	}
+	if want := []string{"getUser()", "debugUser"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("expected %q, got %q", want, labels)
+	}
+	if want := []string{"00000", "00001"}; !reflect.DeepEqual(sortTexts, want) {
+		t.Fatalf("expected the sort texts %q, got %q", want, sortTexts)
+	}
+
+	if got := fuzzyCompletionItems(items, ""); !reflect.DeepEqual(got, items) {
+		t.Fatalf("expected all the items without a prefix, got %#v", got)
+	}
+}
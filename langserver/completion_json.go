@@ -0,0 +1,166 @@
+package langserver
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// The values of completion-format, how the output of completion-command is
+// parsed: a label on each line, or JSON items with their kind and docs.
+const (
+	completionFormatLines = "lines"
+	completionFormatJSON  = "json"
+)
+
+// completionOutputItem is a completion item printed by a completion-command
+// with completion-format json.
+type completionOutputItem struct {
+	Label         string          `json:"label"`
+	Kind          json.RawMessage `json:"kind"`
+	Detail        string          `json:"detail"`
+	Documentation string          `json:"documentation"`
+	InsertText    string          `json:"insertText"`
+	SortText      string          `json:"sortText"`
+	FilterText    string          `json:"filterText"`
+}
+
+// completionKindNames are the names of the kinds of completion items, in
+// the order of their numbers from 1.
+var completionKindNames = []string{
+	"text", "method", "function", "constructor", "field", "variable", "class",
+	"interface", "module", "property", "unit", "value", "enum", "keyword",
+	"snippet", "color", "file", "reference", "folder", "enummember",
+	"constant", "struct", "event", "operator", "typeparameter",
+}
+
+// completionKind returns the kind of a completion item given as its number
+// or its name, e.g. function or enum-member, or 0 if it is none.
+func completionKind(raw json.RawMessage) CompletionItemKind {
+	var n int
+	if json.Unmarshal(raw, &n) == nil {
+		if n >= 1 && n <= len(completionKindNames) {
+			return CompletionItemKind(n)
+		}
+		return 0
+	}
+	var name string
+	if json.Unmarshal(raw, &name) != nil {
+		return 0
+	}
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	for i, kind := range completionKindNames {
+		if kind == name {
+			return CompletionItemKind(i + 1)
+		}
+	}
+	return 0
+}
+
+// completionOutputList is a list of completion items printed by a
+// completion-command, incomplete if typing further changes its items.
+type completionOutputList struct {
+	IsIncomplete bool                   `json:"isIncomplete"`
+	Items        []completionOutputItem `json:"items"`
+}
+
+// jsonCompletionItems parses the output of a completion-command with
+// completion-format json, an array of items, an item on each line or a list
+// of items, which may be incomplete. The items without a label are dropped.
+func jsonCompletionItems(b []byte) (*CompletionList, error) {
+	var list completionOutputList
+	if trimmed := bytes.TrimSpace(b); bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &list.Items); err != nil {
+			return nil, err
+		}
+	} else if json.Unmarshal(trimmed, &list) != nil || list.Items == nil {
+		list = completionOutputList{}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		for {
+			var item completionOutputItem
+			if err := decoder.Decode(&item); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			list.Items = append(list.Items, item)
+		}
+	}
+
+	result := &CompletionList{IsIncomplete: list.IsIncomplete, Items: []CompletionItem{}}
+	for _, item := range list.Items {
+		if item.Label == "" {
+			continue
+		}
+		result.Items = append(result.Items, CompletionItem{
+			Label:         item.Label,
+			Kind:          completionKind(item.Kind),
+			Detail:        item.Detail,
+			Documentation: item.Documentation,
+			InsertText:    cmp.Or(item.InsertText, item.Label),
+			SortText:      item.SortText,
+			FilterText:    item.FilterText,
+		})
+	}
+	return result, nil
+}
+
+// jqCompletionItems runs the completion-jq filter over the JSON output b.
+// Each value of the filter is an item with the fields of completion-format
+// json, a label, an array of them, or a list of items which may be
+// incomplete.
+func jqCompletionItems(filter string, b []byte) (*CompletionList, error) {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	list := map[string]any{"isIncomplete": false}
+	items := []any{}
+	var add func(v any)
+	add = func(v any) {
+		switch v := v.(type) {
+		case string:
+			items = append(items, map[string]any{"label": v})
+		case []any:
+			for _, item := range v {
+				add(item)
+			}
+		case map[string]any:
+			if listed, ok := v["items"].([]any); ok {
+				if incomplete, _ := v["isIncomplete"].(bool); incomplete {
+					list["isIncomplete"] = true
+				}
+				add(listed)
+				return
+			}
+			items = append(items, v)
+		}
+	}
+	iter := query.Run(v)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		add(v)
+	}
+	list["items"] = items
+
+	b, err = json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	return jsonCompletionItems(b)
+}
@@ -0,0 +1,121 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONCompletionItems(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		want       []CompletionItem
+		incomplete bool
+	}{
+		{
+			name:   "array",
+			output: `[{"label": "println", "kind": 3, "detail": "fn(args ...any)", "documentation": "Prints its arguments.", "sortText": "a"}]`,
+			want: []CompletionItem{{
+				Label:         "println",
+				Kind:          FunctionCompletion,
+				Detail:        "fn(args ...any)",
+				Documentation: "Prints its arguments.",
+				InsertText:    "println",
+				SortText:      "a",
+			}},
+		},
+		{
+			name:   "an object on each line",
+			output: "{\"label\": \"Red\", \"kind\": \"enum-member\"}\n{\"label\": \"x\", \"kind\": \"Variable\", \"insertText\": \"x := \"}\n",
+			want: []CompletionItem{
+				{Label: "Red", Kind: EnumMemberCompletion, InsertText: "Red"},
+				{Label: "x", Kind: VariableCompletion, InsertText: "x := "},
+			},
+		},
+		{
+			name:   "unknown kinds and items without a label",
+			output: `[{"label": "a", "kind": "gadget"}, {"label": "b", "kind": 99}, {"kind": 3}]`,
+			want: []CompletionItem{
+				{Label: "a", InsertText: "a"},
+				{Label: "b", InsertText: "b"},
+			},
+		},
+		{
+			name:   "incomplete list",
+			output: `{"isIncomplete": true, "items": [{"label": "fmt", "kind": "module"}]}`,
+			want: []CompletionItem{
+				{Label: "fmt", Kind: ModuleCompletion, InsertText: "fmt"},
+			},
+			incomplete: true,
+		},
+		{
+			name:   "empty",
+			output: "",
+			want:   []CompletionItem{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jsonCompletionItems([]byte(tt.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Items, tt.want) {
+				t.Fatalf("expected %#v, got %#v", tt.want, got.Items)
+			}
+			if got.IsIncomplete != tt.incomplete {
+				t.Fatalf("expected incomplete to be %v, got %v", tt.incomplete, got.IsIncomplete)
+			}
+		})
+	}
+
+	if _, err := jsonCompletionItems([]byte("println\n")); err == nil {
+		t.Fatal("expected an error for output which isn't JSON")
+	}
+}
+
+func TestJQCompletionItems(t *testing.T) {
+	output := `{"results": [{"name": "getUser", "type": "func(id int) User"}, {"name": "users", "type": "[]User"}]}`
+	tests := []struct {
+		filter     string
+		want       []CompletionItem
+		incomplete bool
+	}{
+		{
+			filter: `.results[] | {label: .name, detail: .type, kind: (if .type | startswith("func") then "function" else "variable" end)}`,
+			want: []CompletionItem{
+				{Label: "getUser", Kind: FunctionCompletion, Detail: "func(id int) User", InsertText: "getUser"},
+				{Label: "users", Kind: VariableCompletion, Detail: "[]User", InsertText: "users"},
+			},
+		},
+		{
+			filter: `[.results[].name]`,
+			want: []CompletionItem{
+				{Label: "getUser", InsertText: "getUser"},
+				{Label: "users", InsertText: "users"},
+			},
+		},
+		{
+			filter: `{isIncomplete: true, items: [.results[0].name]}`,
+			want: []CompletionItem{
+				{Label: "getUser", InsertText: "getUser"},
+			},
+			incomplete: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := jqCompletionItems(tt.filter, []byte(output))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Items, tt.want) || got.IsIncomplete != tt.incomplete {
+			t.Fatalf("%s: expected %#v, got %#v", tt.filter, tt.want, got)
+		}
+	}
+
+	if _, err := jqCompletionItems(".results | error", []byte(output)); err == nil {
+		t.Fatal("expected the error of the filter")
+	}
+}
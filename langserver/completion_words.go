@@ -0,0 +1,60 @@
+package langserver
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// bufferWordMinLength is the number of characters of the shortest words
+// offered by buffer-words-completion, the shorter ones being quicker typed
+// than picked.
+const bufferWordMinLength = 3
+
+// bufferWords returns the completion items of buffer-words-completion: the
+// words of the open documents of languageID starting with prefix, ignoring
+// case, other than prefix itself, sorted.
+func (h *langHandler) bufferWords(languageID string, prefix string) []CompletionItem {
+	lower := strings.ToLower(prefix)
+	words := make(map[string]struct{})
+	for _, f := range h.files {
+		if f.LanguageID != languageID {
+			continue
+		}
+		for _, word := range splitBufferWords(f.Text) {
+			if word != prefix && strings.HasPrefix(strings.ToLower(word), lower) {
+				words[word] = struct{}{}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(words))
+	for word := range words {
+		sorted = append(sorted, word)
+	}
+	sort.Strings(sorted)
+	items := make([]CompletionItem, 0, len(sorted))
+	for _, word := range sorted {
+		items = append(items, CompletionItem{
+			Label:      word,
+			Kind:       TextCompletion,
+			InsertText: word,
+		})
+	}
+	return items
+}
+
+// splitBufferWords returns the words of text offered by
+// buffer-words-completion, the identifiers of at least bufferWordMinLength
+// characters not starting with a digit.
+func splitBufferWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !isWordRune(r)
+	}) {
+		if len([]rune(word)) >= bufferWordMinLength && !unicode.IsDigit([]rune(word)[0]) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
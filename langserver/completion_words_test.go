@@ -0,0 +1,40 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBufferWords(t *testing.T) {
+	got := splitBufferWords("func (h *langHandler) bufferWords(a, 123abc string) {\n\tnaïve_x := 42\n}")
+	want := []string{"func", "langHandler", "bufferWords", "string", "naïve_x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestBufferWordsCompletion(t *testing.T) {
+	uri := toURI("/project/a.go")
+	h := &langHandler{
+		bufferWordsCompletion: true,
+		files: map[DocumentURI]*File{
+			uri:                     {LanguageID: "go", Text: "func main() {\n\tfmt.Pr\n}\n"},
+			toURI("/project/b.go"):  {LanguageID: "go", Text: "fmt.Println(Printf, prefix)\n"},
+			toURI("/project/c.txt"): {LanguageID: "text", Text: "Private\n"},
+		},
+	}
+
+	list, err := h.completion(uri, &CompletionParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{Position: Position{Line: 1, Character: 7}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	if want := []string{"Printf", "Println", "prefix"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("expected %q, got %q", want, labels)
+	}
+}
@@ -4,35 +4,70 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig load configuration from file
-func LoadConfig(yamlfile string) (*Config, error) {
-	var config = Config{
+// ConfigNames are the names of the config file looked up in the config
+// directory, in order.
+var ConfigNames = []string{"config.yaml", "config.yml", "config.json", "config.toml"}
+
+// NewConfig returns the default configuration, which is what LoadConfig
+// starts from.
+func NewConfig() *Config {
+	return &Config{
 		ProvideDefinition: true, // Enabled by default.
 		Commands:          &[]Command{},
 		Languages:         &map[string][]Language{},
 		RootMarkers:       &[]string{},
 	}
-	var config1 Config1
+}
 
-	f, err := os.Open(yamlfile)
+// LoadConfig load configuration from file
+func LoadConfig(yamlfile string) (*Config, error) {
+	b, err := os.ReadFile(yamlfile)
 	if err != nil {
 		log.Println("efm-langserver: no configuration file")
-		return &config, nil
+		return NewConfig(), nil
+	}
+	b, err = configToYAML(yamlfile, b)
+	if err != nil {
+		return nil, fmt.Errorf("can not read configuration: %v", err)
+	}
+	config, err := ParseConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	config.Filename = yamlfile
+	return config, nil
+}
+
+// ParseConfig reads the configuration from YAML or JSON b, e.g. given on
+// stdin or in the EFM_CONFIG environment variable. The config has no
+// Filename, so it is not watched for changes.
+func ParseConfig(b []byte) (*Config, error) {
+	var config = *NewConfig()
+	var config1 Config1
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("can not read configuration: %v", err)
+	}
+	if root.Kind == 0 {
+		// An empty file.
+		root = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	if err := resolveExtends(&root); err != nil {
+		return nil, fmt.Errorf("can not read configuration: %v", err)
 	}
-	defer f.Close()
 
-	err = yaml.NewDecoder(f).Decode(&config1)
+	err := root.Decode(&config1)
 	if err != nil || config1.Version == 2 {
-		f, err = os.Open(yamlfile)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-		err = yaml.NewDecoder(f).Decode(&config)
+		err = root.Decode(&config)
 		if err != nil {
 			return nil, fmt.Errorf("can not read configuration: %v", err)
 		}
@@ -46,9 +81,28 @@ func LoadConfig(yamlfile string) (*Config, error) {
 		}
 		config.Languages = &languages
 	}
-	config.Filename = yamlfile
-	for _, langConfigs := range *config.Languages {
+	if err := prepareLanguages(*config.Languages); err != nil {
+		return nil, err
+	}
+	for name, profile := range config.Profiles {
+		if profile == nil || profile.Languages == nil {
+			continue
+		}
+		if err := prepareLanguages(*profile.Languages); err != nil {
+			return nil, fmt.Errorf("profile %v: %v", name, err)
+		}
+	}
+	return &config, nil
+}
+
+// prepareLanguages applies the OS overrides and fills in the defaults of
+// the tools.
+func prepareLanguages(languages map[string][]Language) error {
+	for _, langConfigs := range languages {
 		for i := range langConfigs {
+			if err := langConfigs[i].applyOSOverrides(runtime.GOOS); err != nil {
+				return fmt.Errorf("can not read configuration: %v", err)
+			}
 			if langConfigs[i].HoverChars == "" {
 				langConfigs[i].HoverChars = "_"
 			}
@@ -60,5 +114,21 @@ func LoadConfig(yamlfile string) (*Config, error) {
 			}
 		}
 	}
-	return &config, nil
+	return nil
+}
+
+// configToYAML converts the content b of the config file fname to YAML
+// according to its extension. The keys are the same in every format. JSON is
+// valid YAML already.
+func configToYAML(fname string, b []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case ".toml":
+		var v map[string]any
+		if err := toml.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(v)
+	default:
+		return b, nil
+	}
 }
@@ -0,0 +1,337 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadConfigFormats(t *testing.T) {
+	configs := map[string]string{
+		"config.yaml": `
+version: 2
+lint-debounce: 1s
+languages:
+  go:
+    - lint-command: 'go vet'
+      lint-stdin: false
+`,
+		"config.json": `{
+  "version": 2,
+  "lint-debounce": "1s",
+  "languages": {
+    "go": [{"lint-command": "go vet", "lint-stdin": false}]
+  }
+}`,
+		"config.toml": `
+version = 2
+lint-debounce = "1s"
+
+[[languages.go]]
+lint-command = "go vet"
+lint-stdin = false
+`,
+	}
+
+	dir := t.TempDir()
+	for name, content := range configs {
+		fname := filepath.Join(dir, name)
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadConfig(fname)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if time.Duration(config.LintDebounce) != time.Second {
+			t.Fatalf("%v: unexpected lint-debounce: %v", name, config.LintDebounce)
+		}
+		cfgs := (*config.Languages)["go"]
+		if len(cfgs) != 1 || cfgs[0].LintCommand != "go vet" || cfgs[0].LintStdin {
+			t.Fatalf("%v: unexpected languages: %+v", name, cfgs)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"version": 2, "languages": {"go": [{"lint-command": "go vet"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Filename != "" {
+		t.Fatalf("unexpected filename: %v", config.Filename)
+	}
+	cfgs := (*config.Languages)["go"]
+	if len(cfgs) != 1 || cfgs[0].LintCommand != "go vet" || cfgs[0].HoverChars != "_" {
+		t.Fatalf("unexpected languages: %+v", cfgs)
+	}
+
+	if _, err := ParseConfig([]byte("languages: [")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestImportProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	project := `
+version: 2
+languages:
+  python:
+    - lint-command: 'flake8 -'
+commands:
+  - title: test
+    command: make
+    arguments: [test]
+`
+	if err := os.WriteFile(filepath.Join(root, ".efm-langserver.yaml"), []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &langHandler{
+		logger:              log.New(io.Discard, "", 0),
+		importProjectConfig: true,
+		configs: map[string][]Language{
+			"go":     {{LintCommand: "go vet"}},
+			"python": {{LintCommand: "pylint"}},
+		},
+	}
+	h.importProjectTools(root)
+	h.importProjectTools(root)
+
+	if len(h.configs["go"]) != 1 || h.configs["go"][0].LintCommand != "go vet" {
+		t.Fatalf("tools of other languages should be kept: %+v", h.configs["go"])
+	}
+	if len(h.configs["python"]) != 1 || h.configs["python"][0].LintCommand != "flake8 -" {
+		t.Fatalf("tools of the project should replace the global ones: %+v", h.configs["python"])
+	}
+	if len(h.commands) != 1 || h.commands[0].Title != "test" {
+		t.Fatalf("commands of the project should be added once: %+v", h.commands)
+	}
+}
+
+func TestLoadConfigOSOverrides(t *testing.T) {
+	config := `
+version: 2
+languages:
+  sh:
+    - lint-command: 'shellcheck -f gcc -'
+      lint-stdin: true
+      windows:
+        lint-command: 'shellcheck.exe -f gcc -'
+      linux:
+        lint-command: 'shellcheck -x -f gcc -'
+      darwin:
+        lint-command: '/opt/homebrew/bin/shellcheck -f gcc -'
+`
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(fname, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := (*loaded.Languages)["sh"][0]
+
+	expected := map[string]string{
+		"windows": "shellcheck.exe -f gcc -",
+		"linux":   "shellcheck -x -f gcc -",
+		"darwin":  "/opt/homebrew/bin/shellcheck -f gcc -",
+	}[runtime.GOOS]
+	if expected != "" && cfg.LintCommand != expected {
+		t.Fatalf("expected %q but got: %q", expected, cfg.LintCommand)
+	}
+	if !cfg.LintStdin {
+		t.Fatal("settings without override should be kept")
+	}
+	if !cfg.Windows.IsZero() || !cfg.Linux.IsZero() || !cfg.Darwin.IsZero() {
+		t.Fatal("override blocks should be dropped once applied")
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	config := `
+version: 2
+languages:
+  go:
+    - lint-command: 'go vet'
+profiles:
+  strict:
+    languages:
+      go:
+        - lint-command: 'golangci-lint run'
+    lint-debounce: 2s
+`
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(fname, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Logger = log.New(io.Discard, "", 0)
+
+	h := newLangHandler(loaded)
+	defer h.close()
+	h.applyProfile()
+	if got := h.configs["go"][0].LintCommand; got != "go vet" {
+		t.Fatalf("no profile should be applied by default but got: %v", got)
+	}
+
+	h.profile = "strict"
+	h.applyProfile()
+	if got := h.configs["go"][0].LintCommand; got != "golangci-lint run" {
+		t.Fatalf("expected the tools of the profile but got: %v", got)
+	}
+	if h.configs["go"][0].HoverChars != "_" {
+		t.Fatal("defaults should be filled in the tools of profiles")
+	}
+	if h.lintDebounce != 2*time.Second {
+		t.Fatalf("expected the lint-debounce of the profile but got: %v", h.lintDebounce)
+	}
+}
+
+func TestLoadConfigExtends(t *testing.T) {
+	config := `
+version: 2
+tools:
+  prettier:
+    format-command: 'prettier --stdin-filepath ${INPUT}'
+    format-stdin: true
+    root-markers: [.prettierrc]
+  prettier-md:
+    extends: prettier
+    format-can-range: true
+languages:
+  css:
+    - extends: prettier
+      root-markers: [package.json]
+  markdown:
+    - extends: prettier-md
+`
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(fname, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	css := (*loaded.Languages)["css"][0]
+	if css.FormatCommand != "prettier --stdin-filepath ${INPUT}" || !css.FormatStdin {
+		t.Fatalf("settings of the tool should be inherited: %+v", css)
+	}
+	if len(css.RootMarkers) != 1 || css.RootMarkers[0] != "package.json" {
+		t.Fatalf("settings of the entry should win: %v", css.RootMarkers)
+	}
+	markdown := (*loaded.Languages)["markdown"][0]
+	if !markdown.FormatCanRange || !markdown.FormatStdin || markdown.RootMarkers[0] != ".prettierrc" {
+		t.Fatalf("tools should inherit from other tools: %+v", markdown)
+	}
+
+	bad := "version: 2\nlanguages:\n  css:\n    - extends: missing\n"
+	if err := os.WriteFile(fname, []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(fname); err == nil {
+		t.Fatal("extending an unknown tool should fail")
+	}
+}
+
+func TestLoadConfigToolTemplates(t *testing.T) {
+	config := `
+version: 2
+tools:
+  eslint:
+    lint-command: 'eslint -c {{configfile}} {{args}} --stdin'
+    with:
+      configfile: .eslintrc.json
+      args: ''
+  eslint-strict:
+    extends: eslint
+    with:
+      args: '--max-warnings 0'
+languages:
+  javascript:
+    - extends: eslint
+  typescript:
+    - extends: eslint-strict
+      with:
+        configfile: tsconfig.eslint.json
+  vue:
+    - lint-command: 'vue-lint --format {{.File}}'
+`
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(fname, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"javascript": "eslint -c .eslintrc.json  --stdin",
+		"typescript": "eslint -c tsconfig.eslint.json --max-warnings 0 --stdin",
+		"vue":        "vue-lint --format {{.File}}",
+	}
+	for lang, command := range expected {
+		if got := (*loaded.Languages)[lang][0].LintCommand; got != command {
+			t.Fatalf("%v: expected %q but got: %q", lang, command, got)
+		}
+	}
+}
+
+func TestDumpConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := `
+version: 2
+import-project-config: true
+languages:
+  go:
+    - lint-command: 'go vet'
+  python:
+    - lint-command: flake8
+`
+	fname := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(fname, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(dir, ".efm-langserver.yaml")
+	if err := os.WriteFile(project, []byte("version: 2\nlanguages:\n  python:\n    - lint-command: ruff\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Logger = log.New(io.Discard, "", 0)
+
+	dumped, err := DumpConfig(loaded, ResolveOptions{Root: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(dumped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, expected := range []string{
+		"python:\n        # from " + project + "\n",
+		"go:\n        # from " + fname + "\n",
+		"import-project-config: true # from " + fname,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, out)
+		}
+	}
+}
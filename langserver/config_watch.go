@@ -0,0 +1,141 @@
+package langserver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 2 * time.Second
+
+// reloadConfig reads the config file again and applies it to the session.
+func (h *langHandler) reloadConfig() error {
+	if h.filename == "" {
+		return errors.New("the configuration was not read from a file")
+	}
+	config, err := LoadConfig(h.filename)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.applyConfig(config)
+	h.origins = nil
+	// The tools may read config files of the project which changed too.
+	h.lintCache.forget("")
+	h.applyProfile()
+	h.importProjectTools(h.rootPath)
+	changes := h.settingsChanges
+	h.mu.Unlock()
+
+	// The lint servers are stopped without holding h.mu, as their pool has
+	// its own lock, which the lints using them hold.
+	h.lintServers.stopAll()
+	// The settings the client sent still override the ones of the file.
+	for _, c := range changes {
+		if _, err := h.applySettings(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configFile returns the absolute path of the config file, or "" if the
+// configuration was not read from a file.
+func (h *langHandler) configFile() string {
+	if h.filename == "" {
+		return ""
+	}
+	fname, err := filepath.Abs(h.filename)
+	if err != nil {
+		return ""
+	}
+	return fname
+}
+
+// configFileChangedAny reports whether one of the files is the config file.
+func (h *langHandler) configFileChangedAny(files []string) bool {
+	fname := h.configFile()
+	if fname == "" {
+		return false
+	}
+	for _, file := range files {
+		if sameFile(file, fname) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameFile reports whether the paths a and b are the same file, ignoring
+// case on Windows.
+func sameFile(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// configChanged reloads the config file after it changed. It calls the
+// client, so it must not be used from the goroutine handling requests.
+func (h *langHandler) configChanged() {
+	if err := h.reloadConfig(); err != nil {
+		h.logger.Printf("can not reload %v: %v", h.filename, err)
+		return
+	}
+	if h.loglevel >= 1 {
+		h.logger.Printf("reloaded %v", h.filename)
+	}
+	h.updateWatchedFiles()
+	h.mu.Lock()
+	conn := h.conn
+	h.mu.Unlock()
+	if conn != nil {
+		h.logMessage(LogInfo, "Reloaded configuration file")
+	}
+}
+
+// watchConfig reloads the config file whenever it changes, until the
+// session is closed. The client is asked to watch the file with the other
+// watched files if it can, and the file is only polled otherwise.
+// Capabilities announced on initialize can't change, so tools of a kind the
+// server didn't provide before (e.g. the first hover-command) still need a
+// restart.
+func (h *langHandler) watchConfig() {
+	stat := func() (time.Time, int64) {
+		fi, err := os.Stat(h.filename)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return fi.ModTime(), fi.Size()
+	}
+	modTime, size := stat()
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+
+		t, s := stat()
+		if s < 0 || (t.Equal(modTime) && s == size) {
+			continue
+		}
+		modTime, size = t, s
+
+		h.mu.Lock()
+		watched := h.conn != nil && h.dynamicWatchedFiles
+		h.mu.Unlock()
+		if !watched {
+			h.configChanged()
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestReloadConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+version: 2
+languages:
+  vim:
+    - lint-command: echo before
+      lint-stdin: true
+      lint-ignore-exit-code: true
+`)
+	config, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Logger = log.New(io.Discard, "", 0)
+	h := newLangHandler(config)
+	defer h.close()
+
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.vim"))
+	h.files[uri] = &File{LanguageID: "vim", Text: "a\n"}
+
+	write(`
+version: 2
+lint-debounce: 2s
+root-markers: [.git/]
+languages:
+  vim:
+    - lint-command: echo after
+      lint-stdin: true
+      lint-ignore-exit-code: true
+`)
+	// The linter reads the tools while they are reloaded.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 5 {
+			if _, err := h.lint(context.Background(), uri, eventTypeChange); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for range 5 {
+		if err := h.reloadConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if cfgs := h.configs["vim"]; len(cfgs) != 1 || cfgs[0].LintCommand != "echo after" {
+		t.Fatalf("the tools should be reloaded: %+v", cfgs)
+	}
+	if h.lintDebounce != 2*time.Second || !slices.Equal(h.rootMarkers, []string{".git/"}) {
+		t.Fatalf("the settings should be reloaded: %v %v", h.lintDebounce, h.rootMarkers)
+	}
+}
+
+func TestConfigFileWatched(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		filename: fname,
+		configs: map[string][]Language{
+			"text": {{LintCommand: "textlint", LintWatch: []string{".textlintrc"}}},
+		},
+	}
+	if globs := h.watchedFileGlobs(); !slices.Contains(globs, filepath.ToSlash(fname)) {
+		t.Fatalf("the config file should be watched: %v", globs)
+	}
+	if !h.configFileChangedAny([]string{filepath.Join(filepath.Dir(fname), ".textlintrc"), fname}) {
+		t.Fatal("a change of the config file should be found")
+	}
+	if h.configFileChangedAny([]string{filepath.Join(filepath.Dir(fname), "other.yaml")}) {
+		t.Fatal("other files are not the config file")
+	}
+}
+
+func TestReloadConfigKeepsSettings(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`
+version: 2
+languages:
+  vim:
+    - format-command: before
+`)
+	config, err := LoadConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Logger = log.New(io.Discard, "", 0)
+	h := newLangHandler(config)
+	defer h.close()
+
+	params := json.RawMessage(`{"settings": {"lintDebounce": 3000000000, "languages": {"vim": [{"lintCommand": "vint -"}]}}}`)
+	if _, err := h.handleWorkspaceDidChangeConfiguration(context.Background(), nil, &jsonrpc2.Request{Params: &params}); err != nil {
+		t.Fatal(err)
+	}
+
+	write(`
+version: 2
+lint-debounce: 1s
+languages:
+  vim:
+    - format-command: after
+`)
+	if err := h.reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if cfgs := h.configs["vim"]; len(cfgs) != 1 || cfgs[0].FormatCommand != "after" || cfgs[0].LintCommand != "vint -" {
+		t.Fatalf("expected the settings of the client over the reloaded tools: %+v", cfgs)
+	}
+	if h.lintDebounce != 3*time.Second {
+		t.Fatalf("expected the lint-debounce of the client, got %v", h.lintDebounce)
+	}
+}
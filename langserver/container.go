@@ -0,0 +1,185 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const defaultContainerWorkdir = "/workspace"
+
+// Container runs the commands of a tool inside a container image.
+type Container struct {
+	Image   string   `yaml:"image" json:"image"`
+	Engine  string   `yaml:"engine" json:"engine"`
+	Mounts  []string `yaml:"mounts" json:"mounts"`
+	Workdir string   `yaml:"workdir" json:"workdir"`
+}
+
+func (c *Container) engine() string {
+	if c.Engine != "" {
+		return c.Engine
+	}
+	return "docker"
+}
+
+func (c *Container) workdir() string {
+	if c.Workdir != "" {
+		return c.Workdir
+	}
+	return defaultContainerWorkdir
+}
+
+// pathMap returns the translation between host paths and container paths
+// when root is mounted as the working directory.
+func (c *Container) pathMap(root string) pathMap {
+	mappings := []pathMapping{{local: root, remote: c.workdir()}}
+	for _, mount := range c.Mounts {
+		if host, target, ok := splitMount(mount); ok {
+			mappings = append(mappings, pathMapping{local: host, remote: target})
+		}
+	}
+	return newPathMap(mappings...)
+}
+
+// splitMount parses "host:container[:options]". The host part may contain a
+// Windows drive letter, so the container path is located from the right.
+func splitMount(mount string) (string, string, bool) {
+	i := strings.LastIndex(mount, ":/")
+	if i <= 0 {
+		return "", "", false
+	}
+	target := mount[i+1:]
+	if j := strings.Index(target, ":"); j >= 0 {
+		target = target[:j]
+	}
+	return mount[:i], target, true
+}
+
+// containerPool keeps one running container per image and project root so
+// that commands don't pay the container startup on every lint.
+type containerPool struct {
+	mu      sync.Mutex
+	ids     map[string]*containerID
+	stopped bool
+}
+
+// containerID is a container being started, until done is closed.
+type containerID struct {
+	done   chan struct{}
+	engine string
+	id     string
+	err    error
+}
+
+// get returns the container of c for root, starting it the first time. The
+// lock isn't held while the container starts, so that the tools of other
+// images and projects don't wait for it, and the ones of the same wait for
+// the same container.
+func (p *containerPool) get(c *Container, root string) (string, error) {
+	key := strings.Join(append([]string{c.engine(), c.Image, c.workdir(), root}, c.Mounts...), "\x00")
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return "", fmt.Errorf("failed to start container %v: the server is shutting down", c.Image)
+	}
+	cid, ok := p.ids[key]
+	if !ok {
+		cid = &containerID{done: make(chan struct{}), engine: c.engine()}
+		if p.ids == nil {
+			p.ids = make(map[string]*containerID)
+		}
+		p.ids[key] = cid
+	}
+	p.mu.Unlock()
+	if ok {
+		<-cid.done
+		return cid.id, cid.err
+	}
+
+	id, err := startContainer(c, root)
+	p.mu.Lock()
+	if err == nil && p.stopped {
+		// The pool was stopped meanwhile, and wouldn't remove it.
+		_ = exec.Command(cid.engine, "rm", "-f", id).Run()
+		err = fmt.Errorf("failed to start container %v: the server is shutting down", c.Image)
+	}
+	if err != nil {
+		// The next lint tries again.
+		delete(p.ids, key)
+	}
+	cid.id, cid.err = id, err
+	p.mu.Unlock()
+	close(cid.done)
+	return id, err
+}
+
+// startContainer runs a container of c with root mounted, idling until it
+// is removed.
+func startContainer(c *Container, root string) (string, error) {
+	args := []string{"run", "-d", "--rm", "-v", root + ":" + c.workdir()}
+	for _, mount := range c.Mounts {
+		args = append(args, "-v", mount)
+	}
+	args = append(args, "--entrypoint", "tail", c.Image, "-f", "/dev/null")
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.engine(), args...)
+	cmd.Stderr = &stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to start container %v: %v: %v", c.Image, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// stopAll removes the containers started, and the ones still starting once
+// they are.
+func (p *containerPool) stopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopped = true
+	for key, cid := range p.ids {
+		select {
+		case <-cid.done:
+			_ = exec.Command(cid.engine, "rm", "-f", cid.id).Run()
+		default:
+		}
+		delete(p.ids, key)
+	}
+}
+
+// containerCommand wraps command in "docker exec" against the cached
+// container of config, translating host paths to container paths.
+func (h *langHandler) containerCommand(ctx context.Context, config *Language, dir, command string, args ...string) (*exec.Cmd, error) {
+	c := config.Container
+	id, err := h.containers.get(c, dir)
+	if err != nil {
+		return nil, err
+	}
+	m := c.pathMap(dir)
+
+	// The arguments of the caller are left as is.
+	containerArgs := make([]string, len(args))
+	for i, arg := range args {
+		containerArgs[i] = m.toRemote(arg)
+	}
+	inner, err := shellCommand(ctx, h.shellFor(config), m.toRemote(command), containerArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	execArgs := []string{"exec", "-i", "-w", m.toRemote(dir)}
+	for _, env := range config.Env {
+		execArgs = append(execArgs, "-e", env)
+	}
+	execArgs = append(execArgs, id)
+	cmd := exec.CommandContext(ctx, c.engine(), append(execArgs, inner.Args...)...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
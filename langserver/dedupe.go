@@ -0,0 +1,30 @@
+package langserver
+
+// dedupeDiagnostics drops the diagnostics reporting the same range, code
+// and message as one before them, e.g. found by the same linter run per file
+// and for the workspace. With byCode, diagnostics having a code are the same
+// if their ranges and codes are, whatever their messages.
+func dedupeDiagnostics(diagnostics []Diagnostic, byCode bool) []Diagnostic {
+	type key struct {
+		rng     Range
+		code    string
+		message string
+	}
+	seen := make(map[key]struct{}, len(diagnostics))
+	deduped := make([]Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		k := key{rng: d.Range, message: d.Message}
+		if d.Code != nil {
+			k.code = *d.Code
+			if byCode && k.code != "" {
+				k.message = ""
+			}
+		}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		deduped = append(deduped, d)
+	}
+	return deduped
+}
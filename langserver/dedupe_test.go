@@ -0,0 +1,29 @@
+package langserver
+
+import "testing"
+
+func TestDedupeDiagnostics(t *testing.T) {
+	code := func(s string) *string { return &s }
+	line := func(l int) Range {
+		return Range{Start: Position{Line: l}, End: Position{Line: l, Character: 3}}
+	}
+	diagnostics := []Diagnostic{
+		{Range: line(0), Code: code("no-undef"), Message: "'a' is not defined", Source: code("eslint")},
+		{Range: line(0), Code: code("no-undef"), Message: "'a' is not defined", Source: code("eslint_d")},
+		{Range: line(0), Code: code("no-undef"), Message: "a is undefined"},
+		{Range: line(1), Code: code("no-undef"), Message: "'a' is not defined"},
+		{Range: line(2), Message: "syntax error"},
+		{Range: line(2), Message: "unexpected token"},
+	}
+
+	if got := dedupeDiagnostics(diagnostics, false); len(got) != 5 {
+		t.Fatalf("expected 5 diagnostics but got: %+v", got)
+	}
+	got := dedupeDiagnostics(diagnostics, true)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 diagnostics but got: %+v", got)
+	}
+	if *got[0].Source != "eslint" {
+		t.Fatalf("expected the first diagnostic to be kept but got: %+v", got[0])
+	}
+}
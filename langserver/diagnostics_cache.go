@@ -0,0 +1,117 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// cachedDiagnostics are the diagnostics last published for a file, kept
+// across restarts with diagnostics-cache.
+type cachedDiagnostics struct {
+	// Hash is the SHA-256 of the file on disk when the session ended. The
+	// diagnostics are stale if it changed since.
+	Hash        string       `json:"hash"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// diagnosticsCacheFile returns the file keeping the diagnostics of the
+// workspace root.
+func diagnosticsCacheFile(root string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, "efm-langserver", "diagnostics", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// hashFile returns the SHA-256 of the content of the file uri.
+func hashFile(uri DocumentURI) (string, error) {
+	fname, err := fromURI(uri)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// saveDiagnosticsCache writes the diagnostics last published for the files
+// of the workspace, for restoreDiagnosticsCache to publish them again in
+// the next session.
+func (h *langHandler) saveDiagnosticsCache() {
+	if !h.diagnosticsCache || h.rootPath == "" {
+		return
+	}
+	cache := make(map[DocumentURI]cachedDiagnostics)
+	for uri, diagnostics := range h.published.kept() {
+		if len(diagnostics) == 0 {
+			continue
+		}
+		hash, err := hashFile(uri)
+		if err != nil {
+			continue
+		}
+		cache[uri] = cachedDiagnostics{Hash: hash, Diagnostics: diagnostics}
+	}
+
+	fname, err := diagnosticsCacheFile(h.rootPath)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(fname), 0o755)
+	}
+	var b []byte
+	if err == nil {
+		b, err = json.Marshal(cache)
+	}
+	if err == nil {
+		err = os.WriteFile(fname, b, 0o644)
+	}
+	if err != nil && h.loglevel >= 1 {
+		h.logger.Printf("cannot save the diagnostics cache: %v", err)
+	}
+}
+
+// restoreDiagnosticsCache publishes the diagnostics of the last session for
+// the files which didn't change since, until the tools lint them again.
+func (h *langHandler) restoreDiagnosticsCache() {
+	for uri, diagnostics := range h.loadDiagnosticsCache() {
+		h.publishDiagnostics(context.Background(), uri, diagnostics, 0, true)
+	}
+}
+
+// loadDiagnosticsCache reads the diagnostics of the last session for the
+// files which didn't change since.
+func (h *langHandler) loadDiagnosticsCache() map[DocumentURI][]Diagnostic {
+	if !h.diagnosticsCache || h.rootPath == "" {
+		return nil
+	}
+	fname, err := diagnosticsCacheFile(h.rootPath)
+	if err != nil {
+		return nil
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil
+	}
+	var cache map[DocumentURI]cachedDiagnostics
+	if err := json.Unmarshal(b, &cache); err != nil {
+		if h.loglevel >= 1 {
+			h.logger.Printf("cannot read the diagnostics cache %s: %v", fname, err)
+		}
+		return nil
+	}
+	fresh := make(map[DocumentURI][]Diagnostic)
+	for uri, cached := range cache {
+		if hash, err := hashFile(uri); err == nil && hash == cached.Hash {
+			fresh[uri] = cached.Diagnostics
+		}
+	}
+	return fresh
+}
@@ -0,0 +1,38 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiagnosticsCache(t *testing.T) {
+	// The user cache directory of every platform.
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LocalAppData", t.TempDir())
+	root := t.TempDir()
+	unchanged := filepath.Join(root, "unchanged.go")
+	changed := filepath.Join(root, "changed.go")
+	for _, fname := range []string{unchanged, changed} {
+		if err := os.WriteFile(fname, []byte("package main\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &langHandler{logger: log.New(io.Discard, "", 0), rootPath: root, diagnosticsCache: true}
+	diagnostics := []Diagnostic{{Message: "unused variable", Severity: 2}}
+	h.published.record(toURI(unchanged), diagnostics, true)
+	h.published.record(toURI(changed), diagnostics, true)
+	h.saveDiagnosticsCache()
+
+	if err := os.WriteFile(changed, []byte("package main\n\nvar x int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored := (&langHandler{logger: h.logger, rootPath: root, diagnosticsCache: true}).loadDiagnosticsCache()
+	if len(restored) != 1 || len(restored[toURI(unchanged)]) != 1 || restored[toURI(unchanged)][0].Message != "unused variable" {
+		t.Fatalf("expected the diagnostics of the unchanged file only but got: %+v", restored)
+	}
+}
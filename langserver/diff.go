@@ -8,6 +8,8 @@ package langserver
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf16"
 )
 
 // OpKind is used to denote the type of operation a line represents.
@@ -28,11 +30,25 @@ const (
 // https://blog.jcoglan.com/2017/02/17/the-myers-diff-algorithm-part-3/
 // https://www.codeproject.com/Articles/42279/%2FArticles%2F42279%2FInvestigating-Myers-diff-algorithm-Part-1-of-2
 
-// ComputeEdits computes diff edits from 2 string inputs
+// ComputeEdits computes diff edits from 2 string inputs. The lines replaced
+// by others are diffed again by words, so that the edits touch only the words
+// which changed and editors keep the cursor, folds and undo history around
+// them.
 func ComputeEdits(_ DocumentURI, before, after string) []TextEdit {
-	ops := operations(splitLines(before), splitLines(after))
+	a := splitLines(before)
+	ops := operations(a, splitLines(after))
 	edits := make([]TextEdit, 0, len(ops))
-	for _, op := range ops {
+	for i, op := range ops {
+		if op.Kind == Delete && i+1 < len(ops) && ops[i+1].Kind == Insert && ops[i+1].I1 == op.I2 {
+			// A replacement: the insert following is part of the word
+			// edits.
+			old := strings.Join(a[op.I1:op.I2], "")
+			if words := wordEdits(op.I1, old, strings.Join(ops[i+1].Content, "")); words != nil {
+				edits = append(edits, words...)
+				ops[i+1] = &operation{Kind: Equal}
+				continue
+			}
+		}
 		switch op.Kind {
 		case Delete:
 			// Delete: unformatted[i1:i2] is deleted.
@@ -56,6 +72,57 @@ func ComputeEdits(_ DocumentURI, before, after string) []TextEdit {
 	return edits
 }
 
+// changedLinesIn returns before with only the changes of after to the lines
+// in returns true for. A change of as many lines into others is taken line
+// by line, any other change of lines out of them too is left out entirely.
+func changedLinesIn(before, after string, in func(line int) bool) string {
+	a := splitLines(before)
+	ops := operations(a, splitLines(after))
+	var b strings.Builder
+	i := 0
+	for n := 0; n < len(ops); n++ {
+		op := ops[n]
+		b.WriteString(strings.Join(a[i:op.I1], ""))
+		var content []string
+		if op.Kind == Insert {
+			content = append(content, op.Content...)
+		}
+		// The inserts following at the same line are part of the change.
+		for n+1 < len(ops) && ops[n+1].Kind == Insert && ops[n+1].I1 == op.I2 {
+			content = append(content, ops[n+1].Content...)
+			n++
+		}
+		if len(content) == op.I2-op.I1 {
+			// As many lines replaced by as many others, e.g. reindented,
+			// each replaced by the one in its place.
+			for k, line := range content {
+				if in(op.I1 + k) {
+					b.WriteString(line)
+				} else {
+					b.WriteString(a[op.I1+k])
+				}
+			}
+		} else if linesIn(op.I1, max(op.I2, op.I1+1), in) {
+			b.WriteString(strings.Join(content, ""))
+		} else {
+			b.WriteString(strings.Join(a[op.I1:op.I2], ""))
+		}
+		i = op.I2
+	}
+	b.WriteString(strings.Join(a[i:], ""))
+	return b.String()
+}
+
+// linesIn reports whether the lines start to end, excluded, are all in.
+func linesIn(start, end int, in func(line int) bool) bool {
+	for l := start; l < end; l++ {
+		if !in(l) {
+			return false
+		}
+	}
+	return true
+}
+
 type operation struct {
 	Kind    OpKind
 	Content []string // content from b
@@ -224,3 +291,84 @@ func splitLines(text string) []string {
 	}
 	return lines
 }
+
+// maxWordDiffTokens bounds the words of the lines diffed by words, since the
+// memory of the diff grows with the square of their number. Larger
+// replacements are edits of whole lines.
+const maxWordDiffTokens = 300
+
+// wordEdits returns the edits replacing before, the text of the lines
+// starting at line, with after, word by word, or nil if they have too many
+// words.
+func wordEdits(line int, before, after string) []TextEdit {
+	a, b := splitWords(before), splitWords(after)
+	if len(a) > maxWordDiffTokens || len(b) > maxWordDiffTokens {
+		return nil
+	}
+
+	// The position of every word of a, and of its end.
+	positions := make([]Position, len(a)+1)
+	pos := Position{Line: line}
+	for i, word := range a {
+		positions[i] = pos
+		for _, r := range word {
+			if r == '\n' {
+				pos = Position{Line: pos.Line + 1}
+			} else {
+				pos.Character += utf16.RuneLen(r)
+			}
+		}
+	}
+	positions[len(a)] = pos
+
+	edits := []TextEdit{}
+	ops := operations(a, b)
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		switch op.Kind {
+		case Delete:
+			edit := TextEdit{Range: Range{Start: positions[op.I1], End: positions[op.I2]}}
+			if i+1 < len(ops) && ops[i+1].Kind == Insert && ops[i+1].I1 == op.I2 {
+				edit.NewText = strings.Join(ops[i+1].Content, "")
+				i++
+			}
+			edits = append(edits, edit)
+		case Insert:
+			edits = append(edits, TextEdit{
+				Range:   Range{Start: positions[op.I1], End: positions[op.I1]},
+				NewText: strings.Join(op.Content, ""),
+			})
+		}
+	}
+	return edits
+}
+
+// splitWords splits text into words: runs of letters, digits and
+// underscores, runs of blanks, newlines and single other characters.
+func splitWords(text string) []string {
+	class := func(r rune) int {
+		switch {
+		case r == '\n':
+			return 0
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 3
+	}
+	var words []string
+	start, last := 0, -1
+	for i, r := range text {
+		c := class(r)
+		if i > start && (c != last || c == 0 || c == 3) {
+			words = append(words, text[start:i])
+			start = i
+		}
+		last = c
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
@@ -0,0 +1,90 @@
+package langserver
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// applyEdits applies the non overlapping edits, in the order of the text, to
+// text.
+func applyEdits(text string, edits []TextEdit) string {
+	offset := func(pos Position) int {
+		lines := strings.SplitAfter(text, "\n")
+		n := 0
+		for i := 0; i < pos.Line && i < len(lines); i++ {
+			n += len(lines[i])
+		}
+		if pos.Line >= len(lines) {
+			return n
+		}
+		units := 0
+		for i, r := range lines[pos.Line] {
+			if units >= pos.Character {
+				return n + i
+			}
+			units += utf16.RuneLen(r)
+		}
+		return n + len(lines[pos.Line])
+	}
+	for i := len(edits) - 1; i >= 0; i-- {
+		start, end := offset(edits[i].Range.Start), offset(edits[i].Range.End)
+		text = text[:start] + edits[i].NewText + text[end:]
+	}
+	return text
+}
+
+func TestComputeEdits(t *testing.T) {
+	tests := []struct {
+		before, after string
+	}{
+		{"", "foo\n"},
+		{"foo\n", ""},
+		{"a\nb\nc\n", "a\nc\n"},
+		{"a\nc\n", "a\nb\nc\n"},
+		{"x = 1\ny=2\nz = 3\n", "x = 1\ny = 2\nz = 3\n"},
+		{"func  f(){\n\treturn 1\n}\n", "func f() {\n\treturn 1\n}\n"},
+		{"é😀 foo\n", "é😀 bar\n"},
+		{"a\nb\n", "a\nb"},
+	}
+	for _, tt := range tests {
+		if got := applyEdits(tt.before, ComputeEdits("", tt.before, tt.after)); got != tt.after {
+			t.Errorf("applying the edits of %q to %q gave %q", tt.after, tt.before, got)
+		}
+	}
+}
+
+func TestComputeEditsByWords(t *testing.T) {
+	before := "first line\nlet answer = 41;\nlast line\n"
+	after := "first line\nlet answer = 42;\nlast line\n"
+
+	edits := ComputeEdits("", before, after)
+	want := []TextEdit{{
+		Range:   Range{Start: Position{Line: 1, Character: 13}, End: Position{Line: 1, Character: 15}},
+		NewText: "42",
+	}}
+	if len(edits) != 1 || edits[0] != want[0] {
+		t.Fatalf("expected the edit of the changed word only, got %+v", edits)
+	}
+}
+
+func TestChangedLinesIn(t *testing.T) {
+	before := "a\n  b\nc\nd\n"
+	tests := []struct {
+		after      string
+		start, end int
+		want       string
+	}{
+		{"A\nb\nC\nd\n", 1, 1, "a\nb\nc\nd\n"},
+		{"a\n  b\nc\nd\ne\n", 1, 2, "a\n  b\nc\nd\n"},
+		{"a\n  b\nd\n", 2, 2, "a\n  b\nd\n"},
+		{"a\nb1\nb2\nc\nd\n", 2, 3, "a\n  b\nc\nd\n"},
+		{"a\nb1\nb2\nc\nd\n", 1, 1, "a\nb1\nb2\nc\nd\n"},
+	}
+	for _, tt := range tests {
+		in := func(line int) bool { return line >= tt.start && line <= tt.end }
+		if got := changedLinesIn(before, tt.after, in); got != tt.want {
+			t.Errorf("changedLinesIn(%q, %q, %d, %d) = %q, want %q", before, tt.after, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
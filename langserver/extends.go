@@ -0,0 +1,220 @@
+package langserver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resolveExtends replaces every tool entry having `extends: name` by the
+// tool `name` of the tools section with the settings of the entry on top.
+// Tools may extend other tools. Template parameters like {{args}} in the
+// settings are filled in from the `with` mappings of the entry and of the
+// tools it extends, the entry winning.
+func resolveExtends(root *yaml.Node) error {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	r := &extendsResolver{
+		tools:     deref(mappingValue(doc, "tools")),
+		resolved:  make(map[string]*yaml.Node),
+		resolving: make(map[string]bool),
+	}
+	if r.tools != nil && r.tools.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(r.tools.Content); i += 2 {
+			tool, err := r.tool(r.tools.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			r.tools.Content[i+1] = tool
+		}
+	}
+
+	if err := r.languages(mappingValue(doc, "languages")); err != nil {
+		return err
+	}
+	if profiles := deref(mappingValue(doc, "profiles")); profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 1; i < len(profiles.Content); i += 2 {
+			if err := r.languages(mappingValue(deref(profiles.Content[i]), "languages")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type extendsResolver struct {
+	tools     *yaml.Node
+	resolved  map[string]*yaml.Node
+	resolving map[string]bool
+}
+
+// tool returns the tool name with its own extends resolved.
+func (r *extendsResolver) tool(name string) (*yaml.Node, error) {
+	if tool, ok := r.resolved[name]; ok {
+		return tool, nil
+	}
+	if r.resolving[name] {
+		return nil, fmt.Errorf("tool %v extends itself", name)
+	}
+	tool := deref(mappingValue(r.tools, name))
+	if tool == nil {
+		return nil, fmt.Errorf("unknown tool: %v", name)
+	}
+
+	r.resolving[name] = true
+	defer delete(r.resolving, name)
+	tool, err := r.entry(tool)
+	if err != nil {
+		return nil, fmt.Errorf("tool %v: %v", name, err)
+	}
+	r.resolved[name] = tool
+	return tool, nil
+}
+
+// entry resolves the extends of a tool entry.
+func (r *extendsResolver) entry(node *yaml.Node) (*yaml.Node, error) {
+	node = deref(node)
+	extends := mappingValue(node, "extends")
+	if extends == nil {
+		return node, nil
+	}
+	if r.tools == nil {
+		return nil, fmt.Errorf("line %d: extends %v but there is no tools section", extends.Line, extends.Value)
+	}
+	base, err := r.tool(extends.Value)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", extends.Line, err)
+	}
+	values := withValues(base)
+	for name, value := range withValues(node) {
+		values[name] = value
+	}
+	return mergeMappings(base, node, values), nil
+}
+
+// finalEntry resolves a tool entry of a language and fills in its template
+// parameters.
+func (r *extendsResolver) finalEntry(node *yaml.Node) (*yaml.Node, error) {
+	entry, err := r.entry(node)
+	if err != nil {
+		return nil, err
+	}
+	return substituteNode(entry, withValues(entry)), nil
+}
+
+var templateParamRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// withValues returns the template parameters given by the with mapping of a
+// tool entry.
+func withValues(node *yaml.Node) map[string]string {
+	values := make(map[string]string)
+	with := deref(mappingValue(node, "with"))
+	if with == nil || with.Kind != yaml.MappingNode {
+		return values
+	}
+	for i := 0; i+1 < len(with.Content); i += 2 {
+		values[with.Content[i].Value] = deref(with.Content[i+1]).Value
+	}
+	return values
+}
+
+// substituteNode returns a copy of node with the template parameters
+// {{name}} of values replaced in the strings. Other {{...}} are kept.
+func substituteNode(node *yaml.Node, values map[string]string) *yaml.Node {
+	node = deref(node)
+	copied := *node
+	if node.Kind == yaml.ScalarNode {
+		copied.Value = templateParamRe.ReplaceAllStringFunc(node.Value, func(m string) string {
+			if value, ok := values[templateParamRe.FindStringSubmatch(m)[1]]; ok {
+				return value
+			}
+			return m
+		})
+		return &copied
+	}
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = substituteNode(child, values)
+	}
+	return &copied
+}
+
+func (r *extendsResolver) languages(languages *yaml.Node) error {
+	languages = deref(languages)
+	if languages == nil || languages.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 1; i < len(languages.Content); i += 2 {
+		value := deref(languages.Content[i])
+		if value.Kind != yaml.SequenceNode {
+			// Version 1 has a single tool per language.
+			entry, err := r.finalEntry(value)
+			if err != nil {
+				return err
+			}
+			languages.Content[i] = entry
+			continue
+		}
+		items := make([]*yaml.Node, len(value.Content))
+		for j, item := range value.Content {
+			entry, err := r.finalEntry(item)
+			if err != nil {
+				return err
+			}
+			items[j] = entry
+		}
+		// The sequence may be an alias used elsewhere too, so it is copied.
+		copied := *value
+		copied.Content = items
+		languages.Content[i] = &copied
+	}
+	return nil
+}
+
+// mergeMappings returns the settings of base overridden by the ones of node,
+// without extends. The template parameters of both are given as values, so
+// that tools extending this one can still override them.
+func mergeMappings(base, node *yaml.Node, values map[string]string) *yaml.Node {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: node.Line, Column: node.Column}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		key := base.Content[i].Value
+		if key != "extends" && key != "with" && mappingValue(node, key) == nil {
+			merged.Content = append(merged.Content, base.Content[i], base.Content[i+1])
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; key != "extends" && key != "with" {
+			merged.Content = append(merged.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	if len(values) > 0 {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		with := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, name := range names {
+			with.Content = append(with.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: values[name]})
+		}
+		merged.Content = append(merged.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "with"}, with)
+	}
+	return merged
+}
+
+func deref(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,204 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// sourceFixAllEfm is the kind of the "Fix all" code actions, so that the
+// clients fixing on save can ask for the ones of efm-langserver only.
+const sourceFixAllEfm = SourceFixAll + ".efm"
+
+// fixData identifies the fix-command of a code action until the client
+// resolves it.
+type fixData struct {
+	URI        DocumentURI `json:"uri"`
+	FixCommand string      `json:"fixCommand"`
+}
+
+func fixTitle(config Language) string {
+	name := config.LintSource
+	if name == "" {
+		name = strings.TrimSpace(config.FixCommand)
+		if i := strings.IndexAny(name, " \t"); i > 0 {
+			name = name[:i]
+		}
+	}
+	return "Fix all: " + name
+}
+
+// fixCodeActions lists the fix-commands of languageID without running them;
+// the edits are computed by codeAction/resolve. For the clients which can't
+// resolve the edit of a code action, the fix-commands run right away, only
+// for the requests asking for source.fixAll in only, as on save, rather than
+// whenever the code actions of a range are listed.
+func (h *langHandler) fixCodeActions(uri DocumentURI, languageID string, diagnostics []Diagnostic, only []CodeActionKind) []CodeAction {
+	if !h.resolveCodeActions && (len(only) == 0 || !kindAllowed(sourceFixAllEfm, only)) {
+		return nil
+	}
+	var actions []CodeAction
+	for _, config := range h.fixConfigs(uri, languageID) {
+		if !appliesToDiagnostics(config.AppliesToCodes, diagnostics) {
+			continue
+		}
+		action := CodeAction{
+			Title: fixTitle(config),
+			Kind:  sourceFixAllEfm,
+			Data:  fixData{URI: uri, FixCommand: config.FixCommand},
+		}
+		if !h.resolveCodeActions {
+			resolved, err := h.resolveCodeAction(&action)
+			if err != nil {
+				h.logger.Println(err)
+				continue
+			}
+			resolved.Data = nil
+			action = *resolved
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+func (h *langHandler) fixConfigs(uri DocumentURI, languageID string) []Language {
+	var configs []Language
+	// The aliases may have changed since the document was opened.
+	for _, cfg := range append(append([]Language(nil), h.configs[h.resolveLanguage(languageID)]...), h.globConfigs(uri)...) {
+		if cfg.FixCommand != "" {
+			configs = append(configs, cfg)
+		}
+	}
+	for _, cfg := range h.configs[wildcard] {
+		if cfg.FixCommand != "" && !excludesLanguage(cfg, languageID) {
+			configs = append(configs, cfg)
+		}
+	}
+	return configs
+}
+
+func (h *langHandler) resolveCodeAction(action *CodeAction) (*CodeAction, error) {
+	if action.Data == nil {
+		return action, nil
+	}
+	b, err := json.Marshal(action.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data fixData
+	if err := json.Unmarshal(b, &data); err != nil || data.FixCommand == "" {
+		return action, nil
+	}
+
+	edits, err := h.fix(data.URI, data.FixCommand)
+	if err != nil {
+		return nil, err
+	}
+	action.Edit = &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{data.URI: edits},
+	}
+	return action, nil
+}
+
+// fix runs the fix-command, which prints the fixed document, and returns
+// the edits applying it.
+func (h *langHandler) fix(uri DocumentURI, fixCommand string) ([]TextEdit, error) {
+	f, ok := h.files[uri]
+	if !ok {
+		return nil, fmt.Errorf("document not found: %v", uri)
+	}
+	fname, err := fromURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri: %v: %v", err, uri)
+	}
+	fname = filepath.ToSlash(fname)
+	if runtime.GOOS == "windows" {
+		fname = strings.ToLower(fname)
+	}
+
+	var config *Language
+	for _, cfg := range h.fixConfigs(uri, f.LanguageID) {
+		if cfg.FixCommand == fixCommand {
+			config = &cfg
+			break
+		}
+	}
+	if config == nil {
+		return nil, fmt.Errorf("fix command not found: %v", fixCommand)
+	}
+
+	command := config.FixCommand
+	if !config.FixStdin && !strings.Contains(command, "${INPUT}") {
+		command = command + " ${INPUT}"
+	}
+	command = h.placeholdersFor(config, fname, f, nil).replace(command)
+
+	cmd, err := h.newCommand(context.Background(), config, h.findRootPath(fname, *config), command)
+	if err != nil {
+		return nil, err
+	}
+	if config.FixStdin {
+		cmd.Stdin = strings.NewReader(f.Text)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	b, err := h.output(context.Background(), cmd)
+	// Fixers usually exit non-zero when problems remain after fixing, so
+	// only give up when nothing was printed.
+	if _, ok := err.(*exec.ExitError); err != nil && (!ok || len(b) == 0) {
+		return nil, fmt.Errorf("%v: %v: %v", command, err, stderr.String())
+	}
+	if h.loglevel >= 3 {
+		h.logger.Println(command+":", string(b))
+	}
+	if config.FixJQ != "" {
+		fixed, err := fixJQOutput(config.FixJQ, b)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", command, err)
+		}
+		if fixed == nil {
+			// Nothing to fix.
+			return nil, nil
+		}
+		b = []byte(*fixed)
+	}
+	fixed := restoreLineEndings(f.Text, strings.ReplaceAll(string(b), "\r\n", "\n"))
+	return ComputeEdits(uri, f.Text, fixed), nil
+}
+
+// fixJQOutput returns the fixed document the fix-jq filter extracts from the
+// JSON output b of a fix-command, or nil if it extracts none, e.g. eslint
+// printing no output for a document without anything to fix.
+func fixJQOutput(filter string, b []byte) (*string, error) {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+	iter := query.Run(v)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return nil, nil
+		}
+		switch v := v.(type) {
+		case error:
+			return nil, v
+		case string:
+			return &v, nil
+		case nil:
+		default:
+			return nil, fmt.Errorf("fix-jq: not a string: %v", jqString(v))
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package langserver
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestFixJQ(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.js"))
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"javascript": {{
+				FixCommand: `printf '%s' '[{"filePath":"foo.js","output":"let x = 1;\n"}]'`,
+				FixStdin:   true,
+				FixJQ:      ".[0].output",
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "javascript", Text: "var x = 1\n"},
+		},
+	}
+
+	edits, err := h.fix(uri, h.configs["javascript"][0].FixCommand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := applyEdits(h.files[uri].Text, edits); got != "let x = 1;\n" {
+		t.Fatalf("expected the output extracted by fix-jq, got %q", got)
+	}
+
+	h.configs["javascript"][0].FixCommand = `echo '[{"filePath":"foo.js"}]'`
+	edits, err = h.fix(uri, h.configs["javascript"][0].FixCommand)
+	if err != nil || len(edits) != 0 {
+		t.Fatalf("expected no edit without output, got %+v, %v", edits, err)
+	}
+}
+
+func TestFixLineEndings(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.js"))
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"javascript": {{FixCommand: "sed 's/var/let/'", FixStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "javascript", Text: "var x = 1\r\nvar y = 2\r\n"},
+		},
+	}
+
+	edits, err := h.fix(uri, h.configs["javascript"][0].FixCommand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := applyEdits(h.files[uri].Text, edits); got != "let x = 1\r\nlet y = 2\r\n" {
+		t.Fatalf("expected the CRLF line endings to be kept, got %q", got)
+	}
+}
+
+func TestCodeActionOnly(t *testing.T) {
+	uri := DocumentURI("file:///foo.js")
+	h := &langHandler{
+		logger:             log.New(io.Discard, "", 0),
+		resolveCodeActions: true,
+		configs: map[string][]Language{
+			"javascript": {{
+				FixCommand: "eslint --fix-dry-run",
+				Commands:   []Command{{Title: "Run", Command: "run"}},
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "javascript", Text: "var x = 1\n"},
+		},
+	}
+
+	actions, err := h.codeAction(uri, &CodeActionParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 {
+		t.Fatalf("expected the fix and the command, got %+v", actions)
+	}
+
+	for _, only := range []CodeActionKind{SourceFixAll, sourceFixAllEfm} {
+		params := &CodeActionParams{Context: CodeActionContext{Only: []CodeActionKind{only}}}
+		actions, err := h.codeAction(uri, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actions) != 1 || actions[0].(CodeAction).Kind != sourceFixAllEfm {
+			t.Fatalf("expected only the fix for %q, got %+v", only, actions)
+		}
+	}
+
+	params := &CodeActionParams{Context: CodeActionContext{Only: []CodeActionKind{QuickFix}}}
+	if actions, _ := h.codeAction(uri, params); len(actions) != 0 {
+		t.Fatalf("expected no quick fix, got %+v", actions)
+	}
+}
+
+func TestFixCodeActionsResolve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.js"))
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"javascript": {{FixCommand: "sed s/var/let/", FixStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "javascript", Text: "var x = 1\n"},
+		},
+	}
+
+	// A client resolving the edits gets the actions without them.
+	h.resolveCodeActions = true
+	actions := h.fixCodeActions(uri, "javascript", nil, nil)
+	if len(actions) != 1 || actions[0].Edit != nil || actions[0].Data == nil {
+		t.Fatalf("expected an action to resolve, got %+v", actions)
+	}
+	resolved, err := h.resolveCodeAction(&actions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits := resolved.Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+	if got := applyEdits(h.files[uri].Text, edits); got != "let x = 1\n" {
+		t.Fatalf("expected the fixed document, got %q", got)
+	}
+
+	// The other ones get the edits right away, when they ask for
+	// source.fixAll only, and no action otherwise, without running the
+	// fix-commands.
+	h.resolveCodeActions = false
+	actions = h.fixCodeActions(uri, "javascript", nil, []CodeActionKind{SourceFixAll})
+	if len(actions) != 1 || actions[0].Edit == nil || actions[0].Data != nil {
+		t.Fatalf("expected a resolved action, got %+v", actions)
+	}
+	ran := filepath.Join(t.TempDir(), "ran")
+	h.configs["javascript"][0].FixCommand = "touch " + ran + "; cat"
+	for _, only := range [][]CodeActionKind{nil, {QuickFix}} {
+		if actions := h.fixCodeActions(uri, "javascript", nil, only); len(actions) != 0 {
+			t.Fatalf("expected no action for %v, got %+v", only, actions)
+		}
+	}
+	if _, err := os.Stat(ran); err == nil {
+		t.Fatal("expected the fix-command not to run")
+	}
+}
+
+func TestFixCodeActionsHandler(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.js"))
+
+	// resolvedAction is a code action as the client decodes it.
+	type resolvedAction struct {
+		Title string         `json:"title"`
+		Kind  CodeActionKind `json:"kind"`
+		Edit  *struct {
+			Changes map[DocumentURI][]TextEdit `json:"changes"`
+		} `json:"edit"`
+		Data any `json:"data"`
+	}
+
+	for _, resolve := range []bool{true, false} {
+		server, client := net.Pipe()
+		config := NewConfig()
+		config.Logger = log.New(io.Discard, "", 0)
+		(*config.Languages)["javascript"] = []Language{{FixCommand: "sed s/var/let/", FixStdin: true}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go Serve(ctx, server, config)
+		conn := jsonrpc2.NewConn(
+			context.Background(),
+			jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+			jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+				return nil, nil
+			}))
+
+		params := InitializeParams{RootURI: toURI(base)}
+		if resolve {
+			params.Capabilities.TextDocument.CodeAction.ResolveSupport = &CodeActionResolveSupport{Properties: []string{"edit"}}
+		}
+		var result InitializeResult
+		if err := conn.Call(ctx, "initialize", params, &result); err != nil {
+			t.Fatal(err)
+		}
+		if options, ok := result.Capabilities.CodeActionProvider.(map[string]any); !ok || options["resolveProvider"] != true {
+			t.Fatalf("expected code actions to be resolved, got %+v", result.Capabilities.CodeActionProvider)
+		}
+		if err := conn.Notify(ctx, "textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, LanguageID: "javascript", Text: "var x = 1\n"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// A client fixing on save asks for source.fixAll only.
+		var actions []resolvedAction
+		if err := conn.Call(ctx, "textDocument/codeAction", CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Context:      CodeActionContext{Only: []CodeActionKind{SourceFixAll}},
+		}, &actions); err != nil {
+			t.Fatal(err)
+		}
+		if len(actions) != 1 || actions[0].Kind != sourceFixAllEfm || actions[0].Title != "Fix all: sed" {
+			t.Fatalf("expected the fix of sed, got %+v", actions)
+		}
+
+		action := actions[0]
+		if resolve {
+			if action.Edit != nil || action.Data == nil {
+				t.Fatalf("expected the fix to be resolved later, got %+v", action)
+			}
+			// The data comes back as the client got it.
+			action = resolvedAction{}
+			if err := conn.Call(ctx, "codeAction/resolve", actions[0], &action); err != nil {
+				t.Fatal(err)
+			}
+		} else if action.Data != nil {
+			t.Fatalf("expected no data for a client which can't resolve, got %+v", action)
+		}
+		if action.Edit == nil {
+			t.Fatalf("expected the edit of the fix, got %+v", action)
+		}
+		if got := applyEdits("var x = 1\n", action.Edit.Changes[uri]); got != "let x = 1\n" {
+			t.Fatalf("expected the fixed document, got %q", got)
+		}
+
+		if resolve {
+			// A fix-command gone since the action was listed can't be resolved.
+			actions[0].Data = map[string]any{"uri": uri, "fixCommand": "gone"}
+			if err := conn.Call(ctx, "codeAction/resolve", actions[0], &action); err == nil {
+				t.Fatal("expected a fix-command not configured to fail")
+			}
+			// Actions without data are returned as is.
+			var unchanged resolvedAction
+			if err := conn.Call(ctx, "codeAction/resolve", CodeAction{Title: "other"}, &unchanged); err != nil || unchanged.Title != "other" || unchanged.Edit != nil {
+				t.Fatalf("expected the action as is, got %+v, %v", unchanged, err)
+			}
+		}
+
+		conn.Close()
+		cancel()
+	}
+}
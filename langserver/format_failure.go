@@ -0,0 +1,141 @@
+package langserver
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/reviewdog/errorformat"
+)
+
+// formatFailure is the diagnostics of the formatters of a document which
+// failed, with the version of the document they failed on.
+type formatFailure struct {
+	version     int
+	diagnostics []Diagnostic
+}
+
+// formatFailures keeps the diagnostics of the formatters failing on the open
+// documents, published with the ones of their linters until the document
+// changes.
+type formatFailures struct {
+	mu       sync.Mutex
+	failures map[DocumentURI]formatFailure
+	// lints are the diagnostics of the last lint of each document, without
+	// the failures, to publish them again when the failures change.
+	lints map[DocumentURI]formatFailure
+}
+
+// set keeps the diagnostics of the formatters of uri failing on version, and
+// reports whether they changed, i.e. the document should be linted again to
+// publish them.
+func (p *formatFailures) set(uri DocumentURI, version int, diagnostics []Diagnostic) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last, ok := p.failures[uri]
+	if len(diagnostics) == 0 {
+		delete(p.failures, uri)
+		return ok && last.version == version
+	}
+	if p.failures == nil {
+		p.failures = make(map[DocumentURI]formatFailure)
+	}
+	p.failures[uri] = formatFailure{version: version, diagnostics: diagnostics}
+	return true
+}
+
+// get returns the diagnostics of the formatters of uri which failed on
+// version.
+func (p *formatFailures) get(uri DocumentURI, version int) []Diagnostic {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if last, ok := p.failures[uri]; ok && last.version == version {
+		return last.diagnostics
+	}
+	return nil
+}
+
+// linted keeps the diagnostics the lint of uri on version found.
+func (p *formatFailures) linted(uri DocumentURI, version int, diagnostics []Diagnostic) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lints == nil {
+		p.lints = make(map[DocumentURI]formatFailure)
+	}
+	p.lints[uri] = formatFailure{version: version, diagnostics: diagnostics}
+}
+
+// withLint returns the diagnostics of the last lint of uri with the ones of
+// its formatters which failed, if the lint was of version.
+func (p *formatFailures) withLint(uri DocumentURI, version int) ([]Diagnostic, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lint, ok := p.lints[uri]
+	if !ok || lint.version != version {
+		return nil, false
+	}
+	diagnostics := slices.Clip(lint.diagnostics)
+	if last, ok := p.failures[uri]; ok && last.version == version {
+		diagnostics = append(diagnostics, last.diagnostics...)
+	}
+	return diagnostics, true
+}
+
+// forget drops the diagnostics of the formatters of uri, e.g. when the
+// document is closed.
+func (p *formatFailures) forget(uri DocumentURI) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.failures, uri)
+	delete(p.lints, uri)
+}
+
+// formatFailureDiagnostics parses the error output of the formatter of
+// config which failed with format-lint-formats. Whatever file they name,
+// the errors are the ones of the document formatted. Output matching none
+// of the formats is reported on the first line.
+func formatFailureDiagnostics(config *Language, output string) []Diagnostic {
+	var source string
+	if fields := strings.Fields(config.FormatCommand); len(fields) > 0 {
+		source = filepath.Base(strings.Trim(fields[0], `"'`))
+	}
+
+	var diagnostics []Diagnostic
+	if efms, err := errorformat.NewErrorformat(config.FormatLintFormats); err == nil {
+		scanner := efms.NewScanner(strings.NewReader(output))
+		for scanner.Scan() {
+			entry := scanner.Entry()
+			if !entry.Valid {
+				continue
+			}
+			severity := 1
+			switch entry.Type {
+			case 'W', 'w':
+				severity = 2
+			case 'I', 'i':
+				severity = 3
+			case 'N', 'n':
+				severity = 4
+			}
+			start := Position{Line: max(entry.Lnum-1, 0), Character: max(entry.Col-1, 0)}
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    Range{Start: start, End: start},
+				Message:  entry.Text,
+				Severity: severity,
+				Source:   &source,
+			})
+		}
+	}
+	if len(diagnostics) == 0 {
+		message, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+		diagnostics = append(diagnostics, Diagnostic{
+			Message:  fmt.Sprintf("format failed: %s", cmp.Or(message, "no output")),
+			Severity: 1,
+			Source:   &source,
+		})
+	}
+	return diagnostics
+}
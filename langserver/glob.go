@@ -0,0 +1,99 @@
+package langserver
+
+import (
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// globPrefix starts the language keys matching documents by path instead of
+// by language id, e.g. "glob:*.blade.php".
+const globPrefix = "glob:"
+
+// matchGlob reports whether fname matches pattern. Patterns without a slash
+// match the file name, others the path relative to root.
+func matchGlob(pattern, fname, root string) bool {
+	fname = filepath.ToSlash(fname)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, filepath.Base(fname))
+		return ok
+	}
+	if root != "" {
+		if rel, err := filepath.Rel(root, filepath.FromSlash(fname)); err == nil {
+			fname = filepath.ToSlash(rel)
+		}
+	}
+	ok, _ := filepath.Match(strings.TrimPrefix(pattern, "/"), fname)
+	return ok
+}
+
+// caseInsensitivePaths tells that paths differing only in case are the same
+// file.
+var caseInsensitivePaths = runtime.GOOS == "windows"
+
+// matchPathGlob is matchGlob for fname and for its directories under root,
+// so that a pattern matching a directory, e.g. vendor, matches the files in
+// it too.
+func matchPathGlob(pattern, fname, root string) bool {
+	if caseInsensitivePaths {
+		// fname may have been lowered already, and root not.
+		pattern, fname, root = strings.ToLower(pattern), strings.ToLower(fname), strings.ToLower(root)
+	}
+	fname = filepath.Clean(filepath.FromSlash(fname))
+	root = filepath.Clean(filepath.FromSlash(root))
+	for path := fname; ; {
+		if matchGlob(pattern, path, root) {
+			return true
+		}
+		parent := filepath.Dir(path)
+		if parent == path || parent == root || !strings.HasPrefix(parent, root) {
+			return false
+		}
+		path = parent
+	}
+}
+
+// globKeys returns the sorted keys of the glob: languages matching the path
+// of uri.
+func (h *langHandler) globKeys(uri DocumentURI) []string {
+	fname, err := fromURI(uri)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for key := range h.configs {
+		if pattern, ok := strings.CutPrefix(key, globPrefix); ok && matchGlob(pattern, fname, h.rootPath) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// globConfigs returns the tools of the glob: languages matching the path of
+// uri, in the order of their keys.
+func (h *langHandler) globConfigs(uri DocumentURI) []Language {
+	var configs []Language
+	for _, key := range h.globKeys(uri) {
+		configs = append(configs, h.configs[key]...)
+	}
+	return configs
+}
+
+// configsFor returns the tools of the language of the document uri followed
+// by the ones of the glob: languages matching its path. ok is false if
+// there are none.
+func (h *langHandler) configsFor(uri DocumentURI) ([]Language, bool) {
+	f, ok := h.files[uri]
+	if !ok {
+		return nil, false
+	}
+	// The aliases may have changed since the document was opened.
+	cfgs, ok := h.configs[h.resolveLanguage(f.LanguageID)]
+	if globs := h.globConfigs(uri); len(globs) > 0 {
+		cfgs = append(cfgs[:len(cfgs):len(cfgs)], globs...)
+		ok = true
+	}
+	return cfgs, ok
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os/exec"
 	"path/filepath"
+	"slices"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -31,11 +32,26 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 		h.rootPath = filepath.Clean(rootPath)
 		h.addFolder(rootPath)
 	}
+	if params.InitializationOptions != nil {
+		h.profile = params.InitializationOptions.Profile
+		h.clientMinimumSeverity = params.InitializationOptions.MinimumSeverity
+	}
+	h.dynamicWatchedFiles = params.Capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration
+	// Clients pulling the diagnostics need to be asked to pull again once a
+	// lint finishes, or they'd miss its result.
+	h.pullDiagnostics = params.Capabilities.TextDocument.Diagnostic != nil &&
+		params.Capabilities.Workspace.Diagnostics.RefreshSupport
+	if support := params.Capabilities.TextDocument.CodeAction.ResolveSupport; support != nil {
+		h.resolveCodeActions = slices.Contains(support.Properties, "edit")
+	}
+	h.applyProfile()
+	h.importProjectTools(h.rootPath)
 
 	var completion *CompletionProvider
 	var hasCompletionCommand bool
 	var hasHoverCommand bool
 	var hasCodeActionCommand bool
+	var hasWorkspaceLinter bool
 	var hasSymbolCommand bool
 	var hasFormatCommand bool
 	var hasRangeFormatCommand bool
@@ -53,6 +69,9 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 	if len(h.commands) > 0 {
 		hasCodeActionCommand = true
 	}
+	if h.bufferWordsCompletion || h.pathCompletion != nil || h.snippetsDir != "" {
+		hasCompletionCommand = true
+	}
 	if h.provideDefinition {
 		if _, err = exec.LookPath("ctags"); err == nil {
 			hasDefinitionCommand = true
@@ -61,6 +80,17 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 
 	for _, config := range h.configs {
 		for _, v := range config {
+			if v.LintWorkspace && v.LintCommand != "" {
+				hasWorkspaceLinter = true
+			}
+			if v.FixCommand != "" || v.LintCodeURL != "" || v.SuppressCommentTemplates != nil {
+				hasCodeActionCommand = true
+			}
+			// The diagnostics of the linters may carry the fixes the tools
+			// suggest, offered as quick fixes.
+			if v.LintCommand != "" {
+				hasCodeActionCommand = true
+			}
 			if v.CompletionCommand != "" {
 				hasCompletionCommand = true
 			}
@@ -79,31 +109,89 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 		}
 	}
 
+	var codeLens *CodeLensOptions
+	var executeCommand *ExecuteCommandOptions
+	if h.taskRunners {
+		codeLens = &CodeLensOptions{}
+		executeCommand = &ExecuteCommandOptions{Commands: h.taskCommandIDs()}
+	}
+	if h.spellCheck != nil {
+		hasCodeActionCommand = true
+		if executeCommand == nil {
+			executeCommand = &ExecuteCommandOptions{}
+		}
+		executeCommand.Commands = append(executeCommand.Commands, spellAddWordCommand)
+	}
+	if hasFormatCommand {
+		if executeCommand == nil {
+			executeCommand = &ExecuteCommandOptions{}
+		}
+		executeCommand.Commands = append(executeCommand.Commands, formatChangedLinesCommand)
+	}
+
+	var codeAction any
+	if hasCodeActionCommand {
+		codeAction = &CodeActionOptions{ResolveProvider: true}
+		if executeCommand == nil {
+			executeCommand = &ExecuteCommandOptions{}
+		}
+		executeCommand.Commands = append(executeCommand.Commands, openCodeDocumentationCommand)
+	}
+
 	if hasCompletionCommand {
 		chars := []string{"."}
 		if len(h.triggerChars) > 0 {
 			chars = h.triggerChars
 		}
+		if h.pathCompletion != nil && !slices.Contains(chars, "/") {
+			chars = append(slices.Clip(chars), "/")
+		}
 		completion = &CompletionProvider{
 			TriggerCharacters: chars,
 		}
 	}
 
+	var diagnosticProvider *DiagnosticOptions
+	if h.pullDiagnostics {
+		// The diagnostics of the files workspace linters found are pulled
+		// with the ones of the workspace.
+		h.workspaceDiagnostics = hasWorkspaceLinter
+		diagnosticProvider = &DiagnosticOptions{
+			Identifier:            "efm-langserver",
+			InterFileDependencies: hasWorkspaceLinter,
+			WorkspaceDiagnostics:  hasWorkspaceLinter,
+		}
+	}
+
 	return InitializeResult{
 		Capabilities: ServerCapabilities{
-			TextDocumentSync:           TDSKFull,
+			TextDocumentSync: TextDocumentSyncOptions{
+				OpenClose: true,
+				Change:    TDSKFull,
+				// Whether the documents are formatted is format-on-save,
+				// which may change with the config.
+				WillSaveWaitUntil: hasFormatCommand,
+				Save:              &SaveOptions{},
+			},
 			DocumentFormattingProvider: hasFormatCommand,
 			RangeFormattingProvider:    hasRangeFormatCommand,
 			DocumentSymbolProvider:     hasSymbolCommand,
 			DefinitionProvider:         hasDefinitionCommand,
 			CompletionProvider:         completion,
 			HoverProvider:              hasHoverCommand,
-			CodeActionProvider:         hasCodeActionCommand,
+			CodeActionProvider:         codeAction,
+			CodeLensProvider:           codeLens,
+			ExecuteCommandProvider:     executeCommand,
+			DiagnosticProvider:         diagnosticProvider,
 			Workspace: &ServerCapabilitiesWorkspace{
 				WorkspaceFolders: WorkspaceFoldersServerCapabilities{
 					Supported:           true,
 					ChangeNotifications: true,
 				},
+				FileOperations: &FileOperationsServerCapabilities{
+					DidRename: &FileOperationRegistrationOptions{Filters: fileOperationFilters},
+					DidDelete: &FileOperationRegistrationOptions{Filters: fileOperationFilters},
+				},
 			},
 		},
 	}, nil
@@ -2,14 +2,54 @@ package langserver
 
 import (
 	"context"
+	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// shutdownTimeout is how long closing a session waits for the cancelled
+// linters to finish.
+const shutdownTimeout = 5 * time.Second
+
 func (h *langHandler) handleShutdown(_ context.Context, conn *jsonrpc2.Conn, _ *jsonrpc2.Request) (result any, err error) {
-	if h.lintTimer != nil {
-		h.lintTimer.Stop()
+	h.mu.Lock()
+	h.shutdown = true
+	h.mu.Unlock()
+
+	h.close()
+	return nil, nil
+}
+
+// handleExit ends the session. The connection is closed, so Serve returns.
+func (h *langHandler) handleExit(_ context.Context, conn *jsonrpc2.Conn, _ *jsonrpc2.Request) (result any, err error) {
+	h.close()
+	return nil, conn.Close()
+}
+
+func (h *langHandler) isShutdown() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.shutdown
+}
+
+// close releases everything the session started. It runs on shutdown and
+// when the client disconnects without shutting down.
+func (h *langHandler) close() {
+	h.closeOnce.Do(h.release)
+}
+
+func (h *langHandler) release() {
+	h.mu.Lock()
+	for _, p := range h.lintTimers {
+		p.timer.Stop()
 	}
+	h.mu.Unlock()
+	close(h.done)
+	h.waitLints()
+	if h.conn != nil {
+		h.flushDiagnostics()
+	}
+	h.saveDiagnosticsCache()
 
 	// Close all passthrough server connections
 	for key, server := range h.passthroughServers {
@@ -26,6 +66,24 @@ func (h *langHandler) handleShutdown(_ context.Context, conn *jsonrpc2.Conn, _ *
 		_ = server.cmd.Process.Kill()
 	}
 
-	close(h.request)
-	return nil, nil
+	h.containers.stopAll()
+	h.lintServers.stopAll()
+	h.sshHosts.closeAll()
+}
+
+// waitLints waits until the linter and the lints it cancelled have returned,
+// so that no lint command outlives the session.
+func (h *langHandler) waitLints() {
+	finished := make(chan struct{})
+	go func() {
+		<-h.linterStopped
+		h.lints.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(shutdownTimeout):
+		h.logger.Printf("lint commands still running after %v, shutting down anyway", shutdownTimeout)
+	}
 }
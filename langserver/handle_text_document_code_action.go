@@ -1,15 +1,13 @@
 package langserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -28,7 +26,23 @@ func (h *langHandler) handleTextDocumentCodeAction(_ context.Context, _ *jsonrpc
 }
 
 func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error) {
-	if len(params.Arguments) != 1 {
+	if params.Command == spellAddWordCommand {
+		return h.addSpellWord(params)
+	}
+	if params.Command == formatChangedLinesCommand {
+		return h.formatChangedLines(params)
+	}
+	if params.Command == openCodeDocumentationCommand {
+		return h.openCodeDocumentation(params)
+	}
+	if strings.HasPrefix(params.Command, taskCommandPrefix) {
+		return h.executeTask(params.Command)
+	}
+
+	// The optional second argument holds the values of the command
+	// parameters, the third one the range the code action was requested
+	// for.
+	if len(params.Arguments) < 1 || len(params.Arguments) > 3 {
 		return nil, fmt.Errorf("invalid command")
 	}
 
@@ -50,16 +64,20 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 	params.Command = tok[1]
 
 	var command *Command
+	// Commands run with the execution settings (shell, env, ...) of the
+	// language which defines them.
+	var config Language
 	f, ok := h.files[DocumentURI(tok[2])]
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
 	}
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs, ok := h.configsFor(DocumentURI(tok[2])); ok {
 	loop_lang:
 		for _, cfg := range cfgs {
 			for _, v := range cfg.Commands {
 				if tok[1] == v.Command {
 					command = &v
+					config = cfg
 					break loop_lang
 				}
 			}
@@ -70,9 +88,13 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 			if cfgs, ok := h.configs[wildcard]; ok {
 			loop_wild:
 				for _, cfg := range cfgs {
+					if excludesLanguage(cfg, f.LanguageID) {
+						continue
+					}
 					for _, v := range cfg.Commands {
 						if tok[1] == v.Command {
 							command = &v
+							config = cfg
 							break loop_wild
 						}
 					}
@@ -92,70 +114,117 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 		}
 	}
 
-	var cmd *exec.Cmd
-	var args []string
-	var output string
 	if !strings.HasPrefix(command.Command, ":") {
-		if runtime.GOOS == "windows" {
-			args = []string{"/c", replaceCommandInputFilename(command.Command, fname, h.rootPath)}
-			for _, v := range command.Arguments {
-				arg := fmt.Sprint(v)
-				tmp := replaceCommandInputFilename(arg, fname, h.rootPath)
-				if tmp != arg && fname == "" {
-					h.logger.Println("invalid uri")
-					return nil, fmt.Errorf("invalid uri: %v", uri)
-				}
-				arg = tmp
-				args = append(args, arg)
-			}
-			cmd = exec.Command("cmd", args...)
-		} else {
-			args = []string{"-c", replaceCommandInputFilename(command.Command, fname, h.rootPath)}
-			for _, v := range command.Arguments {
-				arg := fmt.Sprint(v)
-				tmp := replaceCommandInputFilename(arg, fname, h.rootPath)
-				if tmp != arg && fname == "" {
-					h.logger.Println("invalid uri")
-					return nil, fmt.Errorf("invalid uri: %v", uri)
-				}
-				arg = tmp
-				args = append(args, arg)
-			}
-			cmd = exec.Command("sh", args...)
+		var extra []any
+		if len(params.Arguments) > 1 {
+			extra = params.Arguments[1:2]
 		}
-		cmd.Dir = h.rootPath
-		cmd.Env = os.Environ()
-		b, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, err
-		}
-		if h.loglevel >= 3 {
-			h.logger.Print(strings.Join(cmd.Args, " ")+":", string(b))
+		values, missing := commandParameterValues(command.Parameters, extra)
+		rng := commandRange(params.Arguments)
+		if len(missing) > 0 {
+			// Prompting calls the client, which can't answer before this
+			// request returns.
+			go h.promptAndRunCommand(*command, config, fname, uri, values, missing, rng)
+			return "OK", nil
 		}
-		output = string(b)
-	} else {
-		if command.Command == ":reload-config" {
-			config, err := LoadConfig(h.filename)
-			if err != nil {
-				return nil, err
-			}
-			h.commands = *config.Commands
-			h.configs = *config.Languages
-			h.rootMarkers = *config.RootMarkers
-			h.triggerChars = config.TriggerChars
-			h.loglevel = config.LogLevel
-			h.lintDebounce = time.Duration(config.LintDebounce)
+		return h.runCommand(command, &config, fname, uri, values, rng, params.WorkDoneToken)
+	}
+
+	switch command.Command {
+	case ":reload-config":
+		if err := h.reloadConfig(); err != nil {
+			return nil, err
 		}
 		h.logMessage(LogInfo, "Reloaded configuration file")
-		output = "OK"
+	case ":toggle-lint-only-changed-lines":
+		// The linter reads it while linting.
+		h.mu.Lock()
+		h.lintOnlyChangedLines = !h.lintOnlyChangedLines
+		onlyChangedLines := h.lintOnlyChangedLines
+		h.mu.Unlock()
+		if onlyChangedLines {
+			h.logMessage(LogInfo, "Showing diagnostics on changed lines only")
+		} else {
+			h.logMessage(LogInfo, "Showing all diagnostics")
+		}
+		h.lintRequest(DocumentURI(tok[2]), eventTypeChange)
 	}
+	return "OK", nil
+}
+
+// runCommand runs a configured command with the values of its parameters
+// and delivers its output according to the output-mode of the command.
+// Output lines are streamed to the client while the command runs, as
+// progress of token if the client passed one.
+func (h *langHandler) runCommand(command *Command, config *Language, fname, uri string, values map[string]string, rng *Range, token any) (any, error) {
+	shell := h.shellFor(config)
+	var pos *Position
+	if rng != nil {
+		pos = &rng.Start
+	}
+	p := h.placeholdersFor(config, fname, h.files[DocumentURI(uri)], pos)
+	p.shell = ""
 
-	return output, nil
+	// The arguments are passed as is, only the command line is quoted.
+	var args []string
+	for _, v := range command.Arguments {
+		arg := fmt.Sprint(v)
+		tmp := p.replace(arg)
+		if tmp != arg && fname == "" {
+			h.logger.Println("invalid uri")
+			return "", fmt.Errorf("invalid uri: %v", uri)
+		}
+		args = append(args, substituteParameters(tmp, values, nil))
+	}
+	p.shell = shell
+	line := substituteParameters(p.replace(command.Command), values, func(s string) string { return quoteArg(shell, s) })
+	cmd, err := h.newCommand(context.Background(), config, h.rootPath, line, args...)
+	if err != nil {
+		return "", err
+	}
+	var selection string
+	if command.OutputMode == outputModeReplaceSelection {
+		f, ok := h.files[DocumentURI(uri)]
+		if !ok || rng == nil {
+			return nil, fmt.Errorf("no selection for command: %v", command.Title)
+		}
+		selection = textInRange(f.Text, *rng)
+		cmd.Stdin = strings.NewReader(selection)
+	}
+	stream := h.newOutputStream(command.Title, token)
+	var stderr bytes.Buffer
+	cmd.Stdout = stream
+	cmd.Stderr = stream
+	if stdoutOnly(command) {
+		cmd.Stderr = &stderr
+	}
+	err = cmd.Run()
+	stream.close(err)
+	if stderr.Len() > 0 {
+		h.logger.Printf("%v: %s", command.Title, stderr.String())
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return "", err
+	}
+	if h.loglevel >= 3 {
+		h.logger.Print(strings.Join(cmd.Args, " ")+":", stream.String())
+	}
+	output := stream.String()
+	if command.OutputMode == outputModeReplaceSelection && !strings.HasSuffix(selection, "\n") {
+		output = strings.TrimSuffix(output, "\n")
+	}
+	return h.commandOutput(command, DocumentURI(uri), rng, output)
 }
 
-func filterCommands(uri DocumentURI, commands []Command) []Command {
+func filterCommands(uri DocumentURI, rng Range, diagnostics []Diagnostic, commands []Command) []Command {
 	results := []Command{}
 	for _, v := range commands {
+		if !appliesToDiagnostics(v.AppliesToCodes, diagnostics) {
+			continue
+		}
 		if v.OS != "" {
 			found := false
 			for _, os := range strings.FieldsFunc(v.OS, func(r rune) bool { return r == ',' }) {
@@ -167,33 +236,114 @@ func filterCommands(uri DocumentURI, commands []Command) []Command {
 				continue
 			}
 		}
+		arguments := []any{string(uri)}
+		if v.OutputMode == outputModeInsertAtCursor || v.OutputMode == outputModeReplaceSelection || usesCursor(v) {
+			arguments = append(arguments, map[string]any{}, rng)
+		}
 		results = append(results, Command{
 			Title:     v.Title,
 			Command:   fmt.Sprintf("efm-langserver\t%s\t%s", v.Command, string(uri)),
-			Arguments: []any{string(uri)},
+			Arguments: arguments,
 		})
 	}
 	return results
 }
 
-func (h *langHandler) codeAction(uri DocumentURI, _ *CodeActionParams) ([]Command, error) {
+// appliesToDiagnostics reports whether an action limited to the diagnostic
+// codes matching the patterns of codes is relevant for diagnostics. Actions
+// without codes always are.
+func appliesToDiagnostics(codes []string, diagnostics []Diagnostic) bool {
+	if len(codes) == 0 {
+		return true
+	}
+	for _, d := range diagnostics {
+		if d.Code == nil {
+			continue
+		}
+		for _, pattern := range codes {
+			if ok, _ := filepath.Match(pattern, *d.Code); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (h *langHandler) codeAction(uri DocumentURI, params *CodeActionParams) ([]any, error) {
 	f, ok := h.files[uri]
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
 	}
 
+	var rng Range
+	var diagnostics []Diagnostic
+	var only []CodeActionKind
+	if params != nil {
+		rng = params.Range
+		diagnostics = params.Context.Diagnostics
+		only = params.Context.Only
+	}
 	commands := []Command{}
-	commands = append(commands, filterCommands(uri, h.commands)...)
+	commands = append(commands, filterCommands(uri, rng, diagnostics, h.commands)...)
 
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs, ok := h.configsFor(uri); ok {
 		for _, cfg := range cfgs {
-			commands = append(commands, filterCommands(uri, cfg.Commands)...)
+			commands = append(commands, filterCommands(uri, rng, diagnostics, cfg.Commands)...)
 		}
 	}
 	if cfgs, ok := h.configs[wildcard]; ok {
 		for _, cfg := range cfgs {
-			commands = append(commands, filterCommands(uri, cfg.Commands)...)
+			if excludesLanguage(cfg, f.LanguageID) {
+				continue
+			}
+			commands = append(commands, filterCommands(uri, rng, diagnostics, cfg.Commands)...)
+		}
+	}
+
+	actions := []any{}
+	for _, v := range h.spellCodeActions(uri, params) {
+		actions = append(actions, v)
+	}
+	for _, v := range h.fixCodeActions(uri, f.LanguageID, diagnostics, only) {
+		actions = append(actions, v)
+	}
+	for _, v := range dataFixCodeActions(uri, diagnostics) {
+		actions = append(actions, v)
+	}
+	for _, v := range h.suppressCodeActions(uri, diagnostics) {
+		actions = append(actions, v)
+	}
+	for _, v := range codeDocumentationActions(diagnostics) {
+		actions = append(actions, v)
+	}
+	for _, v := range commands {
+		actions = append(actions, v)
+	}
+	if len(only) > 0 {
+		actions = onlyCodeActionKinds(actions, only)
+	}
+	return actions, nil
+}
+
+// onlyCodeActionKinds keeps the code actions of the kinds only or of their
+// subkinds, e.g. source.fixAll.efm for source.fixAll, and drops the commands,
+// which have no kind.
+func onlyCodeActionKinds(actions []any, only []CodeActionKind) []any {
+	kept := []any{}
+	for _, v := range actions {
+		if action, ok := v.(CodeAction); ok && kindAllowed(action.Kind, only) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// kindAllowed reports whether kind is one of only or a subkind of one.
+func kindAllowed(kind CodeActionKind, only []CodeActionKind) bool {
+	for _, k := range only {
+		if kind == k || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
 		}
 	}
-	return commands, nil
+	return false
 }
@@ -0,0 +1,73 @@
+package langserver
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunCommandStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	var logs bytes.Buffer
+	h := &langHandler{logger: log.New(&logs, "", 0), rootPath: t.TempDir()}
+	command := &Command{Title: "greet", Command: "echo hello; echo 'deprecated flag' >&2"}
+
+	// The result of the command is its combined output.
+	output, err := h.runCommand(command, &Language{}, "", "", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "hello\ndeprecated flag\n" {
+		t.Fatalf("expected the combined output, got %q", output)
+	}
+
+	// The edits are the standard output only.
+	command.OutputMode = outputModeWorkspaceEdit
+	command.Command = "echo 'deprecated flag' >&2"
+	if output, err := h.runCommand(command, &Language{}, "", "", nil, nil, nil); err != nil || output != "OK" {
+		t.Fatalf("expected no edit from the standard error, got %v, %v", output, err)
+	}
+	if !strings.Contains(logs.String(), "deprecated flag") {
+		t.Fatalf("expected the standard error to be logged, got %q", logs.String())
+	}
+
+	h.logger = log.New(io.Discard, "", 0)
+	command.Command = "echo 'no such file' >&2; exit 1"
+	if _, err := h.runCommand(command, &Language{}, "", "", nil, nil, nil); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Fatalf("expected the error to tell the standard error, got %v", err)
+	}
+}
+
+func TestAppliesToDiagnostics(t *testing.T) {
+	code := func(s string) Diagnostic { return Diagnostic{Code: &s} }
+	tests := []struct {
+		name        string
+		codes       []string
+		diagnostics []Diagnostic
+		want        bool
+	}{
+		{"no codes", nil, nil, true},
+		{"matching code", []string{"E501"}, []Diagnostic{code("W291"), code("E501")}, true},
+		{"matching pattern", []string{"no-*"}, []Diagnostic{code("no-var")}, true},
+		{"other code", []string{"E501"}, []Diagnostic{code("W291")}, false},
+		{"without code", []string{"E501"}, []Diagnostic{{Message: "line too long"}}, false},
+		{"no diagnostics", []string{"E501"}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := appliesToDiagnostics(tt.codes, tt.diagnostics); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// The commands limited to codes are offered for those only.
+	commands := []Command{{Title: "Fix E501", Command: "fix", AppliesToCodes: []string{"E501"}}, {Title: "Run", Command: "run"}}
+	got := filterCommands("file:///foo.py", Range{}, []Diagnostic{code("W291")}, commands)
+	if len(got) != 1 || got[0].Title != "Run" {
+		t.Fatalf("expected only the command without codes, got %+v", got)
+	}
+}
@@ -6,10 +6,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/sourcegraph/jsonrpc2"
@@ -28,7 +27,7 @@ func (h *langHandler) handleTextDocumentCompletion(_ context.Context, _ *jsonrpc
 	return h.completion(params.TextDocument.URI, &params)
 }
 
-func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]CompletionItem, error) {
+func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) (*CompletionList, error) {
 	f, ok := h.files[uri]
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
@@ -44,8 +43,17 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 		fname = strings.ToLower(fname)
 	}
 
+	// Paths typed in strings are completed whatever the tools.
+	if h.pathCompletionEnabled(f.LanguageID) {
+		if items := h.completePath(fname, f, params.Position); len(items) > 0 {
+			return &CompletionList{Items: items}, nil
+		}
+	}
+
+	snippets := h.snippetItems(f.LanguageID)
+
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs, ok := h.configsFor(uri); ok {
 		for _, cfg := range cfgs {
 			if cfg.CompletionCommand != "" {
 				configs = append(configs, cfg)
@@ -54,6 +62,9 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 	}
 	if cfgs, ok := h.configs[wildcard]; ok {
 		for _, cfg := range cfgs {
+			if excludesLanguage(cfg, f.LanguageID) {
+				continue
+			}
 			if cfg.CompletionCommand != "" {
 				configs = append(configs, cfg)
 			}
@@ -61,6 +72,13 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 	}
 
 	if len(configs) == 0 {
+		_, _, prefix := completionPrefix(f.Text, params.Position)
+		if h.bufferWordsCompletion {
+			return &CompletionList{Items: slices.Concat(h.filterCompletion(snippets, prefix), h.bufferWords(f.LanguageID, prefix))}, nil
+		}
+		if len(snippets) > 0 {
+			return &CompletionList{Items: h.filterCompletion(snippets, prefix)}, nil
+		}
 		if h.loglevel >= 1 {
 			h.logger.Printf("completion for LanguageID not supported: %v", f.LanguageID)
 		}
@@ -72,6 +90,17 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 			return nil, nil
 		}
 
+		// Typing further into the same word filters the items the command
+		// returned for it, unless they were incomplete.
+		start, before, prefix := completionPrefix(f.Text, params.Position)
+		key := completionCacheKey{command: config.CompletionCommand, line: params.Position.Line, start: start, before: before}
+		if items, ok := h.completionCache.get(uri, key, prefix); ok {
+			if h.completionFilter == "" {
+				items = filterCompletionItems(items, prefix)
+			}
+			return &CompletionList{Items: h.filterCompletion(slices.Concat(items, snippets), prefix)}, nil
+		}
+
 		command := config.CompletionCommand
 
 		if strings.Contains(command, "${POSITION}") {
@@ -80,20 +109,16 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 		if !config.CompletionStdin && !strings.Contains(command, "${INPUT}") {
 			command = command + " ${INPUT}"
 		}
-		command = replaceCommandInputFilename(command, fname, h.rootPath)
+		command = h.placeholdersFor(&config, fname, f, &params.Position).replace(command)
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
-		} else {
-			cmd = exec.Command("sh", "-c", command)
+		cmd, err := h.newCommand(context.Background(), &config, h.findRootPath(fname, config), command)
+		if err != nil {
+			return nil, err
 		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.CompletionStdin {
 			cmd.Stdin = strings.NewReader(f.Text)
 		}
-		b, err := cmd.CombinedOutput()
+		b, err := h.combinedOutput(context.Background(), cmd)
 		if err != nil {
 			h.logger.Printf("completion command failed: %v", err)
 			return nil, fmt.Errorf("completion command failed: %v: %v", err, string(b))
@@ -102,14 +127,32 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 			h.logger.Println(command+":", string(b))
 		}
 
-		result := []CompletionItem{}
-		scanner := bufio.NewScanner(bytes.NewReader(b))
-		for scanner.Scan() {
-			result = append(result, CompletionItem{
-				Label:      scanner.Text(),
-				InsertText: scanner.Text(),
-			})
+		result := &CompletionList{Items: []CompletionItem{}}
+		if config.CompletionJQ != "" {
+			result, err = jqCompletionItems(config.CompletionJQ, b)
+			if err != nil {
+				return nil, fmt.Errorf("invalid completion output: %v", err)
+			}
+		} else if config.CompletionFormat == completionFormatJSON {
+			result, err = jsonCompletionItems(b)
+			if err != nil {
+				return nil, fmt.Errorf("invalid completion output: %v", err)
+			}
+		} else {
+			scanner := bufio.NewScanner(bytes.NewReader(b))
+			for scanner.Scan() {
+				result.Items = append(result.Items, CompletionItem{
+					Label:      scanner.Text(),
+					InsertText: scanner.Text(),
+				})
+			}
+		}
+		if result.IsIncomplete {
+			h.completionCache.forget(uri)
+		} else {
+			h.completionCache.set(uri, key, prefix, result.Items)
 		}
+		result.Items = h.filterCompletion(slices.Concat(result.Items, snippets), prefix)
 		return result, nil
 	}
 
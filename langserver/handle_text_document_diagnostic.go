@@ -0,0 +1,166 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *langHandler) handleTextDocumentDiagnostic(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params DocumentDiagnosticParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	return h.pulled.report(params.TextDocument.URI, params.PreviousResultID), nil
+}
+
+// pulledReport is the diagnostics of a URI kept for the clients pulling
+// them, with their result ID.
+type pulledReport struct {
+	resultID    string
+	diagnostics []Diagnostic
+	// version is the version of the document they are the ones of, if open.
+	version *int
+}
+
+// pulledDiagnostics keeps the diagnostics of the open documents for the
+// clients pulling them with textDocument/diagnostic, and the ones of the
+// other files found by workspace linters for workspace/diagnostic.
+type pulledDiagnostics struct {
+	mu      sync.Mutex
+	reports map[DocumentURI]pulledReport
+	// changed is closed when a report changes.
+	changed chan struct{}
+}
+
+// changes returns a channel closed at the next change of a report.
+func (p *pulledDiagnostics) changes() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return p.changed
+}
+
+// notify wakes up the ones waiting for changes. p.mu must be held.
+func (p *pulledDiagnostics) notify() {
+	if p.changed != nil {
+		close(p.changed)
+		p.changed = nil
+	}
+}
+
+// set keeps the diagnostics of uri, and reports whether they changed, i.e.
+// the client should pull them again.
+func (p *pulledDiagnostics) set(uri DocumentURI, diagnostics []Diagnostic, version *int) bool {
+	b, err := json.Marshal(diagnostics)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256(b)
+	resultID := hex.EncodeToString(digest[:])
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reports == nil {
+		p.reports = make(map[DocumentURI]pulledReport)
+	}
+	last, ok := p.reports[uri]
+	p.reports[uri] = pulledReport{resultID: resultID, diagnostics: diagnostics, version: version}
+	if ok && last.resultID == resultID {
+		return false
+	}
+	p.notify()
+	return true
+}
+
+// report returns the report of the diagnostics of uri, unchanged if they
+// are the ones of previousResultID. A document not linted yet has none;
+// the client is asked to pull again once it is.
+func (p *pulledDiagnostics) report(uri DocumentURI, previousResultID string) any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.reports[uri]
+	if !ok {
+		return FullDocumentDiagnosticReport{Kind: DiagnosticReportFull, Items: []Diagnostic{}}
+	}
+	if previousResultID != "" && previousResultID == r.resultID {
+		return UnchangedDocumentDiagnosticReport{Kind: DiagnosticReportUnchanged, ResultID: r.resultID}
+	}
+	return FullDocumentDiagnosticReport{Kind: DiagnosticReportFull, ResultID: r.resultID, Items: r.diagnostics}
+}
+
+// workspaceReports returns the reports of the diagnostics of all the URIs,
+// sorted by URI, unchanged for the ones whose result ID the client sent,
+// followed by empty ones for the URIs the client sent forgotten since.
+func (p *pulledDiagnostics) workspaceReports(previous []PreviousResultID) []any {
+	previousResultIDs := make(map[DocumentURI]string, len(previous))
+	for _, r := range previous {
+		previousResultIDs[r.URI] = r.Value
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	uris := make([]string, 0, len(p.reports))
+	for uri := range p.reports {
+		uris = append(uris, string(uri))
+	}
+	sort.Strings(uris)
+
+	reports := make([]any, 0, len(uris))
+	for _, uri := range uris {
+		r := p.reports[DocumentURI(uri)]
+		if previousResultIDs[DocumentURI(uri)] == r.resultID {
+			reports = append(reports, WorkspaceUnchangedDocumentDiagnosticReport{
+				UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+					Kind:     DiagnosticReportUnchanged,
+					ResultID: r.resultID,
+				},
+				URI:     DocumentURI(uri),
+				Version: r.version,
+			})
+			continue
+		}
+		reports = append(reports, WorkspaceFullDocumentDiagnosticReport{
+			FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
+				Kind:     DiagnosticReportFull,
+				ResultID: r.resultID,
+				Items:    r.diagnostics,
+			},
+			URI:     DocumentURI(uri),
+			Version: r.version,
+		})
+	}
+	// The diagnostics the client has of the URIs forgotten since are
+	// cleared.
+	for _, r := range previous {
+		if _, ok := p.reports[r.URI]; !ok {
+			reports = append(reports, WorkspaceFullDocumentDiagnosticReport{
+				FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{Kind: DiagnosticReportFull, Items: []Diagnostic{}},
+				URI:                          r.URI,
+			})
+		}
+	}
+	return reports
+}
+
+// forget drops the diagnostics of uri, e.g. when the document is closed.
+func (p *pulledDiagnostics) forget(uri DocumentURI) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.reports[uri]; ok {
+		delete(p.reports, uri)
+		p.notify()
+	}
+}
@@ -0,0 +1,142 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestPulledDiagnosticsReport(t *testing.T) {
+	var p pulledDiagnostics
+	uri := DocumentURI("file:///foo")
+
+	full, ok := p.report(uri, "").(FullDocumentDiagnosticReport)
+	if !ok || full.ResultID != "" || full.Items == nil || len(full.Items) != 0 {
+		t.Fatalf("expected an empty full report before the first lint, got %#v", p.report(uri, ""))
+	}
+
+	diagnostics := []Diagnostic{{Message: "unused variable", Severity: 2}}
+	if !p.set(uri, diagnostics, nil) {
+		t.Fatal("expected the first diagnostics to be changed")
+	}
+	if p.set(uri, []Diagnostic{{Message: "unused variable", Severity: 2}}, nil) {
+		t.Fatal("expected the same diagnostics to be unchanged")
+	}
+
+	full, ok = p.report(uri, "").(FullDocumentDiagnosticReport)
+	if !ok || full.Kind != DiagnosticReportFull || full.ResultID == "" || len(full.Items) != 1 {
+		t.Fatalf("expected a full report of the diagnostics, got %#v", p.report(uri, ""))
+	}
+	unchanged, ok := p.report(uri, full.ResultID).(UnchangedDocumentDiagnosticReport)
+	if !ok || unchanged.Kind != DiagnosticReportUnchanged || unchanged.ResultID != full.ResultID {
+		t.Fatalf("expected an unchanged report, got %#v", p.report(uri, full.ResultID))
+	}
+
+	if !p.set(uri, []Diagnostic{}, nil) {
+		t.Fatal("expected the cleared diagnostics to be changed")
+	}
+	if _, ok := p.report(uri, full.ResultID).(FullDocumentDiagnosticReport); !ok {
+		t.Fatal("expected a full report once the diagnostics changed")
+	}
+
+	p.forget(uri)
+	if full, _ := p.report(uri, "").(FullDocumentDiagnosticReport); full.ResultID != "" {
+		t.Fatalf("expected no result ID once forgotten, got %q", full.ResultID)
+	}
+}
+
+func TestPulledDiagnosticsWorkspaceReports(t *testing.T) {
+	var p pulledDiagnostics
+	version := 3
+	p.set("file:///foo", []Diagnostic{{Message: "unused variable"}}, &version)
+	p.set("file:///bar", []Diagnostic{}, nil)
+
+	reports := p.workspaceReports(nil)
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+	bar, ok := reports[0].(WorkspaceFullDocumentDiagnosticReport)
+	if !ok || bar.URI != "file:///bar" || bar.Version != nil {
+		t.Fatalf("expected a full report of bar first, got %#v", reports[0])
+	}
+	foo, ok := reports[1].(WorkspaceFullDocumentDiagnosticReport)
+	if !ok || foo.URI != "file:///foo" || foo.Version == nil || *foo.Version != 3 || len(foo.Items) != 1 {
+		t.Fatalf("expected a full report of foo, got %#v", reports[1])
+	}
+
+	reports = p.workspaceReports([]PreviousResultID{{URI: "file:///foo", Value: foo.ResultID}})
+	if _, ok := reports[0].(WorkspaceFullDocumentDiagnosticReport); !ok {
+		t.Fatalf("expected a full report of bar, got %#v", reports[0])
+	}
+	unchanged, ok := reports[1].(WorkspaceUnchangedDocumentDiagnosticReport)
+	if !ok || unchanged.URI != "file:///foo" || unchanged.ResultID != foo.ResultID {
+		t.Fatalf("expected an unchanged report of foo, got %#v", reports[1])
+	}
+}
+
+func TestWorkspaceDiagnosticHeld(t *testing.T) {
+	h := &langHandler{done: make(chan struct{})}
+	h.pulled.set("file:///foo", []Diagnostic{{Message: "unused variable"}}, nil)
+	foo := h.pulled.workspaceReports(nil)[0].(WorkspaceFullDocumentDiagnosticReport)
+
+	pull := func(previous []PreviousResultID) (any, error) {
+		params, _ := json.Marshal(&WorkspaceDiagnosticParams{PreviousResultIDs: previous})
+		raw := json.RawMessage(params)
+		return h.handleWorkspaceDiagnostic(context.Background(), nil, &jsonrpc2.Request{Params: &raw})
+	}
+	previous := []PreviousResultID{{URI: "file:///foo", Value: foo.ResultID}}
+	result, err := pull(previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deferred, ok := result.(deferredResult)
+	if !ok {
+		t.Fatalf("the request should be held while nothing changed, got %#v", result)
+	}
+
+	done := make(chan any, 1)
+	go func() {
+		result, _ := deferred(context.Background())
+		done <- result
+	}()
+	select {
+	case result := <-done:
+		t.Fatalf("the request should wait for a change, got %#v", result)
+	case <-time.After(50 * time.Millisecond):
+	}
+	h.pulled.set("file:///foo", []Diagnostic{{Message: "unused variable"}}, nil)
+	h.pulled.set("file:///foo", []Diagnostic{}, nil)
+	select {
+	case result := <-done:
+		report := result.(WorkspaceDiagnosticReport)
+		if full, ok := report.Items[0].(WorkspaceFullDocumentDiagnosticReport); !ok || len(full.Items) != 0 {
+			t.Fatalf("expected the changed report of foo, got %#v", report.Items)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request should be answered once the diagnostics changed")
+	}
+
+	// A canceled request stops waiting.
+	foo = h.pulled.workspaceReports(nil)[0].(WorkspaceFullDocumentDiagnosticReport)
+	result, _ = pull([]PreviousResultID{{URI: "file:///foo", Value: foo.ResultID}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := result.(deferredResult)(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the request to be canceled, got %v", err)
+	}
+
+	// The diagnostics of a forgotten URI are cleared at once.
+	h.pulled.forget("file:///foo")
+	result, _ = pull([]PreviousResultID{{URI: "file:///foo", Value: foo.ResultID}})
+	report, ok := result.(WorkspaceDiagnosticReport)
+	if !ok || len(report.Items) != 1 {
+		t.Fatalf("expected a report clearing foo, got %#v", result)
+	}
+	if full := report.Items[0].(WorkspaceFullDocumentDiagnosticReport); full.URI != "file:///foo" || len(full.Items) != 0 {
+		t.Fatalf("expected a report clearing foo, got %#v", full)
+	}
+}
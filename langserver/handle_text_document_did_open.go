@@ -17,7 +17,14 @@ func (h *langHandler) handleTextDocumentDidOpen(_ context.Context, _ *jsonrpc2.C
 		return nil, err
 	}
 
-	if err := h.openFile(params.TextDocument.URI, params.TextDocument.LanguageID, params.TextDocument.Version); err != nil {
+	h.importRootConfig(params.TextDocument.URI)
+	languageID := params.TextDocument.LanguageID
+	if languageID == "" || languageID == "plaintext" {
+		if lang := h.shebangLanguage(params.TextDocument.Text); lang != "" {
+			languageID = lang
+		}
+	}
+	if err := h.openFile(params.TextDocument.URI, languageID, params.TextDocument.Version); err != nil {
 		return nil, err
 	}
 	if err := h.updateFile(params.TextDocument.URI, params.TextDocument.Text, &params.TextDocument.Version, eventTypeOpen); err != nil {
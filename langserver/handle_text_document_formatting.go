@@ -2,14 +2,15 @@ package langserver
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,7 +28,7 @@ func (h *langHandler) handleTextDocumentFormatting(_ context.Context, _ *jsonrpc
 	}
 
 	rng := Range{Position{-1, -1}, Position{-1, -1}}
-	return h.rangeFormatRequest(params.TextDocument.URI, rng, params.Options)
+	return h.formatReply(params.TextDocument.URI, rng, params.Options)
 }
 
 func (h *langHandler) handleTextDocumentRangeFormatting(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
@@ -40,32 +41,123 @@ func (h *langHandler) handleTextDocumentRangeFormatting(_ context.Context, _ *js
 		return nil, err
 	}
 
-	return h.rangeFormatRequest(params.TextDocument.URI, params.Range, params.Options)
+	return h.formatReply(params.TextDocument.URI, params.Range, params.Options)
 }
 
-func (h *langHandler) rangeFormatRequest(uri DocumentURI, rng Range, opt FormattingOptions) ([]TextEdit, error) {
-	if h.formatTimer != nil {
+// The values of format-chain, what happens when a formatter of the chain
+// fails: the next ones run on the output of the previous ones, the chain
+// stops with the output so far, or is aborted without any edit.
+const (
+	formatChainContinue = "continue"
+	formatChainStop     = "stop"
+	formatChainAbort    = "abort"
+)
+
+// The values of format-range-fallback, what a range formatting request does
+// with the formatters without format-can-range: format the whole document and
+// keep only the edits in the range, or leave the document as is.
+const (
+	formatRangeFallbackFull = "full"
+	formatRangeFallbackNone = "none"
+)
+
+// formatChangedLinesCommand formats the document whose URI is its argument,
+// applying only the changes to the lines changed since the last git commit.
+const formatChangedLinesCommand = "efm-langserver.format.changedLines"
+
+// formatReply is the result of a formatting request. While the debounce
+// delay of uri runs, the format is deferred, so that waiting for it doesn't
+// block the other requests of the connection. The document is copied
+// before, as the request sees it, since the handler goroutine may change it
+// meanwhile.
+func (h *langHandler) formatReply(uri DocumentURI, rng Range, opt FormattingOptions) (any, error) {
+	doc, ok := h.snapshot(uri)
+	if !ok {
+		return nil, fmt.Errorf("document not found: %v", uri)
+	}
+	if h.formatWait(uri) > 0 {
+		return deferredResult(func(ctx context.Context) (any, error) {
+			select {
+			case <-time.After(h.formatWait(uri)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return h.rangeFormatRequest(uri, &doc, rng, opt)
+		}), nil
+	}
+	return h.rangeFormatRequest(uri, &doc, rng, opt)
+}
+
+// formatWait is how long the debounce delay of uri still runs.
+func (h *langHandler) formatWait(uri DocumentURI) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Until(h.formatTimes[uri].Add(h.formatDebounce))
+}
+
+// rangeFormatRequest formats uri, waiting for the debounce delay since the
+// last format of the same document first, instead of dropping the request.
+// Every document has its own delay, so that formatting one doesn't hold back
+// the format of another.
+func (h *langHandler) rangeFormatRequest(uri DocumentURI, doc *documentSnapshot, rng Range, opt FormattingOptions) ([]TextEdit, error) {
+	if wait := h.formatWait(uri); wait > 0 {
 		if h.loglevel >= 4 {
-			h.logger.Printf("format debounced: %v", h.formatDebounce)
+			h.logger.Printf("format debounced: %v", wait)
 		}
-		return []TextEdit{}, nil
+		time.Sleep(wait)
 	}
 
 	h.mu.Lock()
-	h.formatTimer = time.AfterFunc(h.formatDebounce, func() {
-		h.mu.Lock()
-		h.formatTimer = nil
-		h.mu.Unlock()
-	})
+	if h.formatTimes == nil {
+		h.formatTimes = make(map[DocumentURI]time.Time)
+	}
+	h.formatTimes[uri] = time.Now()
 	h.mu.Unlock()
-	return h.rangeFormatting(uri, rng, opt)
+	return h.formatDocument(uri, doc, rng, opt, h.formatOnlyChangedLines)
 }
 
 func (h *langHandler) rangeFormatting(uri DocumentURI, rng Range, options FormattingOptions) ([]TextEdit, error) {
-	f, ok := h.files[uri]
+	return h.formatting(uri, rng, options, h.formatOnlyChangedLines)
+}
+
+// formatChangedLines runs formatChangedLinesCommand, applying the edits
+// with workspace/applyEdit.
+func (h *langHandler) formatChangedLines(params *ExecuteCommandParams) (any, error) {
+	if len(params.Arguments) != 1 {
+		return nil, fmt.Errorf("invalid command")
+	}
+	uri, ok := params.Arguments[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid argument")
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	edits, err := h.formatting(DocumentURI(uri), rng, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(edits) > 0 {
+		go h.applyEdit("Format changed lines", WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{DocumentURI(uri): edits},
+		})
+	}
+	return "OK", nil
+}
+
+// formatting formats uri, or the range rng of it, with onlyChangedLines
+// applying only the changes to the lines changed since the last git commit.
+func (h *langHandler) formatting(uri DocumentURI, rng Range, options FormattingOptions, onlyChangedLines bool) ([]TextEdit, error) {
+	doc, ok := h.snapshot(uri)
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
 	}
+	return h.formatDocument(uri, &doc, rng, options, onlyChangedLines)
+}
+
+// formatDocument formats doc, the copy of uri taken by snapshot, like
+// formatting.
+func (h *langHandler) formatDocument(uri DocumentURI, doc *documentSnapshot, rng Range, options FormattingOptions, onlyChangedLines bool) ([]TextEdit, error) {
+	f := &doc.file
 
 	fname, err := fromURI(uri)
 	if err != nil {
@@ -76,22 +168,38 @@ func (h *langHandler) rangeFormatting(uri DocumentURI, rng Range, options Format
 		fname = strings.ToLower(fname)
 	}
 
+	if h.formatExcludes(fname, h.formatExclude, h.rootPath) {
+		return nil, nil
+	}
+	isRange := rng.Start.Line != -1
+
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
-		for _, cfg := range cfgs {
-			if cfg.FormatCommand != "" {
-				if dir := matchRootPath(fname, cfg.RootMarkers); dir == "" && cfg.RequireMarker {
-					continue
-				}
-				configs = append(configs, cfg)
+	for _, cfg := range doc.configs {
+		if cfg.FormatCommand != "" {
+			if dir := matchRootPath(fname, cfg.markers()); dir == "" && cfg.RequireMarker {
+				continue
+			}
+			if h.formatExcludes(fname, cfg.FormatExclude, h.findRootPath(fname, cfg)) {
+				continue
+			}
+			if isRange && !cfg.FormatCanRange && h.formatRangeFallback == formatRangeFallbackNone {
+				continue
 			}
+			configs = append(configs, cfg)
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
-		for _, cfg := range cfgs {
-			if cfg.FormatCommand != "" {
-				configs = append(configs, cfg)
+	for _, cfg := range doc.wildcard {
+		if excludesLanguage(cfg, f.LanguageID) {
+			continue
+		}
+		if cfg.FormatCommand != "" {
+			if h.formatExcludes(fname, cfg.FormatExclude, h.findRootPath(fname, cfg)) {
+				continue
+			}
+			if isRange && !cfg.FormatCanRange && h.formatRangeFallback == formatRangeFallbackNone {
+				continue
 			}
+			configs = append(configs, cfg)
 		}
 	}
 
@@ -101,13 +209,36 @@ func (h *langHandler) rangeFormatting(uri DocumentURI, rng Range, options Format
 		}
 		return nil, nil
 	}
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].FormatPriority > configs[j].FormatPriority
+	})
+	if h.formatFirstOnly {
+		configs = configs[:1]
+	}
 
 	originalText := f.Text
 	text := originalText
 	formatted := false
+	var pos *Position
+	if isRange {
+		pos = &rng.Start
+	}
+	// wholeDocument is whether a formatter formatted the whole document for
+	// a range formatting request, whose edits are kept in the range with
+	// format-range-fallback full.
+	wholeDocument := false
 
+	// failed is the error of the last formatter failing, which ends the
+	// chain unless format-chain is continue.
+	var failed error
+	// failures is the diagnostics of the formatters failing with
+	// format-lint-formats.
+	var failures []Diagnostic
 Configs:
 	for _, config := range configs {
+		if failed != nil && (h.formatChain == formatChainStop || h.formatChain == formatChainAbort) {
+			break
+		}
 		if config.FormatCommand == "" {
 			continue
 		}
@@ -121,22 +252,21 @@ Configs:
 			// This synchronizes the disk with any unsaved changes, preventing data loss.
 			if err := os.WriteFile(fname, []byte(text), 0644); err != nil {
 				h.logger.Printf("Error writing buffer to disk for in-place format: %v", err)
+				failed = fmt.Errorf("%s: %v", config.FormatCommand, err)
 				continue Configs
 			}
 
 			// 2. FORMAT IN-PLACE: The formatter command will now modify the up-to-date file on disk.
-			command := replaceCommandInputFilename(config.FormatCommand, fname, h.rootPath)
+			command := h.placeholdersFor(&config, fname, f, pos).replace(config.FormatCommand)
 
-			var cmd *exec.Cmd
-			if runtime.GOOS == "windows" {
-				cmd = exec.Command("cmd", "/c", command)
-			} else {
-				cmd = exec.Command("sh", "-c", command)
+			cmd, err := h.newCommand(context.Background(), &config, h.findRootPath(fname, config), command)
+			if err != nil {
+				h.logger.Println(command+":", err)
+				failed = fmt.Errorf("%s: %v", config.FormatCommand, err)
+				continue Configs
 			}
-			cmd.Dir = h.findRootPath(fname, config)
-			cmd.Env = append(os.Environ(), config.Env...)
 
-			if output, err := cmd.CombinedOutput(); err != nil {
+			if output, err := h.combinedOutput(context.Background(), cmd); err != nil {
 				h.logger.Printf("in-place formatter exited with error: %v, output: %s", err, string(output))
 			}
 
@@ -144,6 +274,7 @@ Configs:
 			b, err = os.ReadFile(fname)
 			if err != nil {
 				h.logger.Printf("Error reading file back from disk: %v", err)
+				failed = fmt.Errorf("%s: %v", config.FormatCommand, err)
 				continue Configs
 			}
 		} else {
@@ -152,7 +283,7 @@ Configs:
 			if !config.FormatStdin && !strings.Contains(command, "${INPUT}") {
 				command = command + " ${INPUT}"
 			}
-			command = replaceCommandInputFilename(command, fname, h.rootPath)
+			command = h.placeholdersFor(&config, fname, f, pos).replace(command)
 
 			// Formatting Options
 			for placeholder, value := range options {
@@ -162,6 +293,7 @@ Configs:
 				nre2, nerr2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=!%s}`, placeholder))
 				if err != nil || err2 != nil || nerr != nil || nerr2 != nil {
 					h.logger.Println(command+":", err)
+					failed = fmt.Errorf("%s: %v", config.FormatCommand, cmp.Or(err, err2, nerr, nerr2))
 					continue Configs
 				}
 				switch v := value.(type) {
@@ -179,7 +311,7 @@ Configs:
 					}
 				}
 			}
-			if rng.Start.Line != -1 {
+			if isRange {
 				charStart := convertRowColToIndex(text, rng.Start.Line, rng.Start.Character)
 				charEnd := convertRowColToIndex(text, rng.End.Line, rng.End.Character)
 				rangeOptions := map[string]int{
@@ -190,6 +322,7 @@ Configs:
 					re2, err2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=%s}`, placeholder))
 					if err != nil || err2 != nil {
 						h.logger.Println(command+":", err)
+						failed = fmt.Errorf("%s: %v", config.FormatCommand, cmp.Or(err, err2))
 						continue Configs
 					}
 					command = re.ReplaceAllString(command, fmt.Sprintf("$1 %d", value))
@@ -199,42 +332,92 @@ Configs:
 			re := regexp.MustCompile(`\${[^}]*}`)
 			command = re.ReplaceAllString(command, "")
 
-			var cmd *exec.Cmd
-			if runtime.GOOS == "windows" {
-				cmd = exec.Command("cmd", "/c", command)
-			} else {
-				cmd = exec.Command("sh", "-c", command)
+			cmd, err := h.newCommand(context.Background(), &config, h.findRootPath(fname, config), command)
+			if err != nil {
+				h.logger.Println(command+":", err)
+				failed = fmt.Errorf("%s: %v", config.FormatCommand, err)
+				continue Configs
 			}
-			cmd.Dir = h.findRootPath(fname, config)
-			cmd.Env = append(os.Environ(), config.Env...)
 			if config.FormatStdin {
 				cmd.Stdin = strings.NewReader(text)
 			}
 
 			var buf bytes.Buffer
 			cmd.Stderr = &buf
-			var err error
-			b, err = cmd.Output()
+			b, err = h.output(context.Background(), cmd)
 			if err != nil {
 				h.logger.Println(command+":", buf.String())
-				continue
+				failed = fmt.Errorf("%s: %v", config.FormatCommand, err)
+				if len(config.FormatLintFormats) > 0 {
+					failures = append(failures, formatFailureDiagnostics(&config, buf.String())...)
+				}
+				continue Configs
 			}
 		}
 
 		formatted = true
+		if isRange && !config.FormatCanRange {
+			wholeDocument = true
+		}
 
 		if h.loglevel >= 3 {
 			h.logger.Println(config.FormatCommand+":", string(b))
 		}
-		text = strings.Replace(string(b), "\r", "", -1)
+		text = strings.ReplaceAll(string(b), "\r\n", "\n")
 	}
 
+	// The failures are published with the diagnostics of the linters, and
+	// the ones of a former format cleared.
+	if h.formatFailures.set(uri, f.Version, failures) {
+		if diagnostics, ok := h.formatFailures.withLint(uri, f.Version); ok {
+			h.publishDiagnostics(context.Background(), uri, diagnostics, f.Version, false)
+		} else {
+			// The document changed since its last lint, the next one adds
+			// them.
+			h.lintRequest(uri, eventTypeChange)
+		}
+	}
+
+	if failed != nil && h.formatChain == formatChainAbort {
+		// The output of the formatters before the failing one is dropped
+		// rather than applied half formatted.
+		return nil, fmt.Errorf("format aborted: %v", failed)
+	}
 	if formatted {
 		if h.loglevel >= 3 {
 			h.logger.Println("format succeeded")
 		}
+		text = restoreLineEndings(originalText, text)
+		if wholeDocument && h.formatRangeFallback == formatRangeFallbackFull {
+			text = changedLinesIn(originalText, text, func(line int) bool {
+				return line >= rng.Start.Line && line <= rng.End.Line
+			})
+		}
+		if onlyChangedLines {
+			// A file not committed yet is formatted as a whole.
+			if head, ok := h.gitHeads.content(fname); ok {
+				changed := changedLines(head, originalText)
+				text = changedLinesIn(originalText, text, func(line int) bool {
+					return changed[line]
+				})
+			}
+		}
 		return ComputeEdits(uri, originalText, text), nil
 	}
 
 	return nil, fmt.Errorf("format for LanguageID not supported: %v", f.LanguageID)
 }
+
+// formatExcludes reports whether a format-exclude glob matches fname, then
+// left as is.
+func (h *langHandler) formatExcludes(fname string, patterns []string, root string) bool {
+	for _, pattern := range patterns {
+		if matchPathGlob(pattern, fname, root) {
+			if h.loglevel >= 1 {
+				h.logger.Printf("not formatting %s excluded by format-exclude %q", fname, pattern)
+			}
+			return true
+		}
+	}
+	return false
+}
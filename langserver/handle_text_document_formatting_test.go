@@ -1,10 +1,16 @@
 package langserver
 
 import (
+	"context"
+	"io"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFormattingRequireRootMatcher(t *testing.T) {
@@ -36,7 +42,8 @@ func TestFormattingRequireRootMatcher(t *testing.T) {
 	}
 
 	rng := Range{Position{-1, -1}, Position{-1, -1}}
-	d, err := h.rangeFormatRequest(uri, rng, FormattingOptions{})
+	doc, _ := h.snapshot(uri)
+	d, err := h.rangeFormatRequest(uri, &doc, rng, FormattingOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,3 +51,468 @@ func TestFormattingRequireRootMatcher(t *testing.T) {
 		t.Fatal("text edits should be zero as we have no root marker for the language but require one", d)
 	}
 }
+
+func TestWillSaveWaitUntil(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{FormatCommand: "tr a-z A-Z", FormatStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "abnormal!\n"},
+		},
+	}
+
+	edits, err := h.willSaveWaitUntil(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 0 {
+		t.Fatalf("expected no edits without format-on-save, got %v", edits)
+	}
+
+	h.formatOnSave = true
+	edits, err = h.willSaveWaitUntil(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) == 0 {
+		t.Fatal("expected the edits of the formatter with format-on-save")
+	}
+}
+
+func TestFormatDebouncePerDocument(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	a, b := toURI(filepath.Join(base, "a")), toURI(filepath.Join(base, "b"))
+
+	h := &langHandler{
+		logger:         log.New(log.Writer(), "", log.LstdFlags),
+		rootPath:       base,
+		formatDebounce: 200 * time.Millisecond,
+		configs: map[string][]Language{
+			"vim": {{FormatCommand: "tr a-z A-Z", FormatStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			a: {LanguageID: "vim", Text: "abnormal!\n"},
+			b: {LanguageID: "vim", Text: "normal!\n"},
+		},
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	start := time.Now()
+	for _, uri := range []DocumentURI{a, b, a} {
+		doc, _ := h.snapshot(uri)
+		edits, err := h.rangeFormatRequest(uri, &doc, rng, FormattingOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edits) == 0 {
+			t.Fatalf("expected the edits of the formatter for %v", uri)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < h.formatDebounce {
+		t.Fatalf("expected the second format of a to wait for the debounce delay, took %v", elapsed)
+	}
+}
+
+func TestDeferredFormatSnapshot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo"))
+	other := toURI(filepath.Join(base, "bar"))
+
+	h := &langHandler{
+		logger:         log.New(log.Writer(), "", log.LstdFlags),
+		rootPath:       base,
+		formatDebounce: 100 * time.Millisecond,
+		lintDebounce:   time.Hour,
+		formatTimes:    map[DocumentURI]time.Time{uri: time.Now()},
+		configs: map[string][]Language{
+			"vim": {{FormatCommand: "tr a-z A-Z", FormatStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "abnormal!\n"},
+		},
+	}
+	defer h.cancelLintRequest(uri)
+
+	result, err := h.formatReply(uri, Range{Position{-1, -1}, Position{-1, -1}}, FormattingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deferred, ok := result.(deferredResult)
+	if !ok {
+		t.Fatalf("expected the format to be deferred during the debounce delay, got %T", result)
+	}
+	type reply struct {
+		result any
+		err    error
+	}
+	done := make(chan reply)
+	go func() {
+		result, err := deferred(context.Background())
+		done <- reply{result, err}
+	}()
+
+	// The handler goroutine goes on with the notifications meanwhile.
+	version := 2
+	if err := h.updateFile(uri, "changed\n", &version, eventTypeChange); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.openFile(other, "vim", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.closeFile(other); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	edits, _ := r.result.([]TextEdit)
+	if len(edits) != 1 || edits[0].NewText != "ABNORMAL" {
+		t.Fatalf("expected the document as the request saw it to be formatted, got %v", edits)
+	}
+}
+
+func TestFormatPriority(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo"))
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{FormatCommand: "sed s/$/1/", FormatStdin: true},
+				{FormatCommand: "sed s/$/2/", FormatStdin: true, FormatPriority: 1},
+			},
+			wildcard: {{FormatCommand: "sed s/$/3/", FormatStdin: true, FormatPriority: 2}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "x\n"},
+		},
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	for _, tt := range []struct {
+		firstOnly bool
+		want      string
+	}{
+		{false, "x321"},
+		{true, "x3"},
+	} {
+		h.formatFirstOnly = tt.firstOnly
+		edits, err := h.rangeFormatting(uri, rng, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text strings.Builder
+		for _, edit := range edits {
+			text.WriteString(edit.NewText)
+		}
+		if strings.TrimSpace(text.String()) != tt.want {
+			t.Fatalf("format-first-only %v: expected %q but got %+v", tt.firstOnly, tt.want, edits)
+		}
+	}
+}
+
+func TestFormatChain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo"))
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{FormatCommand: "sed s/$/1/", FormatStdin: true},
+				{FormatCommand: "false", FormatStdin: true},
+				{FormatCommand: "sed s/$/3/", FormatStdin: true},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "x\n"},
+		},
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	for _, tt := range []struct {
+		chain string
+		want  string
+	}{
+		{"", "x13"},
+		{formatChainContinue, "x13"},
+		{formatChainStop, "x1"},
+	} {
+		h.formatChain = tt.chain
+		edits, err := h.rangeFormatting(uri, rng, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text strings.Builder
+		for _, edit := range edits {
+			text.WriteString(edit.NewText)
+		}
+		if strings.TrimSpace(text.String()) != tt.want {
+			t.Fatalf("format-chain %q: expected %q but got %+v", tt.chain, tt.want, edits)
+		}
+	}
+
+	h.formatChain = formatChainAbort
+	if edits, err := h.rangeFormatting(uri, rng, nil); err == nil || len(edits) != 0 {
+		t.Fatalf("expected the aborted chain to fail without edits, got %+v", edits)
+	}
+}
+
+func TestFormatExclude(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	vendored := toURI(filepath.Join(base, "vendor", "lib", "foo.js"))
+	minified := toURI(filepath.Join(base, "foo.min.js"))
+	uri := toURI(filepath.Join(base, "foo.js"))
+
+	h := &langHandler{
+		logger:        log.New(io.Discard, "", 0),
+		rootPath:      base,
+		formatExclude: []string{"vendor"},
+		configs: map[string][]Language{
+			"javascript": {{FormatCommand: "tr a-z A-Z", FormatStdin: true, FormatExclude: []string{"*.min.js"}}},
+		},
+		files: map[DocumentURI]*File{
+			vendored: {LanguageID: "javascript", Text: "x\n"},
+			minified: {LanguageID: "javascript", Text: "x\n"},
+			uri:      {LanguageID: "javascript", Text: "x\n"},
+		},
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	for _, excluded := range []DocumentURI{vendored, minified} {
+		if edits, err := h.rangeFormatting(excluded, rng, nil); err != nil || len(edits) != 0 {
+			t.Fatalf("expected %v to be left as is, got %+v, %v", excluded, edits, err)
+		}
+	}
+	if edits, err := h.rangeFormatting(uri, rng, nil); err != nil || len(edits) == 0 {
+		t.Fatalf("expected %v to be formatted, got %+v, %v", uri, edits, err)
+	}
+}
+
+func TestMatchPathGlobCase(t *testing.T) {
+	defer func(v bool) { caseInsensitivePaths = v }(caseInsensitivePaths)
+	caseInsensitivePaths = true
+
+	// The path of the document is lowered on Windows, the root isn't.
+	root := "/Users/Me/Project"
+	for _, pattern := range []string{"vendor", "Vendor", "vendor/lib/*.js", "*.JS"} {
+		if !matchPathGlob(pattern, "/users/me/project/vendor/lib/foo.js", root) {
+			t.Errorf("expected %q to match whatever the case", pattern)
+		}
+	}
+	if matchPathGlob("vendor", "/users/me/project/src/foo.js", root) {
+		t.Error("expected a file out of vendor not to match")
+	}
+}
+
+func TestRestoreLineEndings(t *testing.T) {
+	tests := []struct {
+		original, text, want string
+	}{
+		{"a\r\nb\r\n", "A\nB\n", "A\r\nB\r\n"},
+		{"a\r\nb", "A\nB\n", "A\r\nB"},
+		{"a\nb\n", "A\nB", "A\nB\n"},
+		{"a\r\nb\nc\r\n", "A\nB\nC\n", "A\r\nB\r\nC\r\n"},
+		{"", "A\n", "A"},
+	}
+	for _, tt := range tests {
+		if got := restoreLineEndings(tt.original, tt.text); got != tt.want {
+			t.Errorf("restoreLineEndings(%q, %q) = %q, want %q", tt.original, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRangeFallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo"))
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{FormatCommand: "tr a-z A-Z", FormatStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nb\nc\n"},
+		},
+	}
+
+	rng := Range{Position{1, 0}, Position{1, 1}}
+	for _, tt := range []struct {
+		fallback string
+		want     string
+	}{
+		{"", "A\nB\nC\n"},
+		{formatRangeFallbackFull, "a\nB\nc\n"},
+		{formatRangeFallbackNone, "a\nb\nc\n"},
+	} {
+		h.formatRangeFallback = tt.fallback
+		edits, err := h.rangeFormatting(uri, rng, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := applyEdits(h.files[uri].Text, edits); got != tt.want {
+			t.Fatalf("format-range-fallback %q: expected %q but got %q", tt.fallback, tt.want, got)
+		}
+	}
+}
+
+func TestFormatOnlyChangedLines(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "foo")
+	if err := os.WriteFile(fname, []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "foo"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "foo"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	uri := toURI(fname)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: dir,
+		configs: map[string][]Language{
+			"vim": {{FormatCommand: "tr a-z A-Z", FormatStdin: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nbb\nc\n"},
+		},
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	for _, tt := range []struct {
+		onlyChangedLines bool
+		want             string
+	}{
+		{false, "A\nBB\nC\n"},
+		{true, "a\nBB\nc\n"},
+	} {
+		edits, err := h.formatting(uri, rng, nil, tt.onlyChangedLines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := applyEdits(h.files[uri].Text, edits); got != tt.want {
+			t.Fatalf("only changed lines %v: expected %q but got %q", tt.onlyChangedLines, tt.want, got)
+		}
+	}
+}
+
+func TestFormatFailureDiagnostics(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo"))
+
+	failing := Language{
+		FormatCommand:     `echo "stdin:2:3: unexpected token" >&2; exit 1`,
+		FormatStdin:       true,
+		FormatLintFormats: []string{"%f:%l:%c: %m"},
+	}
+	h := &langHandler{
+		logger:       log.New(io.Discard, "", 0),
+		rootPath:     base,
+		lintDebounce: time.Hour,
+		configs: map[string][]Language{
+			"vim": {failing},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "x\nx)\n", Version: 1},
+		},
+	}
+	linted := []Diagnostic{{Message: "from the linter"}}
+	h.formatFailures.linted(uri, 1, linted)
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	if _, err := h.rangeFormatting(uri, rng, nil); err == nil {
+		t.Fatal("expected the format to fail")
+	}
+	d := h.formatFailures.get(uri, 1)
+	if len(d) != 1 || d[0].Message != "unexpected token" || d[0].Range.Start != (Position{Line: 1, Character: 2}) {
+		t.Fatalf("expected the error of the formatter as a diagnostic, got %+v", d)
+	}
+	// They are published with the result of the last lint, rather than by
+	// running the lint-on-save tools.
+	if len(h.lintTimers) != 0 {
+		t.Fatalf("expected no lint to be requested, got %v", h.lintTimers)
+	}
+	if d, ok := h.formatFailures.withLint(uri, 1); !ok || len(d) != 2 || d[0].Message != "from the linter" {
+		t.Fatalf("expected the diagnostics of the lint with the failure, got %+v", d)
+	}
+	if d := h.formatFailures.get(uri, 2); d != nil {
+		t.Fatalf("expected no diagnostic once the document changed, got %+v", d)
+	}
+
+	h.configs["vim"] = []Language{{FormatCommand: "cat", FormatStdin: true}}
+	if _, err := h.rangeFormatting(uri, rng, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := h.formatFailures.get(uri, 1); d != nil {
+		t.Fatalf("expected the diagnostics cleared by a format succeeding, got %+v", d)
+	}
+
+	// Without a lint of the version, the document is linted again as if it
+	// changed.
+	h.files[uri].Version = 2
+	h.configs["vim"] = []Language{failing}
+	if _, err := h.rangeFormatting(uri, rng, nil); err == nil {
+		t.Fatal("expected the format to fail")
+	}
+	if p, ok := h.lintTimers[uri]; !ok || p.eventType != eventTypeChange {
+		t.Fatalf("expected a lint of the change to be requested, got %v", h.lintTimers)
+	}
+	h.cancelLintRequest(uri)
+
+	failing.FormatLintFormats = []string{"%l:%m"}
+	d = formatFailureDiagnostics(&failing, "Syntax error\n")
+	if len(d) != 1 || d[0].Message != "format failed: Syntax error" || d[0].Range.Start.Line != 0 {
+		t.Fatalf("expected the output matching no format on the first line, got %+v", d)
+	}
+}
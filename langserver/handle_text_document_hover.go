@@ -4,8 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -54,7 +52,7 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 	}
 
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs, ok := h.configsFor(uri); ok {
 		for _, cfg := range cfgs {
 			if cfg.HoverCommand != "" {
 				configs = append(configs, cfg)
@@ -63,6 +61,9 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 	}
 	if cfgs, ok := h.configs[wildcard]; ok {
 		for _, cfg := range cfgs {
+			if excludesLanguage(cfg, f.LanguageID) {
+				continue
+			}
 			if cfg.HoverCommand != "" {
 				configs = append(configs, cfg)
 			}
@@ -108,19 +109,16 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 			command = command + " ${INPUT}"
 		}
 		command = strings.Replace(command, "${INPUT}", word, -1)
+		command = h.placeholdersFor(&config, fname, f, &params.Position).replace(command)
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
-		} else {
-			cmd = exec.Command("sh", "-c", command)
+		cmd, err := h.newCommand(context.Background(), &config, h.findRootPath(fname, config), command)
+		if err != nil {
+			return nil, err
 		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.HoverStdin {
 			cmd.Stdin = strings.NewReader(word)
 		}
-		b, err := cmd.CombinedOutput()
+		b, err := h.combinedOutput(context.Background(), cmd)
 		if err != nil {
 			return nil, err
 		}
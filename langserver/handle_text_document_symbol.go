@@ -6,8 +6,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -75,7 +73,7 @@ func (h *langHandler) symbol(uri DocumentURI) ([]SymbolInformation, error) {
 	}
 
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs, ok := h.configsFor(uri); ok {
 		for _, cfg := range cfgs {
 			if cfg.SymbolCommand != "" {
 				configs = append(configs, cfg)
@@ -84,6 +82,9 @@ func (h *langHandler) symbol(uri DocumentURI) ([]SymbolInformation, error) {
 	}
 	if cfgs, ok := h.configs[wildcard]; ok {
 		for _, cfg := range cfgs {
+			if excludesLanguage(cfg, f.LanguageID) {
+				continue
+			}
 			if cfg.SymbolCommand != "" {
 				configs = append(configs, cfg)
 			}
@@ -105,7 +106,7 @@ func (h *langHandler) symbol(uri DocumentURI) ([]SymbolInformation, error) {
 		if !config.SymbolStdin && !strings.Contains(command, "${INPUT}") {
 			command = command + " ${INPUT}"
 		}
-		command = replaceCommandInputFilename(command, fname, h.rootPath)
+		command = h.placeholdersFor(&config, fname, f, nil).replace(command)
 
 		formats := config.LintFormats
 		if len(formats) == 0 {
@@ -118,18 +119,15 @@ func (h *langHandler) symbol(uri DocumentURI) ([]SymbolInformation, error) {
 			return nil, fmt.Errorf("invalid error-format: %v", config.SymbolFormats)
 		}
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
-		} else {
-			cmd = exec.Command("sh", "-c", command)
+		cmd, err := h.newCommand(context.Background(), &config, h.findRootPath(fname, config), command)
+		if err != nil {
+			h.logger.Println(command+":", err)
+			continue
 		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.SymbolStdin {
 			cmd.Stdin = strings.NewReader(f.Text)
 		}
-		b, err := cmd.CombinedOutput()
+		b, err := h.combinedOutput(context.Background(), cmd)
 		if err != nil {
 			continue
 		}
@@ -0,0 +1,33 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *langHandler) handleTextDocumentWillSaveWaitUntil(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params WillSaveTextDocumentParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	return h.willSaveWaitUntil(params.TextDocument.URI)
+}
+
+// willSaveWaitUntil returns the edits formatting the document uri before it
+// is saved, with format-on-save. It isn't debounced like the formatting
+// requests, so that no save is left unformatted. The client sends no
+// formatting options, so the placeholders of the options are left out.
+func (h *langHandler) willSaveWaitUntil(uri DocumentURI) ([]TextEdit, error) {
+	if !h.formatOnSave {
+		return nil, nil
+	}
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	return h.rangeFormatting(uri, rng, nil)
+}
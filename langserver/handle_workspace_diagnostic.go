@@ -0,0 +1,79 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// workspaceDiagnosticBatch is the number of reports sent in each partial
+// result of workspace/diagnostic.
+const workspaceDiagnosticBatch = 100
+
+func (h *langHandler) handleWorkspaceDiagnostic(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params WorkspaceDiagnosticParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	reports := h.pulled.workspaceReports(params.PreviousResultIDs)
+	if len(params.PreviousResultIDs) > 0 && allUnchanged(reports) {
+		// Nothing changed since the last pull: the request is held until
+		// something does, so that the client doesn't poll.
+		return deferredResult(func(ctx context.Context) (any, error) {
+			for {
+				changed := h.pulled.changes()
+				reports := h.pulled.workspaceReports(params.PreviousResultIDs)
+				if !allUnchanged(reports) {
+					return h.workspaceDiagnosticReport(ctx, conn, &params, reports)
+				}
+				select {
+				case <-changed:
+				case <-h.done:
+					return WorkspaceDiagnosticReport{Items: reports}, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		}), nil
+	}
+	return h.workspaceDiagnosticReport(ctx, conn, &params, reports)
+}
+
+// allUnchanged reports whether the reports are all unchanged ones.
+func allUnchanged(reports []any) bool {
+	for _, r := range reports {
+		if _, ok := r.(WorkspaceUnchangedDocumentDiagnosticReport); !ok {
+			return false
+		}
+	}
+	return len(reports) > 0
+}
+
+// workspaceDiagnosticReport is the result of workspace/diagnostic with the
+// reports, which are sent as partial results if the client asked for them.
+func (h *langHandler) workspaceDiagnosticReport(ctx context.Context, conn *jsonrpc2.Conn, params *WorkspaceDiagnosticParams, reports []any) (any, error) {
+	if params.PartialResultToken == nil || conn == nil {
+		return WorkspaceDiagnosticReport{Items: reports}, nil
+	}
+
+	// With a partial result token, the reports are sent in batches, so that
+	// the client shows the ones of a large workspace as they come, and the
+	// result holds none.
+	for len(reports) > 0 {
+		n := min(len(reports), workspaceDiagnosticBatch)
+		if err := conn.Notify(ctx, "$/progress", &ProgressParams{
+			Token: params.PartialResultToken,
+			Value: WorkspaceDiagnosticReport{Items: reports[:n]},
+		}); err != nil {
+			return nil, err
+		}
+		reports = reports[n:]
+	}
+	return WorkspaceDiagnosticReport{Items: []any{}}, nil
+}
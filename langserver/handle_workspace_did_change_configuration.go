@@ -3,7 +3,9 @@ package langserver
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"maps"
 	"os"
 	"time"
 
@@ -15,14 +17,120 @@ func (h *langHandler) handleWorkspaceDidChangeConfiguration(_ context.Context, _
 		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
 	}
 
+	result, err = h.applySettings(*req.Params)
+	if err == nil {
+		h.mu.Lock()
+		h.settingsChanges = append(h.settingsChanges, *req.Params)
+		h.mu.Unlock()
+		// The lint-watch globs may have changed.
+		go h.updateWatchedFiles()
+	}
+	return result, err
+}
+
+// applySettings applies the params of a didChangeConfiguration
+// notification over the current configuration.
+func (h *langHandler) applySettings(changes json.RawMessage) (any, error) {
 	var params DidChangeConfigurationParams
-	if err := json.Unmarshal(*req.Params, &params); err != nil {
+	if err := json.Unmarshal(changes, &params); err != nil {
+		return nil, err
+	}
+
+	// Clients send sparse settings, so the languages are merged over the
+	// current tools instead of replacing them.
+	var raw struct {
+		Settings map[string]json.RawMessage `json:"settings"`
+	}
+	if err := json.Unmarshal(changes, &raw); err != nil {
 		return nil, err
 	}
+	if settings, ok := raw.Settings["languages"]; ok {
+		var languages map[string]json.RawMessage
+		if err := json.Unmarshal(settings, &languages); err != nil {
+			return nil, err
+		}
+		configs, err := mergeLanguageSettings(h.configs, languages)
+		if err != nil {
+			return nil, err
+		}
+		params.Settings.Languages = &configs
+	}
+	h.setSettingOrigins(raw.Settings)
+	// wsl sent as false turns it off, which didChangeConfiguration can't
+	// tell from a missing setting.
+	if _, ok := raw.Settings["wsl"]; ok {
+		h.wsl = params.Settings.WSL
+	}
 
 	return h.didChangeConfiguration(&params.Settings)
 }
 
+// mergeLanguageSettings returns configs with the language settings sent by
+// the client merged over them. The n-th entry of a language updates the n-th
+// tool and keeps the settings it doesn't mention; entries beyond the
+// current tools are added. A language set to null is removed.
+func mergeLanguageSettings(configs map[string][]Language, languages map[string]json.RawMessage) (map[string][]Language, error) {
+	merged := cloneConfigs(configs)
+	for lang, settings := range languages {
+		var entries []json.RawMessage
+		if err := json.Unmarshal(settings, &entries); err != nil {
+			return nil, fmt.Errorf("languages.%s: %v", lang, err)
+		}
+		if entries == nil {
+			delete(merged, lang)
+			continue
+		}
+		cfgs := merged[lang]
+		for i, entry := range entries {
+			var cfg Language
+			if i < len(cfgs) {
+				cfg = cfgs[i]
+			}
+			cfg, err := mergeLanguage(cfg, entry)
+			if err != nil {
+				return nil, fmt.Errorf("languages.%s[%d]: %v", lang, i, err)
+			}
+			if i < len(cfgs) {
+				cfgs[i] = cfg
+			} else {
+				cfgs = append(cfgs, cfg)
+			}
+		}
+		merged[lang] = cfgs
+	}
+	if err := prepareLanguages(merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeLanguage decodes the settings of a tool over cfg.
+func mergeLanguage(cfg Language, settings json.RawMessage) (Language, error) {
+	// Decoding writes through the pointers and maps of cfg, which the other
+	// sessions share, so they are copied first.
+	if cfg.Passthrough != nil {
+		passthrough := *cfg.Passthrough
+		cfg.Passthrough = &passthrough
+	}
+	if cfg.Container != nil {
+		container := *cfg.Container
+		cfg.Container = &container
+	}
+	if cfg.LintJQFields != nil {
+		fields := *cfg.LintJQFields
+		cfg.LintJQFields = &fields
+	}
+	if cfg.Remote != nil {
+		remote := *cfg.Remote
+		remote.PathMap = maps.Clone(remote.PathMap)
+		cfg.Remote = &remote
+	}
+	cfg.LintCategoryMap = maps.Clone(cfg.LintCategoryMap)
+	cfg.LintSeverityMap = maps.Clone(cfg.LintSeverityMap)
+	err := json.Unmarshal(settings, &cfg)
+	return cfg, err
+}
+
 func (h *langHandler) didChangeConfiguration(config *Config) (any, error) {
 	if config.Languages != nil {
 		h.configs = *config.Languages
@@ -30,6 +138,9 @@ func (h *langHandler) didChangeConfiguration(config *Config) (any, error) {
 	if config.RootMarkers != nil {
 		h.rootMarkers = *config.RootMarkers
 	}
+	if config.RootMarkerRules != nil {
+		h.rootMarkerRules = config.RootMarkerRules
+	}
 	if config.TriggerChars != nil {
 		h.triggerChars = config.TriggerChars
 	}
@@ -45,6 +156,119 @@ func (h *langHandler) didChangeConfiguration(config *Config) (any, error) {
 	if config.FormatDebounce > 0 {
 		h.formatDebounce = time.Duration(config.FormatDebounce)
 	}
+	if config.MaxConcurrentCommands > 0 {
+		h.commandLimit.setMax(config.MaxConcurrentCommands)
+	}
+	if config.MaxDiagnosticsPerFile > 0 {
+		h.maxDiagnosticsPerFile = config.MaxDiagnosticsPerFile
+	}
+	if config.DedupeByCode {
+		h.dedupeByCode = config.DedupeByCode
+	}
+	if config.MinimumSeverity > 0 {
+		h.minimumSeverity = config.MinimumSeverity
+	}
+	if config.ClearDiagnosticsOnClose {
+		h.clearDiagnosticsOnClose = config.ClearDiagnosticsOnClose
+	}
+	if config.SuppressionMarker != "" {
+		h.suppressionMarker = config.SuppressionMarker
+	}
+	if config.DiagnosticsCache {
+		h.diagnosticsCache = config.DiagnosticsCache
+	}
+	if config.FormatOnSave {
+		h.formatOnSave = config.FormatOnSave
+	}
+	if config.LintAfterOpenDelay > 0 {
+		h.lintAfterOpenDelay = time.Duration(config.LintAfterOpenDelay)
+	}
+	if config.FormatFirstOnly {
+		h.formatFirstOnly = config.FormatFirstOnly
+	}
+	if config.FormatChain != "" {
+		h.formatChain = config.FormatChain
+	}
+	if config.FormatExclude != nil {
+		h.formatExclude = config.FormatExclude
+	}
+	if config.FormatRangeFallback != "" {
+		h.formatRangeFallback = config.FormatRangeFallback
+	}
+	if config.FormatOnlyChangedLines {
+		h.formatOnlyChangedLines = config.FormatOnlyChangedLines
+	}
+	if config.BufferWordsCompletion {
+		h.bufferWordsCompletion = config.BufferWordsCompletion
+	}
+	if config.PathCompletion != nil {
+		h.pathCompletion = config.PathCompletion
+	}
+	if config.SnippetsDir != "" {
+		h.snippetsDir = config.SnippetsDir
+	}
+	if config.CompletionFilter != "" {
+		h.completionFilter = config.CompletionFilter
+	}
+	if config.Shell != "" {
+		h.shell = config.Shell
+	}
+	if config.WSL {
+		h.wsl = config.WSL
+	}
+	if config.CommandWrapper != "" {
+		h.commandWrapper = config.CommandWrapper
+	}
+	if config.ImportPreCommit {
+		h.importPreCommit = config.ImportPreCommit
+	}
+	if config.ImportReviewdog {
+		h.importReviewdog = config.ImportReviewdog
+	}
+	if config.ReviewdogLanguages != nil {
+		reviewdogLanguages := maps.Clone(h.reviewdogLanguages)
+		if reviewdogLanguages == nil {
+			reviewdogLanguages = make(map[string][]string)
+		}
+		maps.Copy(reviewdogLanguages, config.ReviewdogLanguages)
+		h.reviewdogLanguages = reviewdogLanguages
+	}
+	if config.ImportProjectConfig {
+		h.importProjectConfig = config.ImportProjectConfig
+	}
+	if config.LintOnlyChangedLines {
+		h.mu.Lock()
+		h.lintOnlyChangedLines = config.LintOnlyChangedLines
+		h.mu.Unlock()
+	}
+	if config.TaskRunners {
+		h.taskRunners = config.TaskRunners
+	}
+	if config.SpellCheck != nil {
+		h.spellCheck = config.SpellCheck
+	}
+	if config.LanguageAliases != nil {
+		languageAliases := maps.Clone(h.languageAliases)
+		if languageAliases == nil {
+			languageAliases = make(map[string]string)
+		}
+		maps.Copy(languageAliases, config.LanguageAliases)
+		h.languageAliases = languageAliases
+	}
+	if config.Profiles != nil {
+		profiles := maps.Clone(h.profiles)
+		if profiles == nil {
+			profiles = make(map[string]*Config)
+		}
+		maps.Copy(profiles, config.Profiles)
+		h.profiles = profiles
+	}
+	if config.Languages != nil || config.Profiles != nil {
+		h.applyProfile()
+	}
+	if config.Languages != nil || config.ImportPreCommit || config.ImportReviewdog || config.ImportProjectConfig {
+		h.importProjectTools(h.rootPath)
+	}
 
 	if config.LogFile != "" {
 		f, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o660)
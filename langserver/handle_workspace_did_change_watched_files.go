@@ -0,0 +1,210 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// watchedFilesRegistration is the id of the registration of the lint-watch
+// globs, the root markers and the config file with the client.
+const watchedFilesRegistration = "efm-langserver/lint-watch"
+
+func (h *langHandler) handleWorkspaceDidChangeWatchedFiles(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params DidChangeWatchedFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, change := range params.Changes {
+		if fname, err := fromURI(change.URI); err == nil {
+			changed = append(changed, fname)
+		}
+	}
+	if h.configFileChangedAny(changed) {
+		// Reloading updates the watched files with the client.
+		go h.configChanged()
+	}
+	// A root marker created or deleted may move the root of the tools,
+	// e.g. a pyproject.toml of a subproject, or change what root-command
+	// prints.
+	markerChanged := h.rootMarkerChangedAny(changed)
+	if markerChanged {
+		h.rootCommands.forget()
+	}
+	for uri := range h.files {
+		if markerChanged || h.watchesAny(uri, changed) {
+			if h.loglevel >= 1 {
+				h.logger.Printf("linting %s again after a change of a watched file", uri)
+			}
+			// The text didn't change, but the result may.
+			h.lintCache.forget(uri)
+			h.lintRequest(uri, eventTypeSave)
+		}
+	}
+	return nil, nil
+}
+
+// watchesAny reports whether a lint tool of the document uri has a
+// lint-watch glob matching one of the files.
+func (h *langHandler) watchesAny(uri DocumentURI, files []string) bool {
+	f, ok := h.files[uri]
+	if !ok {
+		return false
+	}
+	fname, err := fromURI(uri)
+	if err != nil {
+		return false
+	}
+	cfgs, _ := h.configsFor(uri)
+	for _, cfg := range h.configs[wildcard] {
+		if !excludesLanguage(cfg, f.LanguageID) {
+			cfgs = append(cfgs[:len(cfgs):len(cfgs)], cfg)
+		}
+	}
+	for _, cfg := range cfgs {
+		globs := cfg.lintWatch()
+		if cfg.LintCommand == "" || len(globs) == 0 {
+			continue
+		}
+		root := h.findRootPath(fname, cfg)
+		for _, pattern := range globs {
+			for _, file := range files {
+				if matchGlob(pattern, file, root) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// rootMarkerChangedAny reports whether one of the files is a root marker
+// file of the tools or of the global config.
+func (h *langHandler) rootMarkerChangedAny(files []string) bool {
+	markers := h.rootMarkerGlobs()
+	for _, file := range files {
+		for _, pattern := range markers {
+			if matchGlob(pattern, file, "") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rootMarkerGlobs returns the names of the root marker files of the tools
+// and of the global config. Directories, e.g. .git/, aren't watched: their
+// content changes all the time.
+func (h *langHandler) rootMarkerGlobs() []string {
+	var globs []string
+	add := func(markers []RootMarker) {
+		for _, marker := range markers {
+			if marker.Name != "" && !strings.HasSuffix(marker.Name, "/") && !slices.Contains(globs, marker.Name) {
+				globs = append(globs, marker.Name)
+			}
+		}
+	}
+	add(h.markers())
+	for _, cfgs := range h.configs {
+		for _, cfg := range cfgs {
+			add(cfg.markers())
+		}
+	}
+	sort.Strings(globs)
+	return globs
+}
+
+// lintWatchGlobs returns the lint-watch globs of all the tools, and the
+// config files of the well-known linters of the tools without any.
+func (h *langHandler) lintWatchGlobs() []string {
+	var globs []string
+	for _, cfgs := range h.configs {
+		for _, cfg := range cfgs {
+			for _, pattern := range cfg.lintWatch() {
+				if !slices.Contains(globs, pattern) {
+					globs = append(globs, pattern)
+				}
+			}
+		}
+	}
+	sort.Strings(globs)
+	return globs
+}
+
+// watchedFileGlobs returns the lint-watch globs, the root markers and the
+// path of the config file, the files asked to the client to watch.
+func (h *langHandler) watchedFileGlobs() []string {
+	globs := h.lintWatchGlobs()
+	for _, pattern := range h.rootMarkerGlobs() {
+		if !slices.Contains(globs, pattern) {
+			globs = append(globs, pattern)
+		}
+	}
+	if fname := h.configFile(); fname != "" {
+		globs = append(globs, filepath.ToSlash(fname))
+	}
+	sort.Strings(globs)
+	return globs
+}
+
+// updateWatchedFiles asks the client to watch the lint-watch globs and the
+// root markers, replacing the globs registered before. It calls the client, so it must not
+// be used from the goroutine handling requests.
+func (h *langHandler) updateWatchedFiles() {
+	h.mu.Lock()
+	conn := h.conn
+	globs := h.watchedFileGlobs()
+	registered := h.watchedGlobs
+	if conn == nil || !h.dynamicWatchedFiles || slices.Equal(globs, registered) {
+		h.mu.Unlock()
+		return
+	}
+	h.watchedGlobs = globs
+	h.mu.Unlock()
+
+	if len(registered) > 0 {
+		err := conn.Call(context.Background(), "client/unregisterCapability", &UnregistrationParams{
+			Unregisterations: []Unregistration{{ID: watchedFilesRegistration, Method: "workspace/didChangeWatchedFiles"}},
+		}, nil)
+		if err != nil {
+			h.logger.Printf("can not unregister the watched files: %v", err)
+		}
+	}
+	if len(globs) == 0 {
+		return
+	}
+
+	configFile := filepath.ToSlash(h.configFile())
+	var watchers []FileSystemWatcher
+	for _, pattern := range globs {
+		// The client matches full paths, and the globs are filtered by
+		// matchGlob when the changes come. The config file is watched
+		// by its path.
+		if pattern == configFile {
+			watchers = append(watchers, FileSystemWatcher{GlobPattern: pattern})
+			continue
+		}
+		watchers = append(watchers, FileSystemWatcher{GlobPattern: "**/" + strings.TrimPrefix(pattern, "/")})
+	}
+	err := conn.Call(context.Background(), "client/registerCapability", &RegistrationParams{
+		Registrations: []Registration{{
+			ID:              watchedFilesRegistration,
+			Method:          "workspace/didChangeWatchedFiles",
+			RegisterOptions: &DidChangeWatchedFilesRegistrationOptions{Watchers: watchers},
+		}},
+	}, nil)
+	if err != nil {
+		h.logger.Printf("can not register the watched files: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// fileOperationFilters are the files whose renames and deletions the client
+// notifies, all the files and folders, since the diagnostics of any file may
+// have been published.
+var fileOperationFilters = []FileOperationFilter{{Scheme: "file", Pattern: FileOperationPattern{Glob: "**"}}}
+
+func (h *langHandler) handleWorkspaceDidRenameFiles(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params RenameFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	for _, file := range params.Files {
+		h.renameFile(file.OldURI, file.NewURI)
+	}
+	h.lintWorkspaceAgain()
+	return nil, nil
+}
+
+func (h *langHandler) handleWorkspaceDidDeleteFiles(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params DeleteFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	for _, file := range params.Files {
+		h.forgetFiles(file.URI)
+	}
+	h.lintWorkspaceAgain()
+	return nil, nil
+}
+
+// isUnder reports whether uri is dir or, dir being a folder, a file in it.
+func isUnder(uri, dir DocumentURI) bool {
+	return uri == dir || strings.HasPrefix(string(uri), strings.TrimSuffix(string(dir), "/")+"/")
+}
+
+// renameFile moves the open documents of the file or folder oldURI to
+// newURI, and lints them again there.
+func (h *langHandler) renameFile(oldURI, newURI DocumentURI) {
+	moved := make(map[DocumentURI]*File)
+	for uri, f := range h.files {
+		if isUnder(uri, oldURI) {
+			moved[newURI+uri[len(oldURI):]] = f
+		}
+	}
+	h.forgetFiles(oldURI)
+	for uri, f := range moved {
+		h.mu.Lock()
+		h.files[uri] = f
+		h.mu.Unlock()
+		h.lintRequest(uri, eventTypeSave)
+	}
+}
+
+// forgetFiles drops the open documents and the lint results of the file or
+// folder uri, renamed or deleted, and clears the diagnostics published for
+// it, which no tool will do anymore.
+func (h *langHandler) forgetFiles(uri DocumentURI) {
+	for u := range h.files {
+		if isUnder(u, uri) {
+			h.mu.Lock()
+			delete(h.files, u)
+			h.mu.Unlock()
+			h.lintCache.forget(u)
+			h.pulled.forget(u)
+		}
+	}
+	for _, u := range h.published.under(uri) {
+		h.publishDiagnostics(context.Background(), u, nil, 0, false)
+	}
+}
+
+// lintWorkspaceAgain lints the open documents having workspace linters
+// again, whose results may change with the files of the workspace.
+func (h *langHandler) lintWorkspaceAgain() {
+	for uri := range h.files {
+		configs, _ := h.configsFor(uri)
+		for _, config := range configs {
+			if config.LintWorkspace && config.LintCommand != "" {
+				h.lintCache.forget(uri)
+				h.lintRequest(uri, eventTypeSave)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package langserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenameFile(t *testing.T) {
+	h := &langHandler{
+		request: make(chan lintRequest, 2),
+		files: map[DocumentURI]*File{
+			"file:///src/foo.py":  {LanguageID: "python", Text: "foo"},
+			"file:///srcs/bar.py": {LanguageID: "python", Text: "bar"},
+		},
+	}
+
+	h.renameFile("file:///src", "file:///lib")
+	if _, ok := h.files["file:///src/foo.py"]; ok {
+		t.Fatal("expected the document to be moved from the old folder")
+	}
+	if f, ok := h.files["file:///lib/foo.py"]; !ok || f.Text != "foo" {
+		t.Fatalf("expected the document in the new folder, got %v", h.files)
+	}
+	if _, ok := h.files["file:///srcs/bar.py"]; !ok {
+		t.Fatal("expected the document of another folder to stay")
+	}
+
+	select {
+	case req := <-h.request:
+		if req.URI != "file:///lib/foo.py" {
+			t.Fatalf("expected a lint of the renamed document, got %v", req.URI)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the renamed document to be linted")
+	}
+}
+
+func TestForgetFiles(t *testing.T) {
+	h := &langHandler{
+		files: map[DocumentURI]*File{
+			"file:///foo.py": {LanguageID: "python", Text: "foo"},
+		},
+	}
+	h.forgetFiles("file:///foo.py")
+	if len(h.files) != 0 {
+		t.Fatalf("expected the deleted document to be forgotten, got %v", h.files)
+	}
+}
@@ -2,8 +2,10 @@ package langserver
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,7 +13,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,9 +23,9 @@ import (
 	"unicode"
 	"unicode/utf16"
 
-	"github.com/itchyny/gojq"
 	"github.com/reviewdog/errorformat"
 	"github.com/sourcegraph/jsonrpc2"
+	"gopkg.in/yaml.v3"
 
 	"github.com/mattn/go-unicodeclass"
 )
@@ -47,11 +51,122 @@ type Config struct {
 	LogFile        string                 `yaml:"log-file"`
 	LogLevel       int                    `yaml:"log-level"       json:"logLevel"`
 	Commands       *[]Command             `yaml:"commands"        json:"commands"`
+	Tools          map[string]Language    `yaml:"tools,omitempty" json:"-"`
 	Languages      *map[string][]Language `yaml:"languages"       json:"languages"`
 	RootMarkers    *[]string              `yaml:"root-markers"    json:"rootMarkers"`
 	TriggerChars   []string               `yaml:"trigger-chars"   json:"triggerChars"`
 	LintDebounce   Duration               `yaml:"lint-debounce"   json:"lintDebounce"`
 	FormatDebounce Duration               `yaml:"format-debounce" json:"formatDebounce"`
+	Shell          string                 `yaml:"shell"           json:"shell"`
+	WSL            bool                   `yaml:"wsl"             json:"wsl"`
+	CommandWrapper string                 `yaml:"command-wrapper" json:"commandWrapper"`
+
+	// Root markers requiring some content or having a priority, in
+	// addition to root-markers.
+	RootMarkerRules []RootMarker `yaml:"root-marker-rules" json:"rootMarkerRules"`
+
+	// Import tools from the project's .pre-commit-config.yaml.
+	ImportPreCommit bool `yaml:"import-pre-commit" json:"importPreCommit"`
+	// Import lint tools from the runners of the project's .reviewdog.yml,
+	// assigned to the files matching globs by runner name patterns.
+	ImportReviewdog    bool                `yaml:"import-reviewdog"    json:"importReviewdog"`
+	ReviewdogLanguages map[string][]string `yaml:"reviewdog-languages" json:"reviewdogLanguages"`
+
+	// Merge the project's .efm-langserver.yaml over this configuration.
+	ImportProjectConfig bool `yaml:"import-project-config" json:"importProjectConfig"`
+
+	// Only publish diagnostics on lines changed since the last git commit.
+	LintOnlyChangedLines bool `yaml:"lint-only-changed-lines" json:"lintOnlyChangedLines"`
+
+	// Expose Makefile, justfile and package.json tasks as commands.
+	TaskRunners bool `yaml:"task-runners" json:"taskRunners"`
+
+	SpellCheck *SpellCheck `yaml:"spell-check" json:"spellCheck"`
+
+	// Map language ids reported by clients to the language id of the
+	// configuration to use, e.g. jsx: javascriptreact.
+	LanguageAliases map[string]string `yaml:"language-aliases" json:"languageAliases"`
+
+	// Named partial configurations merged over this one when the client
+	// asks for them with initializationOptions.profile.
+	Profiles map[string]*Config `yaml:"profiles" json:"profiles"`
+
+	// Bound the commands of the tools running at once. Zero means no
+	// limit.
+	MaxConcurrentCommands int `yaml:"max-concurrent-commands" json:"maxConcurrentCommands"`
+
+	// Truncate the diagnostics published for a file, with a summary of the
+	// ones left out. Zero means no limit.
+	MaxDiagnosticsPerFile int `yaml:"max-diagnostics-per-file" json:"maxDiagnosticsPerFile"`
+
+	// Diagnostics of the same range, code and message are published once.
+	// Key them by range and code only, whatever their messages.
+	DedupeByCode bool `yaml:"dedupe-by-code" json:"dedupeByCode"`
+
+	// Publish only the diagnostics at least this severe, unless the client
+	// asks for another minimum with initializationOptions.minimumSeverity.
+	MinimumSeverity Severity `yaml:"minimum-severity" json:"minimumSeverity"`
+
+	// Publish no diagnostics for a document when it is closed.
+	ClearDiagnosticsOnClose bool `yaml:"clear-diagnostics-on-close" json:"clearDiagnosticsOnClose"`
+
+	// Comments containing this marker suppress the diagnostics of their
+	// line, and with -next-line appended the ones of the next line.
+	// Defaults to efm-ignore.
+	SuppressionMarker string `yaml:"suppression-marker" json:"suppressionMarker"`
+
+	// Keep the diagnostics published for the files of the workspace across
+	// restarts, in the user cache directory.
+	DiagnosticsCache bool `yaml:"diagnostics-cache" json:"diagnosticsCache"`
+
+	// Format the documents before they are saved with the format-command
+	// tools, for the clients sending textDocument/willSaveWaitUntil.
+	FormatOnSave bool `yaml:"format-on-save" json:"formatOnSave"`
+
+	// Delay the lint of the documents opened, instead of lint-debounce, so
+	// that the many documents a client opens when restoring a session don't
+	// start all their linters at once.
+	LintAfterOpenDelay Duration `yaml:"lint-after-open-delay" json:"lintAfterOpenDelay"`
+
+	// Run only the formatter of the highest format-priority of a document
+	// instead of chaining all of them.
+	FormatFirstOnly bool `yaml:"format-first-only" json:"formatFirstOnly"`
+
+	// What happens when a formatter of the chain fails: continue with the
+	// next one (the default), stop and apply the output so far, or abort
+	// without any edit.
+	FormatChain string `yaml:"format-chain" json:"formatChain"`
+
+	// Globs of the files no formatter formats, e.g. vendored or generated
+	// ones. A glob matching a directory matches the files in it.
+	FormatExclude []string `yaml:"format-exclude" json:"formatExclude"`
+
+	// What a range formatting request does with the formatters without
+	// format-can-range: format the whole document and keep only the edits in
+	// the range, or leave the document as is. By default, all their edits
+	// are applied.
+	FormatRangeFallback string `yaml:"format-range-fallback" json:"formatRangeFallback"`
+
+	// Only apply the changes of the formatters to the lines changed since the
+	// last git commit.
+	FormatOnlyChangedLines bool `yaml:"format-only-changed-lines" json:"formatOnlyChangedLines"`
+
+	// Offer the words of the open documents of the same language as the
+	// completion items of the documents without a completion-command.
+	BufferWordsCompletion bool `yaml:"buffer-words-completion" json:"bufferWordsCompletion"`
+
+	// Complete the paths of files typed in strings, relative to the document
+	// and to the root.
+	PathCompletion *PathCompletion `yaml:"path-completion" json:"pathCompletion"`
+
+	// Directory of VS Code snippet files, offered as completion items,
+	// relative to the root.
+	SnippetsDir string `yaml:"snippets-dir" json:"snippetsDir"`
+
+	// How the completion items of the commands and snippets are filtered by
+	// the word typed so far, for the clients which don't: by prefix, or fuzzy
+	// matching and ranked. By default, they are left to the client.
+	CompletionFilter string `yaml:"completion-filter" json:"completionFilter"`
 
 	// Toggle support for "go to definition" requests.
 	ProvideDefinition bool `yaml:"provide-definition"`
@@ -76,6 +191,8 @@ type Passthrough struct {
 
 // Language is
 type Language struct {
+	Extends            string            `yaml:"extends,omitempty" json:"-"`
+	With               map[string]string `yaml:"with,omitempty" json:"-"`
 	Prefix             string            `yaml:"prefix" json:"prefix"`
 	LintFormats        []string          `yaml:"lint-formats" json:"lintFormats"`
 	LintStdin          bool              `yaml:"lint-stdin" json:"lintStdin"`
@@ -90,10 +207,18 @@ type Language struct {
 	LintAfterOpen      bool              `yaml:"lint-after-open" json:"lintAfterOpen"`
 	LintOnSave         bool              `yaml:"lint-on-save" json:"lintOnSave"`
 	LintJQ             string            `yaml:"lint-jq" json:"lintJq"`
+	LintTimeout        Duration          `yaml:"lint-timeout" json:"lintTimeout"`
+	LintServer         bool              `yaml:"lint-server" json:"lintServer"`
+	LintFormatType     string            `yaml:"lint-format-type" json:"lintFormatType"`
+	LintPattern        string            `yaml:"lint-pattern" json:"lintPattern"`
 	FormatCommand      string            `yaml:"format-command" json:"formatCommand"`
 	FormatCanRange     bool              `yaml:"format-can-range" json:"formatCanRange"`
 	FormatStdin        bool              `yaml:"format-stdin" json:"formatStdin"`
 	FormatInplace      bool              `yaml:"format-inplace" json:"formatInplace"`
+	FixCommand         string            `yaml:"fix-command" json:"fixCommand"`
+	FixStdin           bool              `yaml:"fix-stdin" json:"fixStdin"`
+	AppliesToCodes     []string          `yaml:"applies-to-codes" json:"appliesToCodes"`
+	ExcludeLanguages   []string          `yaml:"exclude-languages" json:"excludeLanguages"`
 	SymbolCommand      string            `yaml:"symbol-command" json:"symbolCommand"`
 	SymbolStdin        bool              `yaml:"symbol-stdin" json:"symbolStdin"`
 	SymbolFormats      []string          `yaml:"symbol-formats" json:"symbolFormats"`
@@ -104,40 +229,139 @@ type Language struct {
 	HoverType          string            `yaml:"hover-type" json:"hoverType"`
 	HoverChars         string            `yaml:"hover-chars" json:"hoverChars"`
 	Env                []string          `yaml:"env" json:"env"`
+	Shell              string            `yaml:"shell" json:"shell"`
 	RootMarkers        []string          `yaml:"root-markers" json:"rootMarkers"`
+	RootMarkerRules    []RootMarker      `yaml:"root-marker-rules" json:"rootMarkerRules"`
+	RootCommand        string            `yaml:"root-command" json:"rootCommand"`
 	RequireMarker      bool              `yaml:"require-marker" json:"requireMarker"`
 	Commands           []Command         `yaml:"commands" json:"commands"`
 	Passthrough        *Passthrough      `yaml:"passthrough" json:"passthrough"`
+	Container          *Container        `yaml:"run-in-container" json:"runInContainer"`
+	WSL                bool              `yaml:"wsl" json:"wsl"`
+	Remote             *Remote           `yaml:"remote" json:"remote"`
+	CommandWrapper     string            `yaml:"command-wrapper" json:"commandWrapper"`
+
+	// LintWatch are globs of files, e.g. linter configs, whose change on
+	// disk lints the documents using the tool again.
+	LintWatch []string `yaml:"lint-watch" json:"lintWatch"`
+
+	// LintFiles and LintFilesExclude select the files ${FILES} expands to,
+	// and LintMaxCommandLength splits them into several commands.
+	LintFiles            []string `yaml:"lint-files" json:"lintFiles"`
+	LintFilesExclude     []string `yaml:"lint-files-exclude" json:"lintFilesExclude"`
+	LintMaxCommandLength int      `yaml:"lint-max-command-length" json:"lintMaxCommandLength"`
+
+	// Attach the notes (%t being n) following a diagnostic to it as related
+	// information instead of reporting them as hints.
+	LintRelatedInformation bool `yaml:"lint-related-information" json:"lintRelatedInformation"`
+
+	// URL of the documentation of a code, ${code} being replaced by the
+	// code, e.g. https://eslint.org/docs/rules/${code}.
+	LintCodeURL string `yaml:"lint-code-url" json:"lintCodeUrl"`
+
+	// Severities by code, or by glob of codes, overriding the ones of the
+	// output, e.g. no-console: hint.
+	LintSeverityMap map[string]string `yaml:"lint-severity-map" json:"lintSeverityMap"`
+
+	// LintJQFields names the fields of the values of lint-jq.
+	LintJQFields *JQFields `yaml:"lint-jq-fields" json:"lintJqFields"`
+
+	// Truncate the diagnostics of the tool for a file, like the global
+	// max-diagnostics-per-file.
+	MaxDiagnosticsPerFile int `yaml:"max-diagnostics-per-file" json:"maxDiagnosticsPerFile"`
+
+	// Clear the diagnostics of a document when it is closed, like the
+	// global clear-diagnostics-on-close.
+	ClearDiagnosticsOnClose bool `yaml:"clear-diagnostics-on-close" json:"clearDiagnosticsOnClose"`
+
+	// The units the columns of the output are counted in: bytes or utf8
+	// (the same), or utf16 like LSP. By default they are used as is.
+	LintColumnEncoding string `yaml:"lint-column-encoding" json:"lintColumnEncoding"`
+
+	// Commands replacing lint-command on save and on change, e.g. a full
+	// analysis on save and a cheap syntax check while typing.
+	LintCommandOnSave   string `yaml:"lint-command-on-save" json:"lintCommandOnSave"`
+	LintCommandOnChange string `yaml:"lint-command-on-change" json:"lintCommandOnChange"`
+
+	// The formatters of a document run from the highest priority to the
+	// lowest, and in the order of the config for the same priority.
+	FormatPriority int `yaml:"format-priority" json:"formatPriority"`
+
+	// Globs of the files the formatter doesn't format, like the global
+	// format-exclude.
+	FormatExclude []string `yaml:"format-exclude" json:"formatExclude"`
+
+	// The error formats of the error output of the formatter when it fails,
+	// e.g. on a syntax error, published as diagnostics of the document.
+	FormatLintFormats []string `yaml:"format-lint-formats" json:"formatLintFormats"`
+
+	// A jq filter extracting the fixed document from the JSON output of
+	// fix-command, e.g. .[0].output for eslint --fix-dry-run --format json.
+	FixJQ string `yaml:"fix-jq" json:"fixJq"`
+
+	// Comments suppressing a diagnostic of the tool on its line or in the
+	// whole file, offered as code actions.
+	SuppressCommentTemplates *SuppressCommentTemplates `yaml:"suppress-comment-templates" json:"suppressCommentTemplates"`
+
+	// How the output of completion-command is parsed: a label on each line
+	// (the default), or json items with their kind, detail and docs.
+	CompletionFormat string `yaml:"completion-format" json:"completionFormat"`
+
+	// A jq filter reshaping the JSON output of completion-command into
+	// completion items, objects with the fields of completion-format json or
+	// labels, e.g. .[] | {label: .name, detail: .type}.
+	CompletionJQ string `yaml:"completion-jq" json:"completionJq"`
+
+	// Settings overriding the ones above on one operating system, applied
+	// by LoadConfig.
+	Windows yaml.Node `yaml:"windows,omitempty" json:"-"`
+	Linux   yaml.Node `yaml:"linux,omitempty" json:"-"`
+	Darwin  yaml.Node `yaml:"darwin,omitempty" json:"-"`
+}
+
+// applyOSOverrides applies the settings of the block for goos and drops
+// all the blocks.
+func (cfg *Language) applyOSOverrides(goos string) error {
+	blocks := map[string]yaml.Node{"windows": cfg.Windows, "linux": cfg.Linux, "darwin": cfg.Darwin}
+	cfg.Windows, cfg.Linux, cfg.Darwin = yaml.Node{}, yaml.Node{}, yaml.Node{}
+	if block := blocks[goos]; !block.IsZero() {
+		if err := block.Decode(cfg); err != nil {
+			return fmt.Errorf("%v: %v", goos, err)
+		}
+	}
+	return nil
 }
 
-// NewHandler create JSON-RPC handler for this language server.
+// NewHandler create JSON-RPC handler for this language server. Every call
+// returns an independent session: documents, workspace folders and tools
+// imported from the project are never shared between handlers, so one
+// process can serve several connections.
 func NewHandler(config *Config) jsonrpc2.Handler {
-	if config.Logger == nil {
-		config.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	return replyHandler{newLangHandler(config)}
+}
+
+func newLangHandler(config *Config) *langHandler {
+	logger := config.Logger
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
 
 	handler := &langHandler{
-		loglevel:          config.LogLevel,
-		logger:            config.Logger,
-		commands:          *config.Commands,
-		configs:           *config.Languages,
+		logger:            logger,
 		provideDefinition: config.ProvideDefinition,
 		files:             make(map[DocumentURI]*File),
 		request:           make(chan lintRequest),
-		lintDebounce:      time.Duration(config.LintDebounce),
-		lintTimer:         nil,
-
-		formatDebounce: time.Duration(config.FormatDebounce),
-		formatTimer:    nil,
-		conn:           nil,
-		filename:       config.Filename,
-		rootMarkers:    *config.RootMarkers,
-		triggerChars:   config.TriggerChars,
+		done:              make(chan struct{}),
+		linterStopped:     make(chan struct{}),
+		lintTimers:        make(map[DocumentURI]*pendingLint),
+		conn:              nil,
+		filename:          config.Filename,
 
-		lastPublishedURIs: make(map[string]map[DocumentURI]struct{}),
+		lastPublishedURIs:  make(map[string]map[DocumentURI]struct{}),
 		passthroughServers: make(map[string]*PassthroughServer),
 	}
-	
+	handler.applyConfig(config)
+
 	// Log configuration information for debugging
 	handler.logger.Printf("Initializing language handler with %d language configurations", len(handler.configs))
 	for langID, langConfigs := range handler.configs {
@@ -150,7 +374,65 @@ func NewHandler(config *Config) jsonrpc2.Handler {
 	}
 	
 	go handler.linter()
-	return jsonrpc2.HandlerWithError(handler.handle)
+	if handler.filename != "" {
+		go handler.watchConfig()
+	}
+	return handler
+}
+
+// applyConfig sets the settings of config, when the session starts and when
+// the config file is reloaded. The lists config doesn't have are empty.
+func (h *langHandler) applyConfig(config *Config) {
+	defaults := NewConfig()
+	commands, languages, rootMarkers := config.Commands, config.Languages, config.RootMarkers
+	if commands == nil {
+		commands = defaults.Commands
+	}
+	if languages == nil {
+		languages = defaults.Languages
+	}
+	if rootMarkers == nil {
+		rootMarkers = defaults.RootMarkers
+	}
+
+	h.loglevel = config.LogLevel
+	h.commands = append([]Command(nil), *commands...)
+	h.configs = cloneConfigs(*languages)
+	h.rootMarkers = *rootMarkers
+	h.rootMarkerRules = config.RootMarkerRules
+	h.triggerChars = config.TriggerChars
+	h.lintDebounce = time.Duration(config.LintDebounce)
+	h.formatDebounce = time.Duration(config.FormatDebounce)
+	h.shell = config.Shell
+	h.wsl = config.WSL
+	h.commandWrapper = config.CommandWrapper
+	h.importPreCommit = config.ImportPreCommit
+	h.importReviewdog = config.ImportReviewdog
+	h.reviewdogLanguages = config.ReviewdogLanguages
+	h.importProjectConfig = config.ImportProjectConfig
+	h.profiles = config.Profiles
+	h.lintOnlyChangedLines = config.LintOnlyChangedLines
+	h.taskRunners = config.TaskRunners
+	h.spellCheck = config.SpellCheck
+	h.languageAliases = config.LanguageAliases
+	h.commandLimit.setMax(config.MaxConcurrentCommands)
+	h.maxDiagnosticsPerFile = config.MaxDiagnosticsPerFile
+	h.dedupeByCode = config.DedupeByCode
+	h.minimumSeverity = config.MinimumSeverity
+	h.clearDiagnosticsOnClose = config.ClearDiagnosticsOnClose
+	h.suppressionMarker = config.SuppressionMarker
+	h.diagnosticsCache = config.DiagnosticsCache
+	h.formatOnSave = config.FormatOnSave
+	h.lintAfterOpenDelay = time.Duration(config.LintAfterOpenDelay)
+	h.formatFirstOnly = config.FormatFirstOnly
+	h.formatChain = config.FormatChain
+	h.formatExclude = config.FormatExclude
+	h.formatRangeFallback = config.FormatRangeFallback
+	h.formatOnlyChangedLines = config.FormatOnlyChangedLines
+	h.bufferWordsCompletion = config.BufferWordsCompletion
+	h.pathCompletion = config.PathCompletion
+	h.snippetsDir = config.SnippetsDir
+	h.completionFilter = config.CompletionFilter
 }
 
 // PassthroughServer represents a connection to another language server
@@ -175,20 +457,150 @@ type langHandler struct {
 	files             map[DocumentURI]*File
 	request           chan lintRequest
 	lintDebounce      time.Duration
-	lintTimer         *time.Timer
+	lintTimers        map[DocumentURI]*pendingLint
 	formatDebounce    time.Duration
-	formatTimer       *time.Timer
+	formatTimes       map[DocumentURI]time.Time
 	conn              *jsonrpc2.Conn
 	rootPath          string
 	filename          string
 	folders           []string
 	rootMarkers       []string
+	rootMarkerRules   []RootMarker
 	triggerChars      []string
+	shell             string
+	wsl               bool
+	commandWrapper    string
+	importPreCommit   bool
+	importReviewdog   bool
+
+	reviewdogLanguages   map[string][]string
+	importProjectConfig  bool
+	profiles             map[string]*Config
+	profile              string
+	lintOnlyChangedLines bool
+	taskRunners          bool
+	spellCheck           *SpellCheck
+	speller              speller
+	sshHosts             sshHosts
+	languageAliases      map[string]string
+
+	// origins maps settings to the file they come from when it is not the
+	// global config. See setOrigin.
+	origins map[string]string
+
+	// settingsChanges is the params of the didChangeConfiguration
+	// notifications, applied again over the config file once reloaded.
+	settingsChanges []json.RawMessage
+
+	// publishedURIsMu guards lastPublishedURIs, which the lints of several
+	// documents update at once.
+	publishedURIsMu sync.Mutex
 
 	// lastPublishedURIs is mapping from LanguageID string to mapping of
 	// whether diagnostics are published in a DocumentURI or not.
 	lastPublishedURIs   map[string]map[DocumentURI]struct{}
 	passthroughServers  map[string]*PassthroughServer
+
+	// containers caches the containers started for `run-in-container`.
+	containers containerPool
+	// rootCommands caches the roots printed by `root-command`.
+	rootCommands rootCommandCache
+	// lintCache keeps the results of the lint tools by document text.
+	lintCache lintCache
+	// commandLimit enforces max-concurrent-commands.
+	commandLimit commandLimit
+	// maxDiagnosticsPerFile is max-diagnostics-per-file.
+	maxDiagnosticsPerFile int
+	// dedupeByCode is dedupe-by-code.
+	dedupeByCode bool
+	// minimumSeverity is minimum-severity, and clientMinimumSeverity the
+	// one the client asked for, which wins.
+	minimumSeverity       Severity
+	clientMinimumSeverity Severity
+	// clearDiagnosticsOnClose is clear-diagnostics-on-close.
+	clearDiagnosticsOnClose bool
+	// suppressionMarker is suppression-marker.
+	suppressionMarker string
+	// diagnosticsCache is diagnostics-cache.
+	diagnosticsCache bool
+	// formatOnSave is format-on-save.
+	formatOnSave bool
+	// lintAfterOpenDelay is lint-after-open-delay, and nextOpenLint the
+	// time the lint of the last document opened is due at.
+	lintAfterOpenDelay time.Duration
+	nextOpenLint       time.Time
+	// gitHeads caches the content of the documents committed to HEAD.
+	gitHeads gitHeadCache
+	// formatFirstOnly is format-first-only.
+	formatFirstOnly bool
+	// formatChain is format-chain.
+	formatChain string
+	// formatExclude is format-exclude.
+	formatExclude []string
+	// formatRangeFallback is format-range-fallback.
+	formatRangeFallback string
+	// formatOnlyChangedLines is format-only-changed-lines.
+	formatOnlyChangedLines bool
+	// bufferWordsCompletion is buffer-words-completion.
+	bufferWordsCompletion bool
+	// pathCompletion is path-completion.
+	pathCompletion *PathCompletion
+	// snippetsDir is snippets-dir.
+	snippetsDir string
+	// snippets keeps the snippets of the files of snippetsDir.
+	snippets snippetFiles
+	// completionFilter is completion-filter.
+	completionFilter string
+	// published remembers the diagnostics published for each URI.
+	published publishedDiagnostics
+	// formatFailures keeps the diagnostics of the formatters which failed.
+	formatFailures formatFailures
+	// completionCache keeps the last completion items of each document.
+	completionCache completionCache
+	// publishQueue coalesces the diagnostics published for each URI.
+	publishQueue publishQueue
+	// pullDiagnostics tells if the client pulls the diagnostics of the open
+	// documents, and workspaceDiagnostics the ones of the other files found
+	// by workspace linters. They are kept in pulled.
+	pullDiagnostics      bool
+	workspaceDiagnostics bool
+	pulled               pulledDiagnostics
+	// pending keeps the requests answered with a deferredResult, which
+	// $/cancelRequest cancels.
+	pending pendingRequests
+	// resolveCodeActions tells if the client resolves the edit of code
+	// actions, so that the fix-commands only run for the one chosen.
+	resolveCodeActions bool
+	// lintOrder drops the results of the lints superseded by newer ones.
+	lintOrder lintOrder
+	// lintServers keeps the lint-server tools running.
+	lintServers lintServerPool
+	// dynamicWatchedFiles tells if the client can be asked to watch the
+	// lint-watch globs, and watchedGlobs are the ones it was asked for.
+	dynamicWatchedFiles bool
+	watchedGlobs        []string
+
+	// done is closed when the session is closed. linter closes
+	// linterStopped once it stopped starting lints, which are tracked by
+	// lints.
+	done          chan struct{}
+	linterStopped chan struct{}
+	lints         sync.WaitGroup
+	closeOnce     sync.Once
+
+	// shutdown is set once the client sent the shutdown request. Later
+	// requests are refused.
+	shutdown bool
+}
+
+// cloneConfigs copies configs so that tools added to one session don't leak
+// into the others.
+func cloneConfigs(configs map[string][]Language) map[string][]Language {
+	clone := make(map[string][]Language, len(configs))
+	for lang, cfgs := range configs {
+		clone[lang] = append([]Language(nil), cfgs...)
+	}
+	return clone
 }
 
 // File is
@@ -198,6 +610,17 @@ type File struct {
 	Version    int
 }
 
+// excludesLanguage reports whether the wildcard tool cfg must skip
+// languageID. Patterns like "json*" are allowed.
+func excludesLanguage(cfg Language, languageID string) bool {
+	for _, pattern := range cfg.ExcludeLanguages {
+		if ok, _ := filepath.Match(pattern, languageID); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // WordAt is
 func (f *File) WordAt(pos Position) string {
 	lines := strings.Split(f.Text, "\n")
@@ -270,18 +693,86 @@ func toURI(path string) DocumentURI {
 	}).String())
 }
 
+// pendingLint is a lint of a document waiting for the debounce delay.
+type pendingLint struct {
+	timer     *time.Timer
+	eventType eventType
+}
+
+// lintAfterOpenStagger is the minimum time between the lints of the
+// documents opened together with lint-after-open-delay.
+const lintAfterOpenStagger = 200 * time.Millisecond
+
+// lintRequest lints uri once no other request came for it during the
+// debounce delay, or lint-after-open-delay after it was opened. Every
+// document has its own delay, so that editing one doesn't hold back the lint
+// of another.
 func (h *langHandler) lintRequest(uri DocumentURI, eventType eventType) {
-	if h.lintTimer != nil {
-		h.lintTimer.Reset(h.lintDebounce)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delay := h.lintDebounce
+	if eventType == eventTypeOpen && h.lintAfterOpenDelay > 0 {
+		// The documents opened together are linted one after another,
+		// lintAfterOpenStagger apart, rather than all at the end of the
+		// delay.
+		at := time.Now().Add(h.lintAfterOpenDelay)
+		if next := h.nextOpenLint.Add(lintAfterOpenStagger); next.After(at) {
+			at = next
+		}
+		h.nextOpenLint = at
+		delay = time.Until(at)
+	}
+	if p, ok := h.lintTimers[uri]; ok {
+		// A save runs the lint-on-save tools too, so it isn't downgraded
+		// by a later change.
+		if p.eventType != eventTypeSave {
+			p.eventType = eventType
+		}
+		p.timer.Reset(delay)
 		return
 	}
-	h.lintTimer = time.AfterFunc(h.lintDebounce, func() {
-		h.lintTimer = nil
-		h.request <- lintRequest{URI: uri, EventType: eventType}
+	if h.lintTimers == nil {
+		h.lintTimers = make(map[DocumentURI]*pendingLint)
+	}
+	p := &pendingLint{eventType: eventType}
+	p.timer = time.AfterFunc(delay, func() {
+		h.mu.Lock()
+		if h.lintTimers[uri] == p {
+			delete(h.lintTimers, uri)
+		}
+		eventType := p.eventType
+		h.mu.Unlock()
+		select {
+		case h.request <- lintRequest{URI: uri, EventType: eventType}:
+		case <-h.done:
+		}
 	})
+	h.lintTimers[uri] = p
+}
+
+// cancelLintRequest drops the lint of uri waiting for its delay, e.g. when
+// the document is closed before.
+func (h *langHandler) cancelLintRequest(uri DocumentURI) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p, ok := h.lintTimers[uri]; ok {
+		p.timer.Stop()
+		delete(h.lintTimers, uri)
+	}
+}
+
+// logPanic logs a recovered panic with its stack, so that a bug in one
+// request or linter doesn't take the server down.
+func (h *langHandler) logPanic(where string, r any) {
+	h.logger.Printf("panic in %s: %v\n%s", where, r, debug.Stack())
 }
 
 func (h *langHandler) logMessage(typ MessageType, message string) {
+	if h.conn == nil {
+		// There is no client yet.
+		return
+	}
 	h.conn.Notify(
 		context.Background(),
 		"window/logMessage",
@@ -292,6 +783,9 @@ func (h *langHandler) logMessage(typ MessageType, message string) {
 }
 
 func (h *langHandler) showMessage(typ MessageType, message string) {
+	if h.conn == nil {
+		return
+	}
 	h.conn.Notify(
 		context.Background(),
 		"window/showMessage",
@@ -301,13 +795,21 @@ func (h *langHandler) showMessage(typ MessageType, message string) {
 		})
 }
 
+// linter runs the lint requests until the session is closed, then cancels
+// the running ones.
 func (h *langHandler) linter() {
 	running := make(map[DocumentURI]context.CancelFunc)
+	defer close(h.linterStopped)
 
 	for {
-		lintReq, ok := <-h.request
-		if !ok {
-			break
+		var lintReq lintRequest
+		select {
+		case lintReq = <-h.request:
+		case <-h.done:
+			for _, cancel := range running {
+				cancel()
+			}
+			return
 		}
 
 		cancel, ok := running[lintReq.URI]
@@ -317,13 +819,35 @@ func (h *langHandler) linter() {
 
 		ctx, cancel := context.WithCancel(context.Background())
 		running[lintReq.URI] = cancel
+		n := h.lintOrder.start(lintReq.URI)
 
+		h.lints.Add(1)
 		go func() {
+			defer h.lints.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					h.logPanic(fmt.Sprintf("lint of %s", lintReq.URI), r)
+				}
+			}()
+
 			uriToDiagnostics, err := h.lint(ctx, lintReq.URI, lintReq.EventType)
 			if err != nil {
 				h.logger.Println(err)
 				return
 			}
+			// A newer lint of the document superseded this one.
+			if ctx.Err() != nil || !h.lintOrder.finish(lintReq.URI, n) {
+				return
+			}
+			if diagnostics, ok := h.spellCheckDiagnostics(lintReq.URI); ok {
+				uriToDiagnostics[lintReq.URI] = append(uriToDiagnostics[lintReq.URI], diagnostics...)
+			}
+			if f, ok := h.files[lintReq.URI]; ok {
+				h.formatFailures.linted(lintReq.URI, f.Version, uriToDiagnostics[lintReq.URI])
+				if diagnostics := h.formatFailures.get(lintReq.URI, f.Version); diagnostics != nil {
+					uriToDiagnostics[lintReq.URI] = append(uriToDiagnostics[lintReq.URI], diagnostics...)
+				}
+			}
 
 			for diagURI, diagnostics := range uriToDiagnostics {
 				if diagURI == "file:" {
@@ -333,58 +857,60 @@ func (h *langHandler) linter() {
 				if _, ok := h.files[lintReq.URI]; ok {
 					version = h.files[lintReq.URI].Version
 				}
-				h.conn.Notify(
-					ctx,
-					"textDocument/publishDiagnostics",
-					&PublishDiagnosticsParams{
-						URI:         diagURI,
-						Diagnostics: diagnostics,
-						Version:     version,
-					})
+				// The other files of workspace linters are only published
+				// when their diagnostics changed.
+				h.publishDiagnostics(ctx, diagURI, diagnostics, version, diagURI != lintReq.URI)
 			}
 		}()
 	}
 }
 
-func matchRootPath(fname string, markers []string) string {
+// matchRootPath returns the directory of the marker with the highest
+// priority among the parents of fname, the nearest one for equal
+// priorities, or "" if there is none.
+func matchRootPath(fname string, markers []RootMarker) string {
+	if len(markers) == 0 {
+		return ""
+	}
+	maxPriority := markers[0].Priority
+	for _, marker := range markers {
+		maxPriority = max(maxPriority, marker.Priority)
+	}
+
+	var root string
+	var rootPriority int
 	dir := filepath.Dir(filepath.Clean(fname))
 	var prev string
 	for dir != prev {
 		files, _ := os.ReadDir(dir)
 		for _, file := range files {
-			name := file.Name()
-			isDir := file.IsDir()
 			for _, marker := range markers {
-				if strings.HasSuffix(marker, "/") {
-					if !isDir {
-						continue
-					}
-					marker = strings.TrimRight(marker, "/")
-					if ok, _ := filepath.Match(marker, name); ok {
-						return dir
-					}
-				} else {
-					if isDir {
-						continue
-					}
-					if ok, _ := filepath.Match(marker, name); ok {
-						return dir
-					}
+				if (root == "" || marker.Priority > rootPriority) && marker.matches(dir, file.Name(), file.IsDir()) {
+					root, rootPriority = dir, marker.Priority
 				}
 			}
 		}
+		if root != "" && rootPriority == maxPriority {
+			// No marker further up can win.
+			break
+		}
 		prev = dir
 		dir = filepath.Dir(dir)
 	}
 
-	return ""
+	return root
 }
 
 func (h *langHandler) findRootPath(fname string, lang Language) string {
-	if dir := matchRootPath(fname, lang.RootMarkers); dir != "" {
+	if lang.RootCommand != "" {
+		if dir := h.commandRoot(fname, lang); dir != "" {
+			return dir
+		}
+	}
+	if dir := matchRootPath(fname, lang.markers()); dir != "" {
 		return dir
 	}
-	if dir := matchRootPath(fname, h.rootMarkers); dir != "" {
+	if dir := matchRootPath(fname, h.markers()); dir != "" {
 		return dir
 	}
 
@@ -406,11 +932,24 @@ func isFilename(s string) bool {
 	}
 }
 
+// forEvent returns cfg with the lint-command-on-save or
+// lint-command-on-change variant replacing lint-command for eventType.
+func (cfg Language) forEvent(eventType eventType) Language {
+	switch {
+	case eventType == eventTypeSave && cfg.LintCommandOnSave != "":
+		cfg.LintCommand = cfg.LintCommandOnSave
+	case eventType == eventTypeChange && cfg.LintCommandOnChange != "":
+		cfg.LintCommand = cfg.LintCommandOnChange
+	}
+	return cfg
+}
+
 func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType eventType) (map[DocumentURI][]Diagnostic, error) {
-	f, ok := h.files[uri]
+	doc, ok := h.snapshot(uri)
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
 	}
+	f := &doc.file
 
 	fname, err := fromURI(uri)
 	if err != nil {
@@ -418,32 +957,45 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 	}
 	fname = filepath.ToSlash(fname)
 
+	// The tools may be replaced meanwhile by a reload of the config file.
+	// The lock is released by a defer, so that a panic doesn't keep it.
+	var langConfigs, wildcardConfigs []Language
+	var hasLangConfigs, hasWildcardConfigs, onlyChangedLines bool
+	func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		langConfigs, hasLangConfigs = h.configsFor(uri)
+		wildcardConfigs, hasWildcardConfigs = h.configs[wildcard]
+		onlyChangedLines = h.lintOnlyChangedLines
+	}()
+
 	var configs []Language
 	var hasConfigForLangID bool
 	var lintToolsForLangID int
 	var skippedReasons []string
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if hasLangConfigs {
 		hasConfigForLangID = true
-		for _, cfg := range cfgs {
+		for _, cfg := range langConfigs {
 			if cfg.LintCommand != "" {
 				lintToolsForLangID++
 			}
 			// if we require markers and find that they dont exist we do not add the configuration
-			if dir := matchRootPath(fname, cfg.RootMarkers); dir == "" && cfg.RequireMarker == true {
+			if dir := matchRootPath(fname, cfg.markers()); dir == "" && cfg.RequireMarker == true {
 				msg := fmt.Sprintf("skipping tool for language `%s` because `require-marker` is true and no root markers were found for file `%s`", f.LanguageID, fname)
 				if h.loglevel >= 1 {
-					h.logger.Printf(msg)
+					h.logger.Print(msg)
 				}
 				skippedReasons = append(skippedReasons, msg)
 				continue
 			}
+			cfg = cfg.forEvent(eventType)
 			switch eventType {
 			case eventTypeOpen:
 				// if LintAfterOpen is not true, ignore didOpen
 				if !cfg.LintAfterOpen {
 					msg := fmt.Sprintf("skipping tool for language `%s` on file open because `lint-after-open` is not true. Lint command: `%s`", f.LanguageID, cfg.LintCommand)
 					if h.loglevel >= 1 {
-						h.logger.Printf(msg)
+						h.logger.Print(msg)
 					}
 					skippedReasons = append(skippedReasons, msg)
 					continue
@@ -453,7 +1005,7 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 				if cfg.LintOnSave {
 					msg := fmt.Sprintf("skipping tool for language `%s` on file change because `lint-on-save` is true. Lint command: `%s`", f.LanguageID, cfg.LintCommand)
 					if h.loglevel >= 1 {
-						h.logger.Printf(msg)
+						h.logger.Print(msg)
 					}
 					skippedReasons = append(skippedReasons, msg)
 					continue
@@ -470,8 +1022,12 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 			}
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
-		for _, cfg := range cfgs {
+	if hasWildcardConfigs {
+		for _, cfg := range wildcardConfigs {
+			if excludesLanguage(cfg, f.LanguageID) {
+				continue
+			}
+			cfg = cfg.forEvent(eventType)
 			if cfg.LintCommand != "" {
 				if h.loglevel >= 1 {
 					h.logger.Printf("appending wildcard tool for language `%s` with lint command: `%s`", f.LanguageID, cfg.LintCommand)
@@ -490,14 +1046,14 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 				h.logger.Printf("no configuration found for linting language `%s`. Check the `languages` section in your config file.", f.LanguageID)
 			} else if lintToolsForLangID == 0 {
 				msg = fmt.Sprintf("configuration for linting language `%s` found, but no tools have a lint-command configured", f.LanguageID)
-				h.logger.Printf(msg)
+				h.logger.Print(msg)
 			} else {
 				if len(skippedReasons) > 0 {
 					msg = strings.Join(skippedReasons, "; ")
 				} else {
 					msg = fmt.Sprintf("configuration for linting language `%s` found, but no tools were applicable for the current action", f.LanguageID)
 				}
-				h.logger.Printf(msg)
+				h.logger.Print(msg)
 			}
 		}
 		if msg != "" {
@@ -510,245 +1066,476 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 		uri: {},
 	}
 	publishedURIs := make(map[DocumentURI]struct{})
-	for i, config := range configs {
+	for _, config := range configs {
 		// To publish empty diagnostics when errors are fixed
 		if config.LintWorkspace {
+			h.publishedURIsMu.Lock()
 			for lastPublishedURI := range h.lastPublishedURIs[f.LanguageID] {
 				if _, ok := uriToDiagnostics[lastPublishedURI]; !ok {
 					uriToDiagnostics[lastPublishedURI] = []Diagnostic{}
 				}
 			}
+			h.publishedURIsMu.Unlock()
 		}
+	}
 
-		if config.LintCommand == "" {
-			continue
+	// The tools run at the same time, and their results are merged in the
+	// order of the configs.
+	results := make([]lintResult, len(configs))
+	errs := make([]error, len(configs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxParallelLintTools)
+	for i := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					h.logPanic(fmt.Sprintf("lint tool `%s`", configs[i].LintCommand), r)
+					errs[i] = fmt.Errorf("lint tool `%s` panicked: %v", configs[i].LintCommand, r)
+				}
+			}()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = h.cachedLintTool(ctx, uri, f, fname, configs[i])
+		}()
+	}
+	wg.Wait()
+	for i, result := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-
-		command := config.LintCommand
-		if !config.LintStdin && !config.LintWorkspace && !strings.Contains(command, "${INPUT}") {
-			command = command + " ${INPUT}"
+		if result.canceled {
+			h.rememberStreamedURIs(f.LanguageID, results)
+			return nil, nil
+		}
+		for diagURI, diagnostics := range result.diagnostics {
+			// The diagnostics of the result may be cached, so only the
+			// copies are changed.
+			n := len(uriToDiagnostics[diagURI])
+			uriToDiagnostics[diagURI] = append(uriToDiagnostics[diagURI], diagnostics...)
+			describeCodes(&configs[i], uriToDiagnostics[diagURI][n:])
+			overrideSeverities(&configs[i], uriToDiagnostics[diagURI][n:])
+			uriToDiagnostics[diagURI] = append(uriToDiagnostics[diagURI][:n],
+				truncateDiagnostics(uriToDiagnostics[diagURI][n:], configs[i].MaxDiagnosticsPerFile)...)
+		}
+		for diagURI := range result.publishedURIs {
+			publishedURIs[diagURI] = struct{}{}
+			// The URI may only have streamed diagnostics to clear.
+			if _, ok := uriToDiagnostics[diagURI]; !ok {
+				uriToDiagnostics[diagURI] = []Diagnostic{}
+			}
 		}
-		rootPath := h.findRootPath(fname, config)
-		command = replaceCommandInputFilename(command, fname, rootPath)
+	}
 
-		formats := config.LintFormats
-		if len(formats) == 0 {
-			formats = []string{"%f:%l:%m", "%f:%l:%c:%m"}
+	if onlyChangedLines {
+		if head, ok := h.gitHeads.content(fname); ok {
+			uriToDiagnostics[uri] = filterChangedLines(uriToDiagnostics[uri], changedLines(head, f.Text))
 		}
+	}
+	marker := cmp.Or(h.suppressionMarker, defaultSuppressionMarker)
+	uriToDiagnostics[uri] = findSuppressions(f.Text, marker).filter(uriToDiagnostics[uri])
+	for diagURI, diagnostics := range uriToDiagnostics {
+		diagnostics = filterSeverity(diagnostics, cmp.Or(h.clientMinimumSeverity, h.minimumSeverity))
+		diagnostics = dedupeDiagnostics(diagnostics, h.dedupeByCode)
+		uriToDiagnostics[diagURI] = truncateDiagnostics(diagnostics, h.maxDiagnosticsPerFile)
+	}
 
-		efms, err := errorformat.NewErrorformat(formats)
-		if err != nil {
-			return nil, fmt.Errorf("invalid error-format: %v", config.LintFormats)
+	// Update state here as no possibility of cancelation
+	for _, config := range configs {
+		if config.LintWorkspace {
+			h.publishedURIsMu.Lock()
+			h.lastPublishedURIs[f.LanguageID] = publishedURIs
+			h.publishedURIsMu.Unlock()
+			break
 		}
+	}
+	return uriToDiagnostics, nil
+}
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.CommandContext(ctx, "cmd", "/c", command)
-		} else {
-			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+// maxParallelLintTools bounds the lint commands of a document running at
+// once.
+const maxParallelLintTools = 4
+
+// lintResult is what a lint tool found.
+type lintResult struct {
+	diagnostics   map[DocumentURI][]Diagnostic
+	publishedURIs map[DocumentURI]struct{}
+	// canceled is set if the lint was canceled by a newer one.
+	canceled bool
+	// timedOut is set if the tool was killed after its lint-timeout.
+	timedOut bool
+}
+
+// add records diagnostics of diagURI. A tool not linting the workspace only
+// reports the diagnostics of the document uri.
+func (r *lintResult) add(uri, diagURI DocumentURI, workspace bool, diagnostics ...Diagnostic) {
+	if runtime.GOOS == "windows" {
+		if strings.ToLower(string(diagURI)) != strings.ToLower(string(uri)) && !workspace {
+			return
 		}
-		cmd.Dir = rootPath
-		cmd.Env = append(os.Environ(), config.Env...)
-		if config.LintStdin {
-			cmd.Stdin = strings.NewReader(f.Text)
+	} else {
+		if diagURI != uri && !workspace {
+			return
 		}
-		b, err := cmd.CombinedOutput()
+	}
+
+	if workspace {
+		r.publishedURIs[diagURI] = struct{}{}
+	}
+	r.diagnostics[diagURI] = append(r.diagnostics[diagURI], diagnostics...)
+}
+
+// lintTool runs the lint command of config for the document uri.
+func (h *langHandler) lintTool(ctx context.Context, uri DocumentURI, f *File, fname string, config Language) (lintResult, error) {
+	result := lintResult{
+		diagnostics:   make(map[DocumentURI][]Diagnostic),
+		publishedURIs: make(map[DocumentURI]struct{}),
+	}
+	if config.LintCommand == "" {
+		return result, nil
+	}
+
+	command := config.LintCommand
+	if !config.LintStdin && !config.LintWorkspace && !config.LintServer && !strings.Contains(command, "${INPUT}") {
+		command = command + " ${INPUT}"
+	}
+	rootPath := h.findRootPath(fname, config)
+	p := h.placeholdersFor(&config, fname, f, nil)
+	p.rootPath = rootPath
+	command = p.replace(command)
+	commands := []string{command}
+	if config.LintWorkspace && strings.Contains(command, filesPlaceholder) {
+		files, err := lintFiles(&config, rootPath, fname)
 		if err != nil {
-			if succeeded(err) {
-				return nil, nil
-			}
+			return result, fmt.Errorf("cannot list the files of %s: %v", rootPath, err)
 		}
-		// Most of lint tools exit with non-zero value. But some commands
-		// return with zero value. We can not handle the output is real result
-		// or output of usage. So efm-langserver ignore that command exiting
-		// with zero-value. So if you want to handle the command which exit
-		// with zero value, please specify lint-ignore-exit-code.
-		if err == nil && !config.LintIgnoreExitCode {
-			h.logMessage(LogError, "command `"+command+"` exit with zero. probably you forgot to specify `lint-ignore-exit-code: true`.")
-			continue
+		if len(files) == 0 {
+			return result, nil
 		}
+		commands = filesCommands(command, p.shell, files, config.LintMaxCommandLength)
+	}
+
+	formats := config.LintFormats
+	if len(formats) == 0 {
+		formats = []string{"%f:%l:%m", "%f:%l:%c:%m"}
+	}
+
+	efms, err := errorformat.NewErrorformat(formats)
+	if err != nil {
+		return result, fmt.Errorf("invalid error-format: %v", config.LintFormats)
+	}
+
+	// The source and prefix are plain text, not shell words.
+	p.shell = ""
+	var source *string
+	if config.LintSource != "" {
+		lintSource := p.replace(config.LintSource)
+		source = &lintSource
 		if h.loglevel >= 3 {
-			h.logger.Println("[Ran Lint Command]: "+command)
-			h.logger.Println("[Lint Command Output]:", string(b))
-		}
-		if config.LintJQ != "" {
-			var jsonData any
-			if err := json.Unmarshal(b, &jsonData); err == nil {
-				query, err := gojq.Parse(config.LintJQ)
-				if err == nil {
-					iter := query.Run(jsonData)
-					for {
-						v, ok := iter.Next()
-						if !ok {
-							break
-						}
-						diagMap, ok := v.(map[string]interface{})
-						if !ok {
-							continue
-						}
-						file, _ := diagMap["file"].(string)
-						message, _ := diagMap["message"].(string)
-						severityStr, _ := diagMap["severity"].(string)
-						rule, _ := diagMap["rule"].(string)
-						var rng Range
-						if r, ok := diagMap["range"].(map[string]interface{}); ok {
-							if s, ok := r["start"].(map[string]interface{}); ok {
-								rng.Start.Line = int(safeFloat(s["line"]))
-								rng.Start.Character = int(safeFloat(s["character"]))
-							}
-							if e, ok := r["end"].(map[string]interface{}); ok {
-								rng.End.Line = int(safeFloat(e["line"]))
-								rng.End.Character = int(safeFloat(e["character"]))
-							}
-						}
-						severity := 1
-						switch strings.ToLower(severityStr) {
-						case "error":
-							severity = 1
-						case "warning":
-							severity = 2
-						case "information", "info":
-							severity = 3
-						case "hint":
-							severity = 4
-						}
-						uriForDiag := uri
-						if file != "" {
-							uriForDiag = toURI(file)
-						}
-						diag := Diagnostic{
-							Range:    rng,
-							Severity: severity,
-							Message:  message,
-							Code:     &rule,
-							Source:   nil,
-						}
-						uriToDiagnostics[uriForDiag] = append(uriToDiagnostics[uriForDiag], diag)
-					}
-					continue
-				}
+			if source != nil {
+				h.logger.Println("[Lint Command Source]:" + *source)
+			} else {
+				h.logger.Println("[Lint Command Source]: nil")
 			}
 		}
-		var source *string
-		if config.LintSource != "" {
-			source = &configs[i].LintSource
-			if h.loglevel >= 3 {
-				if source != nil {
-					h.logger.Println("[Lint Command Source]:" + *source)
-				} else {
-					h.logger.Println("[Lint Command Source]: nil")
-				}
+	}
+
+	var prefix string
+	if config.Prefix != "" {
+		prefix = fmt.Sprintf("[%s] ", p.replace(config.Prefix))
+	}
+
+	// The last diagnostic added, to which the notes following it are
+	// attached with lint-related-information.
+	lastURI, last := DocumentURI(""), -1
+	tool := toolName(&config, source)
+	texts := lineTexts{
+		open:  h.openText,
+		lines: map[DocumentURI][]string{uri: strings.Split(f.Text, "\n")},
+	}
+	// code replaces the number of the entry if it is not nil, and fix is the
+	// replacement of its range the tool suggests.
+	addEntry := func(entry *errorformat.Entry, code, fix *string) {
+		if !entry.Valid {
+			return
+		}
+		if code == nil {
+			code = itoaPtrIfNotZero(entry.Nr)
+		}
+		if (config.LintStdin || config.LintServer) && isFilename(entry.Filename) {
+			entry.Filename = fname
+			path, err := filepath.Abs(entry.Filename)
+			if err != nil {
+				return
 			}
+			path = filepath.ToSlash(path)
+			if runtime.GOOS == "windows" && strings.ToLower(path) != strings.ToLower(fname) {
+				return
+			} else if path != fname {
+				return
+			}
+		} else {
+			entry.Filename = filepath.ToSlash(h.localPath(&config, rootPath, entry.Filename))
 		}
+		word := ""
 
-		var prefix string
-		if config.Prefix != "" {
-			prefix = fmt.Sprintf("[%s] ", config.Prefix)
+		// entry.Col is expected to be one based, if the linter returns zero based we
+		// have the ability to add an offset here.
+		// We only add the offset if the linter reports entry.Col > 0 because 0 means the whole line
+		if config.LintOffsetColumns > 0 && entry.Col > 0 {
+			entry.Col = entry.Col + config.LintOffsetColumns
 		}
 
-		scanner := efms.NewScanner(bytes.NewReader(b))
-		for scanner.Scan() {
-			entry := scanner.Entry()
-			if !entry.Valid {
-				continue
-			}
-			if config.LintStdin && isFilename(entry.Filename) {
-				entry.Filename = fname
-				path, err := filepath.Abs(entry.Filename)
-				if err != nil {
-					continue
-				}
-				path = filepath.ToSlash(path)
-				if runtime.GOOS == "windows" && strings.ToLower(path) != strings.ToLower(fname) {
-					continue
-				} else if path != fname {
-					continue
-				}
+		if entry.Lnum == 0 {
+			entry.Lnum = 1 // entry.Lnum == 0 indicates the top line, set to 1 because it is subtracted later
+		}
+
+		diagURI := uri
+		if entry.Filename != "" {
+			if filepath.IsAbs(entry.Filename) {
+				diagURI = toURI(entry.Filename)
 			} else {
-				entry.Filename = filepath.ToSlash(entry.Filename)
+				diagURI = toURI(filepath.Join(rootPath, entry.Filename))
 			}
-			word := ""
+		}
 
-			// entry.Col is expected to be one based, if the linter returns zero based we
-			// have the ability to add an offset here.
-			// We only add the offset if the linter reports entry.Col > 0 because 0 means the whole line
-			if config.LintOffsetColumns > 0 && entry.Col > 0 {
-				entry.Col = entry.Col + config.LintOffsetColumns
+		// The columns counted in other units than the UTF-16 ones of LSP,
+		// e.g. bytes, are converted using the text of their line.
+		if config.LintColumnEncoding != "" {
+			line := entry.Lnum - 1 - config.LintOffset
+			if entry.Col > 0 {
+				entry.Col = utf16Column(texts.line(diagURI, line), entry.Col-1, config.LintColumnEncoding) + 1
 			}
-
-			if entry.Lnum == 0 {
-				entry.Lnum = 1 // entry.Lnum == 0 indicates the top line, set to 1 because it is subtracted later
+			if entry.EndCol > 0 {
+				if entry.EndLnum > 0 {
+					line = entry.EndLnum - 1 - config.LintOffset
+				}
+				col := utf16Column(texts.line(diagURI, line), entry.EndCol-1+config.LintOffsetColumns, config.LintColumnEncoding)
+				entry.EndCol = col + 1 - config.LintOffsetColumns
 			}
+		}
 
-			if entry.Col == 0 {
-				entry.Col = 1 // entry.Col == 0 indicates the whole line without column, set to 1 because it is subtracted later
-			} else {
-				word = f.WordAt(Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1})
-			}
+		if entry.Col == 0 {
+			entry.Col = 1 // entry.Col == 0 indicates the whole line without column, set to 1 because it is subtracted later
+		} else {
+			word = f.WordAt(Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1})
+		}
 
-			// we allow the config to provide a mapping between LSP types E,W,I,N and whatever categories the linter has
-			if len(config.LintCategoryMap) > 0 {
-				entry.Type = []rune(config.LintCategoryMap[string(entry.Type)])[0]
-			}
+		// we allow the config to provide a mapping between LSP types E,W,I,N and whatever categories the linter has
+		if category := config.LintCategoryMap[string(entry.Type)]; category != "" {
+			entry.Type = []rune(category)[0]
+		}
 
-			severity := 1
-			if config.LintSeverity != 0 {
-				severity = config.LintSeverity
-			}
+		severity := 1
+		if config.LintSeverity != 0 {
+			severity = config.LintSeverity
+		}
 
-			switch entry.Type {
-			case 'E', 'e':
-				severity = 1
-			case 'W', 'w':
-				severity = 2
-			case 'I', 'i':
-				severity = 3
-			case 'N', 'n':
-				severity = 4
+		switch entry.Type {
+		case 'E', 'e':
+			severity = 1
+		case 'W', 'w':
+			severity = 2
+		case 'I', 'i':
+			severity = 3
+		case 'N', 'n':
+			severity = 4
+		}
+
+		start := Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1}
+		end := Position{Line: start.Line, Character: start.Character + len([]rune(word))}
+		if entry.EndLnum > 0 || entry.EndCol > 0 {
+			end = entryEnd(entry, start, config)
+		}
+		if config.LintRelatedInformation && (entry.Type == 'N' || entry.Type == 'n') {
+			if last >= 0 {
+				d := &result.diagnostics[lastURI][last]
+				d.RelatedInformation = append(d.RelatedInformation, DiagnosticRelatedInformation{
+					Location: Location{URI: diagURI, Range: Range{Start: start, End: end}},
+					Message:  entry.Text,
+				})
+				return
+			}
+		}
+		n := len(result.diagnostics[diagURI])
+		result.add(uri, diagURI, config.LintWorkspace, Diagnostic{
+			Range:    Range{Start: start, End: end},
+			Code:     code,
+			Message:  prefix + entry.Text,
+			Severity: severity,
+			Source:   source,
+			Data:     newDiagnosticData(tool, code, strings.Join(entry.Lines, "\n"), fix, nil),
+		})
+		// The notes following a diagnostic that was dropped, e.g. one of
+		// another file, belong to it and not to the one before.
+		if len(result.diagnostics[diagURI]) > n {
+			lastURI, last = diagURI, n
+		} else {
+			last = -1
+		}
+	}
+	scan := func(r io.Reader, publish func()) {
+		scanner := efms.NewScanner(r)
+		for scanner.Scan() {
+			addEntry(scanner.Entry(), nil, nil)
+			if publish != nil {
+				publish()
 			}
+		}
+	}
 
-			diagURI := uri
-			if entry.Filename != "" {
-				if filepath.IsAbs(entry.Filename) {
-					diagURI = toURI(entry.Filename)
-				} else {
-					diagURI = toURI(filepath.Join(rootPath, entry.Filename))
-				}
+	// The lint-timeout starts once max-concurrent-commands allows the tool
+	// to run, so that the time waiting for a slot doesn't count. A lint
+	// canceled while waiting didn't run at all.
+	release, err := h.commandLimit.acquire(ctx)
+	if err != nil {
+		result.canceled = true
+		return result, nil
+	}
+	defer release()
+	if config.LintTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.LintTimeout))
+		defer cancel()
+	}
+	// The diagnostics of a workspace linter are published as they are
+	// found, since such linters may run for a long time.
+	stream := config.LintWorkspace && !config.LintServer && config.LintJQ == "" && config.LintPattern == "" &&
+		(config.LintFormatType == "" || config.LintFormatType == lintFormatTypeErrorformat)
+	var b []byte
+	if config.LintServer {
+		b, err = h.lintServerOutput(ctx, &config, rootPath, command, fname, f.Text)
+	} else {
+		publish := h.partialPublisher(&result, f.Version)
+		// The commands of the chunks of ${FILES} run one after another,
+		// and their outputs are parsed as one.
+		for _, command := range commands {
+			cmd, cmdErr := h.newCommand(ctx, &config, rootPath, command)
+			if cmdErr != nil {
+				return result, cmdErr
 			}
-			if runtime.GOOS == "windows" {
-				if strings.ToLower(string(diagURI)) != strings.ToLower(string(uri)) && !config.LintWorkspace {
-					continue
-				}
+			if config.LintStdin {
+				cmd.Stdin = strings.NewReader(f.Text)
+			}
+			// Children leaving the process group may keep the output open.
+			cmd.WaitDelay = time.Second
+			var out []byte
+			if stream {
+				out, cmdErr = h.streamOutput(cmd, func(r io.Reader) {
+					scan(r, publish)
+				})
 			} else {
-				if diagURI != uri && !config.LintWorkspace {
-					continue
-				}
+				out, cmdErr = cmd.CombinedOutput()
 			}
-
-			if config.LintWorkspace {
-				publishedURIs[diagURI] = struct{}{}
+			b = append(b, out...)
+			if err == nil {
+				err = cmdErr
+			}
+			if ctx.Err() != nil {
+				err = cmdErr
+				break
 			}
-			uriToDiagnostics[diagURI] = append(uriToDiagnostics[diagURI], Diagnostic{
-				Range: Range{
-					Start: Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1},
-					End:   Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1 + len([]rune(word))},
-				},
-				Code:     itoaPtrIfNotZero(entry.Nr),
-				Message:  prefix + entry.Text,
-				Severity: severity,
-				Source:   source,
-			})
 		}
 	}
-
-	// Update state here as no possibility of cancelation
-	for _, config := range configs {
-		if config.LintWorkspace {
-			h.lastPublishedURIs[f.LanguageID] = publishedURIs
-			break
+	// Diagnostics already streamed are cleared by the final publish of the
+	// URIs in publishedURIs.
+	discard := func() {
+		result.diagnostics = make(map[DocumentURI][]Diagnostic)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		msg := fmt.Sprintf("lint command `%s` was killed after the lint-timeout of %v", command, time.Duration(config.LintTimeout))
+		h.logger.Println(msg)
+		h.showMessage(LogWarning, msg)
+		discard()
+		result.timedOut = true
+		return result, nil
+	}
+	if err != nil {
+		if succeeded(err) || errors.Is(err, context.Canceled) {
+			result.canceled = true
+			return result, nil
+		}
+		if config.LintServer {
+			return result, err
 		}
 	}
-	return uriToDiagnostics, nil
+	// Most of lint tools exit with non-zero value. But some commands
+	// return with zero value. We can not handle the output is real result
+	// or output of usage. So efm-langserver ignore that command exiting
+	// with zero-value. So if you want to handle the command which exit
+	// with zero value, please specify lint-ignore-exit-code.
+	if err == nil && !config.LintIgnoreExitCode && !config.LintServer {
+		h.logMessage(LogError, "command `"+command+"` exit with zero. probably you forgot to specify `lint-ignore-exit-code: true`.")
+		discard()
+		return result, nil
+	}
+	if h.loglevel >= 3 {
+		h.logger.Println("[Ran Lint Command]: "+command)
+		h.logger.Println("[Lint Command Output]:", string(b))
+	}
+	var parse func([]byte, DocumentURI, string, *Language, *string, string) (map[DocumentURI][]Diagnostic, error)
+	switch config.LintFormatType {
+	case lintFormatTypeSARIF:
+		parse = h.sarifDiagnostics
+	case lintFormatTypeLSP:
+		parse = h.lspOutputDiagnostics
+	}
+	if parse != nil {
+		diagnostics, err := parse(b, uri, rootPath, &config, source, prefix)
+		if err != nil {
+			h.logMessage(LogError, fmt.Sprintf("command `%s` printed no valid %s output: %v", command, config.LintFormatType, err))
+			return result, nil
+		}
+		for diagURI, d := range diagnostics {
+			result.add(uri, diagURI, config.LintWorkspace, d...)
+		}
+		return result, nil
+	}
+	if config.LintJQ != "" {
+		if diagnostics, ok := h.jqOutputDiagnostics(b, uri, rootPath, &config); ok {
+			for diagURI, d := range diagnostics {
+				result.diagnostics[diagURI] = append(result.diagnostics[diagURI], d...)
+			}
+			return result, nil
+		}
+	}
+	if config.LintPattern != "" {
+		re, err := regexp.Compile(config.LintPattern)
+		if err != nil {
+			h.logMessage(LogError, fmt.Sprintf("invalid lint-pattern: %v", err))
+			return result, nil
+		}
+		for _, m := range patternMatches(re, b) {
+			addEntry(&m.entry, m.code, m.fix)
+		}
+		return result, nil
+	}
+	if !stream {
+		scan(bytes.NewReader(b), nil)
+	}
+	return result, nil
+}
+
+// jqRange returns the range of a lint-jq diagnostic, {start, end} of zero
+// based lines and characters.
+func jqRange(v interface{}) Range {
+	var rng Range
+	r, ok := v.(map[string]interface{})
+	if !ok {
+		return rng
+	}
+	if s, ok := r["start"].(map[string]interface{}); ok {
+		rng.Start.Line = int(safeFloat(s["line"]))
+		rng.Start.Character = int(safeFloat(s["character"]))
+	}
+	// Without an end, the range is empty at the start.
+	rng.End = rng.Start
+	if e, ok := r["end"].(map[string]interface{}); ok {
+		rng.End.Line = int(safeFloat(e["line"]))
+		rng.End.Character = int(safeFloat(e["character"]))
+	}
+	return rng
 }
 
 func safeFloat(v interface{}) float64 {
@@ -779,40 +1566,120 @@ func itoaPtrIfNotZero(n int) *string {
 	return &s
 }
 
+// entryEnd returns the end of the range of an entry reporting its end line
+// or column (%e and %k), the end column being one based and exclusive. An
+// end line without a column covers that line up to its end.
+func entryEnd(entry *errorformat.Entry, start Position, config Language) Position {
+	end := Position{Line: start.Line, Character: start.Character}
+	if entry.EndLnum > 0 {
+		end.Line = entry.EndLnum - 1 - config.LintOffset
+	}
+	if entry.EndCol > 0 {
+		end.Character = entry.EndCol - 1 + config.LintOffsetColumns
+	} else {
+		end = Position{Line: end.Line + 1, Character: 0}
+	}
+	if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
+		return start
+	}
+	return end
+}
+
 func (h *langHandler) closeFile(uri DocumentURI) error {
+	clearDiagnostics := h.clearsDiagnosticsOnClose(uri)
+	h.lintCache.forget(uri)
+	h.formatFailures.forget(uri)
+	h.completionCache.forget(uri)
+	h.cancelLintRequest(uri)
+	h.mu.Lock()
 	delete(h.files, uri)
+	delete(h.formatTimes, uri)
+	h.mu.Unlock()
+	if !h.workspaceDiagnostics {
+		h.pulled.forget(uri)
+	}
+	if clearDiagnostics {
+		h.publishDiagnostics(context.Background(), uri, nil, 0, false)
+	}
 	return nil
 }
 
+// clearsDiagnosticsOnClose reports whether the diagnostics of the document
+// uri are cleared when it is closed, with clear-diagnostics-on-close set
+// globally or on one of its tools. The ones of workspace linters stay, since
+// they don't depend on the document being open.
+func (h *langHandler) clearsDiagnosticsOnClose(uri DocumentURI) bool {
+	configs, _ := h.configsFor(uri)
+	clearDiagnostics := h.clearDiagnosticsOnClose
+	for _, config := range configs {
+		if config.LintWorkspace && config.LintCommand != "" {
+			return false
+		}
+		clearDiagnostics = clearDiagnostics || config.ClearDiagnosticsOnClose
+	}
+	return clearDiagnostics
+}
+
 func (h *langHandler) saveFile(uri DocumentURI) error {
 	h.lintRequest(uri, eventTypeSave)
 	return nil
 }
 
-func (h *langHandler) openFile(uri DocumentURI, languageID string, version int) error {
+// resolveLanguage follows the language aliases from languageID to the
+// language id whose configuration is used. Aliases may point to other
+// aliases; a cycle stops at the last id not seen yet.
+func (h *langHandler) resolveLanguage(languageID string) string {
+	seen := map[string]bool{languageID: true}
+	for {
+		alias, ok := h.languageAliases[languageID]
+		if !ok || seen[alias] {
+			return languageID
+		}
+		seen[alias] = true
+		languageID = alias
+	}
+}
+
+// logConfigsOf logs the tools configured for languageID, when a document of
+// it is opened.
+func (h *langHandler) logConfigsOf(languageID string) {
 	h.logger.Printf("Opening file with language ID: %s", languageID)
-	
+
 	// Check if we have configuration for this language
 	if cfgs, ok := h.configs[languageID]; ok {
 		h.logger.Printf("Found %d configurations for language %s", len(cfgs), languageID)
-		
+
 		// Check for passthrough configurations
 		for _, cfg := range cfgs {
 			if cfg.Passthrough != nil {
-				h.logger.Printf("Found passthrough configuration for %s: %s", 
+				h.logger.Printf("Found passthrough configuration for %s: %s",
 					languageID, cfg.Passthrough.Command)
 			}
 		}
 	} else {
 		h.logger.Printf("No configurations found for language: %s", languageID)
 	}
-	
+}
+
+func (h *langHandler) openFile(uri DocumentURI, languageID string, version int) error {
+	if alias := h.resolveLanguage(languageID); alias != languageID {
+		if h.loglevel >= 2 {
+			h.logger.Printf("Using language ID %s for %s", alias, languageID)
+		}
+		languageID = alias
+	}
+	if h.loglevel >= 2 {
+		h.logConfigsOf(languageID)
+	}
+
 	f := &File{
 		Text:       "",
 		LanguageID: languageID,
 		Version:    version,
 	}
+	h.mu.Lock()
 	h.files[uri] = f
+	h.mu.Unlock()
 	return nil
 }
 
@@ -821,21 +1688,52 @@ func (h *langHandler) updateFile(uri DocumentURI, text string, version *int, eve
 	if !ok {
 		return fmt.Errorf("document not found: %v", uri)
 	}
+	h.mu.Lock()
 	f.Text = text
 	if version != nil {
 		f.Version = *version
 	}
+	h.mu.Unlock()
 
 	h.lintRequest(uri, eventType)
 	return nil
 }
 
-func (h *langHandler) configFor(uri DocumentURI) []Language {
+// documentSnapshot is a copy of an open document and of its tools, for the
+// requests running off the handler goroutine, which changes them.
+type documentSnapshot struct {
+	file     File
+	configs  []Language
+	wildcard []Language
+}
+
+// snapshot copies the document uri and its tools under the lock, false if
+// it isn't open.
+func (h *langHandler) snapshot(uri DocumentURI) (documentSnapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	f, ok := h.files[uri]
 	if !ok {
-		return []Language{}
+		return documentSnapshot{}, false
 	}
-	c, ok := h.configs[f.LanguageID]
+	configs, _ := h.configsFor(uri)
+	return documentSnapshot{file: *f, configs: configs, wildcard: h.configs[wildcard]}, true
+}
+
+// openText returns the text of the open document uri, read under the lock
+// for the linters running off the handler goroutine.
+func (h *langHandler) openText(uri DocumentURI) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, ok := h.files[uri]
+	if !ok {
+		return "", false
+	}
+	return f.Text, true
+}
+
+func (h *langHandler) configFor(uri DocumentURI) []Language {
+	c, ok := h.configsFor(uri)
 	if !ok {
 		return []Language{}
 	}
@@ -1020,7 +1918,7 @@ func (h *langHandler) findPassthrough(uri DocumentURI, method string) (*Passthro
 	
 	h.logger.Printf("findPassthrough: Looking for passthrough config for language: %s", f.LanguageID)
 	
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs, ok := h.configs[h.resolveLanguage(f.LanguageID)]; ok {
 		for _, cfg := range cfgs {
 			if cfg.Passthrough != nil {
 				h.logger.Printf("findPassthrough: Found passthrough for %s: %s", 
@@ -1037,12 +1935,31 @@ func (h *langHandler) findPassthrough(uri DocumentURI, method string) (*Passthro
 }
 
 func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.logPanic(req.Method, r)
+			result, err = nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: fmt.Sprintf("%s: %v", req.Method, r)}
+		}
+	}()
+
 	h.mu.Lock()
 	if h.conn == nil {
 		h.conn = conn
+		go func() {
+			<-conn.DisconnectNotify()
+			h.close()
+		}()
 	}
+	shutdown := h.shutdown
 	h.mu.Unlock()
 
+	if req.Method == "exit" {
+		return h.handleExit(ctx, conn, req)
+	}
+	if shutdown {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: fmt.Sprintf("server is shut down: %s", req.Method)}
+	}
+
 	if h.loglevel >= 2 {
 		h.logger.Printf("Received request: %s", req.Method)
 		if req.Params != nil {
@@ -1131,6 +2048,8 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 	case "initialize":
 		return h.handleInitialize(ctx, conn, req)
 	case "initialized":
+		go h.updateWatchedFiles()
+		go h.restoreDiagnosticsCache()
 		return
 	case "shutdown":
 		return h.handleShutdown(ctx, conn, req)
@@ -1140,8 +2059,14 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 		return h.handleTextDocumentDidChange(ctx, conn, req)
 	case "textDocument/didSave":
 		return h.handleTextDocumentDidSave(ctx, conn, req)
+	case "textDocument/willSave":
+		return nil, nil
+	case "textDocument/willSaveWaitUntil":
+		return h.handleTextDocumentWillSaveWaitUntil(ctx, conn, req)
 	case "textDocument/didClose":
 		return h.handleTextDocumentDidClose(ctx, conn, req)
+	case "$/cancelRequest":
+		return h.handleCancelRequest(ctx, conn, req)
 	case "textDocument/formatting":
 		return h.handleTextDocumentFormatting(ctx, conn, req)
 	case "textDocument/rangeFormatting":
@@ -1156,10 +2081,24 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 		return h.handleTextDocumentHover(ctx, conn, req)
 	case "textDocument/codeAction":
 		return h.handleTextDocumentCodeAction(ctx, conn, req)
+	case "codeAction/resolve":
+		return h.handleCodeActionResolve(ctx, conn, req)
+	case "textDocument/diagnostic":
+		return h.handleTextDocumentDiagnostic(ctx, conn, req)
+	case "textDocument/codeLens":
+		return h.handleTextDocumentCodeLens(ctx, conn, req)
 	case "workspace/executeCommand":
 		return h.handleWorkspaceExecuteCommand(ctx, conn, req)
+	case "workspace/diagnostic":
+		return h.handleWorkspaceDiagnostic(ctx, conn, req)
 	case "workspace/didChangeConfiguration":
 		return h.handleWorkspaceDidChangeConfiguration(ctx, conn, req)
+	case "workspace/didChangeWatchedFiles":
+		return h.handleWorkspaceDidChangeWatchedFiles(ctx, conn, req)
+	case "workspace/didRenameFiles":
+		return h.handleWorkspaceDidRenameFiles(ctx, conn, req)
+	case "workspace/didDeleteFiles":
+		return h.handleWorkspaceDidDeleteFiles(ctx, conn, req)
 	case "workspace/didChangeWorkspaceFolders":
 		return h.handleDidChangeWorkspaceWorkspaceFolders(ctx, conn, req)
 	case "workspace/workspaceFolders":
@@ -1169,25 +2108,18 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method not supported: %s", req.Method)}
 }
 
-func replaceCommandInputFilename(command, fname, rootPath string) string {
+func replaceCommandInputFilename(command, fname, rootPath, shell string) string {
 	ext := filepath.Ext(fname)
 	ext = strings.TrimPrefix(ext, ".")
 
-	command = strings.Replace(command, "${INPUT}", escapeBrackets(fname), -1)
+	command = replacePlaceholder(command, "${INPUT}", fname, shell)
 	command = strings.Replace(command, "${FILEEXT}", ext, -1)
-	command = strings.Replace(command, "${FILENAME}", escapeBrackets(filepath.FromSlash(fname)), -1)
-	command = strings.Replace(command, "${ROOT}", escapeBrackets(rootPath), -1)
+	command = replacePlaceholder(command, "${FILENAME}", filepath.FromSlash(fname), shell)
+	command = replacePlaceholder(command, "${ROOT}", rootPath, shell)
 
 	return command
 }
 
-func escapeBrackets(path string) string {
-	path = strings.Replace(path, "(", `\(`, -1)
-	path = strings.Replace(path, ")", `\)`, -1)
-
-	return path
-}
-
 func succeeded(err error) bool {
 	exitErr, ok := err.(*exec.ExitError)
 	// When the context is canceled, the process is killed,
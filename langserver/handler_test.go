@@ -1,14 +1,24 @@
 package langserver
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"gopkg.in/yaml.v3"
 )
 
 func TestLintNoLinter(t *testing.T) {
@@ -689,3 +699,1148 @@ func TestHover(t *testing.T) {
 		})
 	}
 }
+
+func TestPlaceholders(t *testing.T) {
+	f := &File{Text: "first line\nsecond (word) here\n"}
+	p := placeholders{fname: "/tmp/a.txt", rootPath: "/tmp", shell: shellSh, file: f, pos: &Position{Line: 1, Character: 9}}
+
+	got := p.replace("tool ${INPUT}:${LINE}:${COLUMN} ${WORD} ${LINE_TEXT}")
+	expected := "tool /tmp/a.txt:2:10 word 'second (word) here'"
+	if got != expected {
+		t.Fatalf("expected %q but got: %q", expected, got)
+	}
+
+	p.root, p.workspace = "/tmp", "/home/me/project"
+	if got := p.replace("${RELATIVE_PATH} ${WORKSPACE_NAME}"); got != "a.txt project" {
+		t.Fatalf("unexpected relative path or workspace name: %q", got)
+	}
+
+	p.pos = nil
+	if got := p.replace("tool ${LINE}${WORD}"); got != "tool ''" {
+		t.Fatalf("cursor placeholders should be empty without a cursor but got: %q", got)
+	}
+}
+
+func TestCloneConfigs(t *testing.T) {
+	configs := map[string][]Language{"go": {{LintCommand: "vet"}}}
+	a := &langHandler{configs: cloneConfigs(configs)}
+	b := &langHandler{configs: cloneConfigs(configs)}
+
+	a.addTool("go", Language{LintCommand: "staticcheck"}, ".pre-commit-config.yaml")
+	a.configs["go"][0].LintCommand = "changed"
+	if len(b.configs["go"]) != 1 || b.configs["go"][0].LintCommand != "vet" || configs["go"][0].LintCommand != "vet" {
+		t.Fatalf("sessions should not share configs: %v", b.configs)
+	}
+}
+
+func TestCloseCancelsLints(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	config := NewConfig()
+	config.Logger = log.New(io.Discard, "", 0)
+	(*config.Languages)["vim"] = []Language{{LintCommand: "sleep 30", LintStdin: true}}
+	h := newLangHandler(config)
+	h.rootPath = base
+	h.files = map[DocumentURI]*File{uri: {LanguageID: "vim", Text: "scriptencoding utf-8\n"}}
+
+	h.request <- lintRequest{URI: uri, EventType: eventTypeOpen}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	h.close()
+	if elapsed := time.Since(start); elapsed >= shutdownTimeout {
+		t.Fatalf("close should cancel the running lint but took %v", elapsed)
+	}
+	h.lintRequest(uri, eventTypeSave)
+}
+
+func TestConfigsForGlob(t *testing.T) {
+	uri := toURI("/project/resources/views/home.blade.php")
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: "/project",
+		configs: map[string][]Language{
+			"html":                 {{LintCommand: "htmlhint"}},
+			"glob:*.blade.php":     {{FormatCommand: "blade-formatter --stdin"}},
+			"glob:resources/*/*.*": {{LintCommand: "resources-lint"}},
+			"glob:*.vue":           {{LintCommand: "vue-lint"}},
+		},
+		files: map[DocumentURI]*File{uri: {LanguageID: "html"}},
+	}
+
+	cfgs, ok := h.configsFor(uri)
+	if !ok || len(cfgs) != 3 {
+		t.Fatalf("expected the html tools and two glob tools but got: %+v", cfgs)
+	}
+	if cfgs[0].LintCommand != "htmlhint" || cfgs[1].FormatCommand != "blade-formatter --stdin" || cfgs[2].LintCommand != "resources-lint" {
+		t.Fatalf("unexpected tools: %+v", cfgs)
+	}
+	if len(h.configs["html"]) != 1 {
+		t.Fatal("the tools of the language should not be modified")
+	}
+}
+
+func TestShebangLanguage(t *testing.T) {
+	h := &langHandler{
+		configs: map[string][]Language{
+			"sh":     {{LintCommand: "shellcheck -"}},
+			"python": {{LintCommand: "flake8 -"}},
+		},
+	}
+	tests := map[string]string{
+		"#!/bin/bash\necho hi\n":                  "sh",
+		"#!/usr/bin/env python3\nprint(1)\n":      "python",
+		"#!/usr/bin/env -S VAR=1 python3.11 -u\n": "python",
+		"#!/usr/bin/env node\n":                   "",
+		"echo no shebang\n":                       "",
+	}
+	for text, expected := range tests {
+		if got := h.shebangLanguage(text); got != expected {
+			t.Fatalf("%q: expected %q but got: %q", text, expected, got)
+		}
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	h := &langHandler{
+		languageAliases: map[string]string{
+			"javascriptreact": "javascript",
+			"terraform-vars":  "tfvars",
+			"tfvars":          "terraform",
+			"a":               "b",
+			"b":               "a",
+		},
+	}
+	tests := map[string]string{
+		"javascriptreact": "javascript",
+		"terraform-vars":  "terraform",
+		"go":              "go",
+		"a":               "b",
+	}
+	for id, expected := range tests {
+		if got := h.resolveLanguage(id); got != expected {
+			t.Fatalf("%v: expected %v but got: %v", id, expected, got)
+		}
+	}
+}
+
+func TestOpenFileLanguageAlias(t *testing.T) {
+	var logs bytes.Buffer
+	h := &langHandler{
+		logger:          log.New(&logs, "", 0),
+		loglevel:        1,
+		files:           map[DocumentURI]*File{},
+		languageAliases: map[string]string{"javascriptreact": "javascript"},
+		configs: map[string][]Language{
+			"javascript": {{FixCommand: "eslint --fix-dry-run"}},
+		},
+	}
+	jsx := DocumentURI("file:///foo.jsx")
+	if err := h.openFile(jsx, "javascriptreact", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.files[jsx].LanguageID; got != "javascript" {
+		t.Fatalf("expected the alias to be used, got %q", got)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("expected nothing logged at the default loglevel, got %q", logs.String())
+	}
+
+	h.loglevel = 2
+	if err := h.openFile(jsx, "javascriptreact", 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "Using language ID javascript for javascriptreact") {
+		t.Fatalf("expected the alias to be logged, got %q", logs.String())
+	}
+
+	// An alias configured once the document is open applies too.
+	tsx := DocumentURI("file:///foo.tsx")
+	if err := h.openFile(tsx, "typescriptreact", 1); err != nil {
+		t.Fatal(err)
+	}
+	h.languageAliases["typescriptreact"] = "javascript"
+	if cfgs, ok := h.configsFor(tsx); !ok || len(cfgs) != 1 {
+		t.Fatalf("expected the tools of the alias, got %+v", cfgs)
+	}
+	if cfgs := h.fixConfigs(tsx, h.files[tsx].LanguageID); len(cfgs) != 1 {
+		t.Fatalf("expected the fix-command of the alias, got %+v", cfgs)
+	}
+}
+
+func TestMergeLanguageSettings(t *testing.T) {
+	passthrough := &Passthrough{Command: "pyright-langserver"}
+	configs := map[string][]Language{
+		"python": {
+			{LintCommand: "flake8 -", LintStdin: true, Passthrough: passthrough},
+			{FormatCommand: "black -"},
+		},
+		"go": {{LintCommand: "golint"}},
+		"sh": {{LintCommand: "shellcheck -"}},
+	}
+	settings := map[string]json.RawMessage{
+		"python": json.RawMessage(`[{"lintCommand": "ruff check -", "passthrough": {"args": ["--stdio"]}}]`),
+		"go":     json.RawMessage(`null`),
+		"lua":    json.RawMessage(`[{"formatCommand": "stylua -"}]`),
+	}
+	merged, err := mergeLanguageSettings(configs, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	python := merged["python"]
+	if len(python) != 2 || python[0].LintCommand != "ruff check -" || !python[0].LintStdin || python[1].FormatCommand != "black -" {
+		t.Fatalf("python tools not merged: %+v", python)
+	}
+	if python[0].Passthrough.Command != "pyright-langserver" || len(python[0].Passthrough.Args) != 1 {
+		t.Fatalf("passthrough not merged: %+v", python[0].Passthrough)
+	}
+	if passthrough.Args != nil || configs["python"][0].LintCommand != "flake8 -" {
+		t.Fatal("the original configs were modified")
+	}
+	if _, ok := merged["go"]; ok {
+		t.Fatal("go should be removed")
+	}
+	if len(merged["sh"]) != 1 || len(merged["lua"]) != 1 || merged["lua"][0].HoverChars != "_" {
+		t.Fatalf("unexpected tools: %+v", merged)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(dir, "main.py")
+	if err := os.WriteFile(fname, []byte("print(1)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := NewConfig()
+	config.Logger = log.New(io.Discard, "", 0)
+	config.Languages = &map[string][]Language{
+		"python": {
+			{LintCommand: "flake8", RootMarkers: []string{"go.mod"}},
+			{FormatCommand: "black -", RequireMarker: true, RootMarkers: []string{"pyproject.toml"}},
+		},
+		wildcard: {{HoverCommand: "dict", ExcludeLanguages: []string{"py*"}}},
+	}
+
+	r, err := Resolve(config, fname, ResolveOptions{Root: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.LanguageID != "python" || len(r.Tools) != 3 {
+		t.Fatalf("unexpected resolution: %+v", r)
+	}
+	lint := r.Tools[0]
+	if lint.RootPath != filepath.ToSlash(dir) || lint.Commands["lint-command"] != "flake8 "+filepath.ToSlash(fname) || lint.Skipped != "" {
+		t.Fatalf("unexpected lint tool: %+v", lint)
+	}
+	if r.Tools[1].Skipped == "" || r.Tools[2].Skipped == "" {
+		t.Fatalf("tools should be skipped: %+v", r.Tools)
+	}
+}
+
+func TestMatchRootPathPriority(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "packages", "app")
+	if err := os.MkdirAll(filepath.Join(pkg, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(root, "package.json"):   `{"eslintConfig": {}}`,
+		filepath.Join(root, "pnpm-lock.yaml"): "",
+		filepath.Join(pkg, "package.json"):    `{"name": "app"}`,
+		filepath.Join(pkg, "src", "index.js"): "",
+	}
+	for fname, content := range files {
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fname := filepath.Join(pkg, "src", "index.js")
+
+	tests := []struct {
+		markers  []RootMarker
+		expected string
+	}{
+		{[]RootMarker{{Name: "package.json"}}, pkg},
+		{[]RootMarker{{Name: "package.json", Contains: `"eslintConfig"`}}, root},
+		{[]RootMarker{{Name: "package.json"}, {Name: "pnpm-lock.yaml", Priority: 1}}, root},
+		{[]RootMarker{{Name: "Cargo.toml"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := matchRootPath(fname, tt.markers); got != tt.expected {
+			t.Fatalf("%+v: expected %q but got: %q", tt.markers, tt.expected, got)
+		}
+	}
+}
+
+func TestRootMarkerRules(t *testing.T) {
+	var config Config
+	if err := yaml.Unmarshal([]byte("root-markers: [.git/]\nroot-marker-rules: [{name: package.json, contains: eslint, priority: 2}]"), &config); err != nil {
+		t.Fatal(err)
+	}
+	expected := []RootMarker{{Name: "package.json", Contains: "eslint", Priority: 2}}
+	if !reflect.DeepEqual(*config.RootMarkers, []string{".git/"}) || !reflect.DeepEqual(config.RootMarkerRules, expected) {
+		t.Fatalf("expected %+v but got: %+v %+v", expected, *config.RootMarkers, config.RootMarkerRules)
+	}
+
+	lang := Language{RootMarkers: []string{".git/"}, RootMarkerRules: expected}
+	if markers := lang.markers(); !reflect.DeepEqual(markers, append([]RootMarker{{Name: ".git/"}}, expected...)) {
+		t.Fatalf("expected the root markers followed by the rules but got: %+v", markers)
+	}
+}
+
+func TestFindRootPathCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "go.mod"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := &langHandler{
+		logger:      log.New(io.Discard, "", 0),
+		rootMarkers: []string{"go.mod"},
+	}
+	fname := filepath.Join(sub, "main.go")
+
+	if got := h.findRootPath(fname, Language{RootCommand: "echo " + root}); got != root {
+		t.Fatalf("expected %q but got: %q", root, got)
+	}
+	if got := h.findRootPath(fname, Language{RootCommand: "false"}); got != sub {
+		t.Fatalf("expected the marker root %q but got: %q", sub, got)
+	}
+	if got := h.findRootPath(fname, Language{RootCommand: "true"}); got != sub {
+		t.Fatalf("expected the marker root for an empty output but got: %q", got)
+	}
+	if got := h.findRootPath(fname, Language{RootCommand: "echo .."}); got != root {
+		t.Fatalf("expected the relative root %q but got: %q", root, got)
+	}
+
+	// A failure is retried once rootCommandRetry passed.
+	marker := filepath.Join(root, "marker")
+	command := "test -f " + marker + " && echo " + root
+	if got := h.findRootPath(fname, Language{RootCommand: command}); got != sub {
+		t.Fatalf("expected the marker root %q but got: %q", sub, got)
+	}
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	key := [2]string{command, sub}
+	h.rootCommands.roots[key] = commandRootResult{failedAt: time.Now().Add(-rootCommandRetry)}
+	if got := h.findRootPath(fname, Language{RootCommand: command}); got != root {
+		t.Fatalf("expected %q once the failure expired but got: %q", root, got)
+	}
+}
+
+func TestLintRequestPerDocument(t *testing.T) {
+	h := &langHandler{
+		request:      make(chan lintRequest),
+		done:         make(chan struct{}),
+		lintDebounce: 100 * time.Millisecond,
+	}
+	defer close(h.done)
+	a, b := toURI("/project/a.go"), toURI("/project/b.go")
+
+	// Edits to b keep postponing its lint, but not the one of a.
+	h.lintRequest(a, eventTypeChange)
+	h.lintRequest(b, eventTypeSave)
+	stop := time.After(300 * time.Millisecond)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case req := <-h.request:
+			if req.URI != a {
+				t.Fatalf("expected the lint of %v but got: %v", a, req.URI)
+			}
+			h.lintRequest(b, eventTypeChange) // keeps b pending a little longer
+			select {
+			case req := <-h.request:
+				if req.URI != b || req.EventType != eventTypeSave {
+					t.Fatalf("expected a save lint of %v but got: %+v", b, req)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("the lint of b never came")
+			}
+			return
+		case <-ticker.C:
+			h.lintRequest(b, eventTypeChange)
+		case <-stop:
+			t.Fatal("the lint of a was held back by the edits to b")
+		}
+	}
+}
+
+func TestLintAfterOpenDelay(t *testing.T) {
+	h := &langHandler{
+		request:            make(chan lintRequest),
+		done:               make(chan struct{}),
+		lintDebounce:       10 * time.Millisecond,
+		lintAfterOpenDelay: time.Hour,
+		files:              map[DocumentURI]*File{},
+	}
+	defer close(h.done)
+	a, b := toURI("/project/a.go"), toURI("/project/b.go")
+
+	h.lintRequest(a, eventTypeOpen)
+	h.lintRequest(b, eventTypeChange)
+	select {
+	case req := <-h.request:
+		if req.URI != b {
+			t.Fatalf("expected the lint of %v before the one of the opened document but got: %v", b, req.URI)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the lint of b never came")
+	}
+
+	if err := h.closeFile(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.lintTimers[a]; ok {
+		t.Fatal("expected the lint of the closed document to be dropped")
+	}
+}
+
+func TestLintAfterOpenStagger(t *testing.T) {
+	h := &langHandler{
+		request:            make(chan lintRequest),
+		done:               make(chan struct{}),
+		lintAfterOpenDelay: 10 * time.Millisecond,
+		files:              map[DocumentURI]*File{},
+	}
+	defer close(h.done)
+	uris := []DocumentURI{toURI("/project/a.go"), toURI("/project/b.go"), toURI("/project/c.go")}
+
+	for _, uri := range uris {
+		h.lintRequest(uri, eventTypeOpen)
+	}
+	var last time.Time
+	for _, uri := range uris {
+		select {
+		case req := <-h.request:
+			if req.URI != uri {
+				t.Fatalf("expected the lint of %v but got: %v", uri, req.URI)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the lint of %v never came", uri)
+		}
+		// The timers may fire a little early or late.
+		if !last.IsZero() && time.Since(last) < lintAfterOpenStagger/2 {
+			t.Fatalf("expected the lints of the opened documents to be staggered, got %v apart", time.Since(last))
+		}
+		last = time.Now()
+	}
+}
+
+func TestLintParallelTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	var tools []Language
+	for i := 1; i <= 3; i++ {
+		tools = append(tools, Language{
+			LintCommand:        fmt.Sprintf("sleep 0.5; echo %s:%d:tool %d", file, i, i),
+			LintIgnoreExitCode: true,
+			LintStdin:          true,
+		})
+	}
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs:  map[string][]Language{"vim": tools},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nb\nc\n"},
+		},
+	}
+
+	start := time.Now()
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 1500*time.Millisecond {
+		t.Fatalf("the tools should run at the same time but took %v", elapsed)
+	}
+	if len(d[uri]) != 3 {
+		t.Fatalf("expected a diagnostic of every tool but got: %+v", d[uri])
+	}
+	for i, diag := range d[uri] {
+		if diag.Message != fmt.Sprintf("tool %d", i+1) {
+			t.Fatalf("diagnostics should be in the order of the tools: %+v", d[uri])
+		}
+	}
+}
+
+func TestLintCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+	runs := filepath.Join(t.TempDir(), "runs")
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{
+				LintCommand:        "echo run >> " + runs + "; echo " + file + ":1:found",
+				LintIgnoreExitCode: true,
+				LintStdin:          true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\n"},
+		},
+	}
+	countRuns := func() int {
+		b, _ := os.ReadFile(runs)
+		return strings.Count(string(b), "run")
+	}
+
+	for i := 0; i < 2; i++ {
+		d, err := h.lint(context.Background(), uri, eventTypeChange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d[uri]) != 1 {
+			t.Fatalf("expected a diagnostic but got: %+v", d[uri])
+		}
+	}
+	if n := countRuns(); n != 1 {
+		t.Fatalf("the tool should run once for the same text but ran %d times", n)
+	}
+
+	h.files[uri].Text = "b\n"
+	if _, err := h.lint(context.Background(), uri, eventTypeSave); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRuns(); n != 2 {
+		t.Fatalf("the tool should run again for a new text but ran %d times", n)
+	}
+}
+
+func TestLintTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{LintCommand: "sleep 10", LintStdin: true, LintTimeout: Duration(100 * time.Millisecond)},
+				{LintCommand: "echo " + file + ":1:found", LintIgnoreExitCode: true, LintStdin: true},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\n"},
+		},
+	}
+
+	start := time.Now()
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 5*time.Second {
+		t.Fatalf("the hung tool should be killed but took %v", elapsed)
+	}
+	if len(d[uri]) != 1 {
+		t.Fatalf("the other tool should still report: %+v", d)
+	}
+}
+
+func TestLintTimeoutAfterCommandLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	var tools []Language
+	for i := 1; i <= 3; i++ {
+		tools = append(tools, Language{
+			LintCommand:        fmt.Sprintf("sleep 0.3; echo %s:%d:tool %d", file, i, i),
+			LintIgnoreExitCode: true,
+			LintStdin:          true,
+			LintTimeout:        Duration(time.Second),
+		})
+	}
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs:  map[string][]Language{"vim": tools},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nb\nc\n"},
+		},
+	}
+	h.commandLimit.setMax(1)
+
+	// The last tool waits longer than its lint-timeout for its slot.
+	release, err := h.commandLimit.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(500*time.Millisecond, release)
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 3 {
+		t.Fatalf("the tools waiting for a slot should not time out: %+v", d[uri])
+	}
+}
+
+func TestLintMaxConcurrentCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	var tools []Language
+	for i := 1; i <= 3; i++ {
+		tools = append(tools, Language{
+			LintCommand:        fmt.Sprintf("sleep 0.3; echo %s:%d:tool %d", file, i, i),
+			LintIgnoreExitCode: true,
+			LintStdin:          true,
+		})
+	}
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs:  map[string][]Language{"vim": tools},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nb\nc\n"},
+		},
+	}
+	h.commandLimit.setMax(1)
+
+	start := time.Now()
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("the tools should run one after another but took %v", elapsed)
+	}
+	if len(d[uri]) != 3 {
+		t.Fatalf("expected a diagnostic of every tool but got: %+v", d[uri])
+	}
+
+	release, err := h.commandLimit.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.commandLimit.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("waiting for a slot should stop on cancel, got %v", err)
+	}
+}
+
+func TestLintServer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	server := `n=0
+while IFS= read -r line; do
+  line=${line%"$(printf '\r')"}
+  case "$line" in
+    File:*) file=${line#File: } ;;
+    Content-Length:*) length=${line#Content-Length: } ;;
+    "")
+      head -c "$length" >/dev/null
+      n=$((n+1))
+      out="$file:1:run $n"
+      printf 'Content-Length: %d\r\n\r\n%s' "${#out}" "$out"
+      ;;
+  esac
+done`
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{LintCommand: server, LintServer: true}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nb\nc\n"},
+		},
+	}
+	defer h.lintServers.stopAll()
+
+	for i := 1; i <= 2; i++ {
+		// A change of the text skips the lint cache.
+		h.files[uri].Text += "d\n"
+		d, err := h.lint(context.Background(), uri, eventTypeChange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("run %d", i)
+		if len(d[uri]) != 1 || d[uri][0].Message != want {
+			t.Fatalf("the server should be kept running, want %q but got: %+v", want, d[uri])
+		}
+	}
+}
+
+func TestStreamOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	h := &langHandler{logger: log.New(io.Discard, "", 0)}
+	cmd, err := h.newCommand(context.Background(), nil, "", "echo first; sleep 1; echo second >&2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	var firstAfter time.Duration
+	b, err := h.streamOutput(cmd, func(r io.Reader) {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		if line == "first\n" {
+			firstAfter = time.Since(start)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstAfter == 0 || firstAfter >= time.Second {
+		t.Fatalf("the output should be read while the command runs, got it after %v", firstAfter)
+	}
+	if string(b) != "first\nsecond\n" {
+		t.Fatalf("the whole output should be returned, got %q", b)
+	}
+}
+
+func TestDidChangeWatchedFiles(t *testing.T) {
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.vim"))
+	uri2 := toURI(filepath.Join(base, "foo.txt"))
+
+	h := &langHandler{
+		logger:       log.New(io.Discard, "", 0),
+		rootPath:     base,
+		lintDebounce: time.Hour,
+		done:         make(chan struct{}),
+		configs: map[string][]Language{
+			"vim":  {{LintCommand: "vint", LintWatch: []string{".vintrc*"}}},
+			"text": {{LintCommand: "proselint"}},
+		},
+		files: map[DocumentURI]*File{
+			uri:  {LanguageID: "vim"},
+			uri2: {LanguageID: "text"},
+		},
+	}
+	defer func() {
+		for _, p := range h.lintTimers {
+			p.timer.Stop()
+		}
+	}()
+	if globs := h.lintWatchGlobs(); !reflect.DeepEqual(globs, []string{".vintrc*"}) {
+		t.Fatalf("unexpected lint-watch globs: %v", globs)
+	}
+
+	params, _ := json.Marshal(&DidChangeWatchedFilesParams{
+		Changes: []FileEvent{{URI: toURI(filepath.Join(base, ".vintrc.yaml")), Type: 2}},
+	})
+	raw := json.RawMessage(params)
+	if _, err := h.handleWorkspaceDidChangeWatchedFiles(context.Background(), nil, &jsonrpc2.Request{Params: &raw}); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := h.lintTimers[uri]; !ok || p.eventType != eventTypeSave {
+		t.Fatalf("the document using the watching tool should be linted again")
+	}
+	if _, ok := h.lintTimers[uri2]; ok {
+		t.Fatalf("the other documents should not be linted again")
+	}
+}
+
+func TestDidChangeRootMarker(t *testing.T) {
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.py"))
+	uri2 := toURI(filepath.Join(base, "foo.txt"))
+
+	h := &langHandler{
+		logger:       log.New(io.Discard, "", 0),
+		rootPath:     base,
+		lintDebounce: time.Hour,
+		done:         make(chan struct{}),
+		rootMarkers:  []string{".git/"},
+		configs: map[string][]Language{
+			"python": {{LintCommand: "pydocstyle", RootMarkers: []string{"pyproject.toml"}}},
+			"text":   {{LintCommand: "textlint", LintWatch: []string{".textlintrc"}}},
+		},
+		files: map[DocumentURI]*File{
+			uri:  {LanguageID: "python"},
+			uri2: {LanguageID: "text"},
+		},
+	}
+	defer func() {
+		for _, p := range h.lintTimers {
+			p.timer.Stop()
+		}
+	}()
+	if globs := h.watchedFileGlobs(); !reflect.DeepEqual(globs, []string{".textlintrc", "pyproject.toml"}) {
+		t.Fatalf("unexpected watched globs: %v", globs)
+	}
+
+	params, _ := json.Marshal(&DidChangeWatchedFilesParams{
+		Changes: []FileEvent{{URI: toURI(filepath.Join(base, "sub", "pyproject.toml")), Type: 1}},
+	})
+	raw := json.RawMessage(params)
+	if _, err := h.handleWorkspaceDidChangeWatchedFiles(context.Background(), nil, &jsonrpc2.Request{Params: &raw}); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.lintTimers) != 2 {
+		t.Fatalf("all the documents should be linted again after a root marker changed, got %v", h.lintTimers)
+	}
+}
+
+func TestLintPattern(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{
+				LintCommand:        `echo "` + file + `:2:3 warning W605 invalid escape"; echo "summary: 1 problem"`,
+				LintPattern:        `(?m)^(?P<file>[^:]+):(?P<line>\d+):(?P<col>\d+) (?P<severity>\w+) (?P<code>\w+) (?P<message>.*)$`,
+				LintIgnoreExitCode: true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\nb abc\n"},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 {
+		t.Fatalf("expected one diagnostic but got: %+v", d[uri])
+	}
+	got := d[uri][0]
+	if got.Message != "invalid escape" || got.Severity != 2 || got.Code == nil || *got.Code != "W605" {
+		t.Fatalf("unexpected diagnostic: %+v", got)
+	}
+	if got.Range.Start != (Position{Line: 1, Character: 2}) || got.Range.End != (Position{Line: 1, Character: 5}) {
+		t.Fatalf("unexpected range: %+v", got.Range)
+	}
+}
+
+func TestLintEndPosition(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{
+				LintCommand:        `echo "` + file + `:1:3-2:2: span"; echo "` + file + `:2:1-2:: line"`,
+				LintFormats:        []string{"%f:%l:%c-%e:%k: %m", "%f:%l:%c-%e:: %m"},
+				LintIgnoreExitCode: true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a bc\nb abc\n"},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 2 {
+		t.Fatalf("expected two diagnostics but got: %+v", d[uri])
+	}
+	want := []Range{
+		{Start: Position{Line: 0, Character: 2}, End: Position{Line: 1, Character: 1}},
+		{Start: Position{Line: 1, Character: 0}, End: Position{Line: 2, Character: 0}},
+	}
+	for i, r := range want {
+		if d[uri][i].Range != r {
+			t.Fatalf("diagnostic %d: expected range %+v but got %+v", i, r, d[uri][i].Range)
+		}
+	}
+}
+
+func TestLintCommandForEvent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{
+				LintCommand:         `echo "` + file + `:1:1: open"`,
+				LintCommandOnSave:   `echo "` + file + `:1:1: save"`,
+				LintCommandOnChange: `echo "` + file + `:1:1: change"`,
+				LintFormats:         []string{"%f:%l:%c: %m"},
+				LintStdin:           true,
+				LintAfterOpen:       true,
+				LintIgnoreExitCode:  true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "scriptencoding utf-8\n"},
+		},
+	}
+
+	for eventType, want := range map[eventType]string{
+		eventTypeOpen:   "open",
+		eventTypeSave:   "save",
+		eventTypeChange: "change",
+	} {
+		d, err := h.lint(context.Background(), uri, eventType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d[uri]) != 1 || d[uri][0].Message != want {
+			t.Fatalf("expected the diagnostic of the %s command but got: %+v", want, d[uri])
+		}
+	}
+}
+
+func TestLintColumnEncoding(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {{
+				LintCommand:        `echo "` + file + `:1:9-10: byte offsets"`,
+				LintFormats:        []string{"%f:%l:%c-%k: %m"},
+				LintColumnEncoding: "bytes",
+				LintIgnoreExitCode: true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "é 😀 x\n"},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 {
+		t.Fatalf("expected one diagnostic but got: %+v", d[uri])
+	}
+	want := Range{Start: Position{Line: 0, Character: 5}, End: Position{Line: 0, Character: 6}}
+	if d[uri][0].Range != want {
+		t.Fatalf("expected range %+v but got %+v", want, d[uri][0].Range)
+	}
+}
+
+func TestLintRelatedInformation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"c": {{
+				LintCommand:            `printf '%s\n' "` + file + `:2:5: error: redefinition of x" "` + file + `:1:5: note: previous definition of x"`,
+				LintFormats:            []string{"%f:%l:%c: %trror: %m", "%f:%l:%c: %tote: %m"},
+				LintIgnoreExitCode:     true,
+				LintRelatedInformation: true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "c", Text: "int x;\nint x;\n"},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 {
+		t.Fatalf("expected one diagnostic but got: %+v", d[uri])
+	}
+	related := d[uri][0].RelatedInformation
+	if len(related) != 1 {
+		t.Fatalf("expected one related information but got: %+v", related)
+	}
+	want := DiagnosticRelatedInformation{
+		Location: Location{URI: uri, Range: Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 5}}},
+		Message:  "previous definition of x",
+	}
+	if related[0] != want {
+		t.Fatalf("expected %+v but got %+v", want, related[0])
+	}
+
+	// The note of an error of another file is dropped with it.
+	other := filepath.Join(base, "bar")
+	h.configs["c"][0].LintCommand = `printf '%s\n' "` + file + `:2:5: error: redefinition of x" "` + other + `:2:5: error: redefinition of y" "` + other + `:1:5: note: previous definition of y"`
+	d, err = h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 || len(d[uri][0].RelatedInformation) != 0 {
+		t.Fatalf("expected one diagnostic without related information but got: %+v", d[uri])
+	}
+}
+
+func TestLintJQRelated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"c": {{
+				LintCommand:        `echo '[{"line": 1, "msg": "redefinition", "note": {"line": 0, "msg": "defined here"}}]'`,
+				LintJQ:             `.[] | {file: "` + file + `", message: .msg, range: {start: {line: .line, character: 0}}, related: [{file: "` + filepath.Join(base, "bar") + `", message: .note.msg, range: {start: {line: .note.line, character: 0}, end: {line: .note.line, character: 3}}}]}`,
+				LintStdin:          true,
+				LintIgnoreExitCode: true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "c", Text: "int x;\nint x;\n"},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 {
+		t.Fatalf("expected one diagnostic but got: %+v", d[uri])
+	}
+	got := d[uri][0]
+	if got.Range.Start != (Position{Line: 1}) || got.Range.End != got.Range.Start {
+		t.Fatalf("unexpected range: %+v", got.Range)
+	}
+	want := []DiagnosticRelatedInformation{{
+		Location: Location{URI: toURI(filepath.Join(base, "bar")), Range: Range{End: Position{Character: 3}}},
+		Message:  "defined here",
+	}}
+	if !reflect.DeepEqual(got.RelatedInformation, want) {
+		t.Fatalf("expected %+v but got %+v", want, got.RelatedInformation)
+	}
+}
+
+func TestLintCodeURL(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"javascript": {{
+				LintCommand:        `echo "` + file + `:1:1: Unexpected console statement. [Error/no-console]"; echo "` + file + `:2:1: Parsing error [Error/]"`,
+				LintPattern:        `(?m)^(?P<file>[^:]+):(?P<line>\d+):(?P<col>\d+): (?P<message>.*) \[(?P<severity>\w+)/(?P<code>[^\]]*)\]$`,
+				LintCodeURL:        "https://eslint.org/docs/rules/${code}",
+				LintStdin:          true,
+				LintIgnoreExitCode: true,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "javascript", Text: "console.log(1)\n(\n"},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 2 {
+		t.Fatalf("expected two diagnostics but got: %+v", d[uri])
+	}
+	if got := d[uri][0].CodeDescription; got == nil || got.Href != "https://eslint.org/docs/rules/no-console" {
+		t.Fatalf("unexpected code description: %+v", got)
+	}
+	if got := d[uri][1].CodeDescription; got != nil {
+		t.Fatalf("expected no code description without a code but got: %+v", got)
+	}
+}
+
+func TestClearsDiagnosticsOnClose(t *testing.T) {
+	uri := toURI(filepath.Join(t.TempDir(), "foo.js"))
+	tests := []struct {
+		name    string
+		global  bool
+		configs []Language
+		want    bool
+	}{
+		{"unset", false, []Language{{LintCommand: "eslint"}}, false},
+		{"global", true, []Language{{LintCommand: "eslint"}}, true},
+		{"tool", false, []Language{{LintCommand: "eslint"}, {LintCommand: "tsc", ClearDiagnosticsOnClose: true}}, true},
+		{"workspace", true, []Language{{LintCommand: "tsc", LintWorkspace: true}}, false},
+	}
+	for _, tt := range tests {
+		h := &langHandler{
+			clearDiagnosticsOnClose: tt.global,
+			configs:                 map[string][]Language{"javascript": tt.configs},
+			files:                   map[DocumentURI]*File{uri: {LanguageID: "javascript"}},
+		}
+		if got := h.clearsDiagnosticsOnClose(uri); got != tt.want {
+			t.Fatalf("%s: expected %v but got %v", tt.name, tt.want, got)
+		}
+	}
+}
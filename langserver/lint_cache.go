@@ -0,0 +1,87 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+)
+
+// lintCacheKey identifies a lint tool run on a document. The tool is its
+// config as JSON, so that changing any of its settings misses the cache.
+type lintCacheKey struct {
+	uri  DocumentURI
+	tool string
+}
+
+type lintCacheEntry struct {
+	sum    [sha256.Size]byte
+	result lintResult
+}
+
+// lintCache keeps the last result of every lint tool on every document, to
+// skip running a tool again on the same text, e.g. on didSave right after
+// didChange.
+type lintCache struct {
+	mu      sync.Mutex
+	entries map[lintCacheKey]lintCacheEntry
+}
+
+func (c *lintCache) get(key lintCacheKey, sum [sha256.Size]byte) (lintResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.sum != sum {
+		return lintResult{}, false
+	}
+	return entry.result, true
+}
+
+func (c *lintCache) put(key lintCacheKey, sum [sha256.Size]byte, result lintResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[lintCacheKey]lintCacheEntry)
+	}
+	c.entries[key] = lintCacheEntry{sum: sum, result: result}
+}
+
+// forget drops the results of the document uri, or of all the documents if
+// uri is empty.
+func (c *lintCache) forget(uri DocumentURI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if uri == "" || key.uri == uri {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cachedLintTool is lintTool reusing the result of the last run of the tool
+// if the text of the document didn't change since. Only tools reading the
+// text on stdin or from a lint server are cached: the others read files which
+// may have changed.
+func (h *langHandler) cachedLintTool(ctx context.Context, uri DocumentURI, f *File, fname string, config Language) (lintResult, error) {
+	if !(config.LintStdin || config.LintServer) || config.LintWorkspace || config.LintCommand == "" {
+		return h.lintTool(ctx, uri, f, fname, config)
+	}
+	tool, err := json.Marshal(config)
+	if err != nil {
+		return h.lintTool(ctx, uri, f, fname, config)
+	}
+	key := lintCacheKey{uri: uri, tool: string(tool)}
+	sum := sha256.Sum256([]byte(f.Text))
+	if result, ok := h.lintCache.get(key, sum); ok {
+		if h.loglevel >= 3 {
+			h.logger.Printf("text unchanged, reusing the result of `%s`", config.LintCommand)
+		}
+		return result, nil
+	}
+
+	result, err := h.lintTool(ctx, uri, f, fname, config)
+	if err == nil && !result.canceled && !result.timedOut {
+		h.lintCache.put(key, sum, result)
+	}
+	return result, err
+}
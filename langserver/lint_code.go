@@ -0,0 +1,139 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// codePlaceholder is replaced by the code of a diagnostic in lint-code-url.
+const codePlaceholder = "${code}"
+
+// openCodeDocumentationCommand opens the documentation of the code of a
+// diagnostic, the URL which is its argument, in the browser.
+const openCodeDocumentationCommand = "efm-langserver.openCodeDocumentation"
+
+// describeCodes links the codes of the diagnostics of the tool config to
+// their documentation with lint-code-url. Diagnostics already having a
+// description, e.g. from lint-format-type lsp, keep it.
+func describeCodes(config *Language, diagnostics []Diagnostic) {
+	if config.LintCodeURL == "" {
+		return
+	}
+	for i := range diagnostics {
+		d := &diagnostics[i]
+		if d.Code == nil || *d.Code == "" || d.CodeDescription != nil {
+			continue
+		}
+		d.CodeDescription = &CodeDescription{
+			Href: strings.ReplaceAll(config.LintCodeURL, codePlaceholder, *d.Code),
+		}
+	}
+}
+
+// codeDocumentationActions offers to open the documentation of the codes
+// of diagnostics, the codeDescription of lint-code-url, once for each code.
+func codeDocumentationActions(diagnostics []Diagnostic) []CodeAction {
+	seen := make(map[string]bool)
+	var actions []CodeAction
+	for _, d := range diagnostics {
+		if d.Code == nil || d.CodeDescription == nil || d.CodeDescription.Href == "" || seen[d.CodeDescription.Href] {
+			continue
+		}
+		seen[d.CodeDescription.Href] = true
+		title := fmt.Sprintf("Open the documentation of %s", *d.Code)
+		actions = append(actions, CodeAction{
+			Title:       title,
+			Diagnostics: []Diagnostic{d},
+			Command: &Command{
+				Title:     title,
+				Command:   openCodeDocumentationCommand,
+				Arguments: []any{d.CodeDescription.Href},
+			},
+		})
+	}
+	return actions
+}
+
+// openCodeDocumentation runs openCodeDocumentationCommand, asking the client
+// to open the URL with window/showDocument outside of the editor.
+func (h *langHandler) openCodeDocumentation(params *ExecuteCommandParams) (any, error) {
+	if len(params.Arguments) != 1 {
+		return nil, fmt.Errorf("invalid command")
+	}
+	href, ok := params.Arguments[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid argument")
+	}
+	// Only web pages are opened, not whatever program a URL may start.
+	if u, err := url.Parse(href); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid documentation URL: %v", href)
+	}
+	// It calls the client, which can't answer before this request returns.
+	go func() {
+		err := h.conn.Call(context.Background(), "window/showDocument", &ShowDocumentParams{URI: DocumentURI(href), External: true}, nil)
+		if err != nil {
+			h.logger.Printf("window/showDocument failed: %v", err)
+		}
+	}()
+	return "OK", nil
+}
+
+// severityNamed returns the severity named name: error, warning, info (or
+// information) and hint, or 1 to 4.
+func severityNamed(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "error":
+		return 1, true
+	case "warning":
+		return 2, true
+	case "information", "info":
+		return 3, true
+	case "hint":
+		return 4, true
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 && n <= 4 {
+		return n, true
+	}
+	return 0, false
+}
+
+// overrideSeverities sets the severity of the diagnostics of the tool config
+// whose code is a key of lint-severity-map. A code matching no key exactly
+// takes the severity of the first glob matching it, in the order of the
+// keys.
+func overrideSeverities(config *Language, diagnostics []Diagnostic) {
+	if len(config.LintSeverityMap) == 0 {
+		return
+	}
+	var globs []string
+	for key := range config.LintSeverityMap {
+		if strings.ContainsAny(key, "*?[") {
+			globs = append(globs, key)
+		}
+	}
+	sort.Strings(globs)
+
+	for i := range diagnostics {
+		d := &diagnostics[i]
+		if d.Code == nil || *d.Code == "" {
+			continue
+		}
+		name, ok := config.LintSeverityMap[*d.Code]
+		for _, glob := range globs {
+			if ok {
+				break
+			}
+			if matched, _ := path.Match(glob, *d.Code); matched {
+				name, ok = config.LintSeverityMap[glob], true
+			}
+		}
+		if severity, known := severityNamed(name); ok && known {
+			d.Severity = severity
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package langserver
+
+import "testing"
+
+func TestOverrideSeverities(t *testing.T) {
+	config := &Language{
+		LintSeverityMap: map[string]string{
+			"no-console":           "hint",
+			"@typescript-eslint/*": "warning",
+			"*":                    "info",
+			"E501":                 "3",
+		},
+	}
+	code := func(s string) *string { return &s }
+	diagnostics := []Diagnostic{
+		{Code: code("no-console"), Severity: 1},
+		{Code: code("@typescript-eslint/no-unused-vars"), Severity: 1},
+		{Code: code("E501"), Severity: 1},
+		{Code: code("no-undef"), Severity: 1},
+		{Severity: 1},
+	}
+	overrideSeverities(config, diagnostics)
+
+	expected := []int{4, 2, 3, 3, 1}
+	for i, d := range diagnostics {
+		if d.Severity != expected[i] {
+			t.Fatalf("diagnostic %d: expected severity %d but got %d", i, expected[i], d.Severity)
+		}
+	}
+}
+
+func TestCodeDocumentationActions(t *testing.T) {
+	code, other := "E501", "E302"
+	docs := &CodeDescription{Href: "https://docs.astral.sh/ruff/rules/E501"}
+	diagnostics := []Diagnostic{
+		{Code: &code, CodeDescription: docs},
+		{Code: &code, CodeDescription: docs},
+		{Code: &other},
+	}
+	actions := codeDocumentationActions(diagnostics)
+	if len(actions) != 1 {
+		t.Fatalf("expected one action for the documented code, got %+v", actions)
+	}
+	if c := actions[0].Command; c == nil || c.Command != openCodeDocumentationCommand || c.Arguments[0] != docs.Href {
+		t.Fatalf("expected the command opening %v, got %+v", docs.Href, actions[0])
+	}
+
+	h := &langHandler{}
+	params := &ExecuteCommandParams{Command: openCodeDocumentationCommand, Arguments: []any{"file:///etc/passwd"}}
+	if _, err := h.openCodeDocumentation(params); err == nil {
+		t.Fatal("expected only web pages to be opened")
+	}
+}
@@ -0,0 +1,83 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// diagnosticData is the data of the diagnostics of lint tools, so that code
+// actions and client plugins can use what the tool reported without running
+// it again.
+type diagnosticData struct {
+	// Tool is the lint-source of the tool, or the name of its command.
+	Tool string `json:"tool"`
+	Rule string `json:"rule,omitempty"`
+	// Output is the part of the output the diagnostic was parsed from.
+	Output string `json:"output,omitempty"`
+	// Fix is the text the tool suggests to replace the range with.
+	Fix *string `json:"fix,omitempty"`
+	// Edits are the edits of the fix the tool suggests, of other ranges than
+	// the one of the diagnostic, e.g. the fixits of clang-tidy.
+	Edits []TextEdit `json:"edits,omitempty"`
+}
+
+// toolName returns the name of the lint tool of config for the data of its
+// diagnostics.
+func toolName(config *Language, source *string) string {
+	if source != nil {
+		return *source
+	}
+	if config.LintSource != "" {
+		return config.LintSource
+	}
+	if fields := strings.Fields(config.LintCommand); len(fields) > 0 {
+		return filepath.Base(strings.Trim(fields[0], `"'`))
+	}
+	return ""
+}
+
+// newDiagnosticData returns the data of a diagnostic, or nil if it can't be
+// encoded.
+func newDiagnosticData(tool string, rule *string, output string, fix *string, edits []TextEdit) json.RawMessage {
+	data := diagnosticData{Tool: tool, Output: output, Fix: fix, Edits: edits}
+	if rule != nil {
+		data.Rule = *rule
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// dataFixCodeActions returns the quick fixes applying the fixes the tools
+// suggested for diagnostics, the edits of their own ranges if any, else the
+// replacement of the range of the diagnostic.
+func dataFixCodeActions(uri DocumentURI, diagnostics []Diagnostic) []CodeAction {
+	var actions []CodeAction
+	for _, d := range diagnostics {
+		if len(d.Data) == 0 {
+			continue
+		}
+		var data diagnosticData
+		if err := json.Unmarshal(d.Data, &data); err != nil || (data.Fix == nil && len(data.Edits) == 0) || data.Tool == "" {
+			continue
+		}
+		edits := data.Edits
+		if len(edits) == 0 {
+			edits = []TextEdit{{Range: d.Range, NewText: *data.Fix}}
+		}
+		actions = append(actions, CodeAction{
+			Title:       fmt.Sprintf("Apply the fix of %s", data.Tool),
+			Kind:        QuickFix,
+			Diagnostics: []Diagnostic{d},
+			IsPreferred: true,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{uri: edits},
+			},
+		})
+	}
+	return actions
+}
@@ -0,0 +1,38 @@
+package langserver
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestDataFixCodeActions(t *testing.T) {
+	re := regexp.MustCompile(`(?m)^(?P<line>\d+):(?P<col>\d+) (?P<message>[^|]*)\|(?P<fix>.*)$`)
+	matches := patternMatches(re, []byte("1:5 use const|const\n"))
+	if len(matches) != 1 || matches[0].fix == nil || *matches[0].fix != "const" {
+		t.Fatalf("unexpected matches: %+v", matches)
+	}
+
+	uri := DocumentURI("file:///foo.js")
+	rng := Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 7}}
+	diagnostics := []Diagnostic{
+		{Range: rng, Message: "use const", Data: newDiagnosticData("mylinter", nil, matches[0].entry.Lines[0], matches[0].fix, nil)},
+		{Range: rng, Message: "no fix", Data: newDiagnosticData("mylinter", nil, "", nil, nil)},
+		{Range: rng, Message: "no data"},
+	}
+	actions := dataFixCodeActions(uri, diagnostics)
+	if len(actions) != 1 {
+		t.Fatalf("expected one code action but got: %+v", actions)
+	}
+	edits := actions[0].Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+	if len(edits) != 1 || edits[0].Range != rng || edits[0].NewText != "const" {
+		t.Fatalf("unexpected edits: %+v", edits)
+	}
+}
+
+func TestDataFixCodeActionsAdvertised(t *testing.T) {
+	config := NewConfig()
+	(*config.Languages)["python"] = []Language{{LintCommand: "ruff check --output-format json -"}}
+	if result := initializeServer(t, config); result.Capabilities.CodeActionProvider == nil {
+		t.Fatalf("expected code actions to be provided for the fixes of the linters: %+v", result.Capabilities)
+	}
+}
@@ -0,0 +1,110 @@
+package langserver
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	filesPlaceholder = "${FILES}"
+
+	// defaultLintMaxCommandLength keeps the commands of ${FILES} under the
+	// limit of cmd.exe, which is the lowest.
+	defaultLintMaxCommandLength = 8000
+)
+
+// defaultLintFilesExclude is skipped when listing the files of ${FILES},
+// unless lint-files-exclude is set. .git is always skipped.
+var defaultLintFilesExclude = []string{"node_modules"}
+
+// lintFiles returns the files under root which ${FILES} expands to,
+// relative to root: the ones matching lint-files, or having the extension
+// of fname by default.
+func lintFiles(config *Language, root, fname string) ([]string, error) {
+	patterns := config.LintFiles
+	if len(patterns) == 0 {
+		if ext := filepath.Ext(fname); ext != "" {
+			patterns = []string{"*" + ext}
+		} else {
+			patterns = []string{filepath.Base(fname)}
+		}
+	}
+	exclude := config.LintFilesExclude
+	if exclude == nil {
+		exclude = defaultLintFilesExclude
+	}
+	matchAny := func(patterns []string, path string) bool {
+		for _, pattern := range patterns {
+			if matchGlob(pattern, path, root) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped.
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if d.Name() == ".git" || matchAny(exclude, path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !matchAny(patterns, path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// filesCommands expands ${FILES} in command to files, split into as many
+// commands as needed to keep each under maxLength bytes. A file longer than
+// maxLength still gets a command of its own.
+func filesCommands(command, shell string, files []string, maxLength int) []string {
+	if maxLength <= 0 {
+		maxLength = defaultLintMaxCommandLength
+	}
+	count := strings.Count(command, filesPlaceholder)
+	base := len(command) - count*len(filesPlaceholder)
+
+	var commands []string
+	var chunk []string
+	length := 0
+	flush := func() {
+		if len(chunk) > 0 {
+			commands = append(commands, strings.Replace(command, filesPlaceholder, strings.Join(chunk, " "), -1))
+		}
+		chunk, length = nil, 0
+	}
+	for _, file := range files {
+		arg := quoteArg(shell, file)
+		added := len(arg)
+		if len(chunk) > 0 {
+			added++
+		}
+		if len(chunk) > 0 && base+count*(length+added) > maxLength {
+			flush()
+			added = len(arg)
+		}
+		chunk = append(chunk, arg)
+		length += added
+	}
+	flush()
+	return commands
+}
@@ -0,0 +1,130 @@
+package langserver
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLintFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.ts", "b.js", "src/c.ts", "src/d.test.ts", "node_modules/x/e.ts", ".git/f.ts"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fname := filepath.Join(root, "a.ts")
+
+	tests := []struct {
+		name   string
+		config Language
+		want   []string
+	}{
+		{"extension of the document", Language{}, []string{"a.ts", "src/c.ts", "src/d.test.ts"}},
+		{"lint-files", Language{LintFiles: []string{"*.js", "src/*.ts"}}, []string{"b.js", "src/c.ts", "src/d.test.ts"}},
+		{"lint-files-exclude", Language{LintFilesExclude: []string{"*.test.ts"}}, []string{"a.ts", "node_modules/x/e.ts", "src/c.ts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := lintFiles(&tt.config, root, fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range files {
+				files[i] = filepath.ToSlash(files[i])
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Fatalf("want %v but got %v", tt.want, files)
+			}
+		})
+	}
+}
+
+func TestFilesCommands(t *testing.T) {
+	files := []string{"a.ts", "b.ts", "c.ts", "a-very-long-name.ts"}
+	commands := filesCommands("tsc ${FILES}", shellSh, files, 14)
+	want := []string{"tsc a.ts b.ts", "tsc c.ts", "tsc a-very-long-name.ts"}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("want %q but got %q", want, commands)
+	}
+
+	commands = filesCommands("tsc ${FILES}", shellSh, files, 0)
+	want = []string{"tsc a.ts b.ts c.ts a-very-long-name.ts"}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("want %q but got %q", want, commands)
+	}
+}
+
+func TestLintWorkspaceFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	root := t.TempDir()
+	for _, name := range []string{"a.vim", "b.vim", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.ToSlash(filepath.Join(root, "a.vim"))
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: root,
+		configs: map[string][]Language{
+			"vim": {{
+				LintCommand:          `for f in ${FILES}; do echo "$f:1:linted"; done`,
+				LintWorkspace:        true,
+				LintIgnoreExitCode:   true,
+				LintMaxCommandLength: 40,
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\n"},
+		},
+		lastPublishedURIs: make(map[string]map[DocumentURI]struct{}),
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var linted []string
+	for diagURI, diagnostics := range d {
+		if len(diagnostics) > 0 {
+			linted = append(linted, filepath.Base(string(diagURI)))
+		}
+	}
+	if strings.Join(linted, ",") != "a.vim,b.vim" && strings.Join(linted, ",") != "b.vim,a.vim" {
+		t.Fatalf("the .vim files should be linted, got %v", linted)
+	}
+}
+
+func TestFilesCommandsQuoting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	files := []string{"my file.ts", "$HOME.ts", "a;b&c.ts", "it's.ts", "plain.ts"}
+	commands := filesCommands("printf '%s\\n' ${FILES}", shellSh, files, 0)
+	if len(commands) != 1 {
+		t.Fatalf("expected one command, got %q", commands)
+	}
+	b, err := exec.Command("sh", "-c", commands[0]).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); !reflect.DeepEqual(got, files) {
+		t.Fatalf("expected the files as arguments %q, got %q from %q", files, got, commands[0])
+	}
+}
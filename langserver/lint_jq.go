@@ -0,0 +1,273 @@
+package langserver
+
+import (
+	"cmp"
+	"encoding/json"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/itchyny/gojq"
+)
+
+// JQFields names the fields of the values of lint-jq holding the parts of
+// a diagnostic, as paths of keys separated by dots, e.g. location.row. The
+// fields not set are the ones of the default shape:
+//
+//	{file, message, severity, rule, range: {start, end}, related, fix}
+type JQFields struct {
+	File      string `yaml:"file" json:"file"`
+	Message   string `yaml:"message" json:"message"`
+	Severity  string `yaml:"severity" json:"severity"`
+	Code      string `yaml:"code" json:"code"`
+	Line      string `yaml:"line" json:"line"`
+	Column    string `yaml:"column" json:"column"`
+	EndLine   string `yaml:"end-line" json:"endLine"`
+	EndColumn string `yaml:"end-column" json:"endColumn"`
+	// Fix is the text the tool suggests to replace the range with.
+	Fix string `yaml:"fix" json:"fix"`
+	// The lines and columns are one based, like most tools print them,
+	// instead of zero based like LSP.
+	OneBased bool `yaml:"one-based" json:"oneBased"`
+}
+
+// jqField returns the value at the path of keys separated by dots in v.
+func jqField(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// jqString returns a string or a number as a string.
+func jqString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return ""
+}
+
+// jqOutputDiagnostics runs the lint-jq filter of config over the JSON output
+// b. Each value of the filter is a diagnostic, or an array of diagnostics,
+// whose fields are named by lint-jq-fields. ok is false if the output isn't
+// JSON or the filter is invalid.
+func (h *langHandler) jqOutputDiagnostics(b []byte, uri DocumentURI, rootPath string, config *Language) (map[DocumentURI][]Diagnostic, bool) {
+	var jsonData any
+	if err := json.Unmarshal(b, &jsonData); err != nil {
+		return nil, false
+	}
+	query, err := gojq.Parse(config.LintJQ)
+	if err != nil {
+		return nil, false
+	}
+
+	fields := JQFields{File: "file", Message: "message", Severity: "severity", Code: "rule", Fix: "fix"}
+	if f := config.LintJQFields; f != nil {
+		fields.File = cmp.Or(f.File, fields.File)
+		fields.Message = cmp.Or(f.Message, fields.Message)
+		fields.Severity = cmp.Or(f.Severity, fields.Severity)
+		fields.Code = cmp.Or(f.Code, fields.Code)
+		fields.Fix = cmp.Or(f.Fix, fields.Fix)
+		fields.Line, fields.Column = f.Line, f.Column
+		fields.EndLine, fields.EndColumn = f.EndLine, f.EndColumn
+		fields.OneBased = f.OneBased
+	}
+
+	tool := toolName(config, nil)
+	diagnostics := make(map[DocumentURI][]Diagnostic)
+	add := func(v interface{}) {
+		diagMap, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		file := jqString(jqField(diagMap, fields.File))
+		message := jqString(jqField(diagMap, fields.Message))
+		rule := jqString(jqField(diagMap, fields.Code))
+		severity, ok := severityNamed(jqString(jqField(diagMap, fields.Severity)))
+		if !ok {
+			severity = 1
+		}
+		rng := jqRange(diagMap["range"])
+		if fields.Line != "" {
+			rng = fields.rangeOf(diagMap)
+		}
+		uriForDiag := uri
+		if file != "" {
+			uriForDiag = toURI(h.localPath(config, rootPath, file))
+		}
+		// related is an array of {file, range, message} of other
+		// locations of the diagnostic.
+		var related []DiagnosticRelatedInformation
+		items, _ := diagMap["related"].([]interface{})
+		for _, item := range items {
+			r, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			relatedURI := uriForDiag
+			if file, _ := r["file"].(string); file != "" {
+				relatedURI = toURI(h.localPath(config, rootPath, file))
+			}
+			relatedMessage, _ := r["message"].(string)
+			related = append(related, DiagnosticRelatedInformation{
+				Location: Location{URI: relatedURI, Range: jqRange(r["range"])},
+				Message:  relatedMessage,
+			})
+		}
+		var fix *string
+		var edits []TextEdit
+		switch v := jqField(diagMap, fields.Fix).(type) {
+		case string:
+			fix = &v
+		case nil:
+		default:
+			var text *string
+			if f, ok := h.files[uriForDiag]; ok {
+				text = &f.Text
+			}
+			edits = jqFixEdits(v, text)
+		}
+		output, _ := json.Marshal(diagMap)
+		diagnostics[uriForDiag] = append(diagnostics[uriForDiag], Diagnostic{
+			Range:              rng,
+			Severity:           severity,
+			Message:            message,
+			Code:               &rule,
+			Source:             nil,
+			RelatedInformation: related,
+			Data:               newDiagnosticData(tool, &rule, string(output), fix, edits),
+		})
+	}
+
+	iter := query.Run(jsonData)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if items, ok := v.([]interface{}); ok {
+			for _, item := range items {
+				add(item)
+			}
+			continue
+		}
+		add(v)
+	}
+	return diagnostics, true
+}
+
+// jqFixEdits returns the edits of a fix which isn't the replacement of the
+// range of its diagnostic, an edit or an array of edits of the shapes:
+//
+//	{range: {start, end}, text}  zero based, like LSP
+//	{range: [start, end], text}  offsets in text, like eslint
+//	{edits: [{content, location: {row, column}, end_location}]}  like ruff
+//
+// The edits of offsets are dropped without the text of the document.
+func jqFixEdits(v any, text *string) []TextEdit {
+	var edits []TextEdit
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			edits = append(edits, jqFixEdits(item, text)...)
+		}
+	case map[string]any:
+		if items, ok := v["edits"].([]any); ok {
+			for _, item := range items {
+				e, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+				position := func(v any) Position {
+					return Position{
+						Line:      max(int(safeFloat(jqField(v, "row")))-1, 0),
+						Character: max(int(safeFloat(jqField(v, "column")))-1, 0),
+					}
+				}
+				edits = append(edits, TextEdit{
+					Range:   Range{Start: position(e["location"]), End: position(e["end_location"])},
+					NewText: jqString(e["content"]),
+				})
+			}
+			break
+		}
+		newText := jqString(v["text"])
+		if offsets, ok := v["range"].([]any); ok {
+			if len(offsets) == 2 && text != nil {
+				edits = append(edits, TextEdit{
+					Range: Range{
+						Start: offsetPosition(*text, int(safeFloat(offsets[0]))),
+						End:   offsetPosition(*text, int(safeFloat(offsets[1]))),
+					},
+					NewText: newText,
+				})
+			}
+			break
+		}
+		if _, ok := v["range"].(map[string]any); ok {
+			edits = append(edits, TextEdit{Range: jqRange(v["range"]), NewText: newText})
+		}
+	}
+	return edits
+}
+
+// offsetPosition returns the position of the offset in text, counted in
+// UTF-16 code units like the indexes of JavaScript strings.
+func offsetPosition(text string, offset int) Position {
+	var p Position
+	n := 0
+	for _, r := range text {
+		if n >= offset {
+			break
+		}
+		n += max(utf16.RuneLen(r), 1)
+		if r == '\n' {
+			p.Line++
+			p.Character = 0
+		} else {
+			p.Character += max(utf16.RuneLen(r), 1)
+		}
+	}
+	return p
+}
+
+// rangeOf returns the range of a diagnostic from the line and column
+// fields. Without an end, the range is empty at the start.
+func (fields *JQFields) rangeOf(diagMap map[string]interface{}) Range {
+	offset := 0
+	if fields.OneBased {
+		offset = 1
+	}
+	position := func(line, column string) (Position, bool) {
+		l := jqField(diagMap, line)
+		if l == nil {
+			return Position{}, false
+		}
+		p := Position{Line: max(int(safeFloat(l))-offset, 0)}
+		if column != "" {
+			p.Character = max(int(safeFloat(jqField(diagMap, column)))-offset, 0)
+		}
+		return p, true
+	}
+	start, _ := position(fields.Line, fields.Column)
+	end := start
+	if fields.EndLine != "" {
+		if p, ok := position(fields.EndLine, fields.EndColumn); ok {
+			end = p
+		}
+	} else if fields.EndColumn != "" {
+		if c := jqField(diagMap, fields.EndColumn); c != nil {
+			end.Character = max(int(safeFloat(c))-offset, 0)
+		}
+	}
+	return Range{Start: start, End: end}
+}
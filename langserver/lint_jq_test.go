@@ -0,0 +1,91 @@
+package langserver
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJQOutputDiagnosticsFields(t *testing.T) {
+	base, _ := filepath.Abs("/project")
+	uri := toURI(filepath.Join(base, "main.py"))
+	h := &langHandler{rootPath: base}
+	config := &Language{
+		LintCommand: "ruff check --output-format json",
+		LintJQ:      `[.[] | select(.code != "ignored")]`,
+		LintJQFields: &JQFields{
+			File:      "filename",
+			Code:      "code",
+			Line:      "location.row",
+			Column:    "location.column",
+			EndLine:   "end_location.row",
+			EndColumn: "end_location.column",
+			OneBased:  true,
+		},
+	}
+	output := `[
+		{"filename": "` + filepath.ToSlash(filepath.Join(base, "main.py")) + `", "code": "F401", "message": "unused import", "severity": 2,
+		 "location": {"row": 1, "column": 8}, "end_location": {"row": 1, "column": 10}},
+		{"code": "ignored", "message": "ignored", "location": {"row": 1, "column": 1}}
+	]`
+
+	diagnostics, ok := h.jqOutputDiagnostics([]byte(output), uri, base, config)
+	if !ok {
+		t.Fatal("expected the output to be parsed")
+	}
+	var data diagnosticData
+	if err := json.Unmarshal(diagnostics[uri][0].Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Tool != "ruff" || data.Rule != "F401" || !strings.Contains(data.Output, `"row":1`) {
+		t.Fatalf("unexpected data: %+v", data)
+	}
+	diagnostics[uri][0].Data = nil
+
+	code := "F401"
+	want := []Diagnostic{{
+		Range:    Range{Start: Position{Line: 0, Character: 7}, End: Position{Line: 0, Character: 9}},
+		Severity: 2,
+		Code:     &code,
+		Message:  "unused import",
+	}}
+	if !reflect.DeepEqual(diagnostics[uri], want) {
+		t.Fatalf("expected %+v but got %+v", want, diagnostics)
+	}
+}
+
+func TestJQFixEdits(t *testing.T) {
+	text := "var x = 1\nlet é = 2\n"
+	tests := []struct {
+		name string
+		fix  string
+		want []TextEdit
+	}{
+		{
+			"lsp",
+			`{"range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 3}}, "text": "const"}`,
+			[]TextEdit{{Range: Range{Start: Position{0, 0}, End: Position{0, 3}}, NewText: "const"}},
+		},
+		{
+			"eslint",
+			`{"range": [19, 19], "text": ";"}`,
+			[]TextEdit{{Range: Range{Start: Position{1, 9}, End: Position{1, 9}}, NewText: ";"}},
+		},
+		{
+			"ruff",
+			`{"applicability": "safe", "edits": [{"content": "", "location": {"row": 1, "column": 1}, "end_location": {"row": 2, "column": 1}}]}`,
+			[]TextEdit{{Range: Range{Start: Position{0, 0}, End: Position{1, 0}}, NewText: ""}},
+		},
+	}
+	for _, tt := range tests {
+		var v any
+		if err := json.Unmarshal([]byte(tt.fix), &v); err != nil {
+			t.Fatal(err)
+		}
+		if got := jqFixEdits(v, &text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v but got %+v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/reviewdog/errorformat"
+)
+
+// patternMatch is a match of lint-pattern read like an errorformat entry.
+type patternMatch struct {
+	entry errorformat.Entry
+	// code is the code group, which may not be a number like the ones of
+	// errorformat.
+	code *string
+	// fix is the fix group, the replacement of the range the tool suggests.
+	fix *string
+}
+
+// patternMatches returns the matches of the lint-pattern re in the output b.
+// The named groups are file, line, col, end_line, end_col, severity, code,
+// message and fix. Like %t of errorformat, the first letter of severity is the
+// type: E, W, I or N.
+func patternMatches(re *regexp.Regexp, b []byte) []patternMatch {
+	var matches []patternMatch
+	for _, m := range re.FindAllSubmatch(b, -1) {
+		entry := errorformat.Entry{Valid: true, Lines: []string{string(m[0])}}
+		var code, fix *string
+		for i, name := range re.SubexpNames() {
+			value := strings.TrimSpace(string(m[i]))
+			if name == "" || value == "" {
+				continue
+			}
+			switch name {
+			case "file":
+				entry.Filename = value
+			case "line":
+				entry.Lnum, _ = strconv.Atoi(value)
+			case "col", "column":
+				entry.Col, _ = strconv.Atoi(value)
+			case "end_line":
+				entry.EndLnum, _ = strconv.Atoi(value)
+			case "end_col", "end_column":
+				entry.EndCol, _ = strconv.Atoi(value)
+			case "severity":
+				entry.Type = []rune(value)[0]
+			case "code":
+				code = &value
+			case "message":
+				entry.Text = value
+			case "fix":
+				// The fix is kept as it is, whitespace included.
+				raw := string(m[i])
+				fix = &raw
+			}
+		}
+		matches = append(matches, patternMatch{entry: entry, code: code, fix: fix})
+	}
+	return matches
+}
@@ -0,0 +1,189 @@
+package langserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// lintServer is a lint-server tool started once and asked to lint over its
+// stdin and stdout. A request is the file name and the text of the document:
+//
+//	File: /path/to/file\r\n
+//	Content-Length: 12\r\n
+//	\r\n
+//	<text>
+//
+// and the response is the output to parse with lint-formats:
+//
+//	Content-Length: 34\r\n
+//	\r\n
+//	<output>
+type lintServer struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	exited chan struct{}
+}
+
+// lintServerPool keeps one running lint server per command and project root.
+type lintServerPool struct {
+	mu      sync.Mutex
+	servers map[string]*lintServer
+}
+
+func (p *lintServerPool) get(key string, start func() (*exec.Cmd, error)) (*lintServer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s, ok := p.servers[key]; ok {
+		select {
+		case <-s.exited:
+			delete(p.servers, key)
+		default:
+			return s, nil
+		}
+	}
+
+	cmd, err := start()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start lint server: %v", err)
+	}
+	s := &lintServer{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		exited: make(chan struct{}),
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(s.exited)
+	}()
+	if p.servers == nil {
+		p.servers = make(map[string]*lintServer)
+	}
+	p.servers[key] = s
+	return s, nil
+}
+
+// remove stops the server of key if it is still s, e.g. after a request was
+// abandoned halfway and the framing can't be trusted anymore.
+func (p *lintServerPool) remove(key string, s *lintServer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.servers[key] == s {
+		delete(p.servers, key)
+	}
+	s.stop()
+}
+
+func (p *lintServerPool) stopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, s := range p.servers {
+		s.stop()
+		delete(p.servers, key)
+	}
+}
+
+func (s *lintServer) stop() {
+	_ = s.stdin.Close()
+	if s.cmd.Process != nil {
+		_ = killProcessGroup(s.cmd.Process)
+	}
+}
+
+// request sends a document to the server and reads the response. Requests
+// to a server are serialized.
+func (s *lintServer) request(fname, text string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header := fmt.Sprintf("File: %s\r\nContent-Length: %d\r\n\r\n", fname, len(text))
+	if _, err := io.WriteString(s.stdin, header+text); err != nil {
+		return nil, err
+	}
+
+	length := -1
+	for {
+		line, err := s.stdout.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header: %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length")
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(s.stdout, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// lintServerOutput asks the lint server of config to lint the document,
+// starting the server if it isn't running.
+func (h *langHandler) lintServerOutput(ctx context.Context, config *Language, rootPath, command, fname, text string) ([]byte, error) {
+	key := strings.Join([]string{h.shellFor(config), rootPath, command}, "\x00")
+	s, err := h.lintServers.get(key, func() (*exec.Cmd, error) {
+		// The server outlives the lint starting it.
+		return h.newCommand(context.Background(), config, rootPath, command)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type response struct {
+		b   []byte
+		err error
+	}
+	done := make(chan response, 1)
+	go func() {
+		b, err := s.request(h.remotePath(config, rootPath, fname), text)
+		done <- response{b, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			h.lintServers.remove(key, s)
+			return nil, fmt.Errorf("lint server `%s` failed: %v", command, r.err)
+		}
+		return r.b, nil
+	case <-ctx.Done():
+		h.lintServers.remove(key, s)
+		return nil, ctx.Err()
+	}
+}
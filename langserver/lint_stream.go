@@ -0,0 +1,80 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"os/exec"
+	"time"
+)
+
+// lintStreamInterval is the minimum time between two publishes of the
+// diagnostics a workspace linter found so far.
+const lintStreamInterval = 500 * time.Millisecond
+
+// streamOutput runs cmd like cmd.CombinedOutput, passing the output to
+// consume while the command runs. The caller holds the slot of
+// max-concurrent-commands.
+func (h *langHandler) streamOutput(cmd *exec.Cmd, consume func(io.Reader)) ([]byte, error) {
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	waited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		waited <- err
+	}()
+
+	var out bytes.Buffer
+	r := io.TeeReader(pr, &out)
+	consume(r)
+	// Read what consume left, so that the command doesn't block on a full
+	// pipe and the output is complete.
+	_, _ = io.Copy(io.Discard, r)
+	return out.Bytes(), <-waited
+}
+
+// partialPublisher returns a function publishing the diagnostics found so
+// far in result, at most once per lintStreamInterval. The final result is
+// published by the linter when the command exits.
+func (h *langHandler) partialPublisher(result *lintResult, version int) func() {
+	last := time.Now()
+	return func() {
+		if h.conn == nil || time.Since(last) < lintStreamInterval {
+			return
+		}
+		last = time.Now()
+		for uri, diagnostics := range result.diagnostics {
+			h.publishDiagnostics(context.Background(), uri, append([]Diagnostic(nil), diagnostics...), version, true)
+		}
+	}
+}
+
+// rememberStreamedURIs adds the URIs a canceled lint may have published
+// diagnostics for to the last published ones, so that the next lint clears
+// them.
+func (h *langHandler) rememberStreamedURIs(languageID string, results []lintResult) {
+	h.publishedURIsMu.Lock()
+	defer h.publishedURIsMu.Unlock()
+	published := maps.Clone(h.lastPublishedURIs[languageID])
+	for _, result := range results {
+		for uri := range result.publishedURIs {
+			if published == nil {
+				published = make(map[DocumentURI]struct{})
+			}
+			published[uri] = struct{}{}
+		}
+	}
+	if published == nil {
+		return
+	}
+	if h.lastPublishedURIs == nil {
+		h.lastPublishedURIs = make(map[string]map[DocumentURI]struct{})
+	}
+	h.lastPublishedURIs[languageID] = published
+}
@@ -0,0 +1,48 @@
+package langserver
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// linterConfigs are the config files of well-known linters, watched for the
+// tools running them which don't set lint-watch.
+var linterConfigs = map[string][]string{
+	"eslint":        {".eslintrc*", "eslint.config.*"},
+	"eslint_d":      {".eslintrc*", "eslint.config.*"},
+	"stylelint":     {".stylelintrc*", "stylelint.config.*"},
+	"textlint":      {".textlintrc*"},
+	"markdownlint":  {".markdownlint*"},
+	"flake8":        {".flake8", "setup.cfg", "tox.ini"},
+	"pylint":        {".pylintrc", "pylintrc", "pyproject.toml"},
+	"ruff":          {"ruff.toml", ".ruff.toml", "pyproject.toml"},
+	"mypy":          {"mypy.ini", ".mypy.ini", "pyproject.toml", "setup.cfg"},
+	"golangci-lint": {".golangci.*"},
+	"shellcheck":    {".shellcheckrc"},
+	"hadolint":      {".hadolint.yaml", ".hadolint.yml"},
+	"yamllint":      {".yamllint", ".yamllint.yaml", ".yamllint.yml"},
+	"rubocop":       {".rubocop.yml"},
+	"cspell":        {"cspell.json", ".cspell.json", "cspell.config.*"},
+}
+
+// linterCommandWords is how many words of a lint command are looked at for a
+// well-known linter, e.g. `npx eslint` or `poetry run ruff check`.
+const linterCommandWords = 4
+
+// lintWatch returns the lint-watch globs of the tool, or the config files of
+// the well-known linter its lint command runs.
+func (cfg *Language) lintWatch() []string {
+	if len(cfg.LintWatch) > 0 || cfg.LintCommand == "" {
+		return cfg.LintWatch
+	}
+	for i, word := range strings.Fields(cfg.LintCommand) {
+		if i == linterCommandWords {
+			break
+		}
+		name := strings.TrimSuffix(filepath.Base(filepath.ToSlash(word)), ".exe")
+		if globs, ok := linterConfigs[name]; ok {
+			return globs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintWatchDefaults(t *testing.T) {
+	tests := []struct {
+		cfg      Language
+		expected []string
+	}{
+		{Language{LintCommand: "eslint -f unix --stdin"}, []string{".eslintrc*", "eslint.config.*"}},
+		{Language{LintCommand: "npx eslint -f unix"}, []string{".eslintrc*", "eslint.config.*"}},
+		{Language{LintCommand: "poetry run ruff check -"}, []string{"ruff.toml", ".ruff.toml", "pyproject.toml"}},
+		{Language{LintCommand: "/usr/bin/shellcheck -f gcc -"}, []string{".shellcheckrc"}},
+		{Language{LintCommand: "eslint", LintWatch: []string{"package.json"}}, []string{"package.json"}},
+		{Language{LintCommand: "vint -"}, nil},
+		{Language{LintCommand: "sh -c 'cat | a | b | eslint'"}, nil},
+		{Language{FormatCommand: "ruff format -"}, nil},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.lintWatch(); !reflect.DeepEqual(got, tt.expected) {
+			t.Fatalf("%q: expected %v but got: %v", tt.cfg.LintCommand, tt.expected, got)
+		}
+	}
+}
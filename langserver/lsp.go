@@ -1,5 +1,11 @@
 package langserver
 
+import (
+	"encoding/json"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
 const wildcard = "*"
 
 // DocumentURI is
@@ -22,10 +28,57 @@ type InitializeOptions struct {
 	DocumentSymbol     bool `json:"documentSymbol"`
 	CodeAction         bool `json:"codeAction"`
 	Completion         bool `json:"completion"`
+
+	// Profile names the profile of the config to use.
+	Profile string `json:"profile"`
+
+	// MinimumSeverity hides the diagnostics less severe than it.
+	MinimumSeverity Severity `json:"minimumSeverity"`
 }
 
 // ClientCapabilities is
-type ClientCapabilities struct{}
+type ClientCapabilities struct {
+	Workspace    WorkspaceClientCapabilities    `json:"workspace,omitempty"`
+	TextDocument TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+// WorkspaceClientCapabilities is
+type WorkspaceClientCapabilities struct {
+	DidChangeWatchedFiles DynamicRegistrationCapabilities       `json:"didChangeWatchedFiles,omitempty"`
+	Diagnostics           DiagnosticWorkspaceClientCapabilities `json:"diagnostics,omitempty"`
+}
+
+// DiagnosticWorkspaceClientCapabilities is
+type DiagnosticWorkspaceClientCapabilities struct {
+	RefreshSupport bool `json:"refreshSupport,omitempty"`
+}
+
+// TextDocumentClientCapabilities is
+type TextDocumentClientCapabilities struct {
+	Diagnostic *DiagnosticClientCapabilities `json:"diagnostic,omitempty"`
+	CodeAction CodeActionClientCapabilities  `json:"codeAction,omitempty"`
+}
+
+// CodeActionClientCapabilities is
+type CodeActionClientCapabilities struct {
+	ResolveSupport *CodeActionResolveSupport `json:"resolveSupport,omitempty"`
+}
+
+// CodeActionResolveSupport is
+type CodeActionResolveSupport struct {
+	Properties []string `json:"properties"`
+}
+
+// DiagnosticClientCapabilities is
+type DiagnosticClientCapabilities struct {
+	DynamicRegistration    bool `json:"dynamicRegistration,omitempty"`
+	RelatedDocumentSupport bool `json:"relatedDocumentSupport,omitempty"`
+}
+
+// DynamicRegistrationCapabilities is
+type DynamicRegistrationCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+}
 
 // InitializeResult is
 type InitializeResult struct {
@@ -54,6 +107,20 @@ const (
 	TDSKIncremental
 )
 
+// TextDocumentSyncOptions is
+type TextDocumentSyncOptions struct {
+	OpenClose         bool                 `json:"openClose"`
+	Change            TextDocumentSyncKind `json:"change"`
+	WillSave          bool                 `json:"willSave,omitempty"`
+	WillSaveWaitUntil bool                 `json:"willSaveWaitUntil,omitempty"`
+	Save              *SaveOptions         `json:"save,omitempty"`
+}
+
+// SaveOptions is
+type SaveOptions struct {
+	IncludeText bool `json:"includeText,omitempty"`
+}
+
 // CompletionProvider is
 type CompletionProvider struct {
 	ResolveProvider   bool     `json:"resolveProvider,omitempty"`
@@ -69,19 +136,89 @@ type WorkspaceFoldersServerCapabilities struct {
 // ServerCapabilitiesWorkspace is
 type ServerCapabilitiesWorkspace struct {
 	WorkspaceFolders WorkspaceFoldersServerCapabilities `json:"workspaceFolders"`
+	FileOperations   *FileOperationsServerCapabilities  `json:"fileOperations,omitempty"`
+}
+
+// FileOperationsServerCapabilities is
+type FileOperationsServerCapabilities struct {
+	DidRename *FileOperationRegistrationOptions `json:"didRename,omitempty"`
+	DidDelete *FileOperationRegistrationOptions `json:"didDelete,omitempty"`
+}
+
+// FileOperationRegistrationOptions is
+type FileOperationRegistrationOptions struct {
+	Filters []FileOperationFilter `json:"filters"`
+}
+
+// FileOperationFilter is
+type FileOperationFilter struct {
+	Scheme  string               `json:"scheme,omitempty"`
+	Pattern FileOperationPattern `json:"pattern"`
+}
+
+// FileOperationPattern is
+type FileOperationPattern struct {
+	Glob string `json:"glob"`
+}
+
+// RenameFilesParams is
+type RenameFilesParams struct {
+	Files []FileRename `json:"files"`
+}
+
+// FileRename is
+type FileRename struct {
+	OldURI DocumentURI `json:"oldUri"`
+	NewURI DocumentURI `json:"newUri"`
+}
+
+// DeleteFilesParams is
+type DeleteFilesParams struct {
+	Files []FileDelete `json:"files"`
+}
+
+// FileDelete is
+type FileDelete struct {
+	URI DocumentURI `json:"uri"`
 }
 
 // ServerCapabilities is
 type ServerCapabilities struct {
-	TextDocumentSync           TextDocumentSyncKind         `json:"textDocumentSync,omitempty"`
+	TextDocumentSync           any                          `json:"textDocumentSync,omitempty"`
 	DocumentSymbolProvider     bool                         `json:"documentSymbolProvider,omitempty"`
 	CompletionProvider         *CompletionProvider          `json:"completionProvider,omitempty"`
 	DefinitionProvider         bool                         `json:"definitionProvider,omitempty"`
 	DocumentFormattingProvider bool                         `json:"documentFormattingProvider,omitempty"`
 	RangeFormattingProvider    bool                         `json:"documentRangeFormattingProvider,omitempty"`
 	HoverProvider              bool                         `json:"hoverProvider,omitempty"`
-	CodeActionProvider         bool                         `json:"codeActionProvider,omitempty"`
+	CodeActionProvider         any                          `json:"codeActionProvider,omitempty"`
+	CodeLensProvider           *CodeLensOptions             `json:"codeLensProvider,omitempty"`
+	ExecuteCommandProvider     *ExecuteCommandOptions       `json:"executeCommandProvider,omitempty"`
 	Workspace                  *ServerCapabilitiesWorkspace `json:"workspace,omitempty"`
+	DiagnosticProvider         *DiagnosticOptions           `json:"diagnosticProvider,omitempty"`
+}
+
+// DiagnosticOptions is
+type DiagnosticOptions struct {
+	Identifier            string `json:"identifier,omitempty"`
+	InterFileDependencies bool   `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool   `json:"workspaceDiagnostics"`
+}
+
+// CodeActionOptions is
+type CodeActionOptions struct {
+	CodeActionKinds []CodeActionKind `json:"codeActionKinds,omitempty"`
+	ResolveProvider bool             `json:"resolveProvider,omitempty"`
+}
+
+// CodeLensOptions is
+type CodeLensOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+// ExecuteCommandOptions is
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
 }
 
 // TextDocumentItem is
@@ -132,6 +269,22 @@ type DidSaveTextDocumentParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
 }
 
+// WillSaveTextDocumentParams is
+type WillSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Reason       TextDocumentSaveReason `json:"reason"`
+}
+
+// TextDocumentSaveReason is
+type TextDocumentSaveReason int
+
+// TDSRManual is
+const (
+	TDSRManual TextDocumentSaveReason = iota + 1
+	TDSRAfterDelay
+	TDSRFocusOut
+)
+
 // TextDocumentPositionParams is
 type TextDocumentPositionParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
@@ -187,6 +340,14 @@ type Diagnostic struct {
 	Source             *string                        `json:"source,omitempty"`
 	Message            string                         `json:"message"`
 	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+	CodeDescription    *CodeDescription               `json:"codeDescription,omitempty"`
+	Tags               []int                          `json:"tags,omitempty"`
+	Data               json.RawMessage                `json:"data,omitempty"`
+}
+
+// CodeDescription is
+type CodeDescription struct {
+	Href string `json:"href"`
 }
 
 // PublishDiagnosticsParams is
@@ -282,6 +443,21 @@ type Command struct {
 	Command   string `json:"command" yaml:"command"`
 	Arguments []any  `json:"arguments,omitempty" yaml:"arguments,omitempty"`
 	OS        string `json:"-" yaml:"os,omitempty"`
+
+	Parameters []CommandParameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	OutputMode string             `json:"outputMode,omitempty" yaml:"output-mode,omitempty"`
+	// AppliesToCodes limits the command to code actions requested for
+	// diagnostics with a matching code.
+	AppliesToCodes []string `json:"appliesToCodes,omitempty" yaml:"applies-to-codes,omitempty"`
+}
+
+// CommandParameter is a value asked from the user when running a Command,
+// substituted for ${name} in the command line and arguments.
+type CommandParameter struct {
+	Name    string   `json:"name" yaml:"name"`
+	Prompt  string   `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	Default string   `json:"default,omitempty" yaml:"default,omitempty"`
+	Choices []string `json:"choices,omitempty" yaml:"choices,omitempty"`
 }
 
 // WorkspaceEdit is
@@ -293,10 +469,12 @@ type WorkspaceEdit struct {
 // CodeAction is
 type CodeAction struct {
 	Title       string         `json:"title"`
-	Diagnostics []Diagnostic   `json:"diagnostics"`
-	IsPreferred bool           `json:"isPreferred"` // TODO
-	Edit        *WorkspaceEdit `json:"edit"`
-	Command     *Command       `json:"command"`
+	Kind        CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+	Command     *Command       `json:"command,omitempty"`
+	Data        any            `json:"data,omitempty"`
 }
 
 // CompletionItem is
@@ -319,6 +497,12 @@ type CompletionItem struct {
 	Data                any                 `json:"data,omitempty"`
 }
 
+// CompletionList is
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
 // Hover is
 type Hover struct {
 	Contents any    `json:"contents"`
@@ -372,6 +556,7 @@ const (
 	RefactorRewrite       CodeActionKind = "refactor.rewrite"
 	Source                CodeActionKind = "source"
 	SourceOrganizeImports CodeActionKind = "source.organizeImports"
+	SourceFixAll          CodeActionKind = "source.fixAll"
 )
 
 // CodeActionContext is
@@ -441,3 +626,200 @@ type WorkspaceFolder struct {
 	URI  DocumentURI `json:"uri"`
 	Name string      `json:"name"`
 }
+
+// DocumentDiagnosticParams is
+type DocumentDiagnosticParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+
+	TextDocument     TextDocumentIdentifier `json:"textDocument"`
+	Identifier       string                 `json:"identifier,omitempty"`
+	PreviousResultID string                 `json:"previousResultId,omitempty"`
+}
+
+// DocumentDiagnosticReportKind is
+type DocumentDiagnosticReportKind string
+
+const (
+	// DiagnosticReportFull is
+	DiagnosticReportFull DocumentDiagnosticReportKind = "full"
+	// DiagnosticReportUnchanged is
+	DiagnosticReportUnchanged DocumentDiagnosticReportKind = "unchanged"
+)
+
+// FullDocumentDiagnosticReport is
+type FullDocumentDiagnosticReport struct {
+	Kind     DocumentDiagnosticReportKind `json:"kind"`
+	ResultID string                       `json:"resultId,omitempty"`
+	Items    []Diagnostic                 `json:"items"`
+}
+
+// UnchangedDocumentDiagnosticReport is
+type UnchangedDocumentDiagnosticReport struct {
+	Kind     DocumentDiagnosticReportKind `json:"kind"`
+	ResultID string                       `json:"resultId"`
+}
+
+// WorkspaceDiagnosticParams is
+type WorkspaceDiagnosticParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+
+	Identifier        string             `json:"identifier,omitempty"`
+	PreviousResultIDs []PreviousResultID `json:"previousResultIds"`
+}
+
+// PreviousResultID is
+type PreviousResultID struct {
+	URI   DocumentURI `json:"uri"`
+	Value string      `json:"value"`
+}
+
+// WorkspaceDiagnosticReport is
+type WorkspaceDiagnosticReport struct {
+	Items []any `json:"items"`
+}
+
+// WorkspaceFullDocumentDiagnosticReport is
+type WorkspaceFullDocumentDiagnosticReport struct {
+	FullDocumentDiagnosticReport
+
+	URI     DocumentURI `json:"uri"`
+	Version *int        `json:"version"`
+}
+
+// WorkspaceUnchangedDocumentDiagnosticReport is
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	UnchangedDocumentDiagnosticReport
+
+	URI     DocumentURI `json:"uri"`
+	Version *int        `json:"version"`
+}
+
+// CodeLensParams is
+type CodeLensParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// CodeLens is
+type CodeLens struct {
+	Range   Range    `json:"range"`
+	Command *Command `json:"command,omitempty"`
+	Data    any      `json:"data,omitempty"`
+}
+
+// RegistrationParams is
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+// Registration is
+type Registration struct {
+	ID              string `json:"id"`
+	Method          string `json:"method"`
+	RegisterOptions any    `json:"registerOptions,omitempty"`
+}
+
+// UnregistrationParams is
+type UnregistrationParams struct {
+	// The misspelling is the one of the specification.
+	Unregisterations []Unregistration `json:"unregisterations"`
+}
+
+// Unregistration is
+type Unregistration struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+}
+
+// DidChangeWatchedFilesRegistrationOptions is
+type DidChangeWatchedFilesRegistrationOptions struct {
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// FileSystemWatcher is
+type FileSystemWatcher struct {
+	GlobPattern string `json:"globPattern"`
+}
+
+// DidChangeWatchedFilesParams is
+type DidChangeWatchedFilesParams struct {
+	Changes []FileEvent `json:"changes"`
+}
+
+// FileEvent is
+type FileEvent struct {
+	URI  DocumentURI `json:"uri"`
+	Type int         `json:"type"`
+}
+
+// WorkDoneProgressCreateParams is
+type WorkDoneProgressCreateParams struct {
+	Token any `json:"token"`
+}
+
+// CancelParams is
+type CancelParams struct {
+	ID jsonrpc2.ID `json:"id"`
+}
+
+// ProgressParams is
+type ProgressParams struct {
+	Token any `json:"token"`
+	Value any `json:"value"`
+}
+
+// WorkDoneProgressBegin is
+type WorkDoneProgressBegin struct {
+	Kind        string `json:"kind"`
+	Title       string `json:"title"`
+	Cancellable bool   `json:"cancellable,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// WorkDoneProgressReport is
+type WorkDoneProgressReport struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+// WorkDoneProgressEnd is
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+// ShowMessageRequestParams is
+type ShowMessageRequestParams struct {
+	Type    MessageType         `json:"type"`
+	Message string              `json:"message"`
+	Actions []MessageActionItem `json:"actions,omitempty"`
+}
+
+// MessageActionItem is
+type MessageActionItem struct {
+	Title string `json:"title"`
+}
+
+// ApplyWorkspaceEditParams is
+type ApplyWorkspaceEditParams struct {
+	Label string        `json:"label,omitempty"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+// ApplyWorkspaceEditResult is
+type ApplyWorkspaceEditResult struct {
+	Applied       bool   `json:"applied"`
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// ShowDocumentParams is
+type ShowDocumentParams struct {
+	URI       DocumentURI `json:"uri"`
+	External  bool        `json:"external,omitempty"`
+	TakeFocus bool        `json:"takeFocus,omitempty"`
+	Selection *Range      `json:"selection,omitempty"`
+}
@@ -0,0 +1,122 @@
+package langserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// lspDiagnostic is a Diagnostic as a tool prints it, whose code may be a
+// number.
+type lspDiagnostic struct {
+	Diagnostic
+	Code json.RawMessage `json:"code,omitempty"`
+}
+
+// lspDiagnostics is the diagnostics of a file, like the parameters of
+// textDocument/publishDiagnostics.
+type lspDiagnostics struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+// lspOutputDiagnostics reads the output b of a lint tool of config with
+// lint-format-type lsp: an array of diagnostics of the document uri, or an
+// array of {uri, diagnostics} objects. The diagnostics are forwarded as
+// they are, except for the prefix and the source when they have none.
+func (h *langHandler) lspOutputDiagnostics(b []byte, uri DocumentURI, rootPath string, config *Language, source *string, prefix string) (map[DocumentURI][]Diagnostic, error) {
+	b = bytes.TrimSpace(b)
+	var files []lspDiagnostics
+	if bytes.HasPrefix(b, []byte("{")) {
+		var file lspDiagnostics
+		if err := json.Unmarshal(b, &file); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	} else {
+		var items []json.RawMessage
+		if err := json.Unmarshal(b, &items); err != nil {
+			return nil, err
+		}
+		var document lspDiagnostics
+		for _, item := range items {
+			var keys map[string]json.RawMessage
+			if err := json.Unmarshal(item, &keys); err != nil {
+				return nil, err
+			}
+			if _, ok := keys["diagnostics"]; ok {
+				var file lspDiagnostics
+				if err := json.Unmarshal(item, &file); err != nil {
+					return nil, err
+				}
+				files = append(files, file)
+				continue
+			}
+			var d lspDiagnostic
+			if err := json.Unmarshal(item, &d); err != nil {
+				return nil, err
+			}
+			document.Diagnostics = append(document.Diagnostics, d)
+		}
+		if len(document.Diagnostics) > 0 {
+			files = append(files, document)
+		}
+	}
+
+	diagnostics := make(map[DocumentURI][]Diagnostic)
+	for _, file := range files {
+		diagURI := uri
+		if file.URI != "" && !(config.LintStdin && isFilename(file.URI)) {
+			diagURI = h.lspOutputURI(file.URI, rootPath, config)
+		}
+		for _, d := range file.Diagnostics {
+			diagnostic := d.Diagnostic
+			if code := lspCode(d.Code); code != "" {
+				diagnostic.Code = &code
+			}
+			if diagnostic.Source == nil {
+				diagnostic.Source = source
+			}
+			diagnostic.Message = prefix + diagnostic.Message
+			if len(diagnostic.Data) == 0 {
+				diagnostic.Data = newDiagnosticData(toolName(config, source), diagnostic.Code, "", nil, nil)
+			}
+			diagnostics[diagURI] = append(diagnostics[diagURI], diagnostic)
+		}
+		if _, ok := diagnostics[diagURI]; !ok {
+			// An empty list clears the diagnostics of the file.
+			diagnostics[diagURI] = []Diagnostic{}
+		}
+	}
+	return diagnostics, nil
+}
+
+// lspOutputURI returns the URI of a file named by a tool, which may print a
+// URI or a path relative to root.
+func (h *langHandler) lspOutputURI(name, rootPath string, config *Language) DocumentURI {
+	if strings.HasPrefix(name, "file:") {
+		if path, err := fromURI(DocumentURI(name)); err == nil {
+			name = path
+		}
+	}
+	path := filepath.FromSlash(name)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(rootPath, path)
+	}
+	return toURI(h.localPath(config, rootPath, path))
+}
+
+// lspCode returns the code of a diagnostic, which LSP allows to be an
+// integer or a string, as a string.
+func lspCode(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+func TestLSPOutputDiagnostics(t *testing.T) {
+	root, _ := filepath.Abs("testdata")
+	uri := toURI(filepath.Join(root, "main.py"))
+	h := &langHandler{logger: log.New(io.Discard, "", 0)}
+	source := "mylinter"
+
+	d, err := h.lspOutputDiagnostics([]byte(`[
+	  {"range": {"start": {"line": 1, "character": 2}, "end": {"line": 1, "character": 5}},
+	   "severity": 2, "code": 42, "message": "unused", "tags": [1], "data": {"fix": "x"}}
+	]`), uri, root, &Language{}, &source, "[my] ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 {
+		t.Fatalf("expected a diagnostic for the document but got: %+v", d)
+	}
+	got := d[uri][0]
+	if got.Message != "[my] unused" || *got.Code != "42" || *got.Source != "mylinter" ||
+		got.Range.End.Character != 5 || len(got.Tags) != 1 || string(got.Data) != `{"fix": "x"}` {
+		t.Fatalf("the diagnostic should be forwarded as is: %+v", got)
+	}
+
+	d, err = h.lspOutputDiagnostics([]byte(`[
+	  {"uri": "other.py", "diagnostics": [{"range": {}, "message": "a", "source": "own", "code": "E1"}]},
+	  {"uri": "`+string(uri)+`", "diagnostics": []}
+	]`), uri, root, &Language{}, &source, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := d[toURI(filepath.Join(root, "other.py"))]
+	if len(other) != 1 || *other[0].Source != "own" || *other[0].Code != "E1" {
+		t.Fatalf("unexpected diagnostics of other.py: %+v", d)
+	}
+	if diagnostics, ok := d[uri]; !ok || len(diagnostics) != 0 {
+		t.Fatalf("an empty list should clear the document: %+v", d)
+	}
+}
@@ -0,0 +1,33 @@
+package langserver
+
+import (
+	"fmt"
+	"slices"
+)
+
+// truncateDiagnostics keeps the max most severe diagnostics and appends a
+// summary of the ones left out, so that editors aren't flooded with the
+// findings of pathological files. Zero means no limit.
+func truncateDiagnostics(diagnostics []Diagnostic, max int) []Diagnostic {
+	if max <= 0 || len(diagnostics) <= max {
+		return diagnostics
+	}
+	kept := slices.Clone(diagnostics)
+	// Severity 0 is unset, which clients show as errors.
+	slices.SortStableFunc(kept, func(a, b Diagnostic) int {
+		return severityRank(a.Severity) - severityRank(b.Severity)
+	})
+	suppressed := len(kept) - max
+	kept = kept[:max]
+	return append(kept, Diagnostic{
+		Severity: 3,
+		Message:  fmt.Sprintf("%d more problems suppressed", suppressed),
+	})
+}
+
+func severityRank(severity int) int {
+	if severity == 0 {
+		return 1
+	}
+	return severity
+}
@@ -0,0 +1,32 @@
+package langserver
+
+import "testing"
+
+func TestTruncateDiagnostics(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{Message: "hint", Severity: 4},
+		{Message: "error", Severity: 1},
+		{Message: "warning", Severity: 2},
+		{Message: "unset"},
+	}
+	if got := truncateDiagnostics(diagnostics, 0); len(got) != 4 {
+		t.Fatalf("expected no limit but got: %+v", got)
+	}
+	if got := truncateDiagnostics(diagnostics, 4); len(got) != 4 {
+		t.Fatalf("expected all the diagnostics but got: %+v", got)
+	}
+
+	got := truncateDiagnostics(diagnostics, 2)
+	expected := []string{"error", "unset", "2 more problems suppressed"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v but got: %+v", expected, got)
+	}
+	for i, d := range got {
+		if d.Message != expected[i] {
+			t.Fatalf("expected %v but got: %+v", expected, got)
+		}
+	}
+	if diagnostics[0].Message != "hint" {
+		t.Fatalf("expected the diagnostics to be left as they are but got: %+v", diagnostics)
+	}
+}
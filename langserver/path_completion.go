@@ -0,0 +1,123 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// defaultPathCompletionPattern matches the text before the cursor inside a
+// quoted string, the path typed so far being its group.
+const defaultPathCompletionPattern = "[\"'`]([^\"'`\\s]*)$"
+
+// PathCompletion configures the built-in completion of file paths.
+type PathCompletion struct {
+	Languages []string `yaml:"languages" json:"languages"`
+	Pattern   string   `yaml:"pattern" json:"pattern"`
+}
+
+func (p *PathCompletion) pattern() string {
+	if p.Pattern != "" {
+		return p.Pattern
+	}
+	return defaultPathCompletionPattern
+}
+
+func (h *langHandler) pathCompletionEnabled(languageID string) bool {
+	if h.pathCompletion == nil {
+		return false
+	}
+	for _, lang := range h.pathCompletion.Languages {
+		if lang == languageID || lang == wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// typedPath returns the path typed before pos of text, the first group of
+// the match of pattern at the end of the line before pos, or else the whole
+// match, and whether it matched.
+func typedPath(text string, pos Position, pattern *regexp.Regexp) (string, bool) {
+	lines := strings.Split(text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return "", false
+	}
+	chars := utf16.Encode([]rune(lines[pos.Line]))
+	before := string(utf16.Decode(chars[:min(max(pos.Character, 0), len(chars))]))
+	m := pattern.FindStringSubmatch(before)
+	if m == nil {
+		return "", false
+	}
+	if len(m) > 1 {
+		return m[1], true
+	}
+	return m[0], true
+}
+
+// completePath returns the files completing the path typed at pos of the
+// document fname, relative to its directory and to the root, or nil if no
+// path is typed there.
+func (h *langHandler) completePath(fname string, f *File, pos Position) []CompletionItem {
+	pattern, err := regexp.Compile(h.pathCompletion.pattern())
+	if err != nil {
+		h.logger.Printf("invalid path-completion pattern: %v", err)
+		return nil
+	}
+	typed, ok := typedPath(f.Text, pos, pattern)
+	if !ok {
+		return nil
+	}
+
+	// The name being typed is replaced, whatever the client's words are.
+	dir, base := "", typed
+	if i := strings.LastIndex(typed, "/"); i >= 0 {
+		dir, base = typed[:i+1], typed[i+1:]
+	}
+	start := pos
+	start.Character -= len(utf16.Encode([]rune(base)))
+
+	var dirs []string
+	if filepath.IsAbs(filepath.FromSlash(dir)) {
+		dirs = []string{filepath.FromSlash(dir)}
+	} else {
+		dirs = []string{filepath.Join(filepath.Dir(fname), filepath.FromSlash(dir))}
+		if h.rootPath != "" {
+			dirs = append(dirs, filepath.Join(h.rootPath, filepath.FromSlash(dir)))
+		}
+	}
+
+	seen := make(map[string]bool)
+	items := []CompletionItem{}
+	for _, d := range dirs {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if seen[name] || !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+				continue
+			}
+			seen[name] = true
+			kind := FileCompletion
+			if entry.IsDir() {
+				kind = FolderCompletion
+				name += "/"
+			}
+			items = append(items, CompletionItem{
+				Label:      name,
+				Kind:       kind,
+				FilterText: name,
+				TextEdit:   &TextEdit{Range: Range{Start: start, End: pos}, NewText: name},
+			})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+	return items
+}
@@ -0,0 +1,66 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPathCompletion(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"docs/intro.md", "docs/install.md", "docs/.hidden", "src/main.c", "inc/inc.h"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uri := toURI(filepath.Join(root, "src", "main.c"))
+
+	h := &langHandler{
+		logger:         log.New(io.Discard, "", 0),
+		rootPath:       root,
+		pathCompletion: &PathCompletion{Languages: []string{"c"}},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "c", Text: "#include \"inc/i\"\n#include \"../docs/\"\nint i = ma\n"},
+		},
+	}
+	labels := func(pos Position) ([]string, []TextEdit) {
+		t.Helper()
+		list, err := h.completion(uri, &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{Position: pos},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list == nil {
+			return nil, nil
+		}
+		var labels []string
+		var edits []TextEdit
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+			edits = append(edits, *item.TextEdit)
+		}
+		return labels, edits
+	}
+
+	got, edits := labels(Position{Line: 0, Character: 15})
+	if want := []string{"inc.h"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q relative to the root, got %q", want, got)
+	}
+	if want := (Range{Position{0, 14}, Position{0, 15}}); edits[0].Range != want {
+		t.Fatalf("expected the name typed to be replaced, got %v", edits[0].Range)
+	}
+	if got, _ := labels(Position{Line: 1, Character: 18}); !reflect.DeepEqual(got, []string{"install.md", "intro.md"}) {
+		t.Fatalf("expected the files of docs relative to the document, got %q", got)
+	}
+	if got, _ := labels(Position{Line: 2, Character: 10}); got != nil {
+		t.Fatalf("expected no paths outside strings, got %q", got)
+	}
+}
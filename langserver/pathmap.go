@@ -0,0 +1,143 @@
+package langserver
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pathTranslator converts paths between this machine and the environment a
+// tool runs in.
+type pathTranslator interface {
+	// toRemote rewrites every local path in s, which is usually a whole
+	// command line, to the path seen by the tool.
+	toRemote(s string) string
+	// toLocal maps a path reported by the tool back to this machine.
+	toLocal(path string) string
+}
+
+// pathMapping pairs a directory on this machine with the directory it is
+// visible as where the tool actually runs.
+type pathMapping struct {
+	local  string
+	remote string
+}
+
+type pathMap []pathMapping
+
+func newPathMap(mappings ...pathMapping) pathMap {
+	var m pathMap
+	for _, p := range mappings {
+		if p.local == "" || p.remote == "" {
+			continue
+		}
+		m = append(m, pathMapping{
+			local:  strings.TrimRight(filepath.ToSlash(p.local), "/"),
+			remote: strings.TrimRight(p.remote, "/"),
+		})
+	}
+	// Longest paths first, so nested mounts win over their parents.
+	sort.SliceStable(m, func(i, j int) bool {
+		return len(m[i].local) > len(m[j].local)
+	})
+	return m
+}
+
+// toRemote rewrites the local directories in s which are whole path
+// components, so that /home/user/project rewrites neither
+// /home/user/project2 nor /backup/home/user/project.
+func (m pathMap) toRemote(s string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(s); i++ {
+		if i > 0 && (isPathByte(s[i-1]) || s[i-1] == '/' || s[i-1] == '\\') {
+			continue
+		}
+		for _, p := range m {
+			n := dirPrefix(s[i:], p.local)
+			if native := filepath.FromSlash(p.local); n == 0 && native != p.local {
+				n = dirPrefix(s[i:], native)
+			}
+			if n > 0 {
+				b.WriteString(s[last:i])
+				b.WriteString(p.remote)
+				last = i + n
+				i += n - 1
+				break
+			}
+		}
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// dirPrefix returns the length of dir if s starts with it as a whole path
+// component, or 0.
+func dirPrefix(s, dir string) int {
+	if !strings.HasPrefix(s, dir) || len(s) > len(dir) && isPathByte(s[len(dir)]) {
+		return 0
+	}
+	return len(dir)
+}
+
+// isPathByte reports whether c may be part of the name of a file, rather
+// than separate a path from the rest of a command line.
+func isPathByte(c byte) bool {
+	return c >= 0x80 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("._-~+@", c) >= 0
+}
+
+func (m pathMap) toLocal(path string) string {
+	best := -1
+	for i, p := range m {
+		if path != p.remote && !strings.HasPrefix(path, p.remote+"/") {
+			continue
+		}
+		if best == -1 || len(p.remote) > len(m[best].remote) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return path
+	}
+	return m[best].local + strings.TrimPrefix(path, m[best].remote)
+}
+
+// pathTranslatorFor returns the path translation needed by the commands of
+// config running for the project at root, or nil if paths are shared.
+func (h *langHandler) pathTranslatorFor(config *Language, root string) pathTranslator {
+	if config == nil {
+		return nil
+	}
+	if config.Container != nil {
+		return config.Container.pathMap(root)
+	}
+	if config.Remote != nil {
+		return config.Remote.pathMap()
+	}
+	if h.useWSL(config) {
+		return wslPaths{}
+	}
+	return nil
+}
+
+// localPath maps a filename found in the output of a command of config
+// back to this machine.
+func (h *langHandler) localPath(config *Language, root, path string) string {
+	if t := h.pathTranslatorFor(config, root); t != nil {
+		return t.toLocal(path)
+	}
+	return path
+}
+
+// remotePath maps a filename of this machine to where a command of config
+// sees it.
+func (h *langHandler) remotePath(config *Language, root, path string) string {
+	if t := h.pathTranslatorFor(config, root); t != nil {
+		return t.toRemote(path)
+	}
+	return path
+}
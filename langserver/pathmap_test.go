@@ -0,0 +1,236 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestContainerPathMap(t *testing.T) {
+	c := &Container{
+		Image:  "node:20",
+		Mounts: []string{"/home/user/.cache:/cache:ro", "/home/user/project/vendor:/opt/vendor"},
+	}
+	m := c.pathMap("/home/user/project")
+
+	command := m.toRemote("eslint --cache-location /home/user/.cache/eslint /home/user/project/src/a.js")
+	if command != "eslint --cache-location /cache/eslint /workspace/src/a.js" {
+		t.Fatalf("unexpected command: %v", command)
+	}
+	if path := m.toRemote("/home/user/project/vendor/x.js"); path != "/opt/vendor/x.js" {
+		t.Fatalf("nested mount should win but got: %v", path)
+	}
+
+	// Only whole path components are rewritten.
+	for local, remote := range map[string]string{
+		"--config=/home/user/project/.eslintrc": "--config=/workspace/.eslintrc",
+		`"/home/user/project"`:                  `"/workspace"`,
+		"/home/user/project2/a.js":              "/home/user/project2/a.js",
+		"/backup/home/user/project/a.js":        "/backup/home/user/project/a.js",
+		"/home/user/project.bak/a.js":           "/home/user/project.bak/a.js",
+	} {
+		if got := m.toRemote(local); got != remote {
+			t.Fatalf("%v should map to %v but got: %v", local, remote, got)
+		}
+	}
+
+	for remote, local := range map[string]string{
+		"/workspace/src/a.js": "/home/user/project/src/a.js",
+		"/workspace":          "/home/user/project",
+		"/opt/vendor/x.js":    "/home/user/project/vendor/x.js",
+		"/workspacefoo/a.js":  "/workspacefoo/a.js",
+		"src/a.js":            "src/a.js",
+	} {
+		if got := m.toLocal(remote); got != local {
+			t.Fatalf("%v should map to %v but got: %v", remote, local, got)
+		}
+	}
+}
+
+func TestContainerPool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	// The engine prints the image as the id of the container, and starts
+	// the slow one once the release file exists.
+	engine := filepath.Join(dir, "engine")
+	script := `#!/bin/sh
+[ "$1" = run ] || exit 0
+for image in "$@"; do case "$image" in slow|fast) break;; esac; done
+echo "$image" >> "` + filepath.Join(dir, "runs") + `"
+if [ "$image" = slow ]; then
+	while [ ! -e "` + filepath.Join(dir, "release") + `" ]; do sleep 0.01; done
+fi
+echo "$image"
+`
+	if err := os.WriteFile(engine, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	slow := &Container{Image: "slow", Engine: engine}
+	fast := &Container{Image: "fast", Engine: engine}
+
+	var p containerPool
+	ids := make(chan string, 2)
+	for range 2 {
+		go func() {
+			id, _ := p.get(slow, dir)
+			ids <- id
+		}()
+	}
+	// Another image doesn't wait for the slow one to start.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if id, err := p.get(fast, dir); err != nil || id != "fast" {
+			t.Errorf("expected the fast container but got: %v, %v", id, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the container of another image not to wait")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "release"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if id := <-ids; id != "slow" {
+			t.Fatalf("expected the slow container but got: %v", id)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := strings.Fields(string(b)); len(runs) != 2 {
+		t.Fatalf("expected every container to start once but got: %v", runs)
+	}
+
+	p.stopAll()
+	if _, err := p.get(fast, dir); err == nil {
+		t.Fatal("expected no container to start once stopped")
+	}
+}
+
+func TestContainerCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// The engine prints the id of the container it runs.
+	engine := filepath.Join(t.TempDir(), "engine")
+	if err := os.WriteFile(engine, []byte("#!/bin/sh\necho abc123\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h := &langHandler{}
+	defer h.containers.stopAll()
+	config := &Language{Shell: shellSh, Container: &Container{Image: "node", Engine: engine}}
+
+	args := []string{"/home/user/project/foo.js"}
+	cmd, err := h.containerCommand(context.Background(), config, "/home/user/project", "eslint --stdin", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "/home/user/project/foo.js" {
+		t.Fatalf("expected the arguments of the caller to be left as is, got %v", args)
+	}
+	want := []string{engine, "exec", "-i", "-w", "/workspace", "abc123", "sh", "-c", "eslint --stdin", "/workspace/foo.js"}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("expected %q, got %q", want, cmd.Args)
+	}
+}
+
+func TestSplitMount(t *testing.T) {
+	for mount, expected := range map[string][2]string{
+		"/src:/dst":        {"/src", "/dst"},
+		"/src:/dst:ro":     {"/src", "/dst"},
+		`C:\cache:/cache`:  {`C:\cache`, "/cache"},
+		"C:/cache:/c:ro,z": {"C:/cache", "/c"},
+	} {
+		host, target, ok := splitMount(mount)
+		if !ok || host != expected[0] || target != expected[1] {
+			t.Fatalf("%v should split into %v but got: %v %v", mount, expected, host, target)
+		}
+	}
+	if _, _, ok := splitMount("volume"); ok {
+		t.Fatal("mount without a container path should be rejected")
+	}
+}
+
+func TestWSLPaths(t *testing.T) {
+	var p wslPaths
+	command := p.toRemote(`flake8 --config=C:\Users\me\setup.cfg d:/work/a.py`)
+	if command != "flake8 --config=/mnt/c/Users/me/setup.cfg /mnt/d/work/a.py" {
+		t.Fatalf("unexpected command: %v", command)
+	}
+	for remote, local := range map[string]string{
+		"/mnt/d/work/a.py": "D:/work/a.py",
+		"/mnt/c":           "C:/",
+		"/home/me/a.py":    "/home/me/a.py",
+		"/mnt/cdrom/a":     "/mnt/cdrom/a",
+	} {
+		if got := p.toLocal(remote); got != local {
+			t.Fatalf("%v should map to %v but got: %v", remote, local, got)
+		}
+	}
+}
+
+func TestWSLCommand(t *testing.T) {
+	h := &langHandler{wsl: true}
+	cmd, err := h.newCommand(context.Background(), nil, `C:\work`, "make build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"wsl.exe", "--cd", "/mnt/c/work", "--exec", "env", "sh", "-c", "make build"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("expected %q, got %q", want, cmd.Args)
+	}
+}
+
+func TestWSLCommandArgs(t *testing.T) {
+	h := &langHandler{}
+	config := &Language{Shell: shellSh, Env: []string{"NODE_ENV=test"}}
+
+	args := []string{`C:\src\foo.js`}
+	cmd, err := h.wslCommand(context.Background(), config, `C:\src`, `eslint --config C:\src\.eslintrc`, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != `C:\src\foo.js` {
+		t.Fatalf("expected the arguments of the caller to be left as is, got %v", args)
+	}
+	want := []string{"wsl.exe", "--cd", "/mnt/c/src", "--exec", "env", "NODE_ENV=test", "sh", "-c", "eslint --config /mnt/c/src/.eslintrc", "/mnt/c/src/foo.js"}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("expected %q, got %q", want, cmd.Args)
+	}
+}
+
+func TestDidChangeConfigurationWSL(t *testing.T) {
+	h := &langHandler{logger: log.New(io.Discard, "", 0), wsl: true}
+	for _, tt := range []struct {
+		settings string
+		want     bool
+	}{
+		{`{"settings": {"lintDebounce": 1}}`, true},
+		{`{"settings": {"wsl": false}}`, false},
+		{`{"settings": {"wsl": true}}`, true},
+	} {
+		raw := json.RawMessage(tt.settings)
+		if _, err := h.handleWorkspaceDidChangeConfiguration(context.Background(), nil, &jsonrpc2.Request{Params: &raw}); err != nil {
+			t.Fatal(err)
+		}
+		if h.wsl != tt.want {
+			t.Fatalf("%s: expected wsl to be %v", tt.settings, tt.want)
+		}
+	}
+}
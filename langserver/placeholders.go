@@ -0,0 +1,117 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// placeholders holds what the placeholders of a command template expand to.
+type placeholders struct {
+	fname    string
+	rootPath string
+	// root is the root detected for the file, which ${RELATIVE_PATH} is
+	// relative to, and workspace the workspace folder containing it.
+	root      string
+	workspace string
+	// shell quotes the values for the command line. Empty leaves them
+	// unquoted for commands arguments passed as is.
+	shell string
+	file  *File
+	// pos is the cursor, if the request has one.
+	pos *Position
+}
+
+// placeholdersFor returns the placeholders of the commands of config
+// running for fname.
+func (h *langHandler) placeholdersFor(config *Language, fname string, f *File, pos *Position) placeholders {
+	var lang Language
+	if config != nil {
+		lang = *config
+	}
+	return placeholders{
+		fname:     fname,
+		rootPath:  h.rootPath,
+		root:      h.findRootPath(fname, lang),
+		workspace: h.workspaceFolder(fname),
+		shell:     h.shellFor(config),
+		file:      f,
+		pos:       pos,
+	}
+}
+
+// workspaceFolder returns the workspace folder containing fname.
+func (h *langHandler) workspaceFolder(fname string) string {
+	var folder string
+	for _, f := range h.folders {
+		if len(fname) > len(f) && strings.EqualFold(fname[:len(f)], f) && len(f) > len(folder) {
+			folder = f
+		}
+	}
+	if folder == "" {
+		folder = h.rootPath
+	}
+	return folder
+}
+
+// replace substitutes ${INPUT}, ${FILENAME}, ${FILEEXT}, ${ROOT},
+// ${RELATIVE_PATH} and ${WORKSPACE_NAME}, and the cursor placeholders
+// ${LINE} and ${COLUMN} (one based), ${WORD} and ${LINE_TEXT}. The cursor
+// placeholders are empty when there is no cursor.
+func (p placeholders) replace(command string) string {
+	command = replaceCommandInputFilename(command, p.fname, p.rootPath, p.shell)
+	if !strings.Contains(command, "${") {
+		return command
+	}
+
+	root := p.root
+	if root == "" {
+		root = p.rootPath
+	}
+	relative := p.fname
+	if rel, err := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(p.fname)); err == nil && root != "" {
+		relative = filepath.ToSlash(rel)
+	}
+	command = strings.Replace(command, "${RELATIVE_PATH}", quoteArg(p.shell, relative), -1)
+	var workspace string
+	if p.workspace != "" {
+		workspace = filepath.Base(p.workspace)
+	}
+	command = strings.Replace(command, "${WORKSPACE_NAME}", quoteArg(p.shell, workspace), -1)
+
+	var line, column, word, lineText string
+	if p.pos != nil {
+		line = strconv.Itoa(p.pos.Line + 1)
+		column = strconv.Itoa(p.pos.Character + 1)
+		if p.file != nil {
+			word = p.file.WordAt(*p.pos)
+			if lines := strings.Split(p.file.Text, "\n"); p.pos.Line >= 0 && p.pos.Line < len(lines) {
+				lineText = strings.TrimRight(lines[p.pos.Line], "\r")
+			}
+		}
+	}
+	command = strings.Replace(command, "${LINE}", line, -1)
+	command = strings.Replace(command, "${COLUMN}", column, -1)
+	command = strings.Replace(command, "${WORD}", quoteArg(p.shell, word), -1)
+	command = strings.Replace(command, "${LINE_TEXT}", quoteArg(p.shell, lineText), -1)
+	return command
+}
+
+var cursorPlaceholders = []string{"${LINE}", "${COLUMN}", "${WORD}", "${LINE_TEXT}"}
+
+// usesCursor reports whether command or its arguments refer to the cursor.
+func usesCursor(command Command) bool {
+	texts := []string{command.Command}
+	for _, arg := range command.Arguments {
+		texts = append(texts, fmt.Sprint(arg))
+	}
+	for _, text := range texts {
+		for _, placeholder := range cursorPlaceholders {
+			if strings.Contains(text, placeholder) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// preCommitConfig is the part of .pre-commit-config.yaml efm-langserver
+// understands.
+type preCommitConfig struct {
+	Repos []struct {
+		Hooks []struct {
+			ID   string   `yaml:"id"`
+			Args []string `yaml:"args"`
+		} `yaml:"hooks"`
+	} `yaml:"repos"`
+}
+
+type preCommitHook struct {
+	languages []string
+	// tool returns the tool definition, args being the quoted hook args.
+	tool func(args string) Language
+}
+
+var prettierLanguages = []string{
+	"css", "graphql", "html", "javascript", "javascriptreact", "json", "jsonc",
+	"less", "markdown", "scss", "typescript", "typescriptreact", "vue", "yaml",
+}
+
+var preCommitHooks = map[string]preCommitHook{
+	"flake8": {[]string{"python"}, func(args string) Language {
+		return Language{
+			LintCommand: "flake8" + args + " --stdin-display-name ${INPUT} -",
+			LintStdin:   true,
+			LintFormats: []string{"%f:%l:%c: %m"},
+		}
+	}},
+	"black": {[]string{"python"}, func(args string) Language {
+		return Language{FormatCommand: "black --quiet" + args + " -", FormatStdin: true}
+	}},
+	"isort": {[]string{"python"}, func(args string) Language {
+		return Language{FormatCommand: "isort --quiet" + args + " -", FormatStdin: true}
+	}},
+	"mypy": {[]string{"python"}, func(args string) Language {
+		return Language{
+			LintCommand: "mypy --show-column-numbers --no-error-summary" + args + " ${INPUT}",
+			LintFormats: []string{"%f:%l:%c: %trror: %m", "%f:%l:%c: %tarning: %m", "%f:%l:%c: %tote: %m"},
+		}
+	}},
+	"ruff": {[]string{"python"}, func(args string) Language {
+		return Language{
+			LintCommand: "ruff check --output-format=concise" + args + " --stdin-filename ${INPUT} -",
+			LintStdin:   true,
+			LintFormats: []string{"%f:%l:%c: %m"},
+		}
+	}},
+	"ruff-format": {[]string{"python"}, func(args string) Language {
+		return Language{FormatCommand: "ruff format" + args + " --stdin-filename ${INPUT} -", FormatStdin: true}
+	}},
+	"prettier": {prettierLanguages, func(args string) Language {
+		return Language{FormatCommand: "prettier" + args + " --stdin-filepath ${INPUT}", FormatStdin: true}
+	}},
+	"eslint": {[]string{"javascript", "javascriptreact", "typescript", "typescriptreact"}, func(args string) Language {
+		return Language{
+			LintCommand: "eslint -f unix" + args + " --stdin --stdin-filename ${INPUT}",
+			LintStdin:   true,
+		}
+	}},
+	"shellcheck": {[]string{"sh", "bash", "shellscript"}, func(args string) Language {
+		return Language{
+			LintCommand: "shellcheck -f gcc" + args + " -",
+			LintStdin:   true,
+			LintFormats: []string{"%f:%l:%c: %trror: %m", "%f:%l:%c: %tarning: %m", "%f:%l:%c: %tote: %m"},
+		}
+	}},
+	"shfmt": {[]string{"sh", "bash", "shellscript"}, func(args string) Language {
+		return Language{FormatCommand: "shfmt" + args + " -", FormatStdin: true}
+	}},
+	"yamllint": {[]string{"yaml"}, func(args string) Language {
+		return Language{LintCommand: "yamllint -f parsable" + args + " -", LintStdin: true}
+	}},
+	"hadolint": {[]string{"dockerfile"}, func(args string) Language {
+		return Language{LintCommand: "hadolint" + args + " -", LintStdin: true, LintFormats: []string{"%f:%l %m"}}
+	}},
+	"markdownlint": {[]string{"markdown"}, func(args string) Language {
+		return Language{
+			LintCommand: "markdownlint" + args + " -s",
+			LintStdin:   true,
+			LintFormats: []string{"%f:%l:%c %m", "%f:%l %m"},
+		}
+	}},
+	"stylua": {[]string{"lua"}, func(args string) Language {
+		return Language{FormatCommand: "stylua" + args + " -", FormatStdin: true}
+	}},
+	"go-fmt": {[]string{"go"}, func(args string) Language {
+		return Language{FormatCommand: "gofmt" + args, FormatStdin: true}
+	}},
+}
+
+// importProjectTools adds the tools defined by the project's own tooling
+// configuration at root to the configured languages.
+func (h *langHandler) importProjectTools(root string) {
+	if root == "" {
+		return
+	}
+	if h.importProjectConfig {
+		h.importProjectConfigFile(root)
+	}
+	if h.importPreCommit {
+		h.importPreCommitHooks(root)
+	}
+	if h.importReviewdog {
+		h.importReviewdogRunners(root)
+	}
+}
+
+func (h *langHandler) importPreCommitHooks(root string) {
+	fname := filepath.Join(root, ".pre-commit-config.yaml")
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return
+	}
+	var config preCommitConfig
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		h.logger.Printf("can not read .pre-commit-config.yaml: %v", err)
+		return
+	}
+
+	for _, repo := range config.Repos {
+		for _, hook := range repo.Hooks {
+			known, ok := preCommitHooks[hook.ID]
+			if !ok {
+				if h.loglevel >= 1 {
+					h.logger.Printf("skipping unsupported pre-commit hook: %v", hook.ID)
+				}
+				continue
+			}
+			var args string
+			for _, arg := range hook.Args {
+				args += " " + quoteArg(h.shellFor(nil), arg)
+			}
+			tool := known.tool(args)
+			tool.LintSource = hook.ID
+			tool.LintIgnoreExitCode = tool.LintCommand != ""
+			tool.LintAfterOpen = tool.LintCommand != ""
+			tool.HoverChars = "_"
+			for _, lang := range known.languages {
+				h.addTool(lang, tool, fname)
+			}
+			if h.loglevel >= 1 {
+				h.logger.Printf("imported pre-commit hook %v for %v", hook.ID, strings.Join(known.languages, ", "))
+			}
+		}
+	}
+}
+
+// addTool appends tool imported from the file origin to the configs of lang
+// unless an identical command is configured already.
+func (h *langHandler) addTool(lang string, tool Language, origin string) {
+	if h.configs == nil {
+		h.configs = make(map[string][]Language)
+	}
+	for _, cfg := range h.configs[lang] {
+		if cfg.LintCommand == tool.LintCommand && cfg.FormatCommand == tool.FormatCommand {
+			return
+		}
+	}
+	h.configs[lang] = append(h.configs[lang], tool)
+	h.setOrigin(languageKey(lang, len(h.configs[lang])-1), origin)
+}
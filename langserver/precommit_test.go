@@ -0,0 +1,52 @@
+package langserver
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportPreCommitHooks(t *testing.T) {
+	root := t.TempDir()
+	err := os.WriteFile(filepath.Join(root, ".pre-commit-config.yaml"), []byte(`
+repos:
+- repo: https://github.com/psf/black
+  rev: 24.1.0
+  hooks:
+  - id: black
+- repo: https://github.com/pycqa/flake8
+  rev: 7.0.0
+  hooks:
+  - id: flake8
+    args: [--max-line-length=100]
+  - id: unknown-hook
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &langHandler{
+		logger:          log.New(log.Writer(), "", log.LstdFlags),
+		shell:           shellSh,
+		importPreCommit: true,
+		configs: map[string][]Language{
+			"python": {
+				{FormatCommand: "black --quiet -", FormatStdin: true},
+			},
+		},
+	}
+	h.importProjectTools(root)
+	h.importProjectTools(root)
+
+	cfgs := h.configs["python"]
+	if len(cfgs) != 2 {
+		t.Fatalf("python should have the configured black and the imported flake8 but got: %#v", cfgs)
+	}
+	if cfgs[1].LintCommand != "flake8 --max-line-length=100 --stdin-display-name ${INPUT} -" {
+		t.Fatalf("unexpected lint command: %v", cfgs[1].LintCommand)
+	}
+	if cfgs[1].LintSource != "flake8" || !cfgs[1].LintStdin {
+		t.Fatalf("unexpected flake8 tool: %#v", cfgs[1])
+	}
+}
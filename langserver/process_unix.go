@@ -0,0 +1,33 @@
+//go:build unix
+
+package langserver
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a process group of its own, so that
+// canceling it also kills the processes its shell started, which would keep
+// running and writing to a pipe nobody reads.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process)
+	}
+}
+
+// setCommandLine does nothing, the command line of a process being its
+// arguments.
+func setCommandLine(*exec.Cmd, string) {}
+
+// killProcessGroup kills the process group p leads.
+func killProcessGroup(p *os.Process) error {
+	err := syscall.Kill(-p.Pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
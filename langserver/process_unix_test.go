@@ -0,0 +1,27 @@
+//go:build unix
+
+package langserver
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestCancelKillsProcessGroup(t *testing.T) {
+	h := &langHandler{logger: log.New(io.Discard, "", 0)}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	cmd, err := h.newCommand(ctx, nil, "", "sleep 30 & wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output stays open until the child of the shell is killed too.
+	start := time.Now()
+	_, _ = cmd.CombinedOutput()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the child of the shell should be killed on cancel but the command took %v", elapsed)
+	}
+}
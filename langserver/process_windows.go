@@ -0,0 +1,36 @@
+//go:build windows
+
+package langserver
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup makes canceling cmd kill the processes it started too,
+// which would keep running and writing to a pipe nobody reads.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process)
+	}
+}
+
+// setCommandLine makes cmd start with the command line line as is, rather
+// than its arguments quoted by Go.
+func setCommandLine(cmd *exec.Cmd, line string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = line
+}
+
+// killProcessGroup kills p and its descendants with taskkill, which walks
+// the tree of processes.
+func killProcessGroup(p *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
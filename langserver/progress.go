@@ -0,0 +1,60 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+var progressTokens atomic.Int64
+
+// workDoneProgress reports a long running server initiated operation to the
+// client. Without client support the messages go to window/logMessage.
+type workDoneProgress struct {
+	h       *langHandler
+	token   any
+	created bool
+}
+
+// beginProgress creates a progress token. It calls the client, so it must not
+// be used from the goroutine handling requests.
+func (h *langHandler) beginProgress(title string) *workDoneProgress {
+	p := &workDoneProgress{
+		h:     h,
+		token: fmt.Sprintf("efm-langserver/%d", progressTokens.Add(1)),
+	}
+	err := h.conn.Call(context.Background(), "window/workDoneProgress/create", &WorkDoneProgressCreateParams{Token: p.token}, nil)
+	if err == nil {
+		p.created = true
+		p.notify(&WorkDoneProgressBegin{Kind: "begin", Title: title})
+	} else {
+		h.logMessage(LogInfo, title)
+	}
+	return p
+}
+
+// clientProgress reports through a token the client sent along with its
+// request, which needs no round trip and so can be used while handling it.
+func (h *langHandler) clientProgress(token any, title string) *workDoneProgress {
+	p := &workDoneProgress{h: h, token: token, created: true}
+	p.notify(&WorkDoneProgressBegin{Kind: "begin", Title: title})
+	return p
+}
+
+func (p *workDoneProgress) notify(value any) {
+	p.h.conn.Notify(context.Background(), "$/progress", &ProgressParams{Token: p.token, Value: value})
+}
+
+func (p *workDoneProgress) report(message string) {
+	if p.created {
+		p.notify(&WorkDoneProgressReport{Kind: "report", Message: message})
+	}
+	p.h.logMessage(LogLog, message)
+}
+
+func (p *workDoneProgress) end(message string) {
+	if p.created {
+		p.notify(&WorkDoneProgressEnd{Kind: "end", Message: message})
+	}
+	p.h.logMessage(LogInfo, message)
+}
@@ -0,0 +1,225 @@
+package langserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// projectConfigNames are the names of the config file a project can ship in
+// its root.
+var projectConfigNames = []string{".efm-langserver.yaml", ".efm-langserver.yml", ".efm-langserver.json", ".efm-langserver.toml"}
+
+// importProjectConfigFile merges the project config found in root over the
+// configuration of the session. Settings of the machine (shell, wsl,
+// command-wrapper, logging) are not taken from the project.
+func (h *langHandler) importProjectConfigFile(root string) {
+	for _, name := range projectConfigNames {
+		fname := filepath.Join(root, name)
+		if _, err := os.Stat(fname); err != nil {
+			continue
+		}
+		config, err := LoadConfig(fname)
+		if err != nil {
+			h.logger.Printf("can not read %v: %v", fname, err)
+			return
+		}
+		if h.loglevel >= 1 {
+			h.logger.Printf("merging project config %v", fname)
+		}
+		h.mergeConfig(config, fname)
+		return
+	}
+}
+
+// importRootConfig imports the project config of the root-marker directory
+// of uri, which may be a sub project of the workspace.
+func (h *langHandler) importRootConfig(uri DocumentURI) {
+	if !h.importProjectConfig {
+		return
+	}
+	fname, err := fromURI(uri)
+	if err != nil {
+		return
+	}
+	if dir := matchRootPath(fname, h.markers()); dir != "" && dir != h.rootPath {
+		h.importProjectConfigFile(dir)
+	}
+}
+
+// applyProfile merges the profile chosen by the client over the
+// configuration.
+func (h *langHandler) applyProfile() {
+	if h.profile == "" {
+		return
+	}
+	profile, ok := h.profiles[h.profile]
+	if !ok || profile == nil {
+		h.logger.Printf("profile not found: %v", h.profile)
+		return
+	}
+	if h.loglevel >= 1 {
+		h.logger.Printf("using profile %v", h.profile)
+	}
+	h.mergeConfig(profile, "profile "+h.profile)
+}
+
+// mergeConfig applies config, coming from origin, over the session: the
+// tools of the languages it configures replace the current ones, its
+// commands are added and the other settings it sets win.
+func (h *langHandler) mergeConfig(config *Config, origin string) {
+	if h.configs == nil {
+		h.configs = make(map[string][]Language)
+	}
+	if config.Languages != nil {
+		for lang, cfgs := range *config.Languages {
+			h.configs[lang] = append([]Language(nil), cfgs...)
+			h.clearOrigins(lang)
+			for i := range cfgs {
+				h.setOrigin(languageKey(lang, i), origin)
+			}
+		}
+	}
+	if config.Commands != nil {
+		for _, command := range *config.Commands {
+			if !hasCommand(h.commands, command) {
+				h.commands = append(h.commands, command)
+				h.setOrigin(fmt.Sprintf("commands[%d]", len(h.commands)-1), origin)
+			}
+		}
+	}
+	if config.RootMarkers != nil && len(*config.RootMarkers) > 0 {
+		h.rootMarkers = *config.RootMarkers
+		h.setOrigin("root-markers", origin)
+	}
+	if len(config.RootMarkerRules) > 0 {
+		h.rootMarkerRules = config.RootMarkerRules
+		h.setOrigin("root-marker-rules", origin)
+	}
+	if config.TriggerChars != nil {
+		h.triggerChars = config.TriggerChars
+		h.setOrigin("trigger-chars", origin)
+	}
+	if config.LintDebounce > 0 {
+		h.lintDebounce = time.Duration(config.LintDebounce)
+		h.setOrigin("lint-debounce", origin)
+	}
+	if config.FormatDebounce > 0 {
+		h.formatDebounce = time.Duration(config.FormatDebounce)
+		h.setOrigin("format-debounce", origin)
+	}
+	if config.ImportPreCommit {
+		h.importPreCommit = config.ImportPreCommit
+		h.setOrigin("import-pre-commit", origin)
+	}
+	if config.ImportReviewdog {
+		h.importReviewdog = config.ImportReviewdog
+		h.setOrigin("import-reviewdog", origin)
+	}
+	if config.ReviewdogLanguages != nil {
+		h.reviewdogLanguages = config.ReviewdogLanguages
+		h.setOrigin("reviewdog-languages", origin)
+	}
+	if config.LintOnlyChangedLines {
+		h.lintOnlyChangedLines = config.LintOnlyChangedLines
+		h.setOrigin("lint-only-changed-lines", origin)
+	}
+	if config.MaxDiagnosticsPerFile > 0 {
+		h.maxDiagnosticsPerFile = config.MaxDiagnosticsPerFile
+		h.setOrigin("max-diagnostics-per-file", origin)
+	}
+	if config.DedupeByCode {
+		h.dedupeByCode = config.DedupeByCode
+		h.setOrigin("dedupe-by-code", origin)
+	}
+	if config.MinimumSeverity > 0 {
+		h.minimumSeverity = config.MinimumSeverity
+		h.setOrigin("minimum-severity", origin)
+	}
+	if config.ClearDiagnosticsOnClose {
+		h.clearDiagnosticsOnClose = config.ClearDiagnosticsOnClose
+		h.setOrigin("clear-diagnostics-on-close", origin)
+	}
+	if config.SuppressionMarker != "" {
+		h.suppressionMarker = config.SuppressionMarker
+		h.setOrigin("suppression-marker", origin)
+	}
+	if config.DiagnosticsCache {
+		h.diagnosticsCache = config.DiagnosticsCache
+		h.setOrigin("diagnostics-cache", origin)
+	}
+	if config.FormatOnSave {
+		h.formatOnSave = config.FormatOnSave
+		h.setOrigin("format-on-save", origin)
+	}
+	if config.LintAfterOpenDelay > 0 {
+		h.lintAfterOpenDelay = time.Duration(config.LintAfterOpenDelay)
+		h.setOrigin("lint-after-open-delay", origin)
+	}
+	if config.FormatFirstOnly {
+		h.formatFirstOnly = config.FormatFirstOnly
+		h.setOrigin("format-first-only", origin)
+	}
+	if config.FormatChain != "" {
+		h.formatChain = config.FormatChain
+		h.setOrigin("format-chain", origin)
+	}
+	if config.FormatExclude != nil {
+		h.formatExclude = config.FormatExclude
+		h.setOrigin("format-exclude", origin)
+	}
+	if config.FormatRangeFallback != "" {
+		h.formatRangeFallback = config.FormatRangeFallback
+		h.setOrigin("format-range-fallback", origin)
+	}
+	if config.FormatOnlyChangedLines {
+		h.formatOnlyChangedLines = config.FormatOnlyChangedLines
+		h.setOrigin("format-only-changed-lines", origin)
+	}
+	if config.BufferWordsCompletion {
+		h.bufferWordsCompletion = config.BufferWordsCompletion
+		h.setOrigin("buffer-words-completion", origin)
+	}
+	if config.PathCompletion != nil {
+		h.pathCompletion = config.PathCompletion
+		h.setOrigin("path-completion", origin)
+	}
+	if config.SnippetsDir != "" {
+		h.snippetsDir = config.SnippetsDir
+		h.setOrigin("snippets-dir", origin)
+	}
+	if config.CompletionFilter != "" {
+		h.completionFilter = config.CompletionFilter
+		h.setOrigin("completion-filter", origin)
+	}
+	if config.TaskRunners {
+		h.taskRunners = config.TaskRunners
+		h.setOrigin("task-runners", origin)
+	}
+	if config.SpellCheck != nil {
+		h.spellCheck = config.SpellCheck
+		h.setOrigin("spell-check", origin)
+	}
+	if config.LanguageAliases != nil {
+		h.setOrigin("language-aliases", origin)
+		// The map of the global config is shared with the other sessions.
+		aliases := make(map[string]string, len(h.languageAliases)+len(config.LanguageAliases))
+		for alias, lang := range h.languageAliases {
+			aliases[alias] = lang
+		}
+		for alias, lang := range config.LanguageAliases {
+			aliases[alias] = lang
+		}
+		h.languageAliases = aliases
+	}
+}
+
+func hasCommand(commands []Command, command Command) bool {
+	for _, c := range commands {
+		if c.Title == command.Title && c.Command == command.Command {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// originDidChangeConfiguration is the origin of the settings sent by the
+// client.
+const originDidChangeConfiguration = "didChangeConfiguration"
+
+// languageKey returns the key of the origin of the i-th tool of lang.
+func languageKey(lang string, i int) string {
+	return fmt.Sprintf("languages.%s[%d]", lang, i)
+}
+
+// setOrigin records where the setting key, e.g. "root-markers" or
+// "languages.go[0]", comes from. Settings without an origin come from the
+// global config.
+func (h *langHandler) setOrigin(key, origin string) {
+	if h.origins == nil {
+		h.origins = make(map[string]string)
+	}
+	h.origins[key] = origin
+}
+
+// clearOrigins forgets the origins of the tools of lang.
+func (h *langHandler) clearOrigins(lang string) {
+	prefix := fmt.Sprintf("languages.%s[", lang)
+	for key := range h.origins {
+		if strings.HasPrefix(key, prefix) {
+			delete(h.origins, key)
+		}
+	}
+}
+
+// setSettingOrigins records the settings sent by the client, which uses the
+// JSON names, as coming from didChangeConfiguration.
+func (h *langHandler) setSettingOrigins(settings map[string]json.RawMessage) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if _, ok := settings[name]; !ok || name == "-" || key == "-" {
+			continue
+		}
+		switch key {
+		case "languages":
+			var languages map[string][]json.RawMessage
+			_ = json.Unmarshal(settings[name], &languages)
+			for lang, entries := range languages {
+				if entries == nil {
+					h.clearOrigins(lang)
+				}
+				for i := range entries {
+					h.setOrigin(languageKey(lang, i), originDidChangeConfiguration)
+				}
+			}
+		case "commands":
+			for key := range h.origins {
+				if strings.HasPrefix(key, "commands[") {
+					delete(h.origins, key)
+				}
+			}
+			h.setOrigin(key, originDidChangeConfiguration)
+		default:
+			h.setOrigin(key, originDidChangeConfiguration)
+		}
+	}
+}
+
+// DumpConfig returns the configuration a session would use, like Resolve
+// does, with a comment telling where every setting and tool comes from: the
+// global config, the project config, a profile or an imported file.
+func DumpConfig(config *Config, opts ResolveOptions) (*yaml.Node, error) {
+	h := newResolveHandler(config, opts)
+	defer h.close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	effective := *config
+	effective.Languages = &h.configs
+	effective.Commands = &h.commands
+	effective.RootMarkers = &h.rootMarkers
+	effective.RootMarkerRules = h.rootMarkerRules
+	effective.TriggerChars = h.triggerChars
+	effective.LintDebounce = Duration(h.lintDebounce)
+	effective.FormatDebounce = Duration(h.formatDebounce)
+	effective.ImportPreCommit = h.importPreCommit
+	effective.ImportReviewdog = h.importReviewdog
+	effective.ReviewdogLanguages = h.reviewdogLanguages
+	effective.LintOnlyChangedLines = h.lintOnlyChangedLines
+	effective.MaxDiagnosticsPerFile = h.maxDiagnosticsPerFile
+	effective.DedupeByCode = h.dedupeByCode
+	effective.MinimumSeverity = h.minimumSeverity
+	effective.ClearDiagnosticsOnClose = h.clearDiagnosticsOnClose
+	effective.SuppressionMarker = h.suppressionMarker
+	effective.DiagnosticsCache = h.diagnosticsCache
+	effective.FormatOnSave = h.formatOnSave
+	effective.LintAfterOpenDelay = Duration(h.lintAfterOpenDelay)
+	effective.FormatFirstOnly = h.formatFirstOnly
+	effective.FormatChain = h.formatChain
+	effective.FormatExclude = h.formatExclude
+	effective.FormatRangeFallback = h.formatRangeFallback
+	effective.FormatOnlyChangedLines = h.formatOnlyChangedLines
+	effective.BufferWordsCompletion = h.bufferWordsCompletion
+	effective.PathCompletion = h.pathCompletion
+	effective.SnippetsDir = h.snippetsDir
+	effective.CompletionFilter = h.completionFilter
+	effective.TaskRunners = h.taskRunners
+	effective.SpellCheck = h.spellCheck
+	effective.LanguageAliases = h.languageAliases
+
+	var root yaml.Node
+	if err := root.Encode(&effective); err != nil {
+		return nil, err
+	}
+
+	global := config.Filename
+	if global == "" {
+		global = "global config"
+	}
+	origin := func(keys ...string) string {
+		for _, key := range keys {
+			if origin, ok := h.origins[key]; ok {
+				return "from " + origin
+			}
+		}
+		return "from " + global
+	}
+	doc := &root
+	if doc.Kind == yaml.DocumentNode {
+		doc = doc.Content[0]
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		switch key.Value {
+		case "commands":
+			for j, item := range value.Content {
+				item.HeadComment = origin(fmt.Sprintf("commands[%d]", j), "commands")
+			}
+		case "languages":
+			for k := 0; k+1 < len(value.Content); k += 2 {
+				lang := value.Content[k].Value
+				for j, item := range value.Content[k+1].Content {
+					item.HeadComment = origin(languageKey(lang, j))
+				}
+			}
+		default:
+			key.LineComment = origin(key.Value)
+		}
+	}
+	return &root, nil
+}
@@ -0,0 +1,219 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"maps"
+	"sync"
+	"time"
+)
+
+// publishedDiagnostics remembers a digest of the diagnostics last published
+// for each URI, so that unchanged ones aren't published again.
+type publishedDiagnostics struct {
+	mu      sync.Mutex
+	digests map[DocumentURI][sha256.Size]byte
+	// diagnostics are the diagnostics themselves, kept for the
+	// diagnostics-cache.
+	diagnostics map[DocumentURI][]Diagnostic
+}
+
+// kept returns the diagnostics last published for each URI, if kept.
+func (p *publishedDiagnostics) kept() map[DocumentURI][]Diagnostic {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return maps.Clone(p.diagnostics)
+}
+
+// under returns the URIs diagnostics were published for which are uri or,
+// uri being a folder, in it.
+func (p *publishedDiagnostics) under(uri DocumentURI) []DocumentURI {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var uris []DocumentURI
+	for u := range p.digests {
+		if isUnder(u, uri) {
+			uris = append(uris, u)
+		}
+	}
+	return uris
+}
+
+// record remembers the diagnostics published for uri, and reports whether
+// they changed since the last time. With keep, the diagnostics themselves
+// are kept too.
+func (p *publishedDiagnostics) record(uri DocumentURI, diagnostics []Diagnostic, keep bool) bool {
+	b, err := json.Marshal(diagnostics)
+	if err != nil {
+		return true
+	}
+	digest := sha256.Sum256(b)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.digests == nil {
+		p.digests = make(map[DocumentURI][sha256.Size]byte)
+	}
+	last, ok := p.digests[uri]
+	p.digests[uri] = digest
+	if keep {
+		if p.diagnostics == nil {
+			p.diagnostics = make(map[DocumentURI][]Diagnostic)
+		}
+		p.diagnostics[uri] = diagnostics
+	}
+	return !ok || last != digest
+}
+
+// publishDiagnostics publishes the diagnostics of uri. With onlyChanged, they
+// aren't if they are the ones last published, e.g. for the many files a
+// workspace linter found nothing new in.
+func (h *langHandler) publishDiagnostics(ctx context.Context, uri DocumentURI, diagnostics []Diagnostic, version int, onlyChanged bool) {
+	if h.conn == nil {
+		return
+	}
+	if diagnostics == nil {
+		// An empty list clears the diagnostics of the file.
+		diagnostics = []Diagnostic{}
+	}
+	changed := h.published.record(uri, diagnostics, h.diagnosticsCache)
+	f, open := h.files[uri]
+	if h.pullDiagnostics && (open || h.workspaceDiagnostics) {
+		// The client pulls the diagnostics of the open documents, and the
+		// ones of the other files with workspace diagnostics, and is asked
+		// to when they change.
+		var v *int
+		if open {
+			v = &f.Version
+		}
+		if h.pulled.set(uri, diagnostics, v) {
+			h.publishQueue.requestRefresh(h.flushDiagnostics)
+		}
+		return
+	}
+	if !changed && onlyChanged {
+		return
+	}
+	h.publishQueue.push(&PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+		Version:     version,
+	}, h.flushDiagnostics)
+}
+
+// flushDiagnostics publishes the diagnostics waiting in the queue.
+func (h *langHandler) flushDiagnostics() {
+	for _, params := range h.publishQueue.take() {
+		h.conn.Notify(context.Background(), "textDocument/publishDiagnostics", params)
+	}
+	if h.publishQueue.takeRefresh() {
+		// The reply isn't waited for here, which may be on the handler
+		// reading it.
+		go h.conn.Call(context.Background(), "workspace/diagnostic/refresh", nil, nil)
+	}
+}
+
+// publishWindow is how long diagnostics wait before being published, so
+// that the ones of the same URI published meanwhile, e.g. by the lints of
+// several documents or while typing, replace them instead of flickering.
+const publishWindow = 50 * time.Millisecond
+
+// publishQueue holds the diagnostics waiting to be published, the last ones
+// of each URI.
+type publishQueue struct {
+	mu      sync.Mutex
+	pending map[DocumentURI]*PublishDiagnosticsParams
+	order   []DocumentURI
+	timer   *time.Timer
+	// refresh tells to ask the client to pull the diagnostics again.
+	refresh bool
+}
+
+// push queues params, replacing the ones of the same URI, and runs flush
+// after publishWindow.
+func (q *publishQueue) push(params *PublishDiagnosticsParams, flush func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[DocumentURI]*PublishDiagnosticsParams)
+	}
+	if _, ok := q.pending[params.URI]; !ok {
+		q.order = append(q.order, params.URI)
+	}
+	q.pending[params.URI] = params
+	if q.timer == nil {
+		q.timer = time.AfterFunc(publishWindow, flush)
+	}
+}
+
+// requestRefresh asks the client to pull the diagnostics again after
+// publishWindow, once for all the documents whose ones changed meanwhile.
+func (q *publishQueue) requestRefresh(flush func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.refresh = true
+	if q.timer == nil {
+		q.timer = time.AfterFunc(publishWindow, flush)
+	}
+}
+
+// takeRefresh reports whether a refresh was requested since the last time.
+func (q *publishQueue) takeRefresh() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	refresh := q.refresh
+	q.refresh = false
+	return refresh
+}
+
+// take empties the queue and returns its diagnostics in the order their
+// URIs were queued.
+func (q *publishQueue) take() []*PublishDiagnosticsParams {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	params := make([]*PublishDiagnosticsParams, 0, len(q.order))
+	for _, uri := range q.order {
+		params = append(params, q.pending[uri])
+	}
+	q.pending, q.order = nil, nil
+	return params
+}
+
+// lintOrder numbers the lints of each document, so that the result of a
+// lint finishing after a newer one of the same document is dropped.
+type lintOrder struct {
+	mu       sync.Mutex
+	started  map[DocumentURI]uint64
+	finished map[DocumentURI]uint64
+}
+
+// start returns the number of a new lint of uri.
+func (o *lintOrder) start(uri DocumentURI) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.started == nil {
+		o.started = make(map[DocumentURI]uint64)
+	}
+	o.started[uri]++
+	return o.started[uri]
+}
+
+// finish reports whether the result of the lint n of uri is still the
+// newest one, i.e. no newer lint of uri finished before it.
+func (o *lintOrder) finish(uri DocumentURI, n uint64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.finished == nil {
+		o.finished = make(map[DocumentURI]uint64)
+	}
+	if o.finished[uri] > n {
+		return false
+	}
+	o.finished[uri] = n
+	return true
+}
@@ -0,0 +1,71 @@
+package langserver
+
+import "testing"
+
+func TestPublishedDiagnosticsRecord(t *testing.T) {
+	var p publishedDiagnostics
+	uri := DocumentURI("file:///foo")
+	diagnostics := []Diagnostic{{Message: "unused variable", Severity: 2}}
+
+	if !p.record(uri, diagnostics, false) {
+		t.Fatal("expected the first diagnostics to be new")
+	}
+	if p.record(uri, []Diagnostic{{Message: "unused variable", Severity: 2}}, false) {
+		t.Fatal("expected the same diagnostics to be unchanged")
+	}
+	if !p.record(uri, []Diagnostic{}, false) {
+		t.Fatal("expected the cleared diagnostics to be changed")
+	}
+	if !p.record("file:///bar", []Diagnostic{}, false) {
+		t.Fatal("expected the diagnostics of another file to be new")
+	}
+}
+
+func TestPublishQueue(t *testing.T) {
+	var q publishQueue
+	flushed := make(chan struct{}, 1)
+	flush := func() { flushed <- struct{}{} }
+
+	q.push(&PublishDiagnosticsParams{URI: "file:///foo", Version: 1}, flush)
+	q.push(&PublishDiagnosticsParams{URI: "file:///bar", Version: 1}, flush)
+	q.push(&PublishDiagnosticsParams{URI: "file:///foo", Version: 2}, flush)
+	<-flushed
+
+	params := q.take()
+	if len(params) != 2 || params[0].URI != "file:///foo" || params[0].Version != 2 || params[1].URI != "file:///bar" {
+		t.Fatalf("expected the last diagnostics of each URI but got: %+v", params)
+	}
+	if params := q.take(); len(params) != 0 {
+		t.Fatalf("expected an empty queue but got: %+v", params)
+	}
+}
+
+func TestLintOrder(t *testing.T) {
+	var o lintOrder
+	uri := DocumentURI("file:///foo")
+	first, second := o.start(uri), o.start(uri)
+	other := o.start("file:///bar")
+
+	if !o.finish(uri, second) {
+		t.Fatal("expected the newest lint to be published")
+	}
+	if o.finish(uri, first) {
+		t.Fatal("expected the lint finishing after a newer one to be dropped")
+	}
+	if !o.finish("file:///bar", other) {
+		t.Fatal("expected the lint of another document to be published")
+	}
+}
+
+func TestPublishedDiagnosticsUnder(t *testing.T) {
+	var p publishedDiagnostics
+	p.record("file:///src/foo.py", []Diagnostic{}, false)
+	p.record("file:///srcs/bar.py", []Diagnostic{}, false)
+
+	if uris := p.under("file:///src"); len(uris) != 1 || uris[0] != "file:///src/foo.py" {
+		t.Fatalf("expected the diagnostics of the folder only, got %v", uris)
+	}
+	if uris := p.under("file:///src/foo.py"); len(uris) != 1 {
+		t.Fatalf("expected the diagnostics of the file, got %v", uris)
+	}
+}
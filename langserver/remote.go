@@ -0,0 +1,112 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Remote runs the commands of a tool on another machine over SSH.
+type Remote struct {
+	Host string `yaml:"host" json:"host"`
+	// PathMap maps local directories to the directories they are visible
+	// as on the remote host.
+	PathMap    map[string]string `yaml:"path-map" json:"pathMap"`
+	SSHOptions []string          `yaml:"ssh-options" json:"sshOptions"`
+}
+
+func (r *Remote) pathMap() pathMap {
+	var mappings []pathMapping
+	for local, remote := range r.PathMap {
+		mappings = append(mappings, pathMapping{local: local, remote: remote})
+	}
+	return newPathMap(mappings...)
+}
+
+// sshControlPath is where the multiplexed master connections live, so that
+// commands don't pay the SSH handshake on every lint. It is this process's
+// own, so that closing them doesn't close the ones of other sessions or of
+// the user's ssh.
+func sshControlPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("efm-ssh-%d-%%C", os.Getpid()))
+}
+
+func (r *Remote) sshArgs() []string {
+	args := []string{
+		"-T",
+		"-o", "BatchMode=yes",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + sshControlPath(),
+		"-o", "ControlPersist=600",
+	}
+	return append(args, r.SSHOptions...)
+}
+
+// sshHosts remembers the hosts a master connection was opened to.
+type sshHosts struct {
+	mu    sync.Mutex
+	hosts map[string][]string
+}
+
+func (s *sshHosts) add(r *Remote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hosts == nil {
+		s.hosts = make(map[string][]string)
+	}
+	s.hosts[r.Host] = r.SSHOptions
+}
+
+func (s *sshHosts) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hosts := make([]string, 0, len(s.hosts))
+	for host := range s.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		args := append([]string{"-o", "ControlPath=" + sshControlPath()}, s.hosts[host]...)
+		_ = exec.Command("ssh", append(args, "-O", "exit", host)...).Run()
+		delete(s.hosts, host)
+	}
+}
+
+// remoteCommand runs command on the remote host of config. The buffer is
+// passed through the SSH connection's stdin like for a local command.
+func (h *langHandler) remoteCommand(ctx context.Context, config *Language, dir, command string, args ...string) (*exec.Cmd, error) {
+	r := config.Remote
+	m := r.pathMap()
+
+	remoteArgs := make([]string, len(args))
+	for i, arg := range args {
+		remoteArgs[i] = m.toRemote(arg)
+	}
+	inner, err := shellCommand(ctx, h.shellFor(config), m.toRemote(command), remoteArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// ssh hands a single command line to the remote login shell, which
+	// expands anything not quoted.
+	words := []string{"cd", posixQuote(m.toRemote(filepath.ToSlash(dir))), "&&", "env"}
+	for _, env := range config.Env {
+		words = append(words, posixQuote(env))
+	}
+	for _, arg := range inner.Args {
+		words = append(words, posixQuote(arg))
+	}
+
+	h.sshHosts.add(r)
+	cmd := exec.CommandContext(ctx, "ssh", append(r.sshArgs(), r.Host, strings.Join(words, " "))...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
@@ -0,0 +1,92 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRemoteCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	h := &langHandler{logger: log.New(io.Discard, "", 0)}
+	config := &Language{
+		Env: []string{"TOKEN=$HOME;x"},
+		Remote: &Remote{
+			Host:    "build",
+			PathMap: map[string]string{"/home/user/project": "/src"},
+		},
+	}
+	args := []string{"/home/user/project/foo(1).js", "a;b.js", "*.js"}
+	cmd, err := h.remoteCommand(context.Background(), config, "/home/user/project", "eslint --stdin", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "/home/user/project/foo(1).js" {
+		t.Fatalf("expected the arguments of the caller to be left as is, got %v", args)
+	}
+	if cmd.Args[len(cmd.Args)-2] != "build" {
+		t.Fatalf("expected the command to run on the host, got %v", cmd.Args)
+	}
+
+	// The remote shell gets every word as is, without any expansion.
+	line := cmd.Args[len(cmd.Args)-1]
+	cd, rest, ok := strings.Cut(line, " && env ")
+	if !ok || cd != "cd /src" {
+		t.Fatalf("expected the command to run in the mapped directory, got %q", line)
+	}
+	out, err := exec.Command("sh", "-c", `printf '%s\n' `+rest).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	expected := []string{"TOKEN=$HOME;x", "sh", "-c", "eslint --stdin", "/src/foo(1).js", "a;b.js", "*.js"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Fatalf("expected %q but got %q", expected, words)
+	}
+}
+
+func TestSSHHostsCloseAll(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var s sshHosts
+	s.add(&Remote{Host: "a", SSHOptions: []string{"-p", "2222"}})
+	s.add(&Remote{Host: "b"})
+	s.closeAll()
+
+	b, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the masters of this process are closed.
+	controlPath := filepath.Join(os.TempDir(), fmt.Sprintf("efm-ssh-%d-%%C", os.Getpid()))
+	expected := "-o ControlPath=" + controlPath + " -p 2222 -O exit a\n" +
+		"-o ControlPath=" + controlPath + " -O exit b\n"
+	if string(b) != expected {
+		t.Fatalf("expected %q but got %q", expected, b)
+	}
+	if len(s.hosts) != 0 {
+		t.Fatalf("expected the hosts to be forgotten, got %v", s.hosts)
+	}
+	if args := (&Remote{}).sshArgs(); !strings.Contains(strings.Join(args, " "), "ControlPath="+controlPath) {
+		t.Fatalf("expected the commands to use the masters of this process, got %v", args)
+	}
+}
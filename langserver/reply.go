@@ -0,0 +1,103 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// codeRequestCancelled is the error code of the requests the client
+// canceled with $/cancelRequest.
+const codeRequestCancelled = -32800
+
+// deferredResult is the result of a request that takes a while to compute,
+// e.g. a format waiting for its debounce delay. It is computed in its own
+// goroutine and answered once it is done, so that the requests and
+// notifications after it are not held back meanwhile. ctx is canceled when
+// the client cancels the request.
+type deferredResult func(ctx context.Context) (any, error)
+
+// pendingRequests keeps the functions canceling the deferred results being
+// computed, by request ID.
+type pendingRequests struct {
+	mu      sync.Mutex
+	cancels map[jsonrpc2.ID]context.CancelFunc
+}
+
+func (p *pendingRequests) add(id jsonrpc2.ID, cancel context.CancelFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancels == nil {
+		p.cancels = make(map[jsonrpc2.ID]context.CancelFunc)
+	}
+	p.cancels[id] = cancel
+}
+
+func (p *pendingRequests) remove(id jsonrpc2.ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cancels, id)
+}
+
+// cancel cancels the request id, if it is still being computed.
+func (p *pendingRequests) cancel(id jsonrpc2.ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cancel, ok := p.cancels[id]; ok {
+		cancel()
+	}
+}
+
+func (h *langHandler) handleCancelRequest(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params CancelParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	h.pending.cancel(params.ID)
+	return nil, nil
+}
+
+// replyHandler answers the requests with the results of handle, computing
+// deferred results without blocking the connection.
+type replyHandler struct {
+	h *langHandler
+}
+
+func (r replyHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	result, err := r.h.handle(ctx, conn, req)
+	deferred, ok := result.(deferredResult)
+	if !ok || err != nil {
+		jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+			return result, err
+		}).Handle(ctx, conn, req)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.h.pending.add(req.ID, cancel)
+	go jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (result any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				r.h.logPanic(req.Method, p)
+				result, err = nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: fmt.Sprintf("%s: %v", req.Method, p)}
+			}
+		}()
+		defer func() {
+			r.h.pending.remove(req.ID)
+			cancel()
+		}()
+		result, err = deferred(ctx)
+		if err != nil && ctx.Err() != nil {
+			err = &jsonrpc2.Error{Code: codeRequestCancelled, Message: fmt.Sprintf("%s: request cancelled", req.Method)}
+		}
+		return result, err
+	}).Handle(ctx, conn, req)
+}
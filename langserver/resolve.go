@@ -0,0 +1,185 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extensionLanguages lists the language ids tried for a file extension,
+// after the extension itself, when no language id is given to Resolve.
+var extensionLanguages = map[string][]string{
+	"py":     {"python"},
+	"js":     {"javascript"},
+	"mjs":    {"javascript"},
+	"cjs":    {"javascript"},
+	"jsx":    {"javascriptreact"},
+	"ts":     {"typescript"},
+	"tsx":    {"typescriptreact"},
+	"rb":     {"ruby"},
+	"rs":     {"rust"},
+	"sh":     {"sh", "shellscript"},
+	"bash":   {"sh", "shellscript"},
+	"zsh":    {"zsh", "sh", "shellscript"},
+	"md":     {"markdown"},
+	"yml":    {"yaml"},
+	"h":      {"c", "cpp"},
+	"cc":     {"cpp"},
+	"cxx":    {"cpp"},
+	"hpp":    {"cpp"},
+	"cs":     {"csharp"},
+	"kt":     {"kotlin"},
+	"pl":     {"perl"},
+	"ex":     {"elixir"},
+	"exs":    {"elixir"},
+	"hs":     {"haskell"},
+	"tf":     {"terraform"},
+	"tfvars": {"terraform-vars", "terraform"},
+	"ps1":    {"powershell"},
+	"vim":    {"vim"},
+}
+
+// ResolveOptions describes the session Resolve simulates.
+type ResolveOptions struct {
+	// Root is the workspace root the client would send.
+	Root string
+	// LanguageID is the id the client would report. It is guessed from the
+	// extension and the shebang of the file if empty.
+	LanguageID string
+	// Profile is the profile the client would ask for.
+	Profile string
+}
+
+// Resolution is what applies to a file after all merging.
+type Resolution struct {
+	File        string   `yaml:"file"`
+	LanguageID  string   `yaml:"language-id"`
+	RootPath    string   `yaml:"root-path"`
+	RootMarkers []string `yaml:"root-markers"`
+	// RootMarkerRules are the root-marker-rules of the global config.
+	RootMarkerRules []RootMarker   `yaml:"root-marker-rules,omitempty"`
+	Tools           []ResolvedTool `yaml:"tools"`
+}
+
+// ResolvedTool is a tool entry applying to a file with its commands as they
+// would run.
+type ResolvedTool struct {
+	// Language is the key of the languages section the tool comes from,
+	// e.g. "python", "glob:*.py" or "*".
+	Language        string            `yaml:"language"`
+	Index           int               `yaml:"index"`
+	RootPath        string            `yaml:"root-path"`
+	RootMarkers     []string          `yaml:"root-markers,omitempty"`
+	RootMarkerRules []RootMarker      `yaml:"root-marker-rules,omitempty"`
+	Shell           string            `yaml:"shell"`
+	Commands        map[string]string `yaml:"commands,omitempty"`
+	// Skipped tells why the tool does not run for the file.
+	Skipped string `yaml:"skipped,omitempty"`
+}
+
+// newResolveHandler returns a session initialized like a client would do
+// with opts. It must be closed.
+func newResolveHandler(config *Config, opts ResolveOptions) *langHandler {
+	h := newLangHandler(config)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if opts.Root != "" {
+		h.rootPath = filepath.Clean(opts.Root)
+		h.addFolder(h.rootPath)
+	}
+	h.profile = opts.Profile
+	h.applyProfile()
+	h.importProjectTools(h.rootPath)
+	return h
+}
+
+// Resolve returns the language, root and tools which apply to the file
+// fname with config, including the tools imported from the project and the
+// profile, the way a session opening the file would see them.
+func Resolve(config *Config, fname string, opts ResolveOptions) (*Resolution, error) {
+	fname, err := filepath.Abs(fname)
+	if err != nil {
+		return nil, err
+	}
+	text, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	h := newResolveHandler(config, opts)
+	defer h.close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	uri := toURI(fname)
+	h.importRootConfig(uri)
+
+	languageID := opts.LanguageID
+	if languageID == "" {
+		ext := strings.TrimPrefix(filepath.Ext(fname), ".")
+		candidates := append([]string{ext}, extensionLanguages[ext]...)
+		languageID = h.configuredLanguage(candidates)
+		if languageID == "" {
+			languageID = h.shebangLanguage(string(text))
+		}
+		if languageID == "" {
+			languageID = candidates[len(candidates)-1]
+		}
+	}
+	languageID = h.resolveLanguage(languageID)
+	f := &File{LanguageID: languageID, Text: string(text)}
+	h.files[uri] = f
+
+	fname = filepath.ToSlash(fname)
+	r := &Resolution{
+		File:            fname,
+		LanguageID:      languageID,
+		RootPath:        h.findRootPath(fname, Language{}),
+		RootMarkers:     h.rootMarkers,
+		RootMarkerRules: h.rootMarkerRules,
+	}
+	keys := append([]string{languageID}, h.globKeys(uri)...)
+	keys = append(keys, wildcard)
+	for _, key := range keys {
+		for i, cfg := range h.configs[key] {
+			tool := ResolvedTool{
+				Language:        key,
+				Index:           i,
+				RootPath:        h.findRootPath(fname, cfg),
+				RootMarkers:     cfg.RootMarkers,
+				RootMarkerRules: cfg.RootMarkerRules,
+				Shell:           h.shellFor(&cfg),
+				Commands:        make(map[string]string),
+			}
+			switch {
+			case key == wildcard && excludesLanguage(cfg, languageID):
+				tool.Skipped = "exclude-languages matches " + languageID
+			case cfg.RequireMarker && matchRootPath(fname, cfg.markers()) == "":
+				tool.Skipped = "require-marker is true and no root markers were found"
+			}
+
+			lintCommand := cfg.LintCommand
+			if lintCommand != "" && !cfg.LintStdin && !cfg.LintWorkspace && !strings.Contains(lintCommand, "${INPUT}") {
+				lintCommand += " ${INPUT}"
+			}
+			p := h.placeholdersFor(&cfg, fname, f, nil)
+			p.rootPath = tool.RootPath
+			for name, command := range map[string]string{
+				"lint-command":       lintCommand,
+				"format-command":     cfg.FormatCommand,
+				"fix-command":        cfg.FixCommand,
+				"symbol-command":     cfg.SymbolCommand,
+				"completion-command": cfg.CompletionCommand,
+				"hover-command":      cfg.HoverCommand,
+			} {
+				if command != "" {
+					tool.Commands[name] = h.wrapCommand(&cfg, p.replace(command))
+				}
+			}
+			r.Tools = append(r.Tools, tool)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,113 @@
+package langserver
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/reviewdog/errorformat/fmts"
+	"gopkg.in/yaml.v3"
+)
+
+// reviewdogConfig is the part of .reviewdog.yml efm-langserver understands.
+type reviewdogConfig struct {
+	Runner map[string]struct {
+		Cmd         string   `yaml:"cmd"`
+		Errorformat []string `yaml:"errorformat"`
+		Format      string   `yaml:"format"`
+		Name        string   `yaml:"name"`
+		Level       string   `yaml:"level"`
+	} `yaml:"runner"`
+}
+
+var reviewdogConfigFiles = []string{".reviewdog.yml", ".reviewdog.yaml", "reviewdog.yml", "reviewdog.yaml"}
+
+var reviewdogLevels = map[string]int{
+	"error":   1,
+	"warning": 2,
+	"info":    3,
+}
+
+func (h *langHandler) importReviewdogRunners(root string) {
+	var b []byte
+	var err error
+	var fname string
+	for _, name := range reviewdogConfigFiles {
+		fname = filepath.Join(root, name)
+		if b, err = os.ReadFile(fname); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	var config reviewdogConfig
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		h.logger.Printf("can not read reviewdog config: %v", err)
+		return
+	}
+
+	names := make([]string, 0, len(config.Runner))
+	for name := range config.Runner {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		runner := config.Runner[name]
+		if runner.Cmd == "" {
+			continue
+		}
+		formats := runner.Errorformat
+		var languages []string
+		if f, ok := fmts.DefinedFmts()[runner.Format]; ok {
+			if len(formats) == 0 {
+				formats = f.Errorformat
+			}
+			if f.Language != "" && f.Language != "common" {
+				languages = []string{f.Language}
+			}
+		}
+		// reviewdog-languages maps globs of the paths the runners lint, in
+		// the syntax of the glob: languages, to the names of the runners.
+		for _, pattern := range slices.Sorted(maps.Keys(h.reviewdogLanguages)) {
+			if slices.ContainsFunc(h.reviewdogLanguages[pattern], func(runner string) bool {
+				ok, _ := filepath.Match(runner, name)
+				return ok
+			}) {
+				languages = append(languages, globPrefix+pattern)
+			}
+		}
+		if len(formats) == 0 || len(languages) == 0 {
+			if h.loglevel >= 1 {
+				h.logger.Printf("skipping reviewdog runner %v: no errorformat or language. Map it in `reviewdog-languages`.", name)
+			}
+			continue
+		}
+
+		source := runner.Name
+		if source == "" {
+			source = name
+		}
+		tool := Language{
+			LintCommand:        runner.Cmd,
+			LintFormats:        formats,
+			LintWorkspace:      true,
+			LintIgnoreExitCode: true,
+			LintAfterOpen:      true,
+			LintOnSave:         true,
+			LintSource:         source,
+			LintSeverity:       reviewdogLevels[strings.ToLower(runner.Level)],
+			HoverChars:         "_",
+		}
+		for _, lang := range languages {
+			h.addTool(lang, tool, fname)
+		}
+		if h.loglevel >= 1 {
+			h.logger.Printf("imported reviewdog runner %v for %v", name, strings.Join(languages, ", "))
+		}
+	}
+}
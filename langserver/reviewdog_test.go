@@ -0,0 +1,64 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportReviewdogRunners(t *testing.T) {
+	root := t.TempDir()
+	err := os.WriteFile(filepath.Join(root, ".reviewdog.yml"), []byte(`
+runner:
+  golint:
+    cmd: golint ./...
+    format: golint
+  eslint-src:
+    cmd: eslint -f unix src
+    errorformat:
+    - "%f:%l:%c: %m"
+    level: warning
+  unmapped:
+    cmd: unmapped
+    errorformat:
+    - "%f:%l: %m"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &langHandler{
+		logger:          log.New(io.Discard, "", 0),
+		rootPath:        root,
+		importReviewdog: true,
+		reviewdogLanguages: map[string][]string{
+			"src/*.ts": {"eslint*"},
+			"*.js":     {"eslint-src", "golint"},
+		},
+	}
+	h.importProjectTools(root)
+
+	if cfgs := h.configs["go"]; len(cfgs) != 1 || cfgs[0].LintCommand != "golint ./..." {
+		t.Fatalf("golint should be assigned to the language of its format: %#v", cfgs)
+	}
+	for _, key := range []string{"glob:*.js", "glob:src/*.ts"} {
+		cfgs := h.configs[key]
+		if len(cfgs) == 0 || cfgs[0].LintCommand != "eslint -f unix src" || cfgs[0].LintSeverity != 2 {
+			t.Fatalf("eslint-src should be assigned to %v: %#v", key, cfgs)
+		}
+	}
+	if len(h.configs) != 3 {
+		t.Fatalf("unmapped should be skipped: %#v", h.configs)
+	}
+
+	// The globs match the paths of the documents, not the runner names.
+	cfgs := h.globConfigs(toURI(filepath.Join(root, "src", "main.ts")))
+	if len(cfgs) != 1 || cfgs[0].LintSource != "eslint-src" {
+		t.Fatalf("expected eslint-src for src/main.ts but got: %#v", cfgs)
+	}
+	if cfgs := h.globConfigs(toURI(filepath.Join(root, "lib", "main.ts"))); len(cfgs) != 0 {
+		t.Fatalf("expected no tool for lib/main.ts but got: %#v", cfgs)
+	}
+}
@@ -0,0 +1,115 @@
+package langserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rootCommandTimeout bounds a root-command, which runs before the tools.
+const rootCommandTimeout = 5 * time.Second
+
+// rootCommandRetry is how long a root-command that failed isn't run again.
+const rootCommandRetry = 30 * time.Second
+
+// rootCommandCache caches the roots printed by the root-command of the
+// tools, by command and directory.
+type rootCommandCache struct {
+	mu    sync.Mutex
+	roots map[[2]string]commandRootResult
+}
+
+// commandRootResult is a root printed by a root-command, or "" with the time
+// it failed at.
+type commandRootResult struct {
+	root     string
+	failedAt time.Time
+}
+
+// forget drops the roots, e.g. after a root marker changed.
+func (c *rootCommandCache) forget() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roots = nil
+}
+
+func (c *rootCommandCache) get(key [2]string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.roots[key]
+	if !ok || result.root == "" && time.Since(result.failedAt) >= rootCommandRetry {
+		return "", false
+	}
+	return result.root, true
+}
+
+func (c *rootCommandCache) set(key [2]string, root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.roots == nil {
+		c.roots = make(map[[2]string]commandRootResult)
+	}
+	result := commandRootResult{root: root}
+	if root == "" {
+		result.failedAt = time.Now()
+	}
+	c.roots[key] = result
+}
+
+// commandRoot returns the root path printed by the root-command of lang run
+// in the directory of fname, or "" if it fails or doesn't print a
+// directory. A relative path is relative to that directory. Failures are
+// retried after rootCommandRetry.
+func (h *langHandler) commandRoot(fname string, lang Language) string {
+	dir := filepath.Dir(filepath.FromSlash(fname))
+	key := [2]string{lang.RootCommand, dir}
+	if root, ok := h.rootCommands.get(key); ok {
+		return root
+	}
+	root := h.runRootCommand(dir, lang)
+	h.rootCommands.set(key, root)
+	return root
+}
+
+func (h *langHandler) runRootCommand(dir string, lang Language) string {
+	ctx, cancel := context.WithTimeout(context.Background(), rootCommandTimeout)
+	defer cancel()
+	// The root is a local path, so the command runs on the host even for
+	// tools running in a container or on a remote host.
+	cmd, err := shellCommand(ctx, h.shellFor(&Language{Shell: lang.Shell}), lang.RootCommand)
+	if err != nil {
+		h.logger.Printf("root-command: %v", err)
+		return ""
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), lang.Env...)
+	setProcessGroup(cmd)
+	b, err := cmd.Output()
+	if err != nil {
+		if h.loglevel >= 1 {
+			h.logger.Printf("root-command `%s` failed in %s: %v", lang.RootCommand, dir, err)
+		}
+		return ""
+	}
+	root := strings.TrimSpace(string(b))
+	if root == "" {
+		if h.loglevel >= 1 {
+			h.logger.Printf("root-command `%s` printed nothing in %s", lang.RootCommand, dir)
+		}
+		return ""
+	}
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(dir, root)
+	}
+	root = filepath.Clean(root)
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		if h.loglevel >= 1 {
+			h.logger.Printf("root-command `%s` printed no directory: %q", lang.RootCommand, root)
+		}
+		return ""
+	}
+	return root
+}
@@ -0,0 +1,63 @@
+package langserver
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RootMarker is a rule of root-marker-rules: a file, or a directory if the
+// name ends with a slash, whose presence makes a directory the root of a
+// project, like the names of root-markers, but which can require some
+// content or set a priority:
+//
+//	root-marker-rules:
+//	  - name: package.json
+//	    contains: '"eslintConfig"'
+//	    priority: 10
+type RootMarker struct {
+	Name string `yaml:"name" json:"name"`
+	// Contains is a string the marker file must contain.
+	Contains string `yaml:"contains,omitempty" json:"contains,omitempty"`
+	// Priority makes a marker win over the ones with a lower priority, even
+	// if those are nearer to the file. Among markers of the same priority
+	// the nearest wins.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+}
+
+// rootMarkers returns the root-markers names as markers, followed by the
+// root-marker-rules.
+func rootMarkers(names []string, rules []RootMarker) []RootMarker {
+	markers := make([]RootMarker, 0, len(names)+len(rules))
+	for _, name := range names {
+		markers = append(markers, RootMarker{Name: name})
+	}
+	return append(markers, rules...)
+}
+
+// markers returns the root markers of the tool.
+func (cfg *Language) markers() []RootMarker {
+	return rootMarkers(cfg.RootMarkers, cfg.RootMarkerRules)
+}
+
+// markers returns the root markers of the global config.
+func (h *langHandler) markers() []RootMarker {
+	return rootMarkers(h.rootMarkers, h.rootMarkerRules)
+}
+
+// matches reports whether the entry name of dir is the marker.
+func (m RootMarker) matches(dir, name string, isDir bool) bool {
+	pattern, wantDir := strings.CutSuffix(m.Name, "/")
+	if isDir != wantDir {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern, name); !ok {
+		return false
+	}
+	if m.Contains == "" || isDir {
+		return true
+	}
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	return err == nil && bytes.Contains(b, []byte(m.Contains))
+}
@@ -0,0 +1,252 @@
+package langserver
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// The values of lint-format-type. The output is parsed with lint-formats
+// when it is empty.
+const (
+	lintFormatTypeErrorformat = "errorformat"
+	lintFormatTypeSARIF       = "sarif"
+	lintFormatTypeLSP         = "lsp"
+)
+
+// sarifLog is the part of a SARIF 2.1.0 log turned into diagnostics.
+type sarifLog struct {
+	Runs []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name  string      `json:"name"`
+			Rules []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	DefaultConfig    struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        *int            `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations"`
+	Fixes            []sarifFix      `json:"fixes"`
+}
+
+// sarifFix is a fix of a result, the replacements of regions of artifacts,
+// e.g. the fixits of clang.
+type sarifFix struct {
+	ArtifactChanges []struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Replacements     []struct {
+			DeletedRegion   sarifRegion `json:"deletedRegion"`
+			InsertedContent struct {
+				Text string `json:"text"`
+			} `json:"insertedContent"`
+		} `json:"replacements"`
+	} `json:"artifactChanges"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	} `json:"physicalLocation"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// sarifSeverity maps the level of a SARIF result to a severity, warning
+// being the default level of SARIF.
+func sarifSeverity(level string) int {
+	switch level {
+	case "error":
+		return 1
+	case "note":
+		return 3
+	case "none":
+		return 4
+	default:
+		return 2
+	}
+}
+
+// sarifDiagnostics turns the SARIF log b of a lint tool of config into
+// diagnostics. Results without a location, or located in stdin, are for the
+// document uri.
+func (h *langHandler) sarifDiagnostics(b []byte, uri DocumentURI, rootPath string, config *Language, source *string, prefix string) (map[DocumentURI][]Diagnostic, error) {
+	var log sarifLog
+	if err := json.Unmarshal(b, &log); err != nil {
+		return nil, err
+	}
+
+	diagnostics := make(map[DocumentURI][]Diagnostic)
+	for _, run := range log.Runs {
+		src := source
+		if src == nil && run.Tool.Driver.Name != "" {
+			name := run.Tool.Driver.Name
+			src = &name
+		}
+		artifactURI := func(artifact sarifArtifactLocation) DocumentURI {
+			if artifact.URI != "" && !(config.LintStdin && isFilename(artifact.URI)) {
+				path := sarifPath(artifact, run.OriginalURIBaseIDs, rootPath)
+				return toURI(h.localPath(config, rootPath, path))
+			}
+			return uri
+		}
+		locate := func(loc sarifLocation) (DocumentURI, Range) {
+			return artifactURI(loc.PhysicalLocation.ArtifactLocation), sarifRange(loc.PhysicalLocation.Region, config.LintOffset)
+		}
+
+		for _, res := range run.Results {
+			var rule *sarifRule
+			if res.RuleIndex != nil && *res.RuleIndex >= 0 && *res.RuleIndex < len(run.Tool.Driver.Rules) {
+				rule = &run.Tool.Driver.Rules[*res.RuleIndex]
+			} else {
+				for i := range run.Tool.Driver.Rules {
+					if run.Tool.Driver.Rules[i].ID == res.RuleID {
+						rule = &run.Tool.Driver.Rules[i]
+						break
+					}
+				}
+			}
+
+			level := res.Level
+			if level == "" && rule != nil {
+				level = rule.DefaultConfig.Level
+			}
+			severity := sarifSeverity(level)
+			if level == "" && config.LintSeverity != 0 {
+				severity = config.LintSeverity
+			}
+			message := res.Message.Text
+			if message == "" && rule != nil {
+				message = rule.ShortDescription.Text
+			}
+			var code *string
+			if res.RuleID != "" {
+				ruleID := res.RuleID
+				code = &ruleID
+			} else if rule != nil && rule.ID != "" {
+				code = &rule.ID
+			}
+
+			var related []DiagnosticRelatedInformation
+			for _, loc := range res.RelatedLocations {
+				relatedURI, rng := locate(loc)
+				related = append(related, DiagnosticRelatedInformation{
+					Location: Location{URI: relatedURI, Range: rng},
+					Message:  loc.Message.Text,
+				})
+			}
+
+			locations := res.Locations
+			if len(locations) == 0 {
+				locations = []sarifLocation{{}}
+			}
+			for _, loc := range locations {
+				diagURI, rng := locate(loc)
+				// The first fix, the others being alternatives, is
+				// offered as a quick fix with its changes of the file of
+				// the diagnostic.
+				var edits []TextEdit
+				if len(res.Fixes) > 0 {
+					for _, change := range res.Fixes[0].ArtifactChanges {
+						if artifactURI(change.ArtifactLocation) != diagURI {
+							continue
+						}
+						for _, r := range change.Replacements {
+							edits = append(edits, TextEdit{
+								Range:   sarifRange(r.DeletedRegion, config.LintOffset),
+								NewText: r.InsertedContent.Text,
+							})
+						}
+					}
+				}
+				diagnostics[diagURI] = append(diagnostics[diagURI], Diagnostic{
+					Range:              rng,
+					Severity:           severity,
+					Code:               code,
+					Source:             src,
+					Message:            prefix + message,
+					RelatedInformation: related,
+					Data:               newDiagnosticData(toolName(config, src), code, "", nil, edits),
+				})
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+// sarifPath returns the path of an artifact, resolving the URIs relative to
+// a base id or to root.
+func sarifPath(artifact sarifArtifactLocation, bases map[string]sarifArtifactLocation, root string) string {
+	ref := artifact.URI
+	if base, ok := bases[artifact.URIBaseID]; ok && base.URI != "" && !strings.Contains(ref, ":") {
+		ref = strings.TrimSuffix(base.URI, "/") + "/" + ref
+	}
+	if strings.HasPrefix(ref, "file:") {
+		if path, err := fromURI(DocumentURI(ref)); err == nil {
+			return filepath.FromSlash(path)
+		}
+	}
+	path, err := url.PathUnescape(ref)
+	if err != nil {
+		path = ref
+	}
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return path
+}
+
+// sarifRange converts a region, whose lines and columns are one based and
+// whose end column is exclusive, to a range.
+func sarifRange(region sarifRegion, offset int) Range {
+	startLine := max(region.StartLine, 1) - 1 - offset
+	startColumn := max(region.StartColumn, 1) - 1
+	endLine := startLine
+	if region.EndLine > 0 {
+		endLine = region.EndLine - 1 - offset
+	}
+	endColumn := startColumn
+	if region.EndColumn > 0 {
+		endColumn = region.EndColumn - 1
+	}
+	return Range{
+		Start: Position{Line: startLine, Character: startColumn},
+		End:   Position{Line: endLine, Character: endColumn},
+	}
+}
@@ -0,0 +1,113 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+func TestSarifDiagnostics(t *testing.T) {
+	root, _ := filepath.Abs("testdata")
+	file := filepath.Join(root, "src", "main.c")
+	uri := toURI(file)
+	h := &langHandler{logger: log.New(io.Discard, "", 0)}
+
+	sarif := `{
+	  "version": "2.1.0",
+	  "runs": [{
+	    "tool": {"driver": {"name": "scanner", "rules": [
+	      {"id": "R1", "shortDescription": {"text": "rule one"}, "defaultConfiguration": {"level": "error"}},
+	      {"id": "R2"}
+	    ]}},
+	    "originalUriBaseIds": {"SRCROOT": {"uri": "src/"}},
+	    "results": [
+	      {
+	        "ruleId": "R1",
+	        "message": {"text": "buffer overflow"},
+	        "locations": [{"physicalLocation": {
+	          "artifactLocation": {"uri": "main.c", "uriBaseId": "SRCROOT"},
+	          "region": {"startLine": 3, "startColumn": 5, "endColumn": 9}
+	        }}],
+	        "relatedLocations": [{
+	          "physicalLocation": {"artifactLocation": {"uri": "src/util.h"}, "region": {"startLine": 1}},
+	          "message": {"text": "declared here"}
+	        }]
+	      },
+	      {"ruleIndex": 0, "level": "note"},
+	      {"ruleId": "R2", "message": {"text": "other"}, "locations": [{"physicalLocation": {
+	        "artifactLocation": {"uri": "src/other.c"}, "region": {"startLine": 1}
+	      }}]}
+	    ]
+	  }]
+	}`
+	d, err := h.sarifDiagnostics([]byte(sarif), uri, root, &Language{}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 2 {
+		t.Fatalf("expected two diagnostics for the document but got: %+v", d)
+	}
+	first := d[uri][0]
+	if first.Message != "buffer overflow" || first.Severity != 1 || *first.Code != "R1" || *first.Source != "scanner" {
+		t.Fatalf("unexpected diagnostic: %+v", first)
+	}
+	want := Range{Start: Position{Line: 2, Character: 4}, End: Position{Line: 2, Character: 8}}
+	if first.Range != want {
+		t.Fatalf("want range %+v but got %+v", want, first.Range)
+	}
+	if len(first.RelatedInformation) != 1 ||
+		first.RelatedInformation[0].Location.URI != toURI(filepath.Join(root, "src", "util.h")) ||
+		first.RelatedInformation[0].Message != "declared here" {
+		t.Fatalf("unexpected related information: %+v", first.RelatedInformation)
+	}
+	second := d[uri][1]
+	if second.Message != "rule one" || second.Severity != 3 || *second.Code != "R1" {
+		t.Fatalf("a result without location should be for the document: %+v", second)
+	}
+	other := d[toURI(filepath.Join(root, "src", "other.c"))]
+	if len(other) != 1 || other[0].Severity != 2 {
+		t.Fatalf("the level should default to warning: %+v", other)
+	}
+}
+
+func TestSarifFixes(t *testing.T) {
+	root, _ := filepath.Abs("testdata")
+	uri := toURI(filepath.Join(root, "main.c"))
+	h := &langHandler{logger: log.New(io.Discard, "", 0)}
+
+	sarif := `{
+	  "version": "2.1.0",
+	  "runs": [{
+	    "tool": {"driver": {"name": "clang"}},
+	    "results": [{
+	      "message": {"text": "expected ';' after expression"},
+	      "locations": [{"physicalLocation": {
+	        "artifactLocation": {"uri": "main.c"},
+	        "region": {"startLine": 2, "startColumn": 8}
+	      }}],
+	      "fixes": [{"artifactChanges": [
+	        {"artifactLocation": {"uri": "main.c"}, "replacements": [
+	          {"deletedRegion": {"startLine": 2, "startColumn": 8, "endColumn": 8}, "insertedContent": {"text": ";"}}
+	        ]},
+	        {"artifactLocation": {"uri": "other.c"}, "replacements": [
+	          {"deletedRegion": {"startLine": 1, "startColumn": 1}, "insertedContent": {"text": "x"}}
+	        ]}
+	      ]}]
+	    }]
+	  }]
+	}`
+	d, err := h.sarifDiagnostics([]byte(sarif), uri, root, &Language{}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := dataFixCodeActions(uri, d[uri])
+	if len(actions) != 1 {
+		t.Fatalf("expected a quick fix but got: %+v", actions)
+	}
+	edits := actions[0].Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+	want := []TextEdit{{Range: Range{Start: Position{1, 7}, End: Position{1, 7}}, NewText: ";"}}
+	if len(edits) != 1 || edits[0] != want[0] {
+		t.Fatalf("want edits %+v but got %+v", want, edits)
+	}
+}
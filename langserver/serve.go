@@ -0,0 +1,41 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ErrNoShutdown is returned by Serve when the session ended, by the exit
+// notification or by a dropped connection, without a shutdown request.
+var ErrNoShutdown = errors.New("session ended without shutdown request")
+
+// Serve runs a language server session on rwc until the client sends exit,
+// disconnects or ctx is done. Messages are framed with Content-Length headers
+// like on stdio. A nil config serves with the defaults of NewConfig, and opts
+// are passed to the JSON-RPC connection, e.g. jsonrpc2.LogMessages.
+func Serve(ctx context.Context, rwc io.ReadWriteCloser, config *Config, opts ...jsonrpc2.ConnOpt) error {
+	if config == nil {
+		config = NewConfig()
+	}
+	h := newLangHandler(config)
+	conn := jsonrpc2.NewConn(
+		ctx,
+		jsonrpc2.NewBufferedStream(rwc, jsonrpc2.VSCodeObjectCodec{}),
+		replyHandler{h}, opts...)
+
+	select {
+	case <-conn.DisconnectNotify():
+		h.close()
+		if !h.isShutdown() {
+			return ErrNoShutdown
+		}
+		return nil
+	case <-ctx.Done():
+		conn.Close()
+		h.close()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,296 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestServe(t *testing.T) {
+	server, client := net.Pipe()
+	config := NewConfig()
+	config.Logger = log.New(io.Discard, "", 0)
+	(*config.Languages)["go"] = []Language{{FormatCommand: "gofmt"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, server, config)
+	}()
+
+	conn := jsonrpc2.NewConn(
+		context.Background(),
+		jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+			return nil, nil
+		}))
+	defer conn.Close()
+
+	var result InitializeResult
+	if err := conn.Call(context.Background(), "initialize", InitializeParams{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Capabilities.DocumentFormattingProvider {
+		t.Fatalf("formatting should be provided: %+v", result.Capabilities)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected %v but got: %v", context.Canceled, err)
+	}
+}
+
+// initializeServer serves config and returns the result of the initialize
+// request of a client without capabilities.
+func initializeServer(t *testing.T, config *Config) InitializeResult {
+	t.Helper()
+	server, client := net.Pipe()
+	config.Logger = log.New(io.Discard, "", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Serve(ctx, server, config)
+	conn := jsonrpc2.NewConn(
+		context.Background(),
+		jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+			return nil, nil
+		}))
+	defer conn.Close()
+
+	var result InitializeResult
+	if err := conn.Call(ctx, "initialize", InitializeParams{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestServeExit(t *testing.T) {
+	for _, shutdown := range []bool{true, false} {
+		server, client := net.Pipe()
+		config := NewConfig()
+		config.Logger = log.New(io.Discard, "", 0)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- Serve(context.Background(), server, config)
+		}()
+
+		conn := jsonrpc2.NewConn(
+			context.Background(),
+			jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+			jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+				return nil, nil
+			}))
+
+		var result InitializeResult
+		if err := conn.Call(context.Background(), "initialize", InitializeParams{}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if shutdown {
+			if err := conn.Call(context.Background(), "shutdown", nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.Call(context.Background(), "textDocument/hover", HoverParams{}, nil); err == nil {
+				t.Fatal("requests after shutdown should fail")
+			}
+		}
+		if err := conn.Notify(context.Background(), "exit", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		err := <-done
+		if shutdown && err != nil {
+			t.Fatalf("expected no error after shutdown but got: %v", err)
+		}
+		if !shutdown && err != ErrNoShutdown {
+			t.Fatalf("expected %v but got: %v", ErrNoShutdown, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestServeFormatDebounceDoesNotBlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	server, client := net.Pipe()
+	config := NewConfig()
+	config.Logger = log.New(io.Discard, "", 0)
+	config.FormatDebounce = Duration(time.Second)
+	(*config.Languages)["vim"] = []Language{{FormatCommand: "tr a-z A-Z", FormatStdin: true}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Serve(ctx, server, config)
+
+	conn := jsonrpc2.NewConn(
+		context.Background(),
+		jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+			return nil, nil
+		}))
+	defer conn.Close()
+
+	base, _ := os.Getwd()
+	uri := toURI(filepath.Join(base, "foo.vim"))
+	var result InitializeResult
+	if err := conn.Call(ctx, "initialize", InitializeParams{RootURI: toURI(base)}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Notify(ctx, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "vim", Text: "normal!\n"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	var edits []TextEdit
+	if err := conn.Call(ctx, "textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	waiter, err := conn.DispatchCall(ctx, "textDocument/formatting", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hover *Hover
+	if err := conn.Call(ctx, "textDocument/hover", HoverParams{TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}}}, &hover); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second/2 {
+		t.Fatalf("expected the request after a debounced format to be answered at once, took %v", elapsed)
+	}
+
+	edits = nil
+	if err := waiter.Wait(ctx, &edits); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second/2 {
+		t.Fatalf("expected the second format to wait for the debounce delay, took %v", elapsed)
+	}
+	if len(edits) == 0 {
+		t.Fatal("expected the edits of the debounced format")
+	}
+
+	// The client can cancel a debounced format.
+	id := jsonrpc2.ID{Str: "format", IsString: true}
+	waiter, err = conn.DispatchCall(ctx, "textDocument/formatting", params, jsonrpc2.PickID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Notify(ctx, "$/cancelRequest", CancelParams{ID: id}); err != nil {
+		t.Fatal(err)
+	}
+	err = waiter.Wait(ctx, &edits)
+	if e, ok := err.(*jsonrpc2.Error); !ok || e.Code != codeRequestCancelled {
+		t.Fatalf("expected the format to be cancelled, got %v", err)
+	}
+}
+
+// writerFunc is an io.Writer calling a function.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestServePanic(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	base, _ := os.Getwd()
+	good := filepath.Join(base, "good.vim")
+	broken := toURI(filepath.Join(base, "broken.vim"))
+
+	panics := make(chan string, 10)
+	config := NewConfig()
+	config.Logger = log.New(writerFunc(func(p []byte) (int, error) {
+		if s := string(p); strings.HasPrefix(s, "panic in ") {
+			select {
+			case panics <- s:
+			default:
+			}
+		}
+		return len(p), nil
+	}), "", 0)
+	(*config.Languages)["vim"] = []Language{{
+		LintCommand:        "echo " + good + ":1:found",
+		LintIgnoreExitCode: true,
+		LintStdin:          true,
+		LintAfterOpen:      true,
+	}}
+	h := newLangHandler(config)
+	// The requests and the lints of a broken document panic.
+	h.files[broken] = nil
+
+	server, client := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), replyHandler{h})
+
+	published := make(chan PublishDiagnosticsParams, 10)
+	conn := jsonrpc2.NewConn(
+		context.Background(),
+		jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			var params PublishDiagnosticsParams
+			if req.Method == "textDocument/publishDiagnostics" && json.Unmarshal(*req.Params, &params) == nil {
+				published <- params
+			}
+			return nil, nil
+		}))
+	defer conn.Close()
+
+	var result InitializeResult
+	if err := conn.Call(ctx, "initialize", InitializeParams{RootURI: toURI(base)}, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	err := conn.Call(ctx, "textDocument/hover", HoverParams{TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: broken}}}, nil)
+	if e, ok := err.(*jsonrpc2.Error); !ok || e.Code != jsonrpc2.CodeInternalError {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+
+	h.lintRequest(broken, eventTypeChange)
+	for linted := false; !linted; {
+		select {
+		case p := <-panics:
+			linted = strings.HasPrefix(p, "panic in lint of "+string(broken))
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the lint to panic")
+		}
+	}
+
+	// The server and the linter keep running.
+	if err := conn.Notify(ctx, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: toURI(good), LanguageID: "vim", Text: "normal!\n"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case params := <-published:
+			if params.URI != toURI(good) {
+				continue
+			}
+			if len(params.Diagnostics) != 1 || params.Diagnostics[0].Message != "found" {
+				t.Fatalf("expected the diagnostic of the linter, got %+v", params.Diagnostics)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the document to be linted after a panic")
+		}
+	}
+}
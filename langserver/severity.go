@@ -0,0 +1,52 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Severity is the severity of a diagnostic, given by name (error, warning,
+// info or hint) or by number from 1 to 4.
+type Severity int
+
+// UnmarshalJSON method Unmash severity from string or decimal
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return s.parse(fmt.Sprint(v))
+}
+
+// UnmarshalYAML method Unmash severity from string or decimal
+func (s *Severity) UnmarshalYAML(unmarshal func(any) error) error {
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+	return s.parse(name)
+}
+
+func (s *Severity) parse(name string) error {
+	severity, ok := severityNamed(name)
+	if !ok {
+		return fmt.Errorf("invalid severity: %q", name)
+	}
+	*s = Severity(severity)
+	return nil
+}
+
+// filterSeverity drops the diagnostics less severe than minimum. Zero means
+// no minimum.
+func filterSeverity(diagnostics []Diagnostic, minimum Severity) []Diagnostic {
+	if minimum == 0 {
+		return diagnostics
+	}
+	filtered := make([]Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		if severityRank(d.Severity) <= int(minimum) {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
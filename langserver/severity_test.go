@@ -0,0 +1,51 @@
+package langserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSeverityUnmarshal(t *testing.T) {
+	var options InitializeOptions
+	if err := json.Unmarshal([]byte(`{"minimumSeverity": "warning"}`), &options); err != nil {
+		t.Fatal(err)
+	}
+	if options.MinimumSeverity != 2 {
+		t.Fatalf("expected 2 but got %d", options.MinimumSeverity)
+	}
+	if err := json.Unmarshal([]byte(`{"minimumSeverity": 1}`), &options); err != nil {
+		t.Fatal(err)
+	}
+	if options.MinimumSeverity != 1 {
+		t.Fatalf("expected 1 but got %d", options.MinimumSeverity)
+	}
+	if err := json.Unmarshal([]byte(`{"minimumSeverity": "fatal"}`), &options); err == nil {
+		t.Fatal("expected an error for an unknown severity")
+	}
+
+	var config Config
+	if err := yaml.Unmarshal([]byte("minimum-severity: hint\n"), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MinimumSeverity != 4 {
+		t.Fatalf("expected 4 but got %d", config.MinimumSeverity)
+	}
+}
+
+func TestFilterSeverity(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{Message: "error", Severity: 1},
+		{Message: "unset"},
+		{Message: "warning", Severity: 2},
+		{Message: "hint", Severity: 4},
+	}
+	if got := filterSeverity(diagnostics, 0); len(got) != 4 {
+		t.Fatalf("expected no minimum but got: %+v", got)
+	}
+	got := filterSeverity(diagnostics, 1)
+	if len(got) != 2 || got[0].Message != "error" || got[1].Message != "unset" {
+		t.Fatalf("expected the errors but got: %+v", got)
+	}
+}
@@ -0,0 +1,89 @@
+package langserver
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// shebangLanguages lists the language ids tried for an interpreter, after
+// the name of the interpreter itself.
+var shebangLanguages = map[string][]string{
+	"sh":      {"sh", "shellscript", "bash"},
+	"bash":    {"sh", "shellscript"},
+	"dash":    {"sh", "shellscript"},
+	"ksh":     {"sh", "shellscript"},
+	"zsh":     {"sh", "shellscript"},
+	"python":  {"python"},
+	"pypy":    {"python"},
+	"node":    {"javascript"},
+	"nodejs":  {"javascript"},
+	"bun":     {"javascript", "typescript"},
+	"deno":    {"typescript", "javascript"},
+	"ts-node": {"typescript"},
+	"tsx":     {"typescript"},
+	"luajit":  {"lua"},
+	"gawk":    {"awk"},
+	"Rscript": {"r"},
+	"tclsh":   {"tcl"},
+	"pwsh":    {"powershell", "ps1"},
+	"make":    {"make"},
+}
+
+var interpreterVersionRe = regexp.MustCompile(`[0-9.]+$`)
+
+// shebangInterpreter returns the interpreter named by the shebang on the
+// first line of text, e.g. "python3" for "#!/usr/bin/env python3".
+func shebangInterpreter(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	line, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// Skip the options and the variables of env.
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = path.Base(field)
+			break
+		}
+	}
+	return interpreter
+}
+
+// shebangLanguage returns the configured language of the script text
+// according to its shebang, or "" if there is none.
+func (h *langHandler) shebangLanguage(text string) string {
+	interpreter := shebangInterpreter(text)
+	if interpreter == "" {
+		return ""
+	}
+	name := interpreterVersionRe.ReplaceAllString(interpreter, "")
+	return h.configuredLanguage(append([]string{interpreter, name}, shebangLanguages[name]...))
+}
+
+// configuredLanguage returns the first of candidates which is configured or
+// is an alias, or "" if there is none.
+func (h *langHandler) configuredLanguage(candidates []string) string {
+	for _, lang := range candidates {
+		if lang == "" {
+			continue
+		}
+		// openFile maps aliases.
+		if _, ok := h.configs[lang]; ok {
+			return lang
+		}
+		if _, ok := h.languageAliases[lang]; ok {
+			return lang
+		}
+	}
+	return ""
+}
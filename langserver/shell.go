@@ -0,0 +1,330 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// Supported values for the `shell` option.
+const (
+	shellSh         = "sh"
+	shellBash       = "bash"
+	shellZsh        = "zsh"
+	shellCmd        = "cmd"
+	shellPowerShell = "powershell"
+	shellPwsh       = "pwsh"
+	shellNone       = "none"
+)
+
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return shellCmd
+	}
+	return shellSh
+}
+
+// shellFor returns the shell which should run the commands of config. The
+// per-language setting wins over the global one.
+func (h *langHandler) shellFor(config *Language) string {
+	if config != nil && config.Shell != "" {
+		return config.Shell
+	}
+	if config != nil && (config.Container != nil || config.Remote != nil || h.useWSL(config)) {
+		// The host shell is meaningless inside a Linux container, on a
+		// remote host or in WSL.
+		return shellSh
+	}
+	if h.shell != "" {
+		return h.shell
+	}
+	return defaultShell()
+}
+
+// wrapCommand prepends the command wrapper of config, e.g. "poetry run --",
+// to command. The per-language setting wins over the global one.
+func (h *langHandler) wrapCommand(config *Language, command string) string {
+	wrapper := h.commandWrapper
+	if config != nil && config.CommandWrapper != "" {
+		wrapper = config.CommandWrapper
+	}
+	if wrapper == "" {
+		return command
+	}
+	return wrapper + " " + command
+}
+
+// newCommand builds the command line for config, ready to be started in dir.
+// Canceling ctx kills the command with the processes it started.
+func (h *langHandler) newCommand(ctx context.Context, config *Language, dir, command string, args ...string) (*exec.Cmd, error) {
+	cmd, err := h.toolCommand(ctx, config, dir, command, args...)
+	if err != nil {
+		return nil, err
+	}
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+func (h *langHandler) toolCommand(ctx context.Context, config *Language, dir, command string, args ...string) (*exec.Cmd, error) {
+	command = h.wrapCommand(config, command)
+	if config != nil && config.Container != nil {
+		return h.containerCommand(ctx, config, dir, command, args...)
+	}
+	if config != nil && config.Remote != nil {
+		return h.remoteCommand(ctx, config, dir, command, args...)
+	}
+	if h.useWSL(config) {
+		return h.wslCommand(ctx, config, dir, command, args...)
+	}
+	cmd, err := shellCommand(ctx, h.shellFor(config), command, args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	if config != nil {
+		cmd.Env = append(cmd.Env, config.Env...)
+	}
+	return cmd, nil
+}
+
+func shellCommand(ctx context.Context, shell, command string, args ...string) (*exec.Cmd, error) {
+	switch shell {
+	case shellSh, shellBash, shellZsh:
+		return exec.CommandContext(ctx, shell, append([]string{"-c", command}, args...)...), nil
+	case shellCmd:
+		// cmd takes the rest of its command line as is, which Go would
+		// quote as one argument.
+		line := command
+		for _, arg := range args {
+			line += " " + quoteArg(shell, arg)
+		}
+		cmd := exec.CommandContext(ctx, "cmd", "/d", "/s", "/c", line)
+		setCommandLine(cmd, `cmd /d /s /c "`+line+`"`)
+		return cmd, nil
+	case shellPowerShell, shellPwsh:
+		for _, arg := range args {
+			command += " " + quoteArg(shell, arg)
+		}
+		return exec.CommandContext(ctx, shell, "-NoProfile", "-NonInteractive", "-Command", command), nil
+	case shellNone:
+		words, err := splitCommandLine(command)
+		if err != nil {
+			return nil, err
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		return exec.CommandContext(ctx, words[0], append(words[1:], args...)...), nil
+	}
+	return nil, fmt.Errorf("unsupported shell: %v", shell)
+}
+
+// quoteArg quotes s so that shell passes it to the command as one argument,
+// as is, without any expansion. An empty shell means s is passed as an
+// argument directly.
+func quoteArg(shell, s string) string {
+	switch shell {
+	case "":
+		return s
+	case shellCmd:
+		// The command parses its command line like the C runtime. cmd has
+		// no escape inside quotes, and would end its quoted region at a
+		// quote of s, so its metacharacters, the quotes included, are all
+		// ^-escaped instead: cmd takes them literally, never quoting.
+		return cmdEscape(crtQuote(s))
+	case shellPowerShell, shellPwsh:
+		// Nothing is expanded in single quoted strings, whose quotes are
+		// doubled.
+		if isLiteralWord(s) {
+			return s
+		}
+		return "'" + strings.Replace(s, "'", "''", -1) + "'"
+	case shellNone:
+		// The inverse of splitCommandLine.
+		if isLiteralWord(s) || (s != "" && !strings.ContainsAny(s, " \t\n\r'\"")) {
+			return s
+		}
+		return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+	default:
+		return posixQuote(s)
+	}
+}
+
+// replacePlaceholder replaces placeholder in command with value quoted for
+// shell. Configs written before the values were quoted quote the
+// placeholder themselves, e.g. "${INPUT}", which then gets value escaped
+// for the quotes around it instead.
+func replacePlaceholder(command, placeholder, value, shell string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(command, placeholder)
+		if i < 0 {
+			b.WriteString(command)
+			return b.String()
+		}
+		end := i + len(placeholder)
+		b.WriteString(command[:i])
+		if quote := quoteAround(command, i, end); quote != 0 {
+			b.WriteString(escapeInQuotes(shell, quote, value))
+		} else {
+			b.WriteString(quoteArg(shell, value))
+		}
+		command = command[end:]
+	}
+}
+
+// quoteAround returns the quote s[i:end] is directly enclosed in, or 0.
+func quoteAround(s string, i, end int) byte {
+	if i == 0 || end >= len(s) || s[i-1] != s[end] || (s[end] != '"' && s[end] != '\'') {
+		return 0
+	}
+	return s[end]
+}
+
+// escapeInQuotes escapes s so that shell takes it literally between the
+// quotes quote.
+func escapeInQuotes(shell string, quote byte, s string) string {
+	switch shell {
+	case "", shellCmd:
+		// Nothing but a quote ends them, which paths can't contain.
+		return s
+	case shellPowerShell, shellPwsh:
+		if quote == '\'' {
+			return strings.Replace(s, "'", "''", -1)
+		}
+		return escapeBytes(s, "`\"$", '`')
+	case shellNone:
+		if quote == '\'' {
+			return strings.Replace(s, "'", `'"'"'`, -1)
+		}
+		return escapeBytes(s, `\"`, '\\')
+	default:
+		if quote == '\'' {
+			return strings.Replace(s, "'", `'"'"'`, -1)
+		}
+		return escapeBytes(s, "\\\"$`", '\\')
+	}
+}
+
+// escapeBytes prefixes the bytes of s which are in chars with escape.
+func escapeBytes(s, chars string, escape byte) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(chars, s[i]) >= 0 {
+			b.WriteByte(escape)
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// crtQuote quotes s so that the C runtime parses it as one argument.
+func crtQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			slashes++
+		case '"':
+			// The backslashes before a quote are doubled, and the quote
+			// escaped.
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// cmdMetachars are the characters cmd interprets outside of quotes.
+const cmdMetachars = `^&|<>()"%!`
+
+// cmdEscape escapes the metacharacters of cmd in s with ^.
+func cmdEscape(s string) string {
+	return escapeBytes(s, cmdMetachars, '^')
+}
+
+// isLiteralWord reports whether s is one word which no shell expands.
+func isLiteralWord(s string) bool {
+	return s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_+=:./-", r)
+	})
+}
+
+// posixQuote quotes s in single quotes for a POSIX shell, unless all its
+// characters are taken literally, so that it is one word without any
+// expansion.
+func posixQuote(s string) string {
+	if isLiteralWord(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// splitCommandLine splits s into words like a POSIX shell would, without
+// any expansion. A backslash only escapes whitespace and quotes so that
+// Windows paths survive unquoted.
+func splitCommandLine(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(rs) && (rs[i+1] == '"' || rs[i+1] == '\\') {
+				i++
+				word.WriteRune(rs[i])
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && i+1 < len(rs) && (unicode.IsSpace(rs[i+1]) || rs[i+1] == '\'' || rs[i+1] == '"'):
+			i++
+			word.WriteRune(rs[i])
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %v", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,236 @@
+package langserver
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	for input, expected := range map[string][]string{
+		`flake8 --stdin-display-name foo.py -`: {"flake8", "--stdin-display-name", "foo.py", "-"},
+		`eslint  --format 'unix' "a b"`:        {"eslint", "--format", "unix", "a b"},
+		`echo it\'s 'x'"'"'y'`:                 {"echo", "it's", "x'y"},
+		`C:\tools\lint.exe C:\work\file.c`:     {`C:\tools\lint.exe`, `C:\work\file.c`},
+		`echo "say \"hi\""`:                    {"echo", `say "hi"`},
+		`echo ''`:                              {"echo", ""},
+	} {
+		words, err := splitCommandLine(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(words, expected) {
+			t.Fatalf("split of %q should be %q but got: %q", input, expected, words)
+		}
+	}
+
+	if _, err := splitCommandLine(`echo "foo`); err == nil {
+		t.Fatal("unterminated quote should be an error")
+	}
+}
+
+func TestQuoteArgRoundTrip(t *testing.T) {
+	for _, arg := range []string{"foo.py", "/tmp/a b/c.py", "it's", ""} {
+		words, err := splitCommandLine("lint " + quoteArg(shellNone, arg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(words) != 2 || words[1] != arg {
+			t.Fatalf("quoted %q should survive splitting but got: %q", arg, words)
+		}
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	for _, tt := range []struct {
+		shell, arg, want string
+	}{
+		{shellSh, "src/main.go", "src/main.go"},
+		{shellSh, "my file.go", "'my file.go'"},
+		{shellBash, "$HOME", "'$HOME'"},
+		{shellZsh, "it's", `'it'"'"'s'`},
+		{shellSh, "", "''"},
+		{shellPowerShell, "C:/work/main.go", "C:/work/main.go"},
+		{shellPowerShell, "my file.go", "'my file.go'"},
+		{shellPwsh, "$env:HOME", "'$env:HOME'"},
+		{shellPwsh, "it's", "'it''s'"},
+		{shellCmd, `C:\work\a.go`, `C:\work\a.go`},
+		{shellCmd, `C:\my work\a.go`, `^"C:\my work\a.go^"`},
+		{shellCmd, `say "hi"`, `^"say \^"hi\^"^"`},
+		{shellCmd, `%PATH%!x!`, `^%PATH^%^!x^!`},
+		{shellNone, "my file.go", "'my file.go'"},
+		{shellNone, "$HOME", "$HOME"},
+		{"", "my file.go", "my file.go"},
+	} {
+		if got := quoteArg(tt.shell, tt.arg); got != tt.want {
+			t.Errorf("%s: expected %q to be quoted as %s, got %s", tt.shell, tt.arg, tt.want, got)
+		}
+	}
+}
+
+func TestQuoteArgShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	for _, arg := range []string{"my file.go", "$HOME", "`id`", "a;b&c|d", "it's", `say "hi"`, "(a)[b]*", "~", ""} {
+		b, err := exec.Command("sh", "-c", "printf '%s' "+quoteArg(shellSh, arg)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != arg {
+			t.Errorf("expected %q to be passed as is, got %q", arg, b)
+		}
+	}
+}
+
+// cmdParse parses line like cmd then the C runtime of the command would,
+// failing if cmd would interpret a character of it.
+func cmdParse(t *testing.T, line string) []string {
+	t.Helper()
+	// cmd drops the carets, and would interpret any metacharacter left.
+	var unescaped strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '^' && i+1 < len(line) {
+			i++
+		} else if strings.IndexByte(cmdMetachars, line[i]) >= 0 {
+			t.Fatalf("%q: cmd would interpret %q", line, line[i])
+		}
+		unescaped.WriteByte(line[i])
+	}
+
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	s := unescaped.String()
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			n := 0
+			for ; i < len(s) && s[i] == '\\'; i++ {
+				n++
+			}
+			if i < len(s) && s[i] == '"' {
+				arg.WriteString(strings.Repeat(`\`, n/2))
+				if n%2 == 1 {
+					arg.WriteByte('"')
+				} else {
+					quoted = !quoted
+				}
+			} else {
+				arg.WriteString(strings.Repeat(`\`, n))
+				i--
+			}
+			inArg = true
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+func TestQuoteArgCmd(t *testing.T) {
+	// The text of a document, e.g. ${LINE_TEXT}, can't end the quotes.
+	for _, arg := range []string{`a" & calc & "`, `C:\my work\a.go`, `C:\dir\`, `trail\ "x"`, `%PATH%`, `(a)|b<c>^d!`, ""} {
+		quoted := quoteArg(shellCmd, arg)
+		if args := cmdParse(t, "lint "+quoted); !reflect.DeepEqual(args, []string{"lint", arg}) {
+			t.Errorf("expected %q to be passed as is, got %q from %s", arg, args, quoted)
+		}
+	}
+}
+
+func TestReplacePlaceholder(t *testing.T) {
+	for _, tt := range []struct {
+		shell, command, value, want string
+	}{
+		{shellSh, "lint ${INPUT}", "/a b/x", "lint '/a b/x'"},
+		{shellSh, `lint "${INPUT}"`, "/a b/x", `lint "/a b/x"`},
+		{shellSh, `lint "${INPUT}"`, "/a $b/x", `lint "/a \$b/x"`},
+		{shellSh, "lint '${INPUT}'", "/a b/it's", `lint '/a b/it'"'"'s'`},
+		{shellSh, `lint "--file=${INPUT}"`, "/a b/x", `lint "--file='/a b/x'"`},
+		{shellCmd, `lint "${INPUT}"`, `C:\a b\x`, `lint "C:\a b\x"`},
+		{shellPwsh, `lint "${INPUT}"`, `C:\a $b\x`, "lint \"C:\\a `$b\\x\""},
+		{shellNone, `lint "${INPUT}" ${INPUT}`, "/a b/x", `lint "/a b/x" '/a b/x'`},
+	} {
+		if got := replacePlaceholder(tt.command, "${INPUT}", tt.value, tt.shell); got != tt.want {
+			t.Errorf("%s: expected %s to be %s, got %s", tt.shell, tt.command, tt.want, got)
+		}
+	}
+}
+
+func TestShellCommandArgs(t *testing.T) {
+	for _, arg := range []string{"my file.go", "$HOME", "it's", `say "hi"`, `C:\my work\a.go`} {
+		cmd, err := shellCommand(context.Background(), shellNone, "lint "+quoteArg(shellNone, arg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"lint", arg}; !reflect.DeepEqual(cmd.Args, want) {
+			t.Errorf("expected the arguments %q, got %q", want, cmd.Args)
+		}
+	}
+
+	// The arguments are quoted once, into the command of PowerShell.
+	cmd, err := shellCommand(context.Background(), shellPwsh, "lint", "my file.go", "$HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "lint 'my file.go' '$HOME'"; cmd.Args[len(cmd.Args)-1] != want {
+		t.Fatalf("expected the command %q, got %q", want, cmd.Args[len(cmd.Args)-1])
+	}
+}
+
+func TestCommandWrapper(t *testing.T) {
+	fname := "/src/my file's (1).py"
+	h := &langHandler{commandWrapper: "env WRAPPED=global"}
+
+	for _, shell := range []string{shellSh, shellCmd, shellPwsh, shellNone} {
+		config := &Language{Shell: shell, CommandWrapper: "env WRAPPED=language"}
+		command := h.placeholdersFor(config, fname, nil, nil).replace("lint ${INPUT}")
+		cmd, err := h.newCommand(context.Background(), config, "", command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The wrapper of the language wins, and the file name is quoted
+		// once, for the shell of the tool, behind it.
+		want := "env WRAPPED=language lint " + quoteArg(shell, fname)
+		if shell == shellNone {
+			if got := strings.Join(cmd.Args[:3], " "); got != "env WRAPPED=language lint" || cmd.Args[3] != fname {
+				t.Errorf("expected %q, got %q", want, cmd.Args)
+			}
+		} else if got := cmd.Args[len(cmd.Args)-1]; got != want {
+			t.Errorf("%s: expected the command %q, got %q", shell, want, got)
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	config := &Language{Shell: shellSh}
+	command := h.placeholdersFor(config, fname, nil, nil).replace(`sh -c 'echo "$WRAPPED" "$1"' _ ${INPUT}`)
+	cmd, err := h.newCommand(context.Background(), config, "", command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "global " + fname + "\n"; string(out) != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
@@ -0,0 +1,229 @@
+package langserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snippetDefinition is a snippet of a VS Code snippet file: its prefix and
+// body are a string or an array of them, and scope lists the language ids of
+// the snippets of .code-snippets files, separated by commas.
+type snippetDefinition struct {
+	Prefix      json.RawMessage `json:"prefix"`
+	Body        json.RawMessage `json:"body"`
+	Description string          `json:"description"`
+	Scope       string          `json:"scope"`
+}
+
+// snippet is a snippet read from a snippet file.
+type snippet struct {
+	name        string
+	prefixes    []string
+	body        string
+	description string
+	scope       []string
+}
+
+// snippetFile is the snippets of a file, read when it was last modified at
+// modTime.
+type snippetFile struct {
+	modTime  time.Time
+	snippets []snippet
+}
+
+// snippetFiles keeps the snippets of the files of snippets-dir, read again
+// once modified.
+type snippetFiles struct {
+	mu    sync.Mutex
+	files map[string]snippetFile
+}
+
+// read returns the snippets of the file path.
+func (s *snippetFiles) read(path string) ([]snippet, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[path]; ok && f.modTime.Equal(fi.ModTime()) {
+		return f.snippets, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := parseSnippets(b)
+	if err != nil {
+		return nil, err
+	}
+	if s.files == nil {
+		s.files = make(map[string]snippetFile)
+	}
+	s.files[path] = snippetFile{modTime: fi.ModTime(), snippets: snippets}
+	return snippets, nil
+}
+
+// parseSnippets parses a VS Code snippet file, which may have comments,
+// sorted by name. The snippets without a prefix or a body are dropped.
+func parseSnippets(b []byte) ([]snippet, error) {
+	var definitions map[string]snippetDefinition
+	if err := json.Unmarshal(stripJSONComments(b), &definitions); err != nil {
+		return nil, err
+	}
+	var snippets []snippet
+	for name, d := range definitions {
+		prefixes := stringOrStrings(d.Prefix)
+		body := stringOrStrings(d.Body)
+		if len(prefixes) == 0 || len(body) == 0 {
+			continue
+		}
+		var scope []string
+		for _, lang := range strings.Split(d.Scope, ",") {
+			if lang = strings.TrimSpace(lang); lang != "" {
+				scope = append(scope, lang)
+			}
+		}
+		snippets = append(snippets, snippet{
+			name:        name,
+			prefixes:    prefixes,
+			body:        strings.Join(body, "\n"),
+			description: d.Description,
+			scope:       scope,
+		})
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].name < snippets[j].name
+	})
+	return snippets, nil
+}
+
+// stringOrStrings returns the strings of raw, a string or an array of them.
+func stringOrStrings(raw json.RawMessage) []string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return []string{s}
+	}
+	var ss []string
+	_ = json.Unmarshal(raw, &ss)
+	return ss
+}
+
+// stripJSONComments blanks the // and /* */ comments of b out of its
+// strings, which VS Code allows in snippet files.
+func stripJSONComments(b []byte) []byte {
+	out := bytes.Clone(b)
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch {
+		case inString:
+			if out[i] == '\\' {
+				i++
+			} else if out[i] == '"' {
+				inString = false
+			}
+		case out[i] == '"':
+			inString = true
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
+
+// snippetPaths returns the snippet files of languageID in dir: the ones the
+// package.json of a snippet extension contributes for it if there is one,
+// e.g. friendly-snippets, or else <languageID>.json and the .code-snippets
+// files.
+func snippetPaths(dir string, languageID string) []string {
+	var manifest struct {
+		Contributes struct {
+			Snippets []struct {
+				Language json.RawMessage `json:"language"`
+				Path     string          `json:"path"`
+			} `json:"snippets"`
+		} `json:"contributes"`
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil && json.Unmarshal(b, &manifest) == nil && len(manifest.Contributes.Snippets) > 0 {
+		var paths []string
+		for _, s := range manifest.Contributes.Snippets {
+			for _, lang := range stringOrStrings(s.Language) {
+				if lang == languageID {
+					paths = append(paths, filepath.Join(dir, filepath.FromSlash(s.Path)))
+					break
+				}
+			}
+		}
+		return paths
+	}
+
+	paths := []string{filepath.Join(dir, languageID+".json")}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.code-snippets"))
+	sort.Strings(matches)
+	return append(paths, matches...)
+}
+
+// snippetItems returns the snippets of snippets-dir for languageID as
+// completion items, one for each of their prefixes.
+func (h *langHandler) snippetItems(languageID string) []CompletionItem {
+	if h.snippetsDir == "" {
+		return nil
+	}
+	dir := h.snippetsDir
+	if !filepath.IsAbs(dir) && h.rootPath != "" {
+		dir = filepath.Join(h.rootPath, dir)
+	}
+
+	var items []CompletionItem
+	for _, path := range snippetPaths(dir, languageID) {
+		snippets, err := h.snippets.read(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				h.logger.Printf("invalid snippet file %s: %v", path, err)
+			}
+			continue
+		}
+		for _, s := range snippets {
+			if len(s.scope) > 0 && !slices.Contains(s.scope, languageID) {
+				continue
+			}
+			detail := s.description
+			if detail == "" {
+				detail = s.name
+			}
+			for _, prefix := range s.prefixes {
+				items = append(items, CompletionItem{
+					Label:            prefix,
+					Kind:             SnippetCompletion,
+					Detail:           detail,
+					Documentation:    s.body,
+					InsertText:       s.body,
+					InsertTextFormat: SnippetTextFormat,
+				})
+			}
+		}
+	}
+	return items
+}
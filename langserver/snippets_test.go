@@ -0,0 +1,77 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSnippets(t *testing.T) {
+	snippets, err := parseSnippets([]byte(`{
+	// Print to the console.
+	"Print": {
+		"prefix": ["log", "print"],
+		"body": ["console.log('$1'); // http://x", "$2"], /* a comment */
+		"description": "Log output"
+	},
+	"No body": {"prefix": "nb"}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []snippet{{
+		name:        "Print",
+		prefixes:    []string{"log", "print"},
+		body:        "console.log('$1'); // http://x\n$2",
+		description: "Log output",
+	}}
+	if !reflect.DeepEqual(snippets, want) {
+		t.Fatalf("expected %#v, got %#v", want, snippets)
+	}
+}
+
+func TestSnippetItems(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.json":                `{"Main": {"prefix": "main", "body": "func main() {\n\t$0\n}"}}`,
+		"shared.code-snippets":   `{"Todo": {"prefix": "todo", "body": "TODO: $0", "scope": "go, rust"}, "Js": {"prefix": "fn", "body": "function", "scope": "javascript"}}`,
+		"ext/package.json":       `{"contributes": {"snippets": [{"language": ["go", "gomod"], "path": "./snippets/go.json"}]}}`,
+		"ext/snippets/go.json":   `{"Err": {"prefix": "iferr", "body": "if err != nil {\n\treturn err\n}", "description": "Return the error"}}`,
+		"ext/snippets/rust.json": `{"Fn": {"prefix": "fn", "body": "fn"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &langHandler{logger: log.New(io.Discard, "", 0), rootPath: dir, snippetsDir: "."}
+	var labels []string
+	for _, item := range h.snippetItems("go") {
+		labels = append(labels, item.Label)
+	}
+	if want := []string{"main", "todo"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("expected %q, got %q", want, labels)
+	}
+
+	h.snippetsDir = filepath.Join(dir, "ext")
+	items := h.snippetItems("go")
+	want := []CompletionItem{{
+		Label:            "iferr",
+		Kind:             SnippetCompletion,
+		Detail:           "Return the error",
+		Documentation:    "if err != nil {\n\treturn err\n}",
+		InsertText:       "if err != nil {\n\treturn err\n}",
+		InsertTextFormat: SnippetTextFormat,
+	}}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("expected %#v, got %#v", want, items)
+	}
+}
@@ -0,0 +1,394 @@
+package langserver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	defaultSpellWordlist   = "/usr/share/dict/words"
+	defaultSpellIgnoreFile = ".efm-spell-ignore"
+
+	spellSource         = "spell"
+	spellAddWordCommand = "efm-langserver.spell.addWord"
+	spellSuggestions    = 3
+)
+
+// SpellCheck configures the built-in spell checker.
+type SpellCheck struct {
+	Languages  []string `yaml:"languages" json:"languages"`
+	Wordlist   string   `yaml:"wordlist" json:"wordlist"`
+	IgnoreFile string   `yaml:"ignore-file" json:"ignoreFile"`
+}
+
+func (s *SpellCheck) wordlist() string {
+	if s.Wordlist != "" {
+		return s.Wordlist
+	}
+	return defaultSpellWordlist
+}
+
+// ignoreFile returns the project dictionary which "Add to project
+// dictionary" appends to.
+func (s *SpellCheck) ignoreFile(root string) string {
+	name := s.IgnoreFile
+	if name == "" {
+		name = defaultSpellIgnoreFile
+	}
+	if !filepath.IsAbs(name) && root != "" {
+		name = filepath.Join(root, name)
+	}
+	return name
+}
+
+// speller caches the loaded wordlist.
+type speller struct {
+	mu    sync.Mutex
+	path  string
+	words map[string]bool
+}
+
+func (s *speller) load(path string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == path && s.words != nil {
+		return s.words, nil
+	}
+	words, err := readWords(path)
+	if err != nil {
+		return nil, err
+	}
+	s.path, s.words = path, words
+	return words, nil
+}
+
+// readWords reads a file of one word per line, lowercasing the words.
+func readWords(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" && !strings.HasPrefix(word, "#") {
+			words[strings.ToLower(word)] = true
+		}
+	}
+	return words, scanner.Err()
+}
+
+func (h *langHandler) spellCheckEnabled(languageID string) bool {
+	if h.spellCheck == nil {
+		return false
+	}
+	for _, lang := range h.spellCheck.Languages {
+		if lang == languageID || lang == wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// dictionary returns the known words: the wordlist plus the project
+// dictionary.
+func (h *langHandler) dictionary() (map[string]bool, map[string]bool, error) {
+	words, err := h.speller.load(h.spellCheck.wordlist())
+	if err != nil {
+		return nil, nil, fmt.Errorf("can not read spell check wordlist: %v", err)
+	}
+	ignored, err := readWords(h.spellCheck.ignoreFile(h.rootPath))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("can not read spell check ignore file: %v", err)
+	}
+	return words, ignored, nil
+}
+
+// spellCheckDiagnostics returns the misspelled words of uri. ok is false if
+// the document isn't spell checked.
+func (h *langHandler) spellCheckDiagnostics(uri DocumentURI) (diagnostics []Diagnostic, ok bool) {
+	f, found := h.files[uri]
+	if !found || !h.spellCheckEnabled(f.LanguageID) {
+		return nil, false
+	}
+	words, ignored, err := h.dictionary()
+	if err != nil {
+		h.logger.Println(err)
+		return nil, false
+	}
+
+	source := spellSource
+	diagnostics = []Diagnostic{}
+	for _, w := range spellWords(f.Text) {
+		if isKnownWord(w.text, words, ignored) {
+			continue
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    w.rng,
+			Message:  "Unknown word: " + w.text,
+			Severity: 3,
+			Source:   &source,
+		})
+	}
+	return diagnostics, true
+}
+
+func isKnownWord(word string, dicts ...map[string]bool) bool {
+	lower := strings.ToLower(word)
+	base := strings.TrimSuffix(strings.TrimSuffix(lower, "'s"), "’s")
+	for _, dict := range dicts {
+		if dict[lower] || dict[base] {
+			return true
+		}
+	}
+	return false
+}
+
+type spellWord struct {
+	text string
+	rng  Range
+}
+
+// spellWords returns the words of text worth checking. Code blocks, inline
+// code, URLs and identifier-like words (snake_case, camelCase, ACRONYMS,
+// words with digits) are skipped.
+func spellWords(text string) []spellWord {
+	var words []spellWord
+	inFence := false
+	for lnum, line := range strings.Split(strings.Replace(text, "\r", "", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		rs := []rune(line)
+		inCode := false
+		for i := 0; i < len(rs); i++ {
+			r := rs[i]
+			switch {
+			case r == '`':
+				inCode = !inCode
+			case inCode:
+			case !unicode.IsSpace(r) && (i == 0 || unicode.IsSpace(rs[i-1])) && isURLAt(rs[i:]):
+				for i < len(rs) && !unicode.IsSpace(rs[i]) {
+					i++
+				}
+			case unicode.IsLetter(r):
+				start := i
+				for i < len(rs) && (unicode.IsLetter(rs[i]) || isInnerApostrophe(rs, i)) {
+					i++
+				}
+				end := i
+				i--
+				if (start > 0 && isIdentRune(rs[start-1])) || (end < len(rs) && isIdentRune(rs[end])) {
+					continue
+				}
+				word := string(rs[start:end])
+				if end-start < 2 || hasInnerUpper(word) {
+					continue
+				}
+				words = append(words, spellWord{
+					text: word,
+					rng: Range{
+						Start: Position{Line: lnum, Character: start},
+						End:   Position{Line: lnum, Character: end},
+					},
+				})
+			}
+		}
+	}
+	return words
+}
+
+func isURLAt(rs []rune) bool {
+	end := 0
+	for end < len(rs) && !unicode.IsSpace(rs[end]) {
+		end++
+	}
+	field := string(rs[:end])
+	return strings.Contains(field, "://") || strings.HasPrefix(field, "www.") || strings.Contains(field, "@")
+}
+
+func isInnerApostrophe(rs []rune, i int) bool {
+	return (rs[i] == '\'' || rs[i] == '’') && i > 0 && unicode.IsLetter(rs[i-1]) && i+1 < len(rs) && unicode.IsLetter(rs[i+1])
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsDigit(r)
+}
+
+func hasInnerUpper(word string) bool {
+	for i, r := range word {
+		if i > 0 && unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// spellSuggest returns the closest known words to word, at most two edits
+// away.
+func spellSuggest(word string, words map[string]bool) []string {
+	lower := []rune(strings.ToLower(word))
+	type candidate struct {
+		word     string
+		distance int
+	}
+	var candidates []candidate
+	for w := range words {
+		rw := []rune(w)
+		if d := len(rw) - len(lower); d > 2 || d < -2 {
+			continue
+		}
+		if d := editDistance(lower, rw); d <= 2 {
+			candidates = append(candidates, candidate{w, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].word < candidates[j].word
+	})
+
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == spellSuggestions {
+			break
+		}
+		s := c.word
+		if r := []rune(word); unicode.IsUpper(r[0]) {
+			rs := []rune(s)
+			rs[0] = unicode.ToUpper(rs[0])
+			s = string(rs)
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions
+}
+
+// editDistance is the Damerau-Levenshtein (optimal string alignment)
+// distance of a and b.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// spellCodeActions offers the suggestions and "Add to project dictionary"
+// for the spell check diagnostics of the request.
+func (h *langHandler) spellCodeActions(uri DocumentURI, params *CodeActionParams) []CodeAction {
+	if params == nil || h.spellCheck == nil {
+		return nil
+	}
+	f, ok := h.files[uri]
+	if !ok {
+		return nil
+	}
+	lines := strings.Split(strings.Replace(f.Text, "\r", "", -1), "\n")
+
+	var actions []CodeAction
+	for _, d := range params.Context.Diagnostics {
+		if d.Source == nil || *d.Source != spellSource || d.Range.Start.Line != d.Range.End.Line || d.Range.Start.Line >= len(lines) {
+			continue
+		}
+		line := []rune(lines[d.Range.Start.Line])
+		if d.Range.Start.Character >= d.Range.End.Character || d.Range.End.Character > len(line) {
+			continue
+		}
+		word := string(line[d.Range.Start.Character:d.Range.End.Character])
+
+		if words, _, err := h.dictionary(); err == nil {
+			for i, s := range spellSuggest(word, words) {
+				actions = append(actions, CodeAction{
+					Title:       fmt.Sprintf("Replace with %q", s),
+					Kind:        QuickFix,
+					Diagnostics: []Diagnostic{d},
+					IsPreferred: i == 0,
+					Edit: &WorkspaceEdit{
+						Changes: map[DocumentURI][]TextEdit{
+							uri: {{Range: d.Range, NewText: s}},
+						},
+					},
+				})
+			}
+		}
+		title := fmt.Sprintf("Add %q to project dictionary", word)
+		actions = append(actions, CodeAction{
+			Title:       title,
+			Kind:        QuickFix,
+			Diagnostics: []Diagnostic{d},
+			Command: &Command{
+				Title:     title,
+				Command:   spellAddWordCommand,
+				Arguments: []any{string(uri), word},
+			},
+		})
+	}
+	return actions
+}
+
+// addSpellWord appends word to the project dictionary and checks the
+// document again.
+func (h *langHandler) addSpellWord(params *ExecuteCommandParams) (any, error) {
+	if h.spellCheck == nil {
+		return nil, fmt.Errorf("spell check is not configured")
+	}
+	if len(params.Arguments) != 2 {
+		return nil, fmt.Errorf("invalid command")
+	}
+	uri, ok1 := params.Arguments[0].(string)
+	word, ok2 := params.Arguments[1].(string)
+	if !ok1 || !ok2 || word == "" {
+		return nil, fmt.Errorf("invalid argument")
+	}
+
+	fname := h.spellCheck.ignoreFile(h.rootPath)
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintln(f, word)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if h.loglevel >= 1 {
+		h.logger.Printf("added %v to %v", word, fname)
+	}
+	h.lintRequest(DocumentURI(uri), eventTypeSave)
+	return "OK", nil
+}
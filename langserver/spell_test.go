@@ -0,0 +1,23 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpellWords(t *testing.T) {
+	text := "Teh quick `fooo` brown_fox don't\n```\nskiped\n```\nsee https://exmaple.com and HTTP or camelCase v2beta\n"
+	var words []string
+	for _, w := range spellWords(text) {
+		words = append(words, w.text)
+	}
+	expected := []string{"Teh", "quick", "don't", "see", "and", "or"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Fatalf("words should be %v but got: %v", expected, words)
+	}
+
+	dict := map[string]bool{"the": true, "then": true, "tea": true, "quick": true}
+	if suggestions := spellSuggest("Teh", dict); !reflect.DeepEqual(suggestions, []string{"Tea", "The", "Then"}) {
+		t.Fatalf("unexpected suggestions: %v", suggestions)
+	}
+}
@@ -0,0 +1,76 @@
+package langserver
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// defaultSuppressionMarker is the suppression-marker used if none is set.
+const defaultSuppressionMarker = "efm-ignore"
+
+// suppressions are the lines of a document whose diagnostics are
+// suppressed by a comment, with the codes they are restricted to.
+type suppressions map[int][]string
+
+// findSuppressions finds the suppression comments of text: marker
+// suppresses the diagnostics of its line and marker-next-line the ones of
+// the next line, e.g.
+//
+//	x = eval(s)  # efm-ignore
+//	// efm-ignore-next-line no-console, no-debugger
+//
+// Codes following the marker restrict it to the diagnostics of these codes.
+func findSuppressions(text, marker string) suppressions {
+	if !strings.Contains(text, marker) {
+		return nil
+	}
+	found := make(suppressions)
+	for i, line := range strings.Split(text, "\n") {
+		_, rest, ok := strings.Cut(line, marker)
+		if !ok {
+			continue
+		}
+		target := i
+		if after, ok := strings.CutPrefix(rest, "-next-line"); ok {
+			target, rest = i+1, after
+		}
+		// The marker is a word of its own, e.g. not efm-ignored.
+		if rest != "" && !unicode.IsSpace(rune(rest[0])) && rest[0] != ':' {
+			continue
+		}
+		codes := strings.FieldsFunc(rest, func(r rune) bool {
+			return unicode.IsSpace(r) || r == ',' || r == ':'
+		})
+		// Drop the end of block comments, e.g. */ or -->.
+		for len(codes) > 0 && !strings.ContainsFunc(codes[len(codes)-1], isCodeRune) {
+			codes = codes[:len(codes)-1]
+		}
+		if existing, ok := found[target]; ok && (len(existing) == 0 || len(codes) == 0) {
+			found[target] = nil
+			continue
+		}
+		found[target] = append(found[target], codes...)
+	}
+	return found
+}
+
+func isCodeRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// filter drops the suppressed diagnostics.
+func (s suppressions) filter(diagnostics []Diagnostic) []Diagnostic {
+	if len(s) == 0 {
+		return diagnostics
+	}
+	kept := make([]Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		codes, ok := s[d.Range.Start.Line]
+		if ok && (len(codes) == 0 || (d.Code != nil && slices.Contains(codes, *d.Code))) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
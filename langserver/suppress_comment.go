@@ -0,0 +1,107 @@
+package langserver
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SuppressCommentTemplates are the comments suppressing a diagnostic of a
+// tool, offered as code actions. ${code} is replaced with the code of the
+// diagnostic.
+type SuppressCommentTemplates struct {
+	// Line is inserted above the line of the diagnostic, e.g.
+	// // eslint-disable-next-line ${code}.
+	Line string `yaml:"line" json:"line"`
+	// File is inserted at the top of the file, e.g.
+	// /* eslint-disable ${code} */.
+	File string `yaml:"file" json:"file"`
+}
+
+// diagnosticTool returns the tool of the data of a diagnostic, or "".
+func diagnosticTool(d Diagnostic) string {
+	var data diagnosticData
+	if len(d.Data) == 0 || json.Unmarshal(d.Data, &data) != nil {
+		return ""
+	}
+	return data.Tool
+}
+
+// suppressCodeActions offers the suppress-comment-templates of the tools of
+// the diagnostics of the request as code actions inserting them.
+func (h *langHandler) suppressCodeActions(uri DocumentURI, diagnostics []Diagnostic) []CodeAction {
+	f, ok := h.files[uri]
+	if !ok || len(diagnostics) == 0 {
+		return nil
+	}
+	var configs []Language
+	if cfgs, ok := h.configsFor(uri); ok {
+		configs = append(configs, cfgs...)
+	}
+	for _, cfg := range h.configs[wildcard] {
+		if !excludesLanguage(cfg, f.LanguageID) {
+			configs = append(configs, cfg)
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(f.Text, "\r\n", "\n"), "\n")
+	newline := lineEnding(f.Text)
+	seen := make(map[string]bool)
+	var actions []CodeAction
+	add := func(d Diagnostic, title string, pos Position, text string) {
+		key := fmt.Sprintf("%s:%d", title, pos.Line)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		actions = append(actions, CodeAction{
+			Title:       title,
+			Kind:        QuickFix,
+			Diagnostics: []Diagnostic{d},
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					uri: {{Range: Range{Start: pos, End: pos}, NewText: text + newline}},
+				},
+			},
+		})
+	}
+	for _, d := range diagnostics {
+		tool := diagnosticTool(d)
+		if tool == "" || d.Range.Start.Line >= len(lines) {
+			continue
+		}
+		code := ""
+		if d.Code != nil {
+			code = *d.Code
+		}
+		for _, cfg := range configs {
+			templates := cfg.SuppressCommentTemplates
+			if templates == nil || toolName(&cfg, nil) != tool {
+				continue
+			}
+			name := cmp.Or(code, tool)
+			// A template naming the code is no use for a diagnostic without
+			// one.
+			if t := templates.Line; t != "" && (code != "" || !strings.Contains(t, "${code}")) {
+				line := lines[d.Range.Start.Line]
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				add(d, fmt.Sprintf("Ignore %s for this line", name),
+					Position{Line: d.Range.Start.Line},
+					indent+strings.ReplaceAll(t, "${code}", code))
+			}
+			if t := templates.File; t != "" && (code != "" || !strings.Contains(t, "${code}")) {
+				// The comment goes below a shebang.
+				top := 0
+				if strings.HasPrefix(lines[0], "#!") {
+					top = 1
+				}
+				add(d, fmt.Sprintf("Ignore %s for this file", name),
+					Position{Line: top},
+					strings.ReplaceAll(t, "${code}", code))
+			}
+			break
+		}
+	}
+	return actions
+}
@@ -0,0 +1,63 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestSuppressCodeActions(t *testing.T) {
+	uri := DocumentURI("file:///foo.js")
+	h := &langHandler{
+		logger: log.New(io.Discard, "", 0),
+		configs: map[string][]Language{
+			"javascript": {{
+				LintCommand: "eslint",
+				SuppressCommentTemplates: &SuppressCommentTemplates{
+					Line: "// eslint-disable-next-line ${code}",
+					File: "/* eslint-disable ${code} */",
+				},
+			}},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "javascript", Text: "#!/usr/bin/env node\r\nif (x) {\r\n  var y = 1\r\n}\r\n"},
+		},
+	}
+
+	code := "no-var"
+	d := Diagnostic{
+		Range: Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 5}},
+		Code:  &code,
+		Data:  newDiagnosticData("eslint", &code, "", nil, nil),
+	}
+	other := Diagnostic{Range: d.Range, Code: &code, Data: newDiagnosticData("other", &code, "", nil, nil)}
+	actions := h.suppressCodeActions(uri, []Diagnostic{d, d, other})
+	if len(actions) != 2 {
+		t.Fatalf("expected the line and file actions of eslint once, got %+v", actions)
+	}
+
+	want := []struct {
+		title string
+		edit  TextEdit
+	}{
+		{"Ignore no-var for this line", TextEdit{Range: Range{Start: Position{Line: 2}, End: Position{Line: 2}}, NewText: "  // eslint-disable-next-line no-var\r\n"}},
+		{"Ignore no-var for this file", TextEdit{Range: Range{Start: Position{Line: 1}, End: Position{Line: 1}}, NewText: "/* eslint-disable no-var */\r\n"}},
+	}
+	for i, w := range want {
+		edits := actions[i].Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+		if actions[i].Title != w.title || len(edits) != 1 || edits[0] != w.edit {
+			t.Fatalf("expected %q inserting %+v, got %q with %+v", w.title, w.edit, actions[i].Title, edits)
+		}
+	}
+}
+
+func TestSuppressCodeActionsAdvertised(t *testing.T) {
+	config := NewConfig()
+	(*config.Languages)["javascript"] = []Language{{
+		LintCommand:              "eslint",
+		SuppressCommentTemplates: &SuppressCommentTemplates{Line: "// eslint-disable-next-line ${code}"},
+	}}
+	if result := initializeServer(t, config); result.Capabilities.CodeActionProvider == nil {
+		t.Fatalf("expected code actions to be provided for suppress-comment-templates: %+v", result.Capabilities)
+	}
+}
@@ -0,0 +1,36 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindSuppressions(t *testing.T) {
+	text := `x = eval(s)  # efm-ignore
+// efm-ignore-next-line no-console, no-debugger
+console.log(x)
+/* efm-ignore: E501 */
+the efm-ignored line
+`
+	got := findSuppressions(text, defaultSuppressionMarker)
+	want := suppressions{
+		0: nil,
+		2: {"no-console", "no-debugger"},
+		3: {"E501"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v but got %v", want, got)
+	}
+
+	code := func(s string) *string { return &s }
+	diagnostics := []Diagnostic{
+		{Range: Range{Start: Position{Line: 0}}, Message: "eval"},
+		{Range: Range{Start: Position{Line: 2}}, Code: code("no-console"), Message: "console"},
+		{Range: Range{Start: Position{Line: 2}}, Code: code("semi"), Message: "semi"},
+		{Range: Range{Start: Position{Line: 4}}, Message: "ignored"},
+	}
+	kept := got.filter(diagnostics)
+	if len(kept) != 2 || kept[0].Message != "semi" || kept[1].Message != "ignored" {
+		t.Fatalf("unexpected diagnostics: %+v", kept)
+	}
+}
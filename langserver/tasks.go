@@ -0,0 +1,282 @@
+package langserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// taskCommandPrefix starts the workspace/executeCommand ids of the tasks,
+// e.g. "efm-langserver.task.make.build".
+const taskCommandPrefix = "efm-langserver.task."
+
+// task is a target of a task runner found in the project root.
+type task struct {
+	runner string
+	name   string
+	file   string
+	line   int
+}
+
+func (t task) commandID() string {
+	return taskCommandPrefix + t.runner + "." + t.name
+}
+
+func (t task) commandLine(shell string) string {
+	if t.runner == "npm" {
+		return "npm run " + quoteArg(shell, t.name)
+	}
+	return t.runner + " " + quoteArg(shell, t.name)
+}
+
+var taskFiles = map[string]struct {
+	runner string
+	// languageID is the language id of the file, whose tools' shell and
+	// environment the tasks run with.
+	languageID string
+	parse      func(text string) map[string]int
+}{
+	"Makefile":     {"make", "make", parseMakefileTargets},
+	"makefile":     {"make", "make", parseMakefileTargets},
+	"GNUmakefile":  {"make", "make", parseMakefileTargets},
+	"justfile":     {"just", "just", parseJustfileRecipes},
+	"Justfile":     {"just", "just", parseJustfileRecipes},
+	".justfile":    {"just", "just", parseJustfileRecipes},
+	"package.json": {"npm", "json", parsePackageJSONScripts},
+}
+
+var (
+	makeTargetRe  = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_./-]*)\s*:([^=]|$)`)
+	justRecipeRe  = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)(\s[^:]*)?:([^=]|$)`)
+	jsonScriptsRe = regexp.MustCompile(`"scripts"\s*:`)
+)
+
+// parseMakefileTargets returns the explicit targets with the zero based line
+// they are defined on. Pattern rules and special targets are skipped.
+func parseMakefileTargets(text string) map[string]int {
+	targets := make(map[string]int)
+	for i, line := range strings.Split(text, "\n") {
+		if m := makeTargetRe.FindStringSubmatch(line); m != nil {
+			if _, ok := targets[m[1]]; !ok {
+				targets[m[1]] = i
+			}
+		}
+	}
+	return targets
+}
+
+func parseJustfileRecipes(text string) map[string]int {
+	recipes := make(map[string]int)
+	for i, line := range strings.Split(text, "\n") {
+		if m := justRecipeRe.FindStringSubmatch(line); m != nil {
+			if _, ok := recipes[m[1]]; !ok {
+				recipes[m[1]] = i
+			}
+		}
+	}
+	return recipes
+}
+
+func parsePackageJSONScripts(text string) map[string]int {
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal([]byte(text), &pkg); err != nil {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	start := 0
+	for i, line := range lines {
+		if jsonScriptsRe.MatchString(line) {
+			start = i
+			break
+		}
+	}
+	scripts := make(map[string]int)
+	for name := range pkg.Scripts {
+		scripts[name] = start
+		key, _ := json.Marshal(name)
+		for i := start; i < len(lines); i++ {
+			if strings.Contains(lines[i], string(key)) {
+				scripts[name] = i
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+// parseTasks returns the tasks defined in fname, whose content is text, or
+// nil if fname isn't a task runner file.
+func parseTasks(fname, text string) []task {
+	file, ok := taskFiles[filepath.Base(fname)]
+	if !ok {
+		return nil
+	}
+	var tasks []task
+	for name, line := range file.parse(text) {
+		tasks = append(tasks, task{runner: file.runner, name: name, file: fname, line: line})
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].line < tasks[j].line
+	})
+	return tasks
+}
+
+// discoverTasks scans root for task runner files.
+func discoverTasks(root string) []task {
+	if root == "" {
+		return nil
+	}
+	names := make([]string, 0, len(taskFiles))
+	for name := range taskFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tasks []task
+	for _, name := range names {
+		fname := filepath.Join(root, name)
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, parseTasks(fname, string(b))...)
+	}
+	return tasks
+}
+
+func (h *langHandler) taskCommandIDs() []string {
+	var ids []string
+	for _, t := range discoverTasks(h.rootPath) {
+		ids = append(ids, t.commandID())
+	}
+	return ids
+}
+
+// codeLens puts a "run" lens on every task definition of a task runner file.
+func (h *langHandler) codeLens(uri DocumentURI) ([]CodeLens, error) {
+	lenses := []CodeLens{}
+	if !h.taskRunners {
+		return lenses, nil
+	}
+	f, ok := h.files[uri]
+	if !ok {
+		return nil, fmt.Errorf("document not found: %v", uri)
+	}
+	fname, err := fromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range parseTasks(fname, f.Text) {
+		lenses = append(lenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: t.line, Character: 0},
+				End:   Position{Line: t.line, Character: 0},
+			},
+			Command: &Command{
+				Title:   "▶ " + t.runner + " " + t.name,
+				Command: t.commandID(),
+			},
+		})
+	}
+	return lenses, nil
+}
+
+// taskConfig returns the configuration t runs with, for its shell,
+// environment and the like: the one of the tool of the language of its file
+// running its task runner, e.g. with a command "make lint", or an empty one
+// if no tool does. The other tools, e.g. a linter of the file, have settings
+// of their own which the tasks don't share.
+func (h *langHandler) taskConfig(t task) *Language {
+	uri := toURI(t.file)
+	configs, ok := h.configsFor(uri)
+	if !ok {
+		configs = slices.Concat(h.configs[h.resolveLanguage(taskFiles[filepath.Base(t.file)].languageID)], h.globConfigs(uri))
+	}
+	for _, config := range configs {
+		if runsProgram(config, t.runner) {
+			return &config
+		}
+	}
+	return &Language{}
+}
+
+// runsProgram reports whether one of the commands of config runs program.
+func runsProgram(config Language, program string) bool {
+	commands := []string{config.LintCommand, config.FormatCommand, config.FixCommand, config.HoverCommand, config.CompletionCommand, config.SymbolCommand}
+	for _, c := range config.Commands {
+		commands = append(commands, c.Command)
+	}
+	for _, command := range commands {
+		if fields := strings.Fields(command); len(fields) > 0 && filepath.Base(strings.Trim(fields[0], `"'`)) == program {
+			return true
+		}
+	}
+	return false
+}
+
+// executeTask starts the task named by id in the background and streams its
+// output as progress.
+func (h *langHandler) executeTask(id string) (any, error) {
+	if !h.taskRunners {
+		return nil, fmt.Errorf("task-runners is not enabled")
+	}
+	var found *task
+	for _, t := range discoverTasks(h.rootPath) {
+		if t.commandID() == id {
+			found = &t
+			break
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("task not found: %v", strings.TrimPrefix(id, taskCommandPrefix))
+	}
+
+	config := h.taskConfig(*found)
+	command := found.commandLine(h.shellFor(config))
+	cmd, err := h.newCommand(context.Background(), config, filepath.Dir(found.file), command)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	if h.loglevel >= 1 {
+		h.logger.Printf("started task: %v", command)
+	}
+
+	go func() {
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+			pw.Close()
+		}()
+
+		p := h.beginProgress(command)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			p.report(scanner.Text())
+		}
+		// Keep draining so that the task never blocks on a full pipe.
+		_, _ = io.Copy(io.Discard, pr)
+		if err := <-done; err != nil {
+			p.end(fmt.Sprintf("%v failed: %v", command, err))
+			h.showMessage(LogError, fmt.Sprintf("task %v failed: %v", command, err))
+			return
+		}
+		p.end(command + " finished")
+	}()
+	return "OK", nil
+}
@@ -0,0 +1,75 @@
+package langserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseTasks(t *testing.T) {
+	makefile := ".PHONY: build\nVERSION := 1\nbuild: deps\n\tgo build\n%.o: %.c\ntest:\n"
+	if targets := parseMakefileTargets(makefile); !reflect.DeepEqual(targets, map[string]int{"build": 2, "test": 5}) {
+		t.Fatalf("unexpected make targets: %v", targets)
+	}
+
+	justfile := "set shell := [\"bash\", \"-c\"]\nalias b := build\n# comment\nbuild target=\"all\":\n  make\n@lint:\n"
+	if recipes := parseJustfileRecipes(justfile); !reflect.DeepEqual(recipes, map[string]int{"build": 3, "lint": 5}) {
+		t.Fatalf("unexpected just recipes: %v", recipes)
+	}
+
+	pkg := "{\n  \"name\": \"build\",\n  \"scripts\": {\n    \"build\": \"tsc\",\n    \"lint:fix\": \"eslint --fix .\"\n  }\n}\n"
+	if scripts := parsePackageJSONScripts(pkg); !reflect.DeepEqual(scripts, map[string]int{"build": 3, "lint:fix": 4}) {
+		t.Fatalf("unexpected package.json scripts: %v", scripts)
+	}
+}
+
+func TestTaskConfig(t *testing.T) {
+	h := &langHandler{
+		wsl: true,
+		configs: map[string][]Language{
+			"make": {
+				{LintCommand: "checkmake", Shell: "zsh", Env: []string{"BAR=1"}},
+				{Commands: []Command{{Title: "Lint", Command: "make lint"}}, Shell: "bash", Env: []string{"FOO=1"}},
+			},
+			"json": {{FormatCommand: "prettier --parser json", Shell: "zsh"}},
+		},
+		files: map[DocumentURI]*File{},
+	}
+
+	// The task runs with the tool running make, not the linter of the file.
+	config := h.taskConfig(task{runner: "make", name: "build", file: "/project/Makefile"})
+	if config.Shell != "bash" || !reflect.DeepEqual(config.Env, []string{"FOO=1"}) {
+		t.Fatalf("expected the config of the tool running make, got %#v", config)
+	}
+	if got := h.shellFor(config); got != "bash" {
+		t.Fatalf("expected the shell of make, got %q", got)
+	}
+	if config := h.taskConfig(task{runner: "npm", name: "build", file: "/project/package.json"}); config.Shell != "" {
+		t.Fatalf("expected no config of the other tools of package.json, got %#v", config)
+	}
+
+	// Without tools, the task still runs with a config, e.g. in WSL.
+	config = h.taskConfig(task{runner: "just", name: "build", file: "/project/justfile"})
+	if config == nil {
+		t.Fatal("expected an empty config")
+	}
+	if _, err := h.newCommand(context.Background(), config, "/project", "just build"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecuteTaskDisabled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "Makefile"), []byte("build:\n\ttouch built\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := &langHandler{rootPath: root}
+	if _, err := h.executeTask(taskCommandPrefix + "make.build"); err == nil {
+		t.Fatal("expected the tasks not to run without task-runners")
+	}
+	if _, err := os.Stat(filepath.Join(root, "built")); err == nil {
+		t.Fatal("expected the task not to run")
+	}
+}
@@ -0,0 +1,109 @@
+package langserver
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeader matches the header of a hunk of a unified diff, the start and
+// count of its old and new lines.
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// unifiedDiffEdits returns the edits applying a unified diff, e.g. of git
+// diff or diff -u, to the files it changes, whose relative paths are the ones
+// under root. Each hunk replaces its old lines.
+func unifiedDiffEdits(diff, root string) (map[DocumentURI][]TextEdit, error) {
+	changes := make(map[DocumentURI][]TextEdit)
+	lines := strings.SplitAfter(diff, "\n")
+	var uri DocumentURI
+	gitPaths := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			gitPaths = strings.HasPrefix(line, "--- a/")
+		case strings.HasPrefix(line, "+++ "):
+			path, _, _ := strings.Cut(line[len("+++ "):], "\t")
+			if path == "/dev/null" {
+				return nil, fmt.Errorf("deleting files is not supported: %v", lines[i-1])
+			}
+			if gitPaths {
+				path = strings.TrimPrefix(path, "b/")
+			}
+			path = filepath.FromSlash(path)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(root, path)
+			}
+			uri = toURI(path)
+		case strings.HasPrefix(line, "@@ "):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil || uri == "" {
+				return nil, fmt.Errorf("invalid hunk: %v", line)
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			oldCount, newCount := 1, 1
+			if m[2] != "" {
+				oldCount, _ = strconv.Atoi(m[2])
+			}
+			if m[4] != "" {
+				newCount, _ = strconv.Atoi(m[4])
+			}
+			// A hunk without old lines inserts after its start line.
+			start := oldStart - 1
+			if oldCount == 0 {
+				start = oldStart
+			}
+
+			var newText strings.Builder
+			var last byte
+			oldSeen, newSeen := 0, 0
+			for i+1 < len(lines) && lines[i+1] != "" {
+				body := lines[i+1]
+				kind := body[0]
+				if (oldSeen >= oldCount && newSeen >= newCount) && kind != '\\' {
+					break
+				}
+				i++
+				switch kind {
+				case ' ', '\n', '\r':
+					// Some tools strip the space of empty context lines.
+					newText.WriteString(strings.TrimPrefix(body, " "))
+					oldSeen++
+					newSeen++
+				case '-':
+					oldSeen++
+				case '+':
+					newText.WriteString(body[1:])
+					newSeen++
+				case '\\':
+					// "\ No newline at end of file" follows the last line
+					// of the old or the new file when it has no newline.
+					if last != '-' {
+						text := strings.TrimSuffix(strings.TrimSuffix(newText.String(), "\n"), "\r")
+						newText.Reset()
+						newText.WriteString(text)
+					}
+					continue
+				default:
+					return nil, fmt.Errorf("invalid hunk line: %v", body)
+				}
+				last = kind
+			}
+			text := newText.String()
+			changes[uri] = append(changes[uri], TextEdit{
+				Range: Range{
+					Start: Position{Line: start},
+					End:   Position{Line: start + oldCount},
+				},
+				NewText: text,
+			})
+		}
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no hunk in diff")
+	}
+	return changes, nil
+}
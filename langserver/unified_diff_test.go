@@ -0,0 +1,50 @@
+package langserver
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUnifiedDiffEdits(t *testing.T) {
+	root, _ := filepath.Abs("testdata")
+	diff := `diff --git a/foo.py b/foo.py
+--- a/foo.py
++++ b/foo.py
+@@ -1,3 +1,3 @@
+-import sys
+ import os
++import sys
+
+@@ -10,0 +11,2 @@
++x = 1
++y = 2
+--- bar.txt	2024-01-01 00:00:00
++++ bar.txt	2024-01-02 00:00:00
+@@ -2 +2 @@
+-old
+\ No newline at end of file
++new
+\ No newline at end of file
+`
+	changes, err := unifiedDiffEdits(diff, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[DocumentURI][]TextEdit{
+		toURI(filepath.Join(root, "foo.py")): {
+			{Range: Range{Start: Position{Line: 0}, End: Position{Line: 3}}, NewText: "import os\nimport sys\n\n"},
+			{Range: Range{Start: Position{Line: 10}, End: Position{Line: 10}}, NewText: "x = 1\ny = 2\n"},
+		},
+		toURI(filepath.Join(root, "bar.txt")): {
+			{Range: Range{Start: Position{Line: 1}, End: Position{Line: 2}}, NewText: "new"},
+		},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("want %+v but got %+v", want, changes)
+	}
+
+	if _, err := unifiedDiffEdits("not a diff\n", root); err == nil {
+		t.Fatal("expected an error without hunks")
+	}
+}
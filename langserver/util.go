@@ -28,3 +28,51 @@ func convertRowColToIndex(s string, row, col int) int {
 
 	return index
 }
+
+// textInRange returns the part of s within rng, whose characters count
+// runes like the diagnostics do.
+func textInRange(s string, rng Range) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	for i := rng.Start.Line; i <= rng.End.Line && i < len(lines); i++ {
+		if i < 0 {
+			continue
+		}
+		line := []rune(lines[i])
+		start, end := 0, len(line)
+		if i == rng.Start.Line {
+			start = min(max(rng.Start.Character, 0), len(line))
+		}
+		if i == rng.End.Line {
+			end = min(max(rng.End.Character, start), len(line))
+		}
+		b.WriteString(string(line[start:end]))
+	}
+	return b.String()
+}
+
+// lineEnding returns the line ending of most lines of text, "\r\n" or "\n".
+func lineEnding(text string) string {
+	crlf := strings.Count(text, "\r\n")
+	if crlf > strings.Count(text, "\n")-crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// restoreLineEndings returns text, whose lines end with "\n", with the line
+// ending and the final newline, or its absence, of original, so that
+// formatting a document with CRLF line endings doesn't change all its lines.
+func restoreLineEndings(original, text string) string {
+	if strings.HasSuffix(original, "\n") {
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+	} else {
+		text = strings.TrimSuffix(text, "\n")
+	}
+	if lineEnding(original) == "\r\n" {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	return text
+}
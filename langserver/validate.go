@@ -0,0 +1,364 @@
+package langserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/itchyny/gojq"
+	"github.com/reviewdog/errorformat"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigProblem is a mistake found in a config file. Line is 0 if the
+// position is unknown.
+type ConfigProblem struct {
+	Line    int
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+var yamlLineRe = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// ValidateConfig checks the config file fname for unknown keys, invalid
+// lint-formats, symbol-formats and lint-jq programs and options which have
+// no effect together. An error is returned if the file can't be read at
+// all.
+func ValidateConfig(fname string) ([]ConfigProblem, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	b, err = configToYAML(fname, b)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateConfigData(b)
+}
+
+// ValidateConfigData is like ValidateConfig for the YAML or JSON b.
+func ValidateConfigData(b []byte) ([]ConfigProblem, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+
+	var version struct {
+		Version int `yaml:"version"`
+	}
+	_ = yaml.Unmarshal(b, &version)
+
+	var problems []ConfigProblem
+	var languages map[string][]Language
+	if version.Version == 2 {
+		var config Config
+		problems = append(problems, decodeStrict(b, &config)...)
+	} else {
+		var config struct {
+			Config1 `yaml:",inline"`
+			Tools   any `yaml:"tools"`
+		}
+		problems = append(problems, decodeStrict(b, &config)...)
+	}
+
+	// The remaining checks need the tools with extends resolved.
+	if err := resolveExtends(&root); err != nil {
+		return append(problems, yamlProblem(err.Error())), nil
+	}
+	if version.Version == 2 {
+		var config Config
+		if root.Decode(&config) == nil && config.Languages != nil {
+			languages = *config.Languages
+		}
+	} else {
+		var config Config1
+		_ = root.Decode(&config)
+		languages = make(map[string][]Language)
+		for lang, cfg := range config.Languages {
+			languages[lang] = []Language{cfg}
+		}
+	}
+
+	lines := languageLines(&root)
+	langs := make([]string, 0, len(languages))
+	for lang := range languages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		for i, cfg := range languages[lang] {
+			line := 0
+			if i < len(lines[lang]) {
+				line = lines[lang][i]
+			}
+			for _, message := range validateLanguage(cfg) {
+				problems = append(problems, ConfigProblem{Line: line, Message: fmt.Sprintf("languages.%s[%d]: %s", lang, i, message)})
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	// Aliases of the same anchor report the same problem again.
+	var unique []ConfigProblem
+	for i, p := range problems {
+		if i == 0 || p != problems[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique, nil
+}
+
+// decodeStrict decodes b into v, reporting the unknown keys and the values
+// of the wrong type.
+func decodeStrict(b []byte, v any) []ConfigProblem {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var problems []ConfigProblem
+	err := dec.Decode(v)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			problems = append(problems, yamlProblem(e))
+		}
+	} else if err != nil && err != io.EOF {
+		problems = append(problems, yamlProblem(err.Error()))
+	}
+	return problems
+}
+
+func yamlProblem(s string) ConfigProblem {
+	if m := yamlLineRe.FindStringSubmatch(s); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return ConfigProblem{Line: line, Message: m[2]}
+	}
+	return ConfigProblem{Message: s}
+}
+
+// languageLines returns the line of every tool entry of the languages.
+func languageLines(root *yaml.Node) map[string][]int {
+	lines := make(map[string][]int)
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return lines
+	}
+	languages := mappingValue(root.Content[0], "languages")
+	if languages == nil || languages.Kind != yaml.MappingNode {
+		return lines
+	}
+	for i := 0; i+1 < len(languages.Content); i += 2 {
+		lang, value := languages.Content[i].Value, languages.Content[i+1]
+		if value.Kind == yaml.SequenceNode {
+			for _, item := range value.Content {
+				lines[lang] = append(lines[lang], item.Line)
+			}
+		} else {
+			lines[lang] = append(lines[lang], value.Line)
+		}
+	}
+	return lines
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// compileErrorformat reports whether formats compile. errorformat panics on
+// some malformed formats, e.g. a trailing %.
+func compileErrorformat(formats []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	_, err = errorformat.NewErrorformat(formats)
+	return err
+}
+
+// validateLanguage returns the mistakes of a tool entry.
+func validateLanguage(cfg Language) []string {
+	var messages []string
+	if len(cfg.LintFormats) > 0 {
+		if err := compileErrorformat(cfg.LintFormats); err != nil {
+			messages = append(messages, fmt.Sprintf("invalid lint-formats: %v", err))
+		}
+	}
+	if len(cfg.FormatLintFormats) > 0 {
+		if err := compileErrorformat(cfg.FormatLintFormats); err != nil {
+			messages = append(messages, fmt.Sprintf("invalid format-lint-formats: %v", err))
+		}
+	}
+	if len(cfg.SymbolFormats) > 0 {
+		if err := compileErrorformat(cfg.SymbolFormats); err != nil {
+			messages = append(messages, fmt.Sprintf("invalid symbol-formats: %v", err))
+		}
+	}
+	if cfg.LintPattern != "" {
+		if re, err := regexp.Compile(cfg.LintPattern); err != nil {
+			messages = append(messages, fmt.Sprintf("invalid lint-pattern: %v", err))
+		} else if re.SubexpIndex("message") < 0 {
+			messages = append(messages, "lint-pattern has no message group")
+		}
+		if len(cfg.LintFormats) > 0 {
+			messages = append(messages, "lint-formats has no effect with lint-pattern")
+		}
+	}
+	codes := make([]string, 0, len(cfg.LintSeverityMap))
+	for code := range cfg.LintSeverityMap {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if _, ok := severityNamed(cfg.LintSeverityMap[code]); !ok {
+			messages = append(messages, fmt.Sprintf("lint-severity-map: unknown severity %q of %s", cfg.LintSeverityMap[code], code))
+		}
+		if _, err := path.Match(code, ""); err != nil {
+			messages = append(messages, fmt.Sprintf("lint-severity-map: invalid glob %q: %v", code, err))
+		}
+	}
+	if cfg.LintJQ != "" {
+		query, err := gojq.Parse(cfg.LintJQ)
+		if err == nil {
+			_, err = gojq.Compile(query)
+		}
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("invalid lint-jq: %v", err))
+		}
+	}
+	if cfg.FixJQ != "" {
+		query, err := gojq.Parse(cfg.FixJQ)
+		if err == nil {
+			_, err = gojq.Compile(query)
+		}
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("invalid fix-jq: %v", err))
+		}
+	}
+	if cfg.CompletionJQ != "" {
+		query, err := gojq.Parse(cfg.CompletionJQ)
+		if err == nil {
+			_, err = gojq.Compile(query)
+		}
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("invalid completion-jq: %v", err))
+		}
+	}
+
+	requires := []struct {
+		set     bool
+		option  string
+		command string
+		present bool
+	}{
+		{len(cfg.LintFormats) > 0, "lint-formats", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintJQ != "", "lint-jq", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintStdin, "lint-stdin", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintJQFields != nil, "lint-jq-fields", "lint-jq", cfg.LintJQ != ""},
+		{cfg.LintPattern != "", "lint-pattern", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintRelatedInformation, "lint-related-information", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintCodeURL != "", "lint-code-url", "lint-command", cfg.LintCommand != ""},
+		{len(cfg.LintSeverityMap) > 0, "lint-severity-map", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintColumnEncoding != "", "lint-column-encoding", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintCommandOnSave != "", "lint-command-on-save", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintCommandOnChange != "", "lint-command-on-change", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintOnSave, "lint-on-save", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintAfterOpen, "lint-after-open", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintWorkspace, "lint-workspace", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintTimeout > 0, "lint-timeout", "lint-command", cfg.LintCommand != ""},
+		{cfg.LintServer, "lint-server", "lint-command", cfg.LintCommand != ""},
+		{len(cfg.LintWatch) > 0, "lint-watch", "lint-command", cfg.LintCommand != ""},
+		{len(cfg.LintFiles) > 0, "lint-files", "lint-workspace", cfg.LintWorkspace},
+		{len(cfg.LintFilesExclude) > 0, "lint-files-exclude", "lint-workspace", cfg.LintWorkspace},
+		{cfg.LintMaxCommandLength > 0, "lint-max-command-length", "lint-workspace", cfg.LintWorkspace},
+		{cfg.FormatStdin, "format-stdin", "format-command", cfg.FormatCommand != ""},
+		{cfg.FormatCanRange, "format-can-range", "format-command", cfg.FormatCommand != ""},
+		{cfg.FormatInplace, "format-inplace", "format-command", cfg.FormatCommand != ""},
+		{cfg.FormatPriority != 0, "format-priority", "format-command", cfg.FormatCommand != ""},
+		{len(cfg.FormatExclude) > 0, "format-exclude", "format-command", cfg.FormatCommand != ""},
+		{len(cfg.FormatLintFormats) > 0, "format-lint-formats", "format-command", cfg.FormatCommand != ""},
+		{cfg.FixStdin, "fix-stdin", "fix-command", cfg.FixCommand != ""},
+		{cfg.FixJQ != "", "fix-jq", "fix-command", cfg.FixCommand != ""},
+		{cfg.SuppressCommentTemplates != nil, "suppress-comment-templates", "lint-command", cfg.LintCommand != ""},
+		{cfg.SymbolStdin, "symbol-stdin", "symbol-command", cfg.SymbolCommand != ""},
+		{len(cfg.SymbolFormats) > 0, "symbol-formats", "symbol-command", cfg.SymbolCommand != ""},
+		{cfg.CompletionStdin, "completion-stdin", "completion-command", cfg.CompletionCommand != ""},
+		{cfg.CompletionFormat != "", "completion-format", "completion-command", cfg.CompletionCommand != ""},
+		{cfg.CompletionJQ != "", "completion-jq", "completion-command", cfg.CompletionCommand != ""},
+		{cfg.HoverStdin, "hover-stdin", "hover-command", cfg.HoverCommand != ""},
+		{cfg.RequireMarker, "require-marker", "root-markers", len(cfg.RootMarkers) > 0 || len(cfg.RootMarkerRules) > 0},
+	}
+	for _, r := range requires {
+		if r.set && !r.present {
+			messages = append(messages, fmt.Sprintf("%s has no effect without %s", r.option, r.command))
+		}
+	}
+
+	if cfg.FormatInplace && cfg.FormatStdin {
+		messages = append(messages, "format-inplace and format-stdin conflict")
+	}
+	switch cfg.LintFormatType {
+	case "", lintFormatTypeErrorformat:
+	case lintFormatTypeSARIF, lintFormatTypeLSP:
+		if len(cfg.LintFormats) > 0 || cfg.LintJQ != "" || cfg.LintPattern != "" {
+			messages = append(messages, fmt.Sprintf("lint-formats, lint-jq and lint-pattern have no effect with lint-format-type %s", cfg.LintFormatType))
+		}
+	default:
+		messages = append(messages, fmt.Sprintf("unknown lint-format-type %q", cfg.LintFormatType))
+	}
+	switch cfg.LintColumnEncoding {
+	case "", columnEncodingBytes, columnEncodingUTF8, columnEncodingUTF16:
+	default:
+		messages = append(messages, fmt.Sprintf("unknown lint-column-encoding %q", cfg.LintColumnEncoding))
+	}
+	switch cfg.CompletionFormat {
+	case "", completionFormatLines, completionFormatJSON:
+	default:
+		messages = append(messages, fmt.Sprintf("unknown completion-format %q", cfg.CompletionFormat))
+	}
+	if cfg.LintServer && cfg.LintWorkspace {
+		messages = append(messages, "lint-server and lint-workspace conflict")
+	}
+	globs := append(cfg.LintFiles[:len(cfg.LintFiles):len(cfg.LintFiles)], cfg.LintFilesExclude...)
+	globs = append(globs, cfg.FormatExclude...)
+	for _, pattern := range append(globs, cfg.LintWatch...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			messages = append(messages, fmt.Sprintf("invalid glob %q: %v", pattern, err))
+		}
+	}
+	var runners []string
+	if cfg.WSL {
+		runners = append(runners, "wsl")
+	}
+	if cfg.Container != nil {
+		runners = append(runners, "run-in-container")
+	}
+	if cfg.Remote != nil {
+		runners = append(runners, "remote")
+	}
+	if len(runners) > 1 {
+		messages = append(messages, fmt.Sprintf("only one of %v can be used", runners))
+	}
+	return messages
+}
@@ -0,0 +1,46 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	config := `version: 2
+tools:
+  vet: &vet
+    lint-command: go vet
+languages:
+  go:
+    - <<: *vet
+    - lint-comand: golint
+      lint-formats: ['%f:%l:%c: %m']
+    - format-command: gofmt
+      lint-jq: '.[] | foo('
+`
+	fname := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(fname, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := ValidateConfig(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ConfigProblem{
+		{Line: 8, Message: "field lint-comand not found in type langserver.Language"},
+		{Line: 8, Message: "languages.go[1]: lint-formats has no effect without lint-command"},
+		{Line: 10, Message: "languages.go[2]: invalid lint-jq"},
+		{Line: 10, Message: "languages.go[2]: lint-jq has no effect without lint-command"},
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %v but got: %v", expected, problems)
+	}
+	for i, p := range problems {
+		if p.Line != expected[i].Line || !strings.HasPrefix(p.Message, expected[i].Message) {
+			t.Fatalf("expected %v but got: %v", expected[i], p)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package langserver
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// gitHeadContent returns the content of fname as committed to HEAD. ok is
+// false if fname isn't tracked by git.
+func gitHeadContent(fname string) (string, bool) {
+	cmd := exec.Command("git", "show", "HEAD:./"+filepath.Base(fname))
+	cmd.Dir = filepath.Dir(fname)
+	b, err := cmd.Output()
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// gitHeadCache caches the content of the files as committed to HEAD, so that
+// git doesn't run on every lint, until HEAD moves.
+type gitHeadCache struct {
+	mu      sync.Mutex
+	entries map[string]gitHeadEntry
+}
+
+type gitHeadEntry struct {
+	head    string
+	content string
+	ok      bool
+}
+
+// content is gitHeadContent, cached while the commit of HEAD is the same.
+func (c *gitHeadCache) content(fname string) (string, bool) {
+	head := gitHead(filepath.Dir(fname))
+	if head == "" {
+		return gitHeadContent(fname)
+	}
+	c.mu.Lock()
+	e, found := c.entries[fname]
+	c.mu.Unlock()
+	if found && e.head == head {
+		return e.content, e.ok
+	}
+
+	content, ok := gitHeadContent(fname)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]gitHeadEntry)
+	}
+	c.entries[fname] = gitHeadEntry{head: head, content: content, ok: ok}
+	return content, ok
+}
+
+// gitHead returns the commit HEAD points to in the repository of dir, read
+// from the files of .git, or "" if it can't be found.
+func gitHead(dir string) string {
+	gitDir := ""
+	for d := dir; ; d = filepath.Dir(d) {
+		candidate := filepath.Join(d, ".git")
+		if fi, err := os.Stat(candidate); err == nil {
+			gitDir = candidate
+			if !fi.IsDir() {
+				// A worktree or a submodule points to its git dir.
+				b, err := os.ReadFile(candidate)
+				path, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir: ")
+				if err != nil || !ok {
+					return ""
+				}
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(d, path)
+				}
+				gitDir = path
+			}
+			break
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "ref: ")
+	if !ok {
+		// A detached HEAD is the commit itself.
+		return strings.TrimSpace(string(b))
+	}
+	// The refs of a worktree are in the common dir of the repository.
+	dirs := []string{gitDir}
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(b))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		dirs = append(dirs, common)
+	}
+	for _, d := range dirs {
+		if b, err := os.ReadFile(filepath.Join(d, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(b))
+		}
+	}
+	for _, d := range dirs {
+		b, err := os.ReadFile(filepath.Join(d, "packed-refs"))
+		if err != nil {
+			continue
+		}
+		for _, line := range bytes.Split(b, []byte("\n")) {
+			if commit, name, ok := strings.Cut(strings.TrimSpace(string(line)), " "); ok && name == ref {
+				return commit
+			}
+		}
+	}
+	return ""
+}
+
+// changedLines returns the zero based lines of after which are added or
+// modified compared to before. A line following removed lines counts as
+// changed too.
+func changedLines(before, after string) map[int]bool {
+	before = strings.Replace(before, "\r", "", -1)
+	after = strings.Replace(after, "\r", "", -1)
+	changed := make(map[int]bool)
+	for _, op := range operations(splitLines(before), splitLines(after)) {
+		switch op.Kind {
+		case Insert:
+			for i := range op.Content {
+				changed[op.J1+i] = true
+			}
+		case Delete:
+			changed[op.J1] = true
+		}
+	}
+	return changed
+}
+
+// filterChangedLines drops the diagnostics which don't touch any changed
+// line.
+func filterChangedLines(diagnostics []Diagnostic, changed map[int]bool) []Diagnostic {
+	results := []Diagnostic{}
+	for _, d := range diagnostics {
+		for line := d.Range.Start.Line; line <= d.Range.End.Line; line++ {
+			if changed[line] {
+				results = append(results, d)
+				break
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,79 @@
+package langserver
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChangedLines(t *testing.T) {
+	before := "a\nb\nc\nd\n"
+	after := "a\nB\nc\nnew\nd\n"
+	expected := map[int]bool{1: true, 3: true}
+	if changed := changedLines(before, after); !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("changed lines should be %v but got: %v", expected, changed)
+	}
+
+	diagnostics := []Diagnostic{
+		{Range: Range{Start: Position{Line: 0}, End: Position{Line: 0}}, Message: "old"},
+		{Range: Range{Start: Position{Line: 2}, End: Position{Line: 3}}, Message: "spans"},
+		{Range: Range{Start: Position{Line: 4}, End: Position{Line: 4}}, Message: "old too"},
+	}
+	filtered := filterChangedLines(diagnostics, expected)
+	if len(filtered) != 1 || filtered[0].Message != "spans" {
+		t.Fatalf("only the diagnostic touching a changed line should be kept but got: %v", filtered)
+	}
+}
+
+func TestGitHeadCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "foo")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	commit := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "foo")
+		git("commit", "-q", "-m", content)
+	}
+	git("init", "-q")
+	commit("first\n")
+
+	var cache gitHeadCache
+	if content, ok := cache.content(fname); !ok || content != "first\n" {
+		t.Fatalf("the content at HEAD should be read but got: %q, %v", content, ok)
+	}
+	head := gitHead(dir)
+	if head == "" || cache.entries[fname].head != head {
+		t.Fatalf("the content should be cached for HEAD %q but got: %v", head, cache.entries)
+	}
+
+	// A cached entry is used while HEAD is the same.
+	cache.entries[fname] = gitHeadEntry{head: head, content: "cached", ok: true}
+	if content, _ := cache.content(fname); content != "cached" {
+		t.Fatalf("the cached content should be used but got: %q", content)
+	}
+
+	commit("second\n")
+	if content, ok := cache.content(fname); !ok || content != "second\n" {
+		t.Fatalf("the content should be read again once HEAD moves but got: %q, %v", content, ok)
+	}
+
+	git("pack-refs", "--all")
+	if packed := gitHead(dir); packed == "" || packed == head {
+		t.Fatalf("HEAD should be read from the packed refs but got: %q", packed)
+	}
+}
@@ -0,0 +1,68 @@
+package langserver
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// useWSL reports whether the commands of config run inside WSL.
+func (h *langHandler) useWSL(config *Language) bool {
+	return h.wsl || (config != nil && config.WSL)
+}
+
+var (
+	windowsDrivePathRe = regexp.MustCompile(`\b([A-Za-z]):[\\/][^\s"'|&;<>]*`)
+	wslMountPathRe     = regexp.MustCompile(`^/mnt/([a-z])(/.*)?$`)
+)
+
+// wslPaths translates Windows drive paths to the /mnt/<drive> paths WSL
+// exposes them as.
+type wslPaths struct{}
+
+func (wslPaths) toRemote(s string) string {
+	return windowsDrivePathRe.ReplaceAllStringFunc(s, func(path string) string {
+		return "/mnt/" + strings.ToLower(path[:1]) + "/" + strings.Replace(path[3:], `\`, "/", -1)
+	})
+}
+
+func (wslPaths) toLocal(path string) string {
+	m := wslMountPathRe.FindStringSubmatch(path)
+	if m == nil {
+		return path
+	}
+	rest := m[2]
+	if rest == "" {
+		rest = "/"
+	}
+	return strings.ToUpper(m[1]) + ":" + rest
+}
+
+// wslCommand runs command with the Linux shell of the default WSL
+// distribution.
+func (h *langHandler) wslCommand(ctx context.Context, config *Language, dir, command string, args ...string) (*exec.Cmd, error) {
+	var t wslPaths
+	// The arguments of the caller are left as is.
+	remoteArgs := make([]string, len(args))
+	for i, arg := range args {
+		remoteArgs[i] = t.toRemote(arg)
+	}
+	inner, err := shellCommand(ctx, h.shellFor(config), t.toRemote(command), remoteArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Variables of the Windows environment aren't visible inside WSL
+	// unless listed in WSLENV, so pass them explicitly.
+	wslArgs := []string{"--cd", t.toRemote(filepath.ToSlash(dir)), "--exec", "env"}
+	if config != nil {
+		wslArgs = append(wslArgs, config.Env...)
+	}
+	cmd := exec.CommandContext(ctx, "wsl.exe", append(wslArgs, inner.Args...)...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
@@ -6,11 +6,18 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 
+	"github.com/gorilla/websocket"
 	"github.com/sourcegraph/jsonrpc2"
+	jsonrpc2ws "github.com/sourcegraph/jsonrpc2/websocket"
 	"gopkg.in/yaml.v3"
 
 	"github.com/tecfu/efm-langserver/langserver"
@@ -28,23 +35,72 @@ func main() {
 	var logfile string
 	var loglevel int
 	var dump bool
+	var validate bool
+	var resolve string
+	var languageID string
+	var profile string
 	var showVersion bool
 	var quiet bool
+	var wsPort int
+	var wsOrigins string
+	var stdio bool
+	var socketPort int
+	var pipeName string
+	var nodeIPC bool
+	var clientProcessID int
 
-	flag.StringVar(&yamlfile, "c", "", "path to config.yaml")
+	flag.StringVar(&yamlfile, "c", "", "path to config.yaml, config.json or config.toml, or - to read YAML from stdin")
 	flag.StringVar(&logfile, "logfile", "", "logfile")
 	flag.IntVar(&loglevel, "loglevel", 1, "loglevel")
 	flag.BoolVar(&dump, "d", false, "dump configuration")
+	flag.BoolVar(&validate, "validate-config", false, "check the configuration for mistakes and exit")
+	flag.StringVar(&resolve, "resolve", "", "print the language, root and tools which apply to `FILE` and exit")
+	flag.StringVar(&languageID, "language-id", "", "language id of the -resolve file, guessed from its extension and shebang by default")
+	flag.StringVar(&profile, "profile", "", "profile used by -resolve and -d")
 	flag.BoolVar(&showVersion, "v", false, "Print the version")
 	flag.BoolVar(&quiet, "q", false, "Run quieter")
+	flag.IntVar(&wsPort, "ws-port", 0, "serve WebSocket connections on localhost:PORT instead of stdio")
+	flag.StringVar(&wsOrigins, "ws-origins", "", "comma separated hosts allowed to open WebSocket connections from a browser")
+	flag.BoolVar(&stdio, "stdio", false, "communicate on stdin/stdout (default)")
+	flag.IntVar(&socketPort, "socket", 0, "connect to the client listening on localhost:PORT")
+	flag.StringVar(&pipeName, "pipe", "", "connect to the client listening on the unix socket or Windows named pipe NAME")
+	flag.BoolVar(&nodeIPC, "node-ipc", false, "unsupported, accepted for compatibility with vscode-languageclient")
+	flag.IntVar(&clientProcessID, "clientProcessId", 0, "process id of the client, the server exits once it is gone")
 	flag.Parse()
 
+	transport := transport{
+		stdio:      stdio,
+		socketPort: socketPort,
+		pipeName:   pipeName,
+		wsPort:     wsPort,
+		nodeIPC:    nodeIPC,
+	}
+	if err := transport.check(); err != nil {
+		log.Fatalf("efm-langserver: %v", err)
+	}
+
 	if showVersion {
 		fmt.Printf("%s %s (rev: %s/%s)\n", name, version, revision, runtime.Version())
 		return
 	}
 
-	if yamlfile == "" {
+	// configData is the YAML given on stdin or in EFM_CONFIG instead of a
+	// config file.
+	var configData []byte
+	configSource := yamlfile
+	if yamlfile == "-" {
+		if wsPort == 0 && socketPort == 0 && pipeName == "" && !validate && !dump {
+			log.Fatal("efm-langserver: -c - needs --socket, --pipe or --ws-port, stdin is read for the config")
+		}
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		configData = b
+	} else if env := os.Getenv("EFM_CONFIG"); yamlfile == "" && env != "" {
+		configData = []byte(env)
+		configSource = "EFM_CONFIG"
+	} else if yamlfile == "" {
 		var configHome string
 		if runtime.GOOS == "windows" {
 			configHome = os.Getenv("APPDATA")
@@ -60,7 +116,14 @@ func main() {
 			log.Fatal(err)
 		}
 
-		yamlfile = filepath.Join(dir, "config.yaml")
+		yamlfile = filepath.Join(dir, langserver.ConfigNames[0])
+		for _, name := range langserver.ConfigNames {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				yamlfile = filepath.Join(dir, name)
+				break
+			}
+		}
+		configSource = yamlfile
 	} else {
 		_, err := os.Stat(yamlfile)
 		if err != nil {
@@ -68,17 +131,75 @@ func main() {
 		}
 	}
 
-	config, err := langserver.LoadConfig(yamlfile)
+	if validate {
+		var problems []langserver.ConfigProblem
+		var err error
+		if configData != nil {
+			problems, err = langserver.ValidateConfigData(configData)
+		} else {
+			problems, err = langserver.ValidateConfig(yamlfile)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range problems {
+			if p.Line > 0 {
+				fmt.Printf("%s:%d: %s\n", configSource, p.Line, p.Message)
+			} else {
+				fmt.Printf("%s: %s\n", configSource, p.Message)
+			}
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var config *langserver.Config
+	var err error
+	if configData != nil {
+		config, err = langserver.ParseConfig(configData)
+	} else {
+		config, err = langserver.LoadConfig(yamlfile)
+	}
 	if err != nil {
-		log.Printf("Failed to load config from %s: %v", yamlfile, err)
+		log.Printf("Failed to load config from %s: %v", configSource, err)
 		log.Fatal(err)
 	}
 
 	if dump {
-		err = yaml.NewEncoder(os.Stdout).Encode(&config)
+		config.Logger = log.New(io.Discard, "", 0)
+		root, err := os.Getwd()
 		if err != nil {
 			log.Fatal(err)
 		}
+		dumped, err := langserver.DumpConfig(config, langserver.ResolveOptions{Root: root, Profile: profile})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := yaml.NewEncoder(os.Stdout).Encode(dumped); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if resolve != "" {
+		config.Logger = log.New(io.Discard, "", 0)
+		root, err := os.Getwd()
+		if err != nil {
+			log.Fatal(err)
+		}
+		r, err := langserver.Resolve(config, resolve, langserver.ResolveOptions{
+			Root:       root,
+			LanguageID: languageID,
+			Profile:    profile,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := yaml.NewEncoder(os.Stdout).Encode(r); err != nil {
+			log.Fatal(err)
+		}
 		os.Exit(0)
 	}
 
@@ -91,8 +212,6 @@ func main() {
 		log.SetOutput(io.Discard)
 	}
 
-	log.Println("efm-langserver: reading on stdin, writing on stdout")
-
 	if logfile == "" {
 		logfile = config.LogFile
 	}
@@ -118,15 +237,69 @@ func main() {
 		connOpt = append(connOpt, jsonrpc2.LogMessages(log.New(io.Discard, "", 0)))
 	}
 
-	handler := langserver.NewHandler(config)
-	<-jsonrpc2.NewConn(
-		context.Background(),
-		jsonrpc2.NewBufferedStream(stdrwc{}, jsonrpc2.VSCodeObjectCodec{}),
-		handler, connOpt...).DisconnectNotify()
+	if clientProcessID > 0 {
+		watchClientProcess(clientProcessID, clientProcessPoll, func() {
+			log.Fatalf("efm-langserver: client process %d is gone", clientProcessID)
+		})
+	}
+
+	if wsPort != 0 {
+		addr := net.JoinHostPort("localhost", strconv.Itoa(wsPort))
+		log.Printf("efm-langserver: listening for WebSocket connections on %s", addr)
+		log.Fatal(http.ListenAndServe(addr, wsHandler(config, wsOrigins, connOpt)))
+	}
+
+	rwc, err := transport.dial()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := langserver.Serve(context.Background(), rwc, config, connOpt...); err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("efm-langserver: connections closed")
 }
 
+// wsHandler upgrades every request to a WebSocket and serves a language
+// server session on it. Browsers may only connect from the same origin or
+// from the hosts listed in origins.
+func wsHandler(config *langserver.Config, origins string, connOpt []jsonrpc2.ConnOpt) http.Handler {
+	allowed := map[string]bool{}
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[origin] = true
+		}
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			u, err := url.Parse(origin)
+			if err != nil {
+				return false
+			}
+			return allowed["*"] || allowed[u.Host] || allowed[u.Hostname()] || strings.EqualFold(u.Host, r.Host)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("efm-langserver: %v", err)
+			return
+		}
+		log.Printf("efm-langserver: connection from %s", r.RemoteAddr)
+		<-jsonrpc2.NewConn(
+			context.Background(),
+			jsonrpc2ws.NewObjectStream(ws),
+			langserver.NewHandler(config), connOpt...).DisconnectNotify()
+		log.Printf("efm-langserver: connection from %s closed", r.RemoteAddr)
+	})
+}
+
 type stdrwc struct{}
 
 func (stdrwc) Read(p []byte) (int, error) {
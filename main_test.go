@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	jsonrpc2ws "github.com/sourcegraph/jsonrpc2/websocket"
+
+	"github.com/tecfu/efm-langserver/langserver"
+)
+
+func TestWSHandler(t *testing.T) {
+	config := &langserver.Config{Logger: log.New(io.Discard, "", 0)}
+	server := httptest.NewServer(wsHandler(config, "", nil))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// Each connection is a session of its own, created at the same time.
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn := jsonrpc2.NewConn(context.Background(), jsonrpc2ws.NewObjectStream(ws), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+				return nil, nil
+			}))
+			defer conn.Close()
+
+			var result langserver.InitializeResult
+			if err := conn.Call(context.Background(), "initialize", langserver.InitializeParams{}, &result); err != nil {
+				errs <- err
+				return
+			}
+			if result.Capabilities.TextDocumentSync == nil {
+				errs <- io.ErrUnexpectedEOF
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if config.Languages != nil || config.Commands != nil || config.RootMarkers != nil {
+		t.Fatal("expected the shared config to be left as is")
+	}
+}
+
+func TestWSHandlerOrigin(t *testing.T) {
+	server := httptest.NewServer(wsHandler(&langserver.Config{Logger: log.New(io.Discard, "", 0)}, "example.com", nil))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	header := map[string][]string{"Origin": {"https://evil.test"}}
+	if _, _, err := websocket.DefaultDialer.Dial(url, header); err == nil {
+		t.Fatal("expected a connection from another origin to be refused")
+	}
+	header["Origin"] = []string{"https://example.com"}
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+}
+
+func TestTransportCheck(t *testing.T) {
+	tests := []struct {
+		transport transport
+		ok        bool
+	}{
+		{transport{}, true},
+		{transport{stdio: true}, true},
+		{transport{socketPort: 1234}, true},
+		{transport{pipeName: "efm"}, true},
+		{transport{stdio: true, socketPort: 1234}, false},
+		{transport{stdio: true, pipeName: "efm"}, false},
+		{transport{socketPort: 1234, wsPort: 1235}, false},
+		{transport{nodeIPC: true}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.transport.check(); (err == nil) != tt.ok {
+			t.Errorf("%+v: expected ok to be %v but got: %v", tt.transport, tt.ok, err)
+		}
+	}
+}
+
+func TestTransportPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a unix socket")
+	}
+	name := filepath.Join(t.TempDir(), "efm.sock")
+	l, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	rwc, err := transport{pipeName: name}.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &langserver.Config{Logger: log.New(io.Discard, "", 0)}
+	go langserver.Serve(context.Background(), rwc, config)
+
+	client, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+		return nil, nil
+	}))
+	defer conn.Close()
+	var result langserver.InitializeResult
+	if err := conn.Call(context.Background(), "initialize", langserver.InitializeParams{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Capabilities.TextDocumentSync == nil {
+		t.Fatal("expected the server to answer on the pipe")
+	}
+}
+
+func TestWatchClientProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	gone := make(chan struct{})
+	watchClientProcess(cmd.Process.Pid, 10*time.Millisecond, func() { close(gone) })
+
+	select {
+	case <-gone:
+		t.Fatal("expected the server to keep running while the client does")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to exit once the client is gone")
+	}
+}
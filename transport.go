@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// transport holds the flags choosing how to talk to the client.
+type transport struct {
+	stdio      bool
+	socketPort int
+	pipeName   string
+	wsPort     int
+	nodeIPC    bool
+}
+
+// check rejects flags asking for more than one transport, or for one which
+// isn't supported.
+func (t transport) check() error {
+	if t.nodeIPC {
+		return errors.New("--node-ipc is not supported, use --stdio, --socket or --pipe")
+	}
+	n := 0
+	for _, set := range []bool{t.stdio, t.socketPort != 0, t.pipeName != "", t.wsPort != 0} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("only one of --stdio, --socket, --pipe and -ws-port may be given")
+	}
+	return nil
+}
+
+// dial connects to the client on the socket or pipe it listens on, or
+// returns stdin and stdout.
+func (t transport) dial() (io.ReadWriteCloser, error) {
+	switch {
+	case t.socketPort != 0:
+		conn, err := net.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(t.socketPort)))
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("efm-langserver: connected to %s", conn.RemoteAddr())
+		return conn, nil
+	case t.pipeName != "":
+		conn, err := dialPipe(t.pipeName)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("efm-langserver: connected to %s", t.pipeName)
+		return conn, nil
+	default:
+		log.Println("efm-langserver: reading on stdin, writing on stdout")
+		return stdrwc{}, nil
+	}
+}
+
+// clientProcessPoll is how often the client process is checked.
+const clientProcessPoll = time.Second
+
+// watchClientProcess calls exit once the process pid is gone, so that the
+// server doesn't outlive a client which crashed without closing the
+// connection.
+func watchClientProcess(pid int, interval time.Duration, exit func()) {
+	go func() {
+		for processAlive(pid) {
+			time.Sleep(interval)
+		}
+		exit()
+	}()
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// dialPipe connects to the unix socket a client listens on.
+func dialPipe(name string) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", name)
+}
+
+// processAlive reports whether the process pid is still running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}